@@ -3,6 +3,8 @@ module github.com/CentaurusInfra/dashboard
 go 1.15
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.0
+	github.com/Masterminds/sprig/v3 v3.1.0
 	github.com/docker/distribution v2.7.1+incompatible
 	github.com/docker/spdystream v0.0.0-20181023171402-6480d4af844c // indirect
 	github.com/elazarl/goproxy v0.0.0-20190421051319-9d40249d3c2f // indirect
@@ -10,12 +12,16 @@ require (
 	github.com/emicklei/go-restful v2.9.6+incompatible
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/googleapis/gnostic v0.2.0 // indirect
+	github.com/gorilla/websocket v1.4.0
 	github.com/igm/sockjs-go v2.0.1+incompatible // indirect
-	github.com/imdario/mergo v0.3.6 // indirect
 	github.com/kubernetes/dashboard v1.10.1
 	github.com/lib/pq v1.10.4
 	github.com/prometheus/client_golang v1.0.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/spf13/pflag v1.0.5
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
 	golang.org/x/net v0.0.0-20191004110552-13f9640d40b9
 	golang.org/x/text v0.3.2
 	gopkg.in/igm/sockjs-go.v2 v2.0.0
@@ -27,6 +33,8 @@ require (
 	k8s.io/client-go v0.17.0
 	k8s.io/heapster v1.5.4
 	k8s.io/klog v1.0.0
+	sigs.k8s.io/kustomize/api v0.3.2
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace k8s.io/client-go => github.com/CentaurusInfra/arktos/staging/src/k8s.io/client-go v0.0.0-20200925053813-94992457ec50
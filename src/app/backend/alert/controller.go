@@ -0,0 +1,279 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultInterval is the interval in which all rules are evaluated.
+	DefaultInterval = time.Minute
+
+	// ResolvedRetention is how long resolved alerts are still listed.
+	ResolvedRetention = time.Hour
+)
+
+// Controller periodically evaluates the alert rules of all tenants on the partitions, keeps the
+// state of their alerts and sends notifications when alerts fire or resolve.
+type Controller struct {
+	store              Store
+	notifier           Notifier
+	tenantPartitions   []Partition
+	resourcePartitions []Partition
+	interval           time.Duration
+	now                func() time.Time
+
+	mu       sync.Mutex
+	alerts   map[string]Alert
+	resolved []Alert
+}
+
+// NewController creates a controller that evaluates the rules every DefaultInterval. Tenant
+// resources are evaluated on the tenant partitions and nodes on the resource partitions.
+func NewController(store Store, notifier Notifier, tenantPartitions, resourcePartitions []Partition) *Controller {
+	return &Controller{
+		store:              store,
+		notifier:           notifier,
+		tenantPartitions:   tenantPartitions,
+		resourcePartitions: resourcePartitions,
+		interval:           DefaultInterval,
+		now:                time.Now,
+		alerts:             make(map[string]Alert),
+	}
+}
+
+// Start starts evaluating in the background.
+func (self *Controller) Start() {
+	go wait.Forever(self.evaluate, self.interval)
+}
+
+// Alerts returns the firing and recently resolved alerts of the tenant, latest first.
+func (self *Controller) Alerts(tenant string) AlertList {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	result := AlertList{Alerts: make([]Alert, 0)}
+	for _, alert := range self.alerts {
+		if alert.Tenant == tenant {
+			result.Alerts = append(result.Alerts, alert)
+		}
+	}
+	for _, alert := range self.resolved {
+		if alert.Tenant == tenant {
+			result.Alerts = append(result.Alerts, alert)
+		}
+	}
+
+	sort.SliceStable(result.Alerts, func(i, j int) bool {
+		return result.Alerts[i].StartsAt.After(result.Alerts[j].StartsAt)
+	})
+	return result
+}
+
+// Rules returns the rules of the tenant.
+func (self *Controller) Rules(tenant string) ([]Rule, error) {
+	return self.store.ListRules(tenant)
+}
+
+// SaveRule validates and stores the rule. It is evaluated from the next evaluation on.
+func (self *Controller) SaveRule(rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	for _, sink := range rule.Sinks {
+		if err := self.notifier.CheckSink(sink); err != nil {
+			return err
+		}
+	}
+	return self.store.SaveRule(rule)
+}
+
+// DeleteRule deletes the rule. Its alerts are resolved by the next evaluation.
+func (self *Controller) DeleteRule(tenant, name string) error {
+	return self.store.DeleteRule(tenant, name)
+}
+
+// Silences returns the silences of the tenant.
+func (self *Controller) Silences(tenant string) ([]Silence, error) {
+	return self.store.ListSilences(tenant)
+}
+
+// AddSilence validates and stores the silence with a new ID. Silences without a start start now.
+func (self *Controller) AddSilence(silence Silence) (*Silence, error) {
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = self.now()
+	}
+	if err := silence.Validate(); err != nil {
+		return nil, err
+	}
+
+	id, err := generateSilenceID()
+	if err != nil {
+		return nil, err
+	}
+	silence.ID = id
+
+	if err := self.store.AddSilence(silence); err != nil {
+		return nil, err
+	}
+	return &silence, nil
+}
+
+// DeleteSilence deletes the silence.
+func (self *Controller) DeleteSilence(tenant, id string) error {
+	return self.store.DeleteSilence(tenant, id)
+}
+
+// evaluate evaluates all rules, updates the alert state and sends the notifications of alerts that
+// started firing or were resolved. Alerts of rules that could not be evaluated on a partition keep
+// their state until the next evaluation.
+func (self *Controller) evaluate() {
+	rules, err := self.store.ListRules("")
+	if err != nil {
+		log.Printf("Could not list alert rules: %s", err.Error())
+		return
+	}
+	// Without the silences every silenced alert would be notified, so the evaluation is skipped.
+	silences, err := self.store.ListSilences("")
+	if err != nil {
+		log.Printf("Could not list alert silences, skipping evaluation: %s", err.Error())
+		return
+	}
+
+	now := self.now()
+	sinks := make(map[string][]Sink)
+	firing := make(map[string]Alert)
+	failed := make(map[string]bool)
+	for _, rule := range rules {
+		sinks[ruleKey(rule.Tenant, rule.Name)] = rule.Sinks
+		for _, partition := range self.getPartitions(rule) {
+			alerts, err := evaluate(rule, partition)
+			if err != nil {
+				log.Printf("Could not evaluate alert rule %s of %s tenant on %s partition: %s", rule.Name,
+					rule.Tenant, partition.Name, err.Error())
+				failed[ruleKey(rule.Tenant, rule.Name)+"/"+partition.Name] = true
+				continue
+			}
+			for _, alert := range alerts {
+				firing[alert.key()] = alert
+			}
+		}
+	}
+
+	self.mu.Lock()
+	notifications := make([]Alert, 0)
+	for key, alert := range firing {
+		if previous, exists := self.alerts[key]; exists {
+			alert.StartsAt = previous.StartsAt
+			alert.notified = previous.notified
+		} else {
+			alert.StartsAt = now
+		}
+
+		// Alerts that were silenced when they started are notified once the silence is over.
+		alert.Silenced = isSilenced(silences, alert, now)
+		if !alert.notified && !alert.Silenced {
+			alert.notified = true
+			notifications = append(notifications, alert)
+		}
+		firing[key] = alert
+	}
+
+	for key, previous := range self.alerts {
+		if _, exists := firing[key]; exists {
+			continue
+		}
+		if failed[ruleKey(previous.Tenant, previous.Rule)+"/"+previous.Partition] {
+			firing[key] = previous
+			continue
+		}
+
+		// Receivers of the firing notification always get the resolved one.
+		previous.State = Resolved
+		previous.EndsAt = &now
+		if previous.notified {
+			notifications = append(notifications, previous)
+		}
+		self.resolved = append(self.resolved, previous)
+	}
+
+	retained := make([]Alert, 0, len(self.resolved))
+	for _, alert := range self.resolved {
+		if now.Sub(*alert.EndsAt) < ResolvedRetention {
+			retained = append(retained, alert)
+		}
+	}
+	self.resolved = retained
+	self.alerts = firing
+	self.mu.Unlock()
+
+	for _, alert := range notifications {
+		for _, sink := range sinks[ruleKey(alert.Tenant, alert.Rule)] {
+			if err := self.notifier.Notify(sink, alert); err != nil {
+				log.Printf("Could not send %s notification of alert rule %s of %s tenant: %s", sink.Type,
+					alert.Rule, alert.Tenant, err.Error())
+			}
+		}
+	}
+}
+
+// getPartitions returns the partitions the rule is evaluated on.
+func (self *Controller) getPartitions(rule Rule) []Partition {
+	var candidates []Partition
+	switch rule.Condition {
+	case NodeNotReady:
+		candidates = self.resourcePartitions
+	case PartitionUnreachable:
+		candidates = append(append([]Partition{}, self.tenantPartitions...), self.resourcePartitions...)
+	default:
+		candidates = self.tenantPartitions
+	}
+
+	result := make([]Partition, 0)
+	seen := make(map[string]bool)
+	for _, partition := range candidates {
+		if seen[partition.Name] || (len(rule.Partition) > 0 && rule.Partition != partition.Name) {
+			continue
+		}
+		seen[partition.Name] = true
+		result = append(result, partition)
+	}
+	return result
+}
+
+func isSilenced(silences []Silence, alert Alert, now time.Time) bool {
+	for _, silence := range silences {
+		if silence.Matches(alert, now) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateSilenceID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,363 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// memoryStore implements Store in memory.
+type memoryStore struct {
+	rules    []Rule
+	silences []Silence
+	// silencesErr is returned by ListSilences if set.
+	silencesErr error
+}
+
+func (self *memoryStore) ListRules(tenant string) ([]Rule, error) {
+	result := make([]Rule, 0)
+	for _, rule := range self.rules {
+		if tenant == "" || rule.Tenant == tenant {
+			result = append(result, rule)
+		}
+	}
+	return result, nil
+}
+
+func (self *memoryStore) SaveRule(rule Rule) error {
+	self.DeleteRule(rule.Tenant, rule.Name)
+	self.rules = append(self.rules, rule)
+	return nil
+}
+
+func (self *memoryStore) DeleteRule(tenant, name string) error {
+	for i, rule := range self.rules {
+		if rule.Tenant == tenant && rule.Name == name {
+			self.rules = append(self.rules[:i], self.rules[i+1:]...)
+			return nil
+		}
+	}
+	return errors.NewNotFound("rule not found")
+}
+
+func (self *memoryStore) ListSilences(tenant string) ([]Silence, error) {
+	if self.silencesErr != nil {
+		return nil, self.silencesErr
+	}
+	result := make([]Silence, 0)
+	for _, silence := range self.silences {
+		if tenant == "" || silence.Tenant == tenant {
+			result = append(result, silence)
+		}
+	}
+	return result, nil
+}
+
+func (self *memoryStore) AddSilence(silence Silence) error {
+	self.silences = append(self.silences, silence)
+	return nil
+}
+
+func (self *memoryStore) DeleteSilence(tenant, id string) error {
+	for i, silence := range self.silences {
+		if silence.Tenant == tenant && silence.ID == id {
+			self.silences = append(self.silences[:i], self.silences[i+1:]...)
+			return nil
+		}
+	}
+	return errors.NewNotFound("silence not found")
+}
+
+// recordingNotifier records the summaries of all notifications.
+type recordingNotifier struct {
+	notifications []string
+}
+
+func (self *recordingNotifier) Notify(sink Sink, alert Alert) error {
+	self.notifications = append(self.notifications, string(sink.Type)+" "+formatSummary(alert))
+	return nil
+}
+
+// CheckSink forbids all sinks on forbidden.example.com.
+func (self *recordingNotifier) CheckSink(sink Sink) error {
+	if strings.Contains(sink.URL, "forbidden.example.com") {
+		return errors.NewForbidden("forbidden sink")
+	}
+	return nil
+}
+
+func newPod(tenant, name, waitingReason string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: "default", Name: name}}
+	if len(waitingReason) > 0 {
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:         "app",
+			RestartCount: 5,
+			State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: waitingReason}},
+		}}
+	}
+	return pod
+}
+
+func newDeployment(tenant, name string, replicas, available int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: "default", Name: name},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{AvailableReplicas: available},
+	}
+}
+
+func newNode(name string, ready v1.ConditionStatus) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metaV1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}}},
+	}
+}
+
+func newQuota(tenant, name, used, hard string) *v1.ResourceQuota {
+	return &v1.ResourceQuota{
+		ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: "default", Name: name},
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{v1.ResourceCPU: resource.MustParse(hard)},
+			Used: v1.ResourceList{v1.ResourceCPU: resource.MustParse(used)},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	partition := Partition{Name: "tp1", Client: testutil.NewClientset(
+		newPod("t1", "crashing", crashLoopBackOff),
+		newPod("t1", "waiting", "ContainerCreating"),
+		newPod("t2", "crashing", crashLoopBackOff),
+		newDeployment("t1", "available", 2, 2),
+		newDeployment("t1", "unavailable", 3, 1),
+		newNode("ready", v1.ConditionTrue),
+		newNode("notready", v1.ConditionFalse),
+		newQuota("t1", "low", "100m", "1"),
+		newQuota("t1", "high", "900m", "1"),
+	)}
+
+	cases := []struct {
+		rule     Rule
+		expected []string
+	}{
+		{
+			Rule{Name: "r", Tenant: "t1", Condition: PodCrashLooping},
+			[]string{"pod/t1/default/crashing"},
+		},
+		{
+			Rule{Name: "r", Tenant: SystemTenant, Condition: PodCrashLooping},
+			[]string{"pod/t1/default/crashing", "pod/t2/default/crashing"},
+		},
+		{
+			Rule{Name: "r", Tenant: "t1", Condition: DeploymentUnavailable},
+			[]string{"deployment/t1/default/unavailable"},
+		},
+		{
+			Rule{Name: "r", Tenant: SystemTenant, Condition: NodeNotReady},
+			[]string{"node///notready"},
+		},
+		{
+			Rule{Name: "r", Tenant: "t1", Condition: QuotaAboveThreshold, Threshold: 80},
+			[]string{"resourcequota/t1/default/high"},
+		},
+		{
+			Rule{Name: "r", Tenant: SystemTenant, Condition: PartitionUnreachable},
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		alerts, err := evaluate(c.rule, partition)
+		if err != nil {
+			t.Fatalf("evaluate(%v) returned unexpected error: %v", c.rule, err)
+		}
+
+		actual := make([]string, 0)
+		for _, alert := range alerts {
+			actual = append(actual, alert.Resource.String())
+		}
+		sort.Strings(actual)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("evaluate(%v) == %v, expected %v", c.rule, actual, c.expected)
+		}
+	}
+}
+
+func TestControllerEvaluate(t *testing.T) {
+	client := testutil.NewClientset(newPod("t1", "crashing", crashLoopBackOff))
+	store := &memoryStore{rules: []Rule{{
+		Name:      "crashes",
+		Tenant:    "t1",
+		Condition: PodCrashLooping,
+		Sinks:     []Sink{{Type: WebhookSink, URL: "http://example.com"}},
+	}}}
+	notifier := &recordingNotifier{}
+	controller := NewController(store, notifier, []Partition{{Name: "tp1", Client: client}}, nil)
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	controller.now = func() time.Time { return now }
+
+	firing := "webhook [FIRING] crashes: Container app of pod default/crashing is crash looping after 5 restarts"
+	resolved := "webhook [RESOLVED] crashes: Container app of pod default/crashing is crash looping after 5 restarts"
+
+	controller.evaluate()
+	now = now.Add(time.Minute)
+	controller.evaluate()
+	if expected := []string{firing}; !reflect.DeepEqual(notifier.notifications, expected) {
+		t.Fatalf("Notifications == %v, expected %v", notifier.notifications, expected)
+	}
+
+	alerts := controller.Alerts("t1").Alerts
+	if len(alerts) != 1 || alerts[0].State != Firing || !alerts[0].StartsAt.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("Alerts() == %v, expected one firing alert started at first evaluation", alerts)
+	}
+	if others := controller.Alerts("t2").Alerts; len(others) != 0 {
+		t.Errorf("Alerts() of other tenant == %v, expected none", others)
+	}
+
+	if err := client.Tracker().UpdateWithMultiTenancy(podsResource, newPod("t1", "crashing", ""), "default", "t1"); err != nil {
+		t.Fatalf("Could not update pod: %v", err)
+	}
+	now = now.Add(time.Minute)
+	controller.evaluate()
+	if expected := []string{firing, resolved}; !reflect.DeepEqual(notifier.notifications, expected) {
+		t.Fatalf("Notifications == %v, expected %v", notifier.notifications, expected)
+	}
+
+	alerts = controller.Alerts("t1").Alerts
+	if len(alerts) != 1 || alerts[0].State != Resolved || !alerts[0].EndsAt.Equal(now) {
+		t.Fatalf("Alerts() == %v, expected one alert resolved at last evaluation", alerts)
+	}
+
+	now = now.Add(ResolvedRetention)
+	controller.evaluate()
+	if alerts := controller.Alerts("t1").Alerts; len(alerts) != 0 {
+		t.Errorf("Alerts() == %v, expected resolved alert to expire", alerts)
+	}
+}
+
+func TestControllerSilence(t *testing.T) {
+	client := testutil.NewClientset(newNode("notready", v1.ConditionFalse))
+	store := &memoryStore{rules: []Rule{{
+		Name:      "nodes",
+		Tenant:    SystemTenant,
+		Condition: NodeNotReady,
+		Sinks:     []Sink{{Type: SlackSink, URL: "http://example.com"}},
+	}}}
+	notifier := &recordingNotifier{}
+	controller := NewController(store, notifier, nil, []Partition{{Name: "rp1", Client: client}})
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	controller.now = func() time.Time { return now }
+
+	silence, err := controller.AddSilence(Silence{Tenant: SystemTenant, Rule: "nodes", EndsAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("AddSilence() returned unexpected error: %v", err)
+	}
+	if len(silence.ID) == 0 || !silence.StartsAt.Equal(now) {
+		t.Errorf("AddSilence() == %v, expected an ID and start at now", silence)
+	}
+
+	controller.evaluate()
+	if len(notifier.notifications) != 0 {
+		t.Fatalf("Notifications == %v, expected none while silenced", notifier.notifications)
+	}
+	if alerts := controller.Alerts(SystemTenant).Alerts; len(alerts) != 1 || !alerts[0].Silenced {
+		t.Fatalf("Alerts() == %v, expected one silenced alert", alerts)
+	}
+
+	now = now.Add(time.Hour)
+	controller.evaluate()
+	expected := []string{"slack [FIRING] nodes: Node notready is not ready, its ready condition is False"}
+	if !reflect.DeepEqual(notifier.notifications, expected) {
+		t.Errorf("Notifications == %v, expected %v after silence ended", notifier.notifications, expected)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	cases := []struct {
+		rule  Rule
+		valid bool
+	}{
+		{Rule{Name: "r", Tenant: "t1", Condition: PodCrashLooping}, true},
+		{Rule{Name: "", Tenant: "t1", Condition: PodCrashLooping}, false},
+		{Rule{Name: "r", Tenant: "t1", Condition: "Unknown"}, false},
+		{Rule{Name: "r", Tenant: "t1", Condition: NodeNotReady}, false},
+		{Rule{Name: "r", Tenant: SystemTenant, Condition: NodeNotReady}, true},
+		{Rule{Name: "r", Tenant: "t1", Condition: QuotaAboveThreshold}, false},
+		{Rule{Name: "r", Tenant: "t1", Condition: QuotaAboveThreshold, Threshold: 90}, true},
+		{Rule{Name: "r", Tenant: "t1", Condition: PodCrashLooping, Sinks: []Sink{{Type: WebhookSink, URL: "ftp://x"}}}, false},
+		{Rule{Name: "r", Tenant: "t1", Condition: PodCrashLooping, Sinks: []Sink{{Type: EmailSink}}}, false},
+		{Rule{Name: "r", Tenant: "t1", Condition: PodCrashLooping, Sinks: []Sink{{Type: EmailSink, To: []string{"a@b.c"}}}}, true},
+	}
+
+	for _, c := range cases {
+		if err := c.rule.Validate(); (err == nil) != c.valid {
+			t.Errorf("Validate(%v) == %v, expected valid: %v", c.rule, err, c.valid)
+		}
+	}
+}
+
+var podsResource = v1.SchemeGroupVersion.WithResource("pods")
+
+func TestControllerSaveRuleWithForbiddenSink(t *testing.T) {
+	store := &memoryStore{}
+	controller := NewController(store, &recordingNotifier{}, nil, nil)
+	rule := Rule{
+		Name:      "crashes",
+		Tenant:    "t1",
+		Condition: PodCrashLooping,
+		Sinks:     []Sink{{Type: WebhookSink, URL: "http://forbidden.example.com"}},
+	}
+	if err := controller.SaveRule(rule); err == nil || len(store.rules) != 0 {
+		t.Errorf("SaveRule() == %v, expected forbidden sink to be rejected", err)
+	}
+}
+
+func TestControllerSkipsEvaluationWithoutSilences(t *testing.T) {
+	client := testutil.NewClientset(newPod("t1", "crashing", crashLoopBackOff))
+	store := &memoryStore{
+		rules: []Rule{{
+			Name:      "crashes",
+			Tenant:    "t1",
+			Condition: PodCrashLooping,
+			Sinks:     []Sink{{Type: WebhookSink, URL: "http://example.com"}},
+		}},
+		silencesErr: errors.NewInternal("database is down"),
+	}
+	notifier := &recordingNotifier{}
+	controller := NewController(store, notifier, []Partition{{Name: "tp1", Client: client}}, nil)
+
+	controller.evaluate()
+	if len(notifier.notifications) != 0 {
+		t.Fatalf("Notifications == %v, expected none without silences", notifier.notifications)
+	}
+
+	store.silencesErr = nil
+	controller.evaluate()
+	if len(notifier.notifications) != 1 {
+		t.Errorf("Notifications == %v, expected the firing notification once silences are listed",
+			notifier.notifications)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/resourcequota"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Partition is a tenant or resource partition whose resources are evaluated.
+type Partition struct {
+	Name   string
+	Client kubernetes.Interface
+}
+
+// crashLoopBackOff is the waiting reason of containers that are restarted after repeated failures.
+const crashLoopBackOff = "CrashLoopBackOff"
+
+// evaluate returns an alert for every resource of the partition that matches the condition of the
+// rule. Rules of the system tenant cover the resources of all tenants.
+func evaluate(rule Rule, partition Partition) ([]Alert, error) {
+	tenant := rule.Tenant
+	if tenant == SystemTenant && !partitionConditions[rule.Condition] {
+		tenant = metaV1.TenantAllExplicit
+	}
+
+	var resources []Resource
+	var messages []string
+	var err error
+	switch rule.Condition {
+	case PodCrashLooping:
+		resources, messages, err = getCrashLoopingPods(partition.Client, tenant)
+	case DeploymentUnavailable:
+		resources, messages, err = getUnavailableDeployments(partition.Client, tenant)
+	case NodeNotReady:
+		resources, messages, err = getNotReadyNodes(partition.Client)
+	case QuotaAboveThreshold:
+		resources, messages, err = getQuotasAboveThreshold(partition.Client, tenant, rule.Threshold)
+	case PartitionUnreachable:
+		// An unreachable partition is the alert itself and not an evaluation error.
+		if _, err := partition.Client.Discovery().ServerVersion(); err != nil {
+			resources = []Resource{{Kind: "partition", Name: partition.Name}}
+			messages = []string{fmt.Sprintf("Partition %s is unreachable: %s", partition.Name, err.Error())}
+		}
+	default:
+		return nil, fmt.Errorf("unknown condition %s", rule.Condition)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]Alert, 0, len(resources))
+	for i, r := range resources {
+		alerts = append(alerts, Alert{
+			Rule:      rule.Name,
+			Tenant:    rule.Tenant,
+			Partition: partition.Name,
+			Condition: rule.Condition,
+			Resource:  r,
+			Message:   messages[i],
+			State:     Firing,
+		})
+	}
+	return alerts, nil
+}
+
+func getCrashLoopingPods(client kubernetes.Interface, tenant string) ([]Resource, []string, error) {
+	pods, err := client.CoreV1().PodsWithMultiTenancy(v1.NamespaceAll, tenant).List(api.ListEverything)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resources []Resource
+	var messages []string
+	for _, pod := range pods.Items {
+		statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...),
+			pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == crashLoopBackOff {
+				resources = append(resources, toResource(api.ResourceKindPod, pod.ObjectMeta))
+				messages = append(messages, fmt.Sprintf("Container %s of pod %s/%s is crash looping after %d restarts",
+					status.Name, pod.Namespace, pod.Name, status.RestartCount))
+				break
+			}
+		}
+	}
+	return resources, messages, nil
+}
+
+func getUnavailableDeployments(client kubernetes.Interface, tenant string) ([]Resource, []string, error) {
+	deployments, err := client.AppsV1().DeploymentsWithMultiTenancy(v1.NamespaceAll, tenant).List(api.ListEverything)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resources []Resource
+	var messages []string
+	for _, deployment := range deployments.Items {
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		if deployment.Status.AvailableReplicas < desired {
+			resources = append(resources, toResource(api.ResourceKindDeployment, deployment.ObjectMeta))
+			messages = append(messages, fmt.Sprintf("Deployment %s/%s has %d of %d replicas available",
+				deployment.Namespace, deployment.Name, deployment.Status.AvailableReplicas, desired))
+		}
+	}
+	return resources, messages, nil
+}
+
+func getNotReadyNodes(client kubernetes.Interface) ([]Resource, []string, error) {
+	nodes, err := client.CoreV1().Nodes().List(api.ListEverything)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resources []Resource
+	var messages []string
+	for _, node := range nodes.Items {
+		status := v1.ConditionUnknown
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeReady {
+				status = condition.Status
+				break
+			}
+		}
+		if status != v1.ConditionTrue {
+			resources = append(resources, Resource{Kind: api.ResourceKindNode, Name: node.Name})
+			messages = append(messages, fmt.Sprintf("Node %s is not ready, its ready condition is %s", node.Name, status))
+		}
+	}
+	return resources, messages, nil
+}
+
+// getQuotasAboveThreshold returns the resource quotas of which any resource is used at or above the
+// threshold percentage.
+func getQuotasAboveThreshold(client kubernetes.Interface, tenant string, threshold int64) ([]Resource, []string, error) {
+	quotas, err := client.CoreV1().ResourceQuotasWithMultiTenancy(v1.NamespaceAll, tenant).List(api.ListEverything)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resources []Resource
+	var messages []string
+	for i := range quotas.Items {
+		detail := resourcequota.ToResourceQuotaDetail(&quotas.Items[i])
+
+		// Resources are checked in a stable order, so that the message does not change between runs.
+		names := make([]string, 0, len(detail.StatusList))
+		for name := range detail.StatusList {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			status := detail.StatusList[v1.ResourceName(name)]
+			percentage, ok := getUsedPercentage(status)
+			if ok && percentage >= float64(threshold) {
+				resources = append(resources, toResource(api.ResourceKindResourceQuota, quotas.Items[i].ObjectMeta))
+				messages = append(messages, fmt.Sprintf("Resource quota %s/%s uses %.0f%% of %s (%s of %s)",
+					detail.ObjectMeta.Namespace, detail.ObjectMeta.Name, percentage, name, status.Used, status.Hard))
+				break
+			}
+		}
+	}
+	return resources, messages, nil
+}
+
+func getUsedPercentage(status resourcequota.ResourceStatus) (float64, bool) {
+	hard, err := resource.ParseQuantity(status.Hard)
+	if err != nil || hard.IsZero() {
+		return 0, false
+	}
+	used, err := resource.ParseQuantity(status.Used)
+	if err != nil {
+		return 0, false
+	}
+	return float64(used.MilliValue()) / float64(hard.MilliValue()) * 100, true
+}
+
+func toResource(kind api.ResourceKind, meta metaV1.ObjectMeta) Resource {
+	return Resource{Kind: string(kind), Tenant: meta.Tenant, Namespace: meta.Namespace, Name: meta.Name}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// requestTimeout limits the duration of a single webhook request.
+const requestTimeout = 10 * time.Second
+
+// Notifier sends alert notifications to sinks.
+type Notifier interface {
+	// Notify sends the firing or resolved alert to the sink.
+	Notify(sink Sink, alert Alert) error
+	// CheckSink returns a forbidden error if notifications must not be sent to the sink.
+	CheckSink(sink Sink) error
+}
+
+// Implements Notifier interface for webhook, slack and email sinks.
+type notifier struct {
+	client    *http.Client
+	smtpHost  string
+	smtpFrom  string
+	sinkHosts []string
+	sendMail  func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewNotifier returns a notifier that sends emails through the given SMTP server, in the
+// host:port format, from the given address. Email sinks fail if no SMTP server is configured.
+// Webhook and slack sinks are only notified if the host of their URL is one of the sink hosts,
+// which can also be wildcards like '*.example.com', so that tenants cannot make the dashboard post
+// to internal services.
+func NewNotifier(smtpHost, smtpFrom string, sinkHosts []string) Notifier {
+	return &notifier{
+		// Redirects are not followed, as they could lead to any host.
+		client: &http.Client{
+			Timeout: requestTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		smtpHost:  smtpHost,
+		smtpFrom:  smtpFrom,
+		sinkHosts: sinkHosts,
+		sendMail:  smtp.SendMail,
+	}
+}
+
+// CheckSink implements Notifier interface. See Notifier for more information.
+func (self *notifier) CheckSink(sink Sink) error {
+	if sink.Type != WebhookSink && sink.Type != SlackSink {
+		return nil
+	}
+
+	parsed, err := url.Parse(sink.URL)
+	if err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("invalid %s sink url %q", sink.Type, sink.URL))
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range self.sinkHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return errors.NewForbidden(fmt.Sprintf("notifications must not be sent to host %s", host))
+}
+
+// Notify implements Notifier interface. See Notifier for more information.
+func (self *notifier) Notify(sink Sink, alert Alert) error {
+	// Rules may have been saved before the sink hosts were changed.
+	if err := self.CheckSink(sink); err != nil {
+		return err
+	}
+
+	switch sink.Type {
+	case WebhookSink:
+		return self.post(sink.URL, alert)
+	case SlackSink:
+		return self.post(sink.URL, map[string]string{"text": formatSummary(alert)})
+	case EmailSink:
+		return self.email(sink.To, alert)
+	default:
+		return fmt.Errorf("unknown sink type %s", sink.Type)
+	}
+}
+
+func (self *notifier) post(url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	response, err := self.client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("sink %s responded with %s", url, response.Status)
+	}
+	return nil
+}
+
+func (self *notifier) email(to []string, alert Alert) error {
+	if len(self.smtpHost) == 0 {
+		return fmt.Errorf("no SMTP server configured for email notifications")
+	}
+
+	message := "From: " + self.smtpFrom + "\r\n" +
+		"To: " + strings.Join(to, ", ") + "\r\n" +
+		"Subject: " + formatSummary(alert) + "\r\n" +
+		"\r\n" +
+		fmt.Sprintf("Rule: %s\r\nTenant: %s\r\nPartition: %s\r\nCondition: %s\r\nStarted: %s\r\n\r\n%s\r\n",
+			alert.Rule, alert.Tenant, alert.Partition, alert.Condition, alert.StartsAt.Format(time.RFC3339),
+			alert.Message)
+	return self.sendMail(self.smtpHost, nil, self.smtpFrom, to, []byte(message))
+}
+
+// formatSummary returns a single line summary of the alert, e.g. for slack messages and subjects.
+func formatSummary(alert Alert) string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(string(alert.State)), alert.Rule, alert.Message)
+}
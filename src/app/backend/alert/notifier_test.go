@@ -0,0 +1,112 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	alert := Alert{
+		Rule:      "crashes",
+		Tenant:    "t1",
+		Partition: "tp1",
+		Condition: PodCrashLooping,
+		Resource:  Resource{Kind: "pod", Tenant: "t1", Namespace: "default", Name: "p1"},
+		Message:   "Pod is crash looping",
+		State:     Firing,
+		StartsAt:  time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	var mail string
+	n := NewNotifier("smtp.example.com:25", "dashboard@example.com", []string{"127.0.0.1"}).(*notifier)
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		mail = addr + " " + from + " " + strings.Join(to, ",") + "\n" + string(msg)
+		return nil
+	}
+
+	sinks := []Sink{
+		{Type: WebhookSink, URL: server.URL},
+		{Type: SlackSink, URL: server.URL},
+		{Type: EmailSink, To: []string{"ops@example.com"}},
+	}
+	for _, sink := range sinks {
+		if err := n.Notify(sink, alert); err != nil {
+			t.Fatalf("Notify(%v) returned unexpected error: %v", sink, err)
+		}
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Received %d requests, expected 2", len(received))
+	}
+	webhook := Alert{}
+	if err := json.Unmarshal([]byte(received[0]), &webhook); err != nil || webhook.Resource != alert.Resource ||
+		webhook.State != Firing {
+		t.Errorf("Webhook received %s, expected the alert", received[0])
+	}
+	if expected := `{"text":"[FIRING] crashes: Pod is crash looping"}`; received[1] != expected {
+		t.Errorf("Slack received %s, expected %s", received[1], expected)
+	}
+	if !strings.HasPrefix(mail, "smtp.example.com:25 dashboard@example.com ops@example.com\n") ||
+		!strings.Contains(mail, "Subject: [FIRING] crashes: Pod is crash looping\r\n") {
+		t.Errorf("Sent mail %q, expected alert mail", mail)
+	}
+
+	if err := NewNotifier("", "", nil).Notify(Sink{Type: EmailSink, To: []string{"ops@example.com"}}, alert); err == nil {
+		t.Error("Notify() to email sink without SMTP server should fail")
+	}
+}
+
+func TestCheckSink(t *testing.T) {
+	n := NewNotifier("", "", []string{"hooks.slack.com", "*.example.com"})
+	cases := []struct {
+		sink    Sink
+		allowed bool
+	}{
+		{Sink{Type: SlackSink, URL: "https://hooks.slack.com/services/x"}, true},
+		{Sink{Type: WebhookSink, URL: "https://alerts.example.com:8443/hook"}, true},
+		{Sink{Type: WebhookSink, URL: "https://ALERTS.Example.com/hook"}, true},
+		{Sink{Type: WebhookSink, URL: "https://example.com.evil.org/hook"}, false},
+		{Sink{Type: WebhookSink, URL: "http://169.254.169.254/latest/meta-data"}, false},
+		{Sink{Type: SlackSink, URL: "http://localhost:9090/api/v1/csrftoken/login"}, false},
+		{Sink{Type: EmailSink, To: []string{"ops@internal"}}, true},
+	}
+
+	for _, c := range cases {
+		if err := n.CheckSink(c.sink); (err == nil) != c.allowed {
+			t.Errorf("CheckSink(%v) == %v, expected allowed=%t", c.sink, err, c.allowed)
+		}
+		if c.allowed {
+			continue
+		}
+		if err := n.Notify(c.sink, Alert{}); err == nil {
+			t.Errorf("Notify(%v) should fail for forbidden host", c.sink)
+		}
+	}
+}
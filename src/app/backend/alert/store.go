@@ -0,0 +1,191 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
+)
+
+// Store persists alert rules and silences.
+type Store interface {
+	// ListRules returns the rules of the tenant, or of all tenants if the tenant is empty.
+	ListRules(tenant string) ([]Rule, error)
+	// SaveRule creates the rule or replaces the rule of the same tenant and name.
+	SaveRule(rule Rule) error
+	// DeleteRule deletes the rule and returns a not found error if it does not exist.
+	DeleteRule(tenant, name string) error
+	// ListSilences returns the silences of the tenant, or of all tenants if the tenant is empty.
+	ListSilences(tenant string) ([]Silence, error)
+	// AddSilence stores a new silence.
+	AddSilence(silence Silence) error
+	// DeleteSilence deletes the silence and returns a not found error if it does not exist.
+	DeleteSilence(tenant, id string) error
+}
+
+const createRulesTableStatement = `CREATE TABLE IF NOT EXISTS alertrules (
+	tenant TEXT NOT NULL,
+	name TEXT NOT NULL,
+	partition TEXT NOT NULL,
+	condition TEXT NOT NULL,
+	threshold BIGINT NOT NULL,
+	sinks TEXT NOT NULL,
+	PRIMARY KEY (tenant, name));`
+
+const createSilencesTableStatement = `CREATE TABLE IF NOT EXISTS alertsilences (
+	id TEXT PRIMARY KEY,
+	tenant TEXT NOT NULL,
+	rule TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	startsat TIMESTAMP NOT NULL,
+	endsat TIMESTAMP NOT NULL,
+	comment TEXT NOT NULL);`
+
+const upsertRuleStatement = `INSERT INTO alertrules (tenant, name, partition, condition, threshold, sinks)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (tenant, name) DO UPDATE SET
+	partition = EXCLUDED.partition,
+	condition = EXCLUDED.condition,
+	threshold = EXCLUDED.threshold,
+	sinks = EXCLUDED.sinks;`
+
+// Empty tenant parameters match all tenants.
+const selectRulesStatement = `SELECT tenant, name, partition, condition, threshold, sinks FROM alertrules
+	WHERE $1 = '' OR tenant = $1 ORDER BY tenant, name;`
+
+const deleteRuleStatement = `DELETE FROM alertrules WHERE tenant = $1 AND name = $2;`
+
+const insertSilenceStatement = `INSERT INTO alertsilences (id, tenant, rule, resource, startsat, endsat, comment)
+	VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
+const selectSilencesStatement = `SELECT id, tenant, rule, resource, startsat, endsat, comment FROM alertsilences
+	WHERE $1 = '' OR tenant = $1 ORDER BY startsat;`
+
+const deleteSilenceStatement = `DELETE FROM alertsilences WHERE tenant = $1 AND id = $2;`
+
+// Implements Store interface backed by postgres.
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the alert tables if they do not exist yet and returns a store backed by
+// them.
+func NewPostgresStore(db *sql.DB) (Store, error) {
+	for _, statement := range []string{createRulesTableStatement, createSilencesTableStatement} {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, err
+		}
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// ListRules implements Store interface. See Store for more information.
+func (self *postgresStore) ListRules(tenant string) ([]Rule, error) {
+	defer instrumentation.ObserveDBQuery("list_alert_rules", time.Now())
+	rows, err := self.db.Query(selectRulesStatement, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Rule, 0)
+	for rows.Next() {
+		var r Rule
+		var sinks string
+		if err := rows.Scan(&r.Tenant, &r.Name, &r.Partition, &r.Condition, &r.Threshold, &sinks); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(sinks), &r.Sinks); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// SaveRule implements Store interface. See Store for more information.
+func (self *postgresStore) SaveRule(rule Rule) error {
+	defer instrumentation.ObserveDBQuery("save_alert_rule", time.Now())
+	sinks, err := json.Marshal(rule.Sinks)
+	if err != nil {
+		return err
+	}
+
+	_, err = self.db.Exec(upsertRuleStatement, rule.Tenant, rule.Name, rule.Partition, rule.Condition,
+		rule.Threshold, string(sinks))
+	return err
+}
+
+// DeleteRule implements Store interface. See Store for more information.
+func (self *postgresStore) DeleteRule(tenant, name string) error {
+	return self.delete(deleteRuleStatement, tenant, name, "rule")
+}
+
+// ListSilences implements Store interface. See Store for more information.
+func (self *postgresStore) ListSilences(tenant string) ([]Silence, error) {
+	defer instrumentation.ObserveDBQuery("list_alert_silences", time.Now())
+	rows, err := self.db.Query(selectSilencesStatement, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Silence, 0)
+	for rows.Next() {
+		var s Silence
+		err := rows.Scan(&s.ID, &s.Tenant, &s.Rule, &s.Resource, &s.StartsAt, &s.EndsAt, &s.Comment)
+		if err != nil {
+			return nil, err
+		}
+		s.StartsAt, s.EndsAt = s.StartsAt.UTC(), s.EndsAt.UTC()
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
+
+// AddSilence implements Store interface. See Store for more information.
+func (self *postgresStore) AddSilence(silence Silence) error {
+	defer instrumentation.ObserveDBQuery("add_alert_silence", time.Now())
+	_, err := self.db.Exec(insertSilenceStatement, silence.ID, silence.Tenant, silence.Rule, silence.Resource,
+		silence.StartsAt.UTC(), silence.EndsAt.UTC(), silence.Comment)
+	return err
+}
+
+// DeleteSilence implements Store interface. See Store for more information.
+func (self *postgresStore) DeleteSilence(tenant, id string) error {
+	return self.delete(deleteSilenceStatement, tenant, id, "silence")
+}
+
+func (self *postgresStore) delete(statement, tenant, name, kind string) error {
+	defer instrumentation.ObserveDBQuery("delete_alert_"+kind, time.Now())
+	result, err := self.db.Exec(statement, tenant, name)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.NewNotFound(fmt.Sprintf("%s %s of tenant %s not found", kind, name, tenant))
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// SystemTenant is the tenant of rules that cover all tenants and the partitions themselves.
+const SystemTenant = "system"
+
+// Condition is a condition observed by the dashboard that alerts can be defined on.
+type Condition string
+
+const (
+	// PodCrashLooping fires for every pod with a container in CrashLoopBackOff.
+	PodCrashLooping Condition = "PodCrashLooping"
+	// DeploymentUnavailable fires for every deployment with less available replicas than desired.
+	DeploymentUnavailable Condition = "DeploymentUnavailable"
+	// NodeNotReady fires for every node whose Ready condition is not true.
+	NodeNotReady Condition = "NodeNotReady"
+	// QuotaAboveThreshold fires for every resource quota of which any resource is used above the
+	// threshold of the rule.
+	QuotaAboveThreshold Condition = "QuotaAboveThreshold"
+	// PartitionUnreachable fires for every partition whose apiserver cannot be reached.
+	PartitionUnreachable Condition = "PartitionUnreachable"
+)
+
+// partitionConditions are the conditions on partitions and their nodes, which can only be used by
+// rules of the system tenant.
+var partitionConditions = map[Condition]bool{
+	NodeNotReady:         true,
+	PartitionUnreachable: true,
+}
+
+var conditions = map[Condition]bool{
+	PodCrashLooping:       true,
+	DeploymentUnavailable: true,
+	NodeNotReady:          true,
+	QuotaAboveThreshold:   true,
+	PartitionUnreachable:  true,
+}
+
+// SinkType is the type of a notification sink.
+type SinkType string
+
+const (
+	// WebhookSink posts the alert as JSON to the URL of the sink.
+	WebhookSink SinkType = "webhook"
+	// SlackSink posts a Slack compatible message to the incoming webhook URL of the sink.
+	SlackSink SinkType = "slack"
+	// EmailSink sends an email to the recipients of the sink.
+	EmailSink SinkType = "email"
+)
+
+// Sink is a destination of the notifications of a rule.
+type Sink struct {
+	Type SinkType `json:"type"`
+	// URL of webhook and slack sinks.
+	URL string `json:"url,omitempty"`
+	// To are the recipients of email sinks.
+	To []string `json:"to,omitempty"`
+}
+
+// Rule defines a condition that is evaluated periodically and where its notifications are sent to.
+type Rule struct {
+	// Name of the rule, unique within the tenant.
+	Name   string `json:"name"`
+	Tenant string `json:"tenant"`
+	// Partition limits the rule to the partition with this cluster name. Empty means all partitions.
+	Partition string    `json:"partition,omitempty"`
+	Condition Condition `json:"condition"`
+	// Threshold is the used percentage of a quota above which QuotaAboveThreshold alerts fire.
+	Threshold int64  `json:"threshold,omitempty"`
+	Sinks     []Sink `json:"sinks"`
+}
+
+// Validate returns a bad request error if the rule is not valid.
+func (self Rule) Validate() error {
+	if len(self.Name) == 0 || strings.Contains(self.Name, "/") {
+		return errors.NewBadRequest("rule name has to be non-empty and must not contain '/'")
+	}
+	if !conditions[self.Condition] {
+		return errors.NewBadRequest(fmt.Sprintf("unknown condition %s", self.Condition))
+	}
+	if partitionConditions[self.Condition] && self.Tenant != SystemTenant {
+		return errors.NewBadRequest(fmt.Sprintf("condition %s can only be used by the %s tenant",
+			self.Condition, SystemTenant))
+	}
+	if self.Condition == QuotaAboveThreshold && (self.Threshold <= 0 || self.Threshold > 100) {
+		return errors.NewBadRequest("threshold has to be a percentage between 1 and 100")
+	}
+
+	for _, sink := range self.Sinks {
+		switch sink.Type {
+		case WebhookSink, SlackSink:
+			parsed, err := url.Parse(sink.URL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+				return errors.NewBadRequest(fmt.Sprintf("invalid %s sink url %q", sink.Type, sink.URL))
+			}
+		case EmailSink:
+			if len(sink.To) == 0 {
+				return errors.NewBadRequest("email sink needs at least one recipient")
+			}
+		default:
+			return errors.NewBadRequest(fmt.Sprintf("unknown sink type %s", sink.Type))
+		}
+	}
+	return nil
+}
+
+// Silence suppresses the notifications of matching alerts between its start and end.
+type Silence struct {
+	ID     string `json:"id"`
+	Tenant string `json:"tenant"`
+	// Rule is the name of the silenced rule. Empty means all rules of the tenant.
+	Rule string `json:"rule,omitempty"`
+	// Resource is the name of the silenced resource. Empty means all resources.
+	Resource string    `json:"resource,omitempty"`
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+	Comment  string    `json:"comment,omitempty"`
+}
+
+// Validate returns a bad request error if the silence is not valid.
+func (self Silence) Validate() error {
+	if !self.EndsAt.After(self.StartsAt) {
+		return errors.NewBadRequest("silence has to end after it starts")
+	}
+	return nil
+}
+
+// Matches returns true if the silence is active at the given time and covers the alert.
+func (self Silence) Matches(alert Alert, now time.Time) bool {
+	return self.Tenant == alert.Tenant &&
+		(len(self.Rule) == 0 || self.Rule == alert.Rule) &&
+		(len(self.Resource) == 0 || self.Resource == alert.Resource.Name) &&
+		!now.Before(self.StartsAt) && now.Before(self.EndsAt)
+}
+
+// State is the state of an alert.
+type State string
+
+const (
+	Firing   State = "firing"
+	Resolved State = "resolved"
+)
+
+// Resource identifies the resource an alert is about.
+type Resource struct {
+	Kind      string `json:"kind"`
+	Tenant    string `json:"tenant,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (self Resource) String() string {
+	return strings.Join([]string{self.Kind, self.Tenant, self.Namespace, self.Name}, "/")
+}
+
+// Alert is a condition of a rule that was observed on a resource.
+type Alert struct {
+	Rule      string     `json:"rule"`
+	Tenant    string     `json:"tenant"`
+	Partition string     `json:"partition"`
+	Condition Condition  `json:"condition"`
+	Resource  Resource   `json:"resource"`
+	Message   string     `json:"message"`
+	State     State      `json:"state"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	// Silenced is true if a silence currently suppresses the notifications of the alert.
+	Silenced bool `json:"silenced"`
+
+	// notified is true if the firing notification was sent, so that the resolved one is sent too.
+	notified bool
+}
+
+// key identifies the alert across evaluations.
+func (self Alert) key() string {
+	return ruleKey(self.Tenant, self.Rule) + "/" + self.Partition + "/" + self.Resource.String()
+}
+
+func ruleKey(tenant, name string) string {
+	return tenant + "/" + name
+}
+
+// AlertList is the list of the current and recently resolved alerts of a tenant.
+type AlertList struct {
+	Alerts []Alert `json:"alerts"`
+}
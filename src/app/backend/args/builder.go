@@ -102,6 +102,42 @@ func (self *holderBuilder) SetSidecarHost(sidecarHost string) *holderBuilder {
 	return self
 }
 
+// SetPrometheusHost 'prometheus-host' argument of Dashboard binary.
+func (self *holderBuilder) SetPrometheusHost(prometheusHost string) *holderBuilder {
+	self.holder.prometheusHost = prometheusHost
+	return self
+}
+
+// SetPartitionMetricsHosts 'partition-metrics-hosts' argument of Dashboard binary.
+func (self *holderBuilder) SetPartitionMetricsHosts(partitionMetricsHosts map[string]string) *holderBuilder {
+	self.holder.partitionMetricsHosts = partitionMetricsHosts
+	return self
+}
+
+// SetAlertSMTPHost 'alert-smtp-host' argument of Dashboard binary.
+func (self *holderBuilder) SetAlertSMTPHost(alertSMTPHost string) *holderBuilder {
+	self.holder.alertSMTPHost = alertSMTPHost
+	return self
+}
+
+// SetAlertSMTPFrom 'alert-smtp-from' argument of Dashboard binary.
+func (self *holderBuilder) SetAlertSMTPFrom(alertSMTPFrom string) *holderBuilder {
+	self.holder.alertSMTPFrom = alertSMTPFrom
+	return self
+}
+
+// SetTracingOTLPEndpoint 'tracing-otlp-endpoint' argument of Dashboard binary.
+func (self *holderBuilder) SetTracingOTLPEndpoint(tracingOTLPEndpoint string) *holderBuilder {
+	self.holder.tracingOTLPEndpoint = tracingOTLPEndpoint
+	return self
+}
+
+// SetCapacityNodeCost 'capacity-node-cost' argument of Dashboard binary.
+func (self *holderBuilder) SetCapacityNodeCost(capacityNodeCost float64) *holderBuilder {
+	self.holder.capacityNodeCost = capacityNodeCost
+	return self
+}
+
 // SetKubeConfigFile 'kubeconfig' argument of Dashboard binary.
 func (self *holderBuilder) SetKubeConfigFile(kubeConfigFile string) *holderBuilder {
 	self.holder.kubeConfigFile = kubeConfigFile
@@ -126,6 +162,12 @@ func (self *holderBuilder) SetAPILogLevel(apiLogLevel string) *holderBuilder {
 	return self
 }
 
+// SetAlertSinkHosts 'alert-sink-hosts' argument of Dashboard binary.
+func (self *holderBuilder) SetAlertSinkHosts(alertSinkHosts []string) *holderBuilder {
+	self.holder.alertSinkHosts = alertSinkHosts
+	return self
+}
+
 // SetAuthenticationMode 'authentication-mode' argument of Dashboard binary.
 func (self *holderBuilder) SetAuthenticationMode(authMode []string) *holderBuilder {
 	self.holder.authenticationMode = authMode
@@ -168,6 +210,18 @@ func (self *holderBuilder) SetLocaleConfig(localeConfig string) *holderBuilder {
 	return self
 }
 
+// SetHelmRepository 'helm-repository' argument of Dashboard binary.
+func (self *holderBuilder) SetHelmRepository(url string) *holderBuilder {
+	self.holder.helmRepository = url
+	return self
+}
+
+// SetVMVNCPort 'vm-vnc-port' argument of Dashboard binary.
+func (self *holderBuilder) SetVMVNCPort(port int) *holderBuilder {
+	self.holder.vmVNCPort = port
+	return self
+}
+
 // GetHolderBuilder returns singleton instance of argument holder builder.
 func GetHolderBuilder() *holderBuilder {
 	return builder
@@ -29,6 +29,9 @@ type holder struct {
 	port                    int
 	tokenTTL                int
 	metricClientCheckPeriod int
+	vmVNCPort               int
+
+	capacityNodeCost float64
 
 	insecureBindAddress net.IP
 	bindAddress         net.IP
@@ -40,13 +43,20 @@ type holder struct {
 	metricsProvider      string
 	heapsterHost         string
 	sidecarHost          string
+	prometheusHost       string
 	kubeConfigFile       string
 	systemBanner         string
 	systemBannerSeverity string
 	apiLogLevel          string
 	namespace            string
+	alertSMTPHost        string
+	alertSMTPFrom        string
+	tracingOTLPEndpoint  string
 
 	authenticationMode []string
+	alertSinkHosts     []string
+
+	partitionMetricsHosts map[string]string
 
 	autoGenerateCertificates  bool
 	enableInsecureLogin       bool
@@ -55,6 +65,8 @@ type holder struct {
 	enableSkipLogin bool
 
 	localeConfig string
+
+	helmRepository string
 }
 
 // GetInsecurePort 'insecure-port' argument of Dashboard binary.
@@ -67,6 +79,11 @@ func (self *holder) GetPort() int {
 	return self.port
 }
 
+// GetVMVNCPort 'vm-vnc-port' argument of Dashboard binary.
+func (self *holder) GetVMVNCPort() int {
+	return self.vmVNCPort
+}
+
 // GetTokenTTL 'token-ttl' argument of Dashboard binary.
 func (self *holder) GetTokenTTL() int {
 	return self.tokenTTL
@@ -130,6 +147,41 @@ func (self *holder) GetSidecarHost() string {
 	return self.sidecarHost
 }
 
+// GetPrometheusHost 'prometheus-host' argument of Dashboard binary.
+func (self *holder) GetPrometheusHost() string {
+	return self.prometheusHost
+}
+
+// GetPartitionMetricsHosts 'partition-metrics-hosts' argument of Dashboard binary.
+func (self *holder) GetPartitionMetricsHosts() map[string]string {
+	return self.partitionMetricsHosts
+}
+
+// GetAlertSMTPHost 'alert-smtp-host' argument of Dashboard binary.
+func (self *holder) GetAlertSMTPHost() string {
+	return self.alertSMTPHost
+}
+
+// GetAlertSMTPFrom 'alert-smtp-from' argument of Dashboard binary.
+func (self *holder) GetAlertSMTPFrom() string {
+	return self.alertSMTPFrom
+}
+
+// GetAlertSinkHosts 'alert-sink-hosts' argument of Dashboard binary.
+func (self *holder) GetAlertSinkHosts() []string {
+	return self.alertSinkHosts
+}
+
+// GetTracingOTLPEndpoint 'tracing-otlp-endpoint' argument of Dashboard binary.
+func (self *holder) GetTracingOTLPEndpoint() string {
+	return self.tracingOTLPEndpoint
+}
+
+// GetCapacityNodeCost 'capacity-node-cost' argument of Dashboard binary.
+func (self *holder) GetCapacityNodeCost() float64 {
+	return self.capacityNodeCost
+}
+
 // GetKubeConfigFile 'kubeconfig' argument of Dashboard binary.
 func (self *holder) GetKubeConfigFile() string {
 	return self.kubeConfigFile
@@ -184,3 +236,8 @@ func (self *holder) GetNamespace() string {
 func (self *holder) GetLocaleConfig() string {
 	return self.localeConfig
 }
+
+// GetHelmRepository 'helm-repository' argument of Dashboard binary.
+func (self *holder) GetHelmRepository() string {
+	return self.helmRepository
+}
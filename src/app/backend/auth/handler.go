@@ -23,6 +23,7 @@ import (
 
 	authApi "github.com/CentaurusInfra/dashboard/src/app/backend/auth/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/validation"
 )
 
@@ -90,12 +91,14 @@ func (self AuthHandler) handleLogin(request *restful.Request, response *restful.
 		loginSpec.NameSpace = "default"
 	}
 	if loginSpec.Tenant == "" {
+		instrumentation.RecordAuthFailure(instrumentation.AuthFailureMissingTenant)
 		response.WriteError(http.StatusUnauthorized, errors.NewUnauthorized("Invalid username or password"))
 		return
 	}
 	authmanager := AuthAllocator(loginSpec.Tenant, self.manager)
 	loginResponse, err := authmanager.Login(loginSpec)
 	if err != nil {
+		instrumentation.RecordAuthFailure(instrumentation.AuthFailureLogin)
 		response.AddHeader("Content-Type", "text/plain")
 		response.WriteErrorString(errors.HandleHTTPError(err), err.Error()+"\n")
 		return
@@ -125,6 +128,7 @@ func (self *AuthHandler) handleJWETokenRefresh(request *restful.Request, respons
 		}
 	}
 	if err != nil {
+		instrumentation.RecordAuthFailure(instrumentation.AuthFailureTokenRefresh)
 		response.AddHeader("Content-Type", "text/plain")
 		response.WriteErrorString(errors.HandleHTTPError(err), err.Error()+"\n")
 		return
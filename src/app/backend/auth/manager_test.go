@@ -30,6 +30,7 @@ import (
 	pluginclientset "github.com/CentaurusInfra/dashboard/src/app/backend/plugin/client/clientset/versioned"
 	v1 "k8s.io/api/authorization/v1"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -49,6 +50,10 @@ func (self *fakeClientManager) GetClusterName() string {
 	return ""
 }
 
+func (self *fakeClientManager) Identity(req *restful.Request) (string, error) {
+	return "", nil
+}
+
 func (self *fakeClientManager) Client(req *restful.Request) (kubernetes.Interface, error) {
 	return nil, nil
 }
@@ -93,7 +98,11 @@ func (self *fakeClientManager) GetTenant(authInfo api.AuthInfo, namespace string
 
 func (self *fakeClientManager) VerberClient(req *restful.Request, config *rest.Config) (clientapi.ResourceVerber, error) {
 	return client.NewResourceVerber(nil, nil, nil, nil, nil,
-		nil, nil, nil, nil, nil), nil
+		nil, nil, nil, nil, nil, nil), nil
+}
+
+func (self *fakeClientManager) RESTMapper() meta.RESTMapper {
+	return nil
 }
 
 func (self *fakeClientManager) CanI(req *restful.Request, ssar *v1.SelfSubjectAccessReview) bool {
@@ -0,0 +1,265 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bulk executes a single action on many resources at once, e.g. to clean up a tenant.
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/labeling"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// MaxConcurrency is the maximum number of resources a bulk action works on in parallel.
+	MaxConcurrency = 10
+
+	// MaxResources is the maximum number of resources of a single bulk action.
+	MaxResources = 1000
+)
+
+// Action is the operation that is executed on every resource.
+type Action string
+
+const (
+	ActionDelete   Action = "delete"
+	ActionScale    Action = "scale"
+	ActionLabel    Action = "label"
+	ActionAnnotate Action = "annotate"
+	ActionRestart  Action = "restart"
+)
+
+// ItemStatus is the outcome of the action for a single resource.
+type ItemStatus string
+
+const (
+	ItemSucceeded ItemStatus = "Succeeded"
+	ItemFailed    ItemStatus = "Failed"
+)
+
+// ResourceReference identifies a single resource. The kind is the same as in the raw resource API,
+// e.g. 'deployment'. The namespace is empty for resources that are not namespaced.
+type ResourceReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// ResourceSelector selects all resources of a kind in a namespace that match the label selector. If the
+// namespace of a namespaced kind is empty, the resources of all namespaces of the tenant are selected.
+type ResourceSelector struct {
+	Kind          string `json:"kind"`
+	Namespace     string `json:"namespace"`
+	LabelSelector string `json:"labelSelector"`
+}
+
+// ActionSpec is a specification of a bulk action. The resources are given either as a list of
+// references or by a selector.
+type ActionSpec struct {
+	Action Action `json:"action"`
+
+	Resources []ResourceReference `json:"resources"`
+	Selector  *ResourceSelector   `json:"selector"`
+
+	// Desired number of replicas. Only used to scale.
+	Replicas *int32 `json:"replicas"`
+
+	// Labels or annotations to set. Only used to label or annotate.
+	Set map[string]string `json:"set"`
+
+	// Keys of the labels or annotations to remove. Only used to label or annotate.
+	Remove []string `json:"remove"`
+}
+
+// ItemResult is the outcome of the action for a single resource.
+type ItemResult struct {
+	ResourceReference `json:",inline"`
+
+	Status ItemStatus `json:"status"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// ActionResult contains the outcome of the action for every resource, in the order of the references
+// or the list of selected resources.
+type ActionResult struct {
+	Action    Action       `json:"action"`
+	Items     []ItemResult `json:"items"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+}
+
+// Scaler sets the desired number of replicas of a resource through its scale subresource.
+type Scaler interface {
+	Scale(tenant, kind, namespace, name string, replicas int32) error
+}
+
+// Execute executes the action on all resources of the spec in the given tenant. The scaler is only used
+// to scale.
+func Execute(verber clientapi.ResourceVerber, scaler Scaler, tenant string, spec *ActionSpec) (*ActionResult, error) {
+	execute, err := getExecutor(scaler, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	references, err := getReferences(verber, tenant, spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(references) > MaxResources {
+		return nil, errors.NewInvalid(fmt.Sprintf("Bulk actions are limited to %d resources, got %d",
+			MaxResources, len(references)))
+	}
+	log.Printf("Executing bulk %s on %d resources of tenant %s", spec.Action, len(references), tenant)
+
+	result := &ActionResult{Action: spec.Action, Items: make([]ItemResult, len(references))}
+	semaphore := make(chan struct{}, MaxConcurrency)
+	wg := sync.WaitGroup{}
+	for i, reference := range references {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, reference ResourceReference) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			item := ItemResult{ResourceReference: reference, Status: ItemSucceeded}
+			if err := execute(verber, tenant, reference); err != nil {
+				item.Status = ItemFailed
+				item.Error = errors.LocalizeError(err).Error()
+			}
+			result.Items[i] = item
+		}(i, reference)
+	}
+	wg.Wait()
+
+	for _, item := range result.Items {
+		if item.Status == ItemSucceeded {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// executor executes the action on a single resource.
+type executor func(verber clientapi.ResourceVerber, tenant string, reference ResourceReference) error
+
+func getExecutor(scaler Scaler, spec *ActionSpec) (executor, error) {
+	switch spec.Action {
+	case ActionDelete:
+		return deleteResource, nil
+	case ActionScale:
+		if spec.Replicas == nil || *spec.Replicas < 0 {
+			return nil, errors.NewInvalid("Scaling requires a non-negative number of replicas")
+		}
+		replicas := *spec.Replicas
+		return func(verber clientapi.ResourceVerber, tenant string, reference ResourceReference) error {
+			return scaler.Scale(tenant, reference.Kind, reference.Namespace, reference.Name, replicas)
+		}, nil
+	case ActionLabel, ActionAnnotate:
+		if len(spec.Set) == 0 && len(spec.Remove) == 0 {
+			return nil, errors.NewInvalid(fmt.Sprintf("Nothing to %s", spec.Action))
+		}
+		field := labeling.FieldLabels
+		if spec.Action == ActionAnnotate {
+			field = labeling.FieldAnnotations
+		}
+		patch, err := labeling.NewPatch(field, spec.Set, spec.Remove, "")
+		if err != nil {
+			return nil, err
+		}
+		return func(verber clientapi.ResourceVerber, tenant string, reference ResourceReference) error {
+			_, err := verber.PatchWithMultiTenancy(reference.Kind, tenant, len(reference.Namespace) > 0,
+				reference.Namespace, reference.Name, types.MergePatchType, patch)
+			return err
+		}, nil
+	case ActionRestart:
+		return restart, nil
+	default:
+		return nil, errors.NewInvalid(fmt.Sprintf("Unknown bulk action %q", spec.Action))
+	}
+}
+
+// getReferences returns the references of the spec or lists the resources of the selector.
+func getReferences(verber clientapi.ResourceVerber, tenant string, spec *ActionSpec) ([]ResourceReference, error) {
+	if spec.Selector == nil {
+		if len(spec.Resources) == 0 {
+			return nil, errors.NewInvalid("Bulk actions require a list of resources or a selector")
+		}
+		return spec.Resources, nil
+	}
+	if len(spec.Resources) > 0 {
+		return nil, errors.NewInvalid("Bulk actions take either a list of resources or a selector, not both")
+	}
+
+	selector := spec.Selector
+	raw, err := verber.ListWithMultiTenancy(selector.Kind, tenant, len(selector.Namespace) > 0, selector.Namespace,
+		selector.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	list := struct {
+		Items []struct {
+			metaV1.ObjectMeta `json:"metadata"`
+		} `json:"items"`
+	}{}
+	if err := json.Unmarshal(raw.(*runtime.Unknown).Raw, &list); err != nil {
+		return nil, err
+	}
+	references := make([]ResourceReference, 0, len(list.Items))
+	for _, item := range list.Items {
+		references = append(references, ResourceReference{
+			Kind:      selector.Kind,
+			Namespace: item.Namespace,
+			Name:      item.Name,
+		})
+	}
+	return references, nil
+}
+
+func deleteResource(verber clientapi.ResourceVerber, tenant string, reference ResourceReference) error {
+	return verber.DeleteWithMultiTenancy(reference.Kind, tenant, len(reference.Namespace) > 0,
+		reference.Namespace, reference.Name)
+}
+
+// restart patches the restart annotation into the pod template, which rolls all pods of the workload.
+func restart(verber clientapi.ResourceVerber, tenant string, reference ResourceReference) error {
+	switch reference.Kind {
+	case api.ResourceKindDeployment, api.ResourceKindStatefulSet, api.ResourceKindDaemonSet:
+	default:
+		return errors.NewInvalid(fmt.Sprintf("%s cannot be restarted", reference.Kind))
+	}
+
+	patch, err := common.RestartPatch()
+	if err != nil {
+		return err
+	}
+	_, err = verber.PatchWithMultiTenancy(reference.Kind, tenant, len(reference.Namespace) > 0, reference.Namespace,
+		reference.Name, types.MergePatchType, patch)
+	return err
+}
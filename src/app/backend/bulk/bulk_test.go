@@ -0,0 +1,370 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bulk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeVerber keeps the objects of a single tenant in memory.
+type fakeVerber struct {
+	mu      sync.Mutex
+	tenant  string
+	objects map[string]map[string]interface{}
+}
+
+func newFakeVerber(tenant string, objects ...map[string]interface{}) *fakeVerber {
+	verber := &fakeVerber{tenant: tenant, objects: make(map[string]map[string]interface{})}
+	for _, object := range objects {
+		u := unstructured.Unstructured{Object: object}
+		verber.objects[key(strings.ToLower(u.GetKind()), u.GetNamespace(), u.GetName())] = object
+	}
+	return verber
+}
+
+func newObject(kind, namespace, name string, labels map[string]interface{}, spec map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":     kind,
+		"metadata": map[string]interface{}{"name": name, "namespace": namespace, "labels": labels},
+		"spec":     spec,
+	}
+}
+
+func key(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+func (v *fakeVerber) get(kind, tenant, namespace, name string) (map[string]interface{}, error) {
+	object, ok := v.objects[key(kind, namespace, name)]
+	if tenant != v.tenant || !ok {
+		return nil, errors.NewNotFound(fmt.Sprintf("%s %s not found", kind, name))
+	}
+	return object, nil
+}
+
+func (v *fakeVerber) Put(kind string, namespaceSet bool, namespace string, name string, object *runtime.Unknown) error {
+	return v.PutWithMultiTenancy(kind, "", namespaceSet, namespace, name, object)
+}
+
+func (v *fakeVerber) PutWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string, name string,
+	object *runtime.Unknown) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, err := v.get(kind, tenant, namespace, name); err != nil {
+		return err
+	}
+	updated := new(unstructured.Unstructured)
+	if err := updated.UnmarshalJSON(object.Raw); err != nil {
+		return err
+	}
+	v.objects[key(kind, namespace, name)] = updated.Object
+	return nil
+}
+
+func (v *fakeVerber) Patch(kind string, namespaceSet bool, namespace string, name string, patchType types.PatchType,
+	data []byte) (runtime.Object, error) {
+	return v.PatchWithMultiTenancy(kind, "", namespaceSet, namespace, name, patchType, data)
+}
+
+// PatchWithMultiTenancy only supports JSON merge patches.
+func (v *fakeVerber) PatchWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	name string, patchType types.PatchType, data []byte) (runtime.Object, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	object, err := v.get(kind, tenant, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if patchType != types.MergePatchType {
+		return nil, errors.NewInvalid(fmt.Sprintf("unsupported patch type %s", patchType))
+	}
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	mergePatch(object, patch)
+	raw, err := json.Marshal(object)
+	return &runtime.Unknown{Raw: raw}, err
+}
+
+// mergePatch applies a JSON merge patch as described in RFC 7386.
+func mergePatch(object, patch map[string]interface{}) {
+	for key, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(object, key)
+		case map[string]interface{}:
+			target, ok := object[key].(map[string]interface{})
+			if !ok {
+				target = make(map[string]interface{})
+				object[key] = target
+			}
+			mergePatch(target, value)
+		default:
+			object[key] = value
+		}
+	}
+}
+
+func (v *fakeVerber) Get(kind string, namespaceSet bool, namespace string, name string) (runtime.Object, error) {
+	return v.GetWithMultiTenancy(kind, "", namespaceSet, namespace, name)
+}
+
+func (v *fakeVerber) GetWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	name string) (runtime.Object, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	object, err := v.get(kind, tenant, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(object)
+	return &runtime.Unknown{Raw: raw}, err
+}
+
+func (v *fakeVerber) Delete(kind string, namespaceSet bool, namespace string, name string) error {
+	return v.DeleteWithMultiTenancy(kind, "", namespaceSet, namespace, name)
+}
+
+func (v *fakeVerber) DeleteWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, err := v.get(kind, tenant, namespace, name); err != nil {
+		return err
+	}
+	delete(v.objects, key(kind, namespace, name))
+	return nil
+}
+
+func (v *fakeVerber) List(kind string, namespaceSet bool, namespace string, labelSelector string) (
+	runtime.Object, error) {
+	return v.ListWithMultiTenancy(kind, "", namespaceSet, namespace, labelSelector)
+}
+
+// ListWithMultiTenancy only supports selectors of the form 'key=value'.
+func (v *fakeVerber) ListWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	labelSelector string) (runtime.Object, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	items := make([]interface{}, 0)
+	for _, object := range v.objects {
+		u := unstructured.Unstructured{Object: object}
+		if strings.ToLower(u.GetKind()) != kind || (namespaceSet && u.GetNamespace() != namespace) {
+			continue
+		}
+		if parts := strings.SplitN(labelSelector, "=", 2); len(labelSelector) > 0 && u.GetLabels()[parts[0]] != parts[1] {
+			continue
+		}
+		items = append(items, object)
+	}
+	raw, err := json.Marshal(map[string]interface{}{"items": items})
+	return &runtime.Unknown{Raw: raw}, err
+}
+
+// Scale sets the replicas of objects that have replicas, like the scale subresource.
+func (v *fakeVerber) Scale(tenant, kind, namespace, name string, replicas int32) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	object, err := v.get(kind, tenant, namespace, name)
+	if err != nil {
+		return err
+	}
+	if _, ok, _ := unstructured.NestedFieldNoCopy(object, "spec", "replicas"); !ok {
+		return errors.NewNotFound(fmt.Sprintf("%s %s has no scale subresource", kind, name))
+	}
+	return unstructured.SetNestedField(object, int64(replicas), "spec", "replicas")
+}
+
+func (v *fakeVerber) object(t *testing.T, kind, namespace, name string) *unstructured.Unstructured {
+	object, err := v.get(kind, v.tenant, namespace, name)
+	if err != nil {
+		t.Fatalf("could not get %s %s: %v", kind, name, err)
+	}
+	return &unstructured.Unstructured{Object: object}
+}
+
+func TestExecuteDelete(t *testing.T) {
+	verber := newFakeVerber("foo",
+		newObject("Deployment", "bar", "a", nil, nil),
+		newObject("Deployment", "bar", "b", nil, nil),
+		newObject("Namespace", "", "baz", nil, nil))
+
+	result, err := Execute(verber, verber, "foo", &ActionSpec{
+		Action: ActionDelete,
+		Resources: []ResourceReference{
+			{Kind: "deployment", Namespace: "bar", Name: "a"},
+			{Kind: "deployment", Namespace: "bar", Name: "missing"},
+			{Kind: "namespace", Name: "baz"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if result.Succeeded != 2 || result.Failed != 1 {
+		t.Errorf("Expected 2 succeeded and 1 failed, got %+v", result)
+	}
+	if item := result.Items[1]; item.Name != "missing" || item.Status != ItemFailed || len(item.Error) == 0 {
+		t.Errorf("Expected failed result for missing deployment, got %+v", item)
+	}
+	if len(verber.objects) != 1 {
+		t.Errorf("Expected only deployment b to remain, got %v", verber.objects)
+	}
+}
+
+func TestExecuteWithSelector(t *testing.T) {
+	var objects []map[string]interface{}
+	for i := 0; i < 3*MaxConcurrency; i++ {
+		objects = append(objects, newObject("Deployment", "bar", fmt.Sprintf("app-%d", i),
+			map[string]interface{}{"app": "foo"}, map[string]interface{}{"replicas": int64(1)}))
+	}
+	objects = append(objects,
+		newObject("Deployment", "bar", "other", map[string]interface{}{"app": "other"},
+			map[string]interface{}{"replicas": int64(1)}),
+		newObject("Deployment", "baz", "app", map[string]interface{}{"app": "foo"},
+			map[string]interface{}{"replicas": int64(1)}))
+	verber := newFakeVerber("foo", objects...)
+
+	replicas := int32(3)
+	result, err := Execute(verber, verber, "foo", &ActionSpec{
+		Action:   ActionScale,
+		Selector: &ResourceSelector{Kind: "deployment", Namespace: "bar", LabelSelector: "app=foo"},
+		Replicas: &replicas,
+	})
+	if err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if result.Succeeded != 3*MaxConcurrency || result.Failed != 0 {
+		t.Errorf("Expected %d succeeded, got %+v", 3*MaxConcurrency, result)
+	}
+	for _, c := range []struct {
+		namespace, name string
+		expected        int64
+	}{
+		{"bar", "app-0", 3},
+		{"bar", "other", 1},
+		{"baz", "app", 1},
+	} {
+		actual, _, _ := unstructured.NestedInt64(verber.object(t, "deployment", c.namespace, c.name).Object, "spec", "replicas")
+		if actual != c.expected {
+			t.Errorf("Expected %d replicas of %s/%s, got %d", c.expected, c.namespace, c.name, actual)
+		}
+	}
+}
+
+func TestExecuteWithSelectorInAllNamespaces(t *testing.T) {
+	verber := newFakeVerber("foo",
+		newObject("Deployment", "bar", "a", map[string]interface{}{"app": "foo"}, nil),
+		newObject("Deployment", "baz", "b", map[string]interface{}{"app": "foo"}, nil),
+		newObject("Deployment", "baz", "c", map[string]interface{}{"app": "other"}, nil))
+
+	result, err := Execute(verber, verber, "foo", &ActionSpec{
+		Action:   ActionDelete,
+		Selector: &ResourceSelector{Kind: "deployment", LabelSelector: "app=foo"},
+	})
+	if err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if result.Succeeded != 2 || len(verber.objects) != 1 {
+		t.Errorf("Expected the deployments of both namespaces to be deleted, got %+v", result)
+	}
+}
+
+func TestExecuteLabelAndAnnotate(t *testing.T) {
+	verber := newFakeVerber("foo", newObject("Service", "bar", "a", map[string]interface{}{"app": "foo", "old": "x"}, nil))
+	reference := []ResourceReference{{Kind: "service", Namespace: "bar", Name: "a"}}
+
+	if _, err := Execute(verber, verber, "foo", &ActionSpec{Action: ActionLabel, Resources: reference,
+		Set: map[string]string{"team": "a"}, Remove: []string{"old"}}); err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if _, err := Execute(verber, verber, "foo", &ActionSpec{Action: ActionAnnotate, Resources: reference,
+		Set: map[string]string{"note": "b"}}); err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+
+	object := verber.object(t, "service", "bar", "a")
+	labels := object.GetLabels()
+	if len(labels) != 2 || labels["app"] != "foo" || labels["team"] != "a" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if annotations := object.GetAnnotations(); annotations["note"] != "b" {
+		t.Errorf("unexpected annotations %v", annotations)
+	}
+}
+
+func TestExecuteRestartAndScaleUnsupportedKinds(t *testing.T) {
+	verber := newFakeVerber("foo",
+		newObject("StatefulSet", "bar", "a", nil, map[string]interface{}{"template": map[string]interface{}{}}),
+		newObject("Job", "bar", "b", nil, map[string]interface{}{"template": map[string]interface{}{}}),
+		newObject("Service", "bar", "c", nil, map[string]interface{}{}))
+
+	result, err := Execute(verber, verber, "foo", &ActionSpec{Action: ActionRestart, Resources: []ResourceReference{
+		{Kind: "statefulset", Namespace: "bar", Name: "a"},
+		{Kind: "job", Namespace: "bar", Name: "b"},
+	}})
+	if err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if result.Items[0].Status != ItemSucceeded || result.Items[1].Status != ItemFailed {
+		t.Errorf("Expected only the stateful set to be restarted, got %+v", result.Items)
+	}
+	annotations, _, _ := unstructured.NestedStringMap(verber.object(t, "statefulset", "bar", "a").Object,
+		"spec", "template", "metadata", "annotations")
+	if len(annotations[common.RestartedAtAnnotation]) == 0 {
+		t.Errorf("Expected restart annotation, got %v", annotations)
+	}
+
+	replicas := int32(1)
+	result, err = Execute(verber, verber, "foo", &ActionSpec{Action: ActionScale, Replicas: &replicas,
+		Resources: []ResourceReference{{Kind: "service", Namespace: "bar", Name: "c"}}})
+	if err != nil {
+		t.Fatalf("Execute() returned unexpected error: %v", err)
+	}
+	if result.Failed != 1 {
+		t.Errorf("Expected scaling a service to fail, got %+v", result)
+	}
+}
+
+func TestExecuteInvalidSpec(t *testing.T) {
+	verber := newFakeVerber("foo")
+	reference := []ResourceReference{{Kind: "deployment", Namespace: "bar", Name: "a"}}
+	negative := int32(-1)
+
+	cases := []*ActionSpec{
+		{Action: "unknown", Resources: reference},
+		{Action: ActionDelete},
+		{Action: ActionDelete, Resources: reference, Selector: &ResourceSelector{Kind: "deployment"}},
+		{Action: ActionScale, Resources: reference},
+		{Action: ActionScale, Resources: reference, Replicas: &negative},
+		{Action: ActionLabel, Resources: reference},
+		{Action: ActionDelete, Resources: make([]ResourceReference, MaxResources+1)},
+	}
+	for _, c := range cases {
+		if _, err := Execute(verber, verber, "foo", c); err == nil {
+			t.Errorf("Expected error for %+v", c)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/node"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Capacity is the capacity of the schedulable nodes of a resource partition and the demand on it.
+// CPU is in millicores, memory in bytes.
+type Capacity struct {
+	Nodes          int64 `json:"nodes"`
+	CPUCapacity    int64 `json:"cpuCapacity"`
+	CPURequests    int64 `json:"cpuRequests"`
+	CPUUsage       int64 `json:"cpuUsage"`
+	MemoryCapacity int64 `json:"memoryCapacity"`
+	MemoryRequests int64 `json:"memoryRequests"`
+	MemoryUsage    int64 `json:"memoryUsage"`
+	PodCapacity    int64 `json:"podCapacity"`
+	Pods           int64 `json:"pods"`
+}
+
+// Collect returns the current capacity of a partition. The capacity is the allocatable resources of
+// the nodes that are not cordoned. Requests are summed over the pods on these nodes that are not
+// finished yet. Actual usage is read from the metric client and left empty if it is nil or fails.
+func Collect(client kubernetes.Interface, metricClient metricapi.MetricClient) (*Capacity, error) {
+	nodes, err := client.CoreV1().Nodes().List(api.ListEverything)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := client.CoreV1().PodsWithMultiTenancy(metaV1.NamespaceAll, metaV1.TenantAllExplicit).
+		List(api.ListEverything)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Capacity{}
+	schedulable := make(map[string]bool)
+	var schedulableNodes []v1.Node
+	for _, n := range nodes.Items {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		schedulable[n.Name] = true
+		schedulableNodes = append(schedulableNodes, n)
+
+		result.Nodes++
+		result.CPUCapacity += n.Status.Allocatable.Cpu().MilliValue()
+		result.MemoryCapacity += n.Status.Allocatable.Memory().Value()
+		result.PodCapacity += n.Status.Allocatable.Pods().Value()
+	}
+
+	for _, pod := range pods.Items {
+		if !schedulable[pod.Spec.NodeName] || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		reqs, _, err := node.PodRequestsAndLimits(&pod)
+		if err != nil {
+			return nil, err
+		}
+		cpuRequests, memoryRequests := reqs[v1.ResourceCPU], reqs[v1.ResourceMemory]
+		result.CPURequests += cpuRequests.MilliValue()
+		result.MemoryRequests += memoryRequests.Value()
+		result.Pods++
+	}
+
+	result.CPUUsage, result.MemoryUsage = getNodeUsage(metricClient, schedulableNodes)
+	return result, nil
+}
+
+// getNodeUsage returns the sum of the latest cpu and memory usage of the nodes.
+func getNodeUsage(metricClient metricapi.MetricClient, nodes []v1.Node) (cpu int64, memory int64) {
+	if metricClient == nil || len(nodes) == 0 {
+		return 0, 0
+	}
+
+	selectors := make([]metricapi.ResourceSelector, len(nodes))
+	for i, n := range nodes {
+		selectors[i] = metricapi.ResourceSelector{
+			ResourceType: api.ResourceKindNode,
+			ResourceName: n.Name,
+			UID:          n.UID,
+		}
+	}
+
+	// Metrics are returned for all selectors of the first metric, then for those of the second one.
+	metrics, err := metricClient.DownloadMetrics(selectors, []string{metricapi.CpuUsage, metricapi.MemoryUsage},
+		metricapi.NoResourceCache).GetMetrics()
+	if err != nil {
+		log.Printf("Could not download node usage metrics: %s", err.Error())
+		return 0, 0
+	}
+	if len(metrics) != 2*len(nodes) {
+		log.Printf("Expected %d node usage metrics, got %d", 2*len(nodes), len(metrics))
+		return 0, 0
+	}
+
+	for i := range nodes {
+		cpu += rollup.Latest(metrics[i].DataPoints)
+		memory += rollup.Latest(metrics[len(nodes)+i].DataPoints)
+	}
+	return cpu, memory
+}
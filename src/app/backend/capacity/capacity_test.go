@@ -0,0 +1,175 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/testutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newNode(name string, unschedulable bool, cpu, memory, pods string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metaV1.ObjectMeta{Name: name},
+		Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+			v1.ResourcePods:   resource.MustParse(pods),
+		}},
+	}
+}
+
+// newNodePod returns a pod that is scheduled on the node.
+func newNodePod(tenant, name, nodeName string, phase v1.PodPhase, cpu, memory string) *v1.Pod {
+	pod := testutil.NewPod(tenant, "default", name, phase, cpu, memory)
+	pod.Spec.NodeName = nodeName
+	return pod
+}
+
+func TestCollect(t *testing.T) {
+	client := testutil.NewClientset(
+		newNode("n1", false, "4", "8Ki", "110"),
+		newNode("n2", false, "2", "4Ki", "110"),
+		newNode("n3", true, "8", "16Ki", "110"),
+		newNodePod("t1", "p1", "n1", v1.PodRunning, "500m", "1Ki"),
+		newNodePod("t2", "p2", "n2", v1.PodPending, "1", "2Ki"),
+		newNodePod("t1", "p3", "n1", v1.PodSucceeded, "2", "1Ki"),
+		newNodePod("t1", "p4", "n3", v1.PodRunning, "2", "1Ki"),
+		newNodePod("t1", "p5", "", v1.PodPending, "2", "1Ki"),
+	)
+	metricClient := testutil.MetricClient{Usage: map[string]map[string]int64{
+		"n1": {metricapi.CpuUsage: 300, metricapi.MemoryUsage: 512},
+		"n2": {metricapi.CpuUsage: 200, metricapi.MemoryUsage: 1024},
+		"n3": {metricapi.CpuUsage: 1000, metricapi.MemoryUsage: 1024},
+	}}
+
+	cases := []struct {
+		info         string
+		metricClient metricapi.MetricClient
+		expected     Capacity
+	}{
+		{
+			"capacity of schedulable nodes and requests of their unfinished pods",
+			metricClient,
+			Capacity{Nodes: 2, CPUCapacity: 6000, CPURequests: 1500, CPUUsage: 500, MemoryCapacity: 12 * 1024,
+				MemoryRequests: 3 * 1024, MemoryUsage: 1536, PodCapacity: 220, Pods: 2},
+		},
+		{
+			"usage is empty without metric client",
+			nil,
+			Capacity{Nodes: 2, CPUCapacity: 6000, CPURequests: 1500, MemoryCapacity: 12 * 1024,
+				MemoryRequests: 3 * 1024, PodCapacity: 220, Pods: 2},
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := Collect(client, c.metricClient)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.info, err)
+		}
+		if *actual != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.info, c.expected, *actual)
+		}
+	}
+}
+
+// memoryStore keeps the sums of the samples like the postgres store.
+type memoryStore struct {
+	records map[string]*Record
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[string]*Record)}
+}
+
+func (self *memoryStore) Add(partition string, hour time.Time, s Capacity) error {
+	key := partition + "/" + hour.String()
+	record, exists := self.records[key]
+	if !exists {
+		record = &Record{Partition: partition, Hour: hour}
+		self.records[key] = record
+	}
+	record.Samples++
+	record.Nodes += s.Nodes
+	record.CPUCapacity += s.CPUCapacity
+	record.CPURequests += s.CPURequests
+	record.CPUUsage += s.CPUUsage
+	record.MemoryCapacity += s.MemoryCapacity
+	record.MemoryRequests += s.MemoryRequests
+	record.MemoryUsage += s.MemoryUsage
+	record.PodCapacity += s.PodCapacity
+	record.Pods += s.Pods
+	return nil
+}
+
+func (self *memoryStore) List(partition string, from, to time.Time) ([]Record, error) {
+	result := []Record{}
+	for _, record := range self.records {
+		if record.Partition == partition && !record.Hour.Before(from) && record.Hour.Before(to) {
+			result = append(result, toAverage(*record))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Hour.Before(result[j].Hour)
+	})
+	return result, nil
+}
+
+func TestSamplerSample(t *testing.T) {
+	store := newMemoryStore()
+	// The rollup shares the partitions, so their clients can be replaced between samples.
+	partitions := []rollup.Partition{
+		{Name: "rp1", Client: testutil.NewClientset(newNode("n1", false, "4", "8Ki", "110"),
+			newNodePod("t1", "p1", "n1", v1.PodRunning, "100m", "1Ki"))},
+		{Name: "rp2", Client: testutil.NewClientset(newNode("n1", false, "2", "8Ki", "110")),
+			MetricClient: func() metricapi.MetricClient { return nil }},
+	}
+	sampler := NewSampler(store, partitions)
+
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i, minute := range []int{5, 35, 55, 65} {
+		sampler.Collect(start.Add(time.Duration(minute) * time.Minute))
+		if i == 1 {
+			// Requests of the second half of the first hour are doubled.
+			partitions[0].Client = testutil.NewClientset(newNode("n1", false, "4", "8Ki", "110"),
+				newNodePod("t1", "p1", "n1", v1.PodRunning, "100m", "1Ki"),
+				newNodePod("t1", "p2", "n1", v1.PodRunning, "100m", "1Ki"))
+		}
+	}
+
+	records, _ := store.List("rp1", start, start.Add(2*time.Hour))
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 hourly records, got %+v", records)
+	}
+	if first := records[0]; first.Samples != 3 || first.CPURequests != 133 || first.CPUCapacity != 4000 || !first.Hour.Equal(start) {
+		t.Errorf("Expected averaged record of first hour, got %+v", first)
+	}
+	if second := records[1]; second.Samples != 1 || second.CPURequests != 200 || second.Pods != 2 {
+		t.Errorf("Expected record of second hour, got %+v", second)
+	}
+
+	records, _ = store.List("rp2", start, start.Add(2*time.Hour))
+	if len(records) != 2 || records[0].CPUCapacity != 2000 {
+		t.Errorf("Expected records of second partition, got %+v", records)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"math"
+)
+
+// Forecasting methods.
+const (
+	// MethodLinear fits a least squares line to the history.
+	MethodLinear = "linear"
+	// MethodHoltWinters uses additive triple exponential smoothing with a daily season, which follows
+	// trends and daily load patterns.
+	MethodHoltWinters = "holtwinters"
+)
+
+// Season is the length of the seasonal cycle of the Holt-Winters method in hourly steps.
+const Season = 24
+
+// smoothingFactors are the candidates of the smoothing factors of the Holt-Winters method. The
+// factors that forecast the history best are used.
+var smoothingFactors = []float64{0.05, 0.2, 0.4, 0.6, 0.8}
+
+// forecast returns the values of the hourly series for the next horizon steps and the method that
+// was used. Holt-Winters needs two full seasons of history and falls back to linear otherwise.
+func forecast(series []float64, method string, horizon int) ([]float64, string) {
+	if method == MethodHoltWinters && len(series) >= 2*Season {
+		return holtWinters(series, Season, horizon), MethodHoltWinters
+	}
+	return linear(series, horizon), MethodLinear
+}
+
+// linear returns the values of the least squares line through the series for the next horizon
+// steps.
+func linear(series []float64, horizon int) []float64 {
+	result := make([]float64, horizon)
+	n := float64(len(series))
+	if len(series) == 0 {
+		return result
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range series {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	slope := 0.0
+	if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+		slope = (n*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	for h := range result {
+		result[h] = intercept + slope*(n+float64(h))
+	}
+	return result
+}
+
+// holtWinters returns the additive Holt-Winters forecast of the series for the next horizon steps.
+// The series has to contain at least two seasons.
+func holtWinters(series []float64, season, horizon int) []float64 {
+	var best *holtWintersModel
+	for _, alpha := range smoothingFactors {
+		for _, beta := range smoothingFactors {
+			for _, gamma := range smoothingFactors {
+				model := fitHoltWinters(series, season, alpha, beta, gamma)
+				if best == nil || model.sse < best.sse {
+					best = model
+				}
+			}
+		}
+	}
+
+	result := make([]float64, horizon)
+	for h := range result {
+		result[h] = best.level + float64(h+1)*best.trend + best.seasonals[(len(series)+h)%season]
+	}
+	return result
+}
+
+// holtWintersModel is the state of the Holt-Winters method after the last value of a series.
+type holtWintersModel struct {
+	level     float64
+	trend     float64
+	seasonals []float64
+	// sse is the sum of the squared errors of the one step ahead forecasts of the series.
+	sse float64
+}
+
+// fitHoltWinters smooths the series with the given factors. The trend is initialized from the means
+// of the first and the last full season, the seasonal components from the detrended deviations of
+// all full seasons from their means and the level so that the first value is forecasted.
+func fitHoltWinters(series []float64, season int, alpha, beta, gamma float64) *holtWintersModel {
+	seasons := len(series) / season
+	means := make([]float64, seasons)
+	for j := range means {
+		means[j] = mean(series[j*season : (j+1)*season])
+	}
+
+	center := float64(season-1) / 2
+	trend := (means[seasons-1] - means[0]) / float64((seasons-1)*season)
+	model := &holtWintersModel{
+		level:     means[0] - trend*(center+1),
+		trend:     trend,
+		seasonals: make([]float64, season),
+	}
+	for i := range model.seasonals {
+		for j := 0; j < seasons; j++ {
+			model.seasonals[i] += series[j*season+i] - means[j] - trend*(float64(i)-center)
+		}
+		model.seasonals[i] /= float64(seasons)
+	}
+
+	for t, y := range series {
+		s := model.seasonals[t%season]
+		predicted := model.level + model.trend + s
+		model.sse += (y - predicted) * (y - predicted)
+
+		level := alpha*(y-s) + (1-alpha)*(model.level+model.trend)
+		model.trend = beta*(level-model.level) + (1-beta)*model.trend
+		model.level = level
+		model.seasonals[t%season] = gamma*(y-level) + (1-gamma)*s
+	}
+	return model
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// exhaustion returns the first step of the forecast, counted from 1, whose value reaches the
+// capacity, or 0 if the capacity is not reached.
+func exhaustion(forecast []float64, capacity float64) int {
+	for h, value := range forecast {
+		if value >= capacity {
+			return h + 1
+		}
+	}
+	return 0
+}
+
+// peak returns the highest value of the forecast and the current value, but at least 0.
+func peak(current float64, forecast []float64) float64 {
+	result := math.Max(current, 0)
+	for _, value := range forecast {
+		result = math.Max(result, value)
+	}
+	return result
+}
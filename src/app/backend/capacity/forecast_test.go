@@ -0,0 +1,106 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func TestLinear(t *testing.T) {
+	cases := []struct {
+		info     string
+		series   []float64
+		expected []float64
+	}{
+		{"empty series", []float64{}, []float64{0, 0}},
+		{"single value", []float64{5}, []float64{5, 5}},
+		{"constant", []float64{3, 3, 3}, []float64{3, 3}},
+		{"increasing", []float64{1, 3, 5, 7}, []float64{9, 11}},
+		{"noisy", []float64{1, 2, 1, 2}, []float64{2, 2.2}},
+	}
+
+	for _, c := range cases {
+		actual := linear(c.series, len(c.expected))
+		for i := range c.expected {
+			if !almostEqual(actual[i], c.expected[i], 1e-9) {
+				t.Errorf("%s: expected %v, got %v", c.info, c.expected, actual)
+				break
+			}
+		}
+	}
+}
+
+// dailySeries returns hourly values with a daily cycle on top of a linear trend.
+func dailySeries(days int, base, trend, amplitude float64) []float64 {
+	result := make([]float64, days*Season)
+	for i := range result {
+		result[i] = base + trend*float64(i) + amplitude*math.Sin(2*math.Pi*float64(i%Season)/Season)
+	}
+	return result
+}
+
+func TestHoltWinters(t *testing.T) {
+	series := dailySeries(7, 100, 0.5, 20)
+	expected := dailySeries(9, 100, 0.5, 20)[len(series):]
+
+	actual, method := forecast(series, MethodHoltWinters, len(expected))
+	if method != MethodHoltWinters {
+		t.Fatalf("expected Holt-Winters to be used, got %s", method)
+	}
+	for i := range expected {
+		if !almostEqual(actual[i], expected[i], 2) {
+			t.Fatalf("expected the daily cycle and the trend to be followed at step %d: expected %v, got %v",
+				i+1, expected[i], actual[i])
+		}
+	}
+
+	// The daily peak is missed by the linear forecast.
+	linearForecast, _ := forecast(series, MethodLinear, len(expected))
+	if peak(0, linearForecast) >= peak(0, actual) {
+		t.Errorf("expected linear forecast to stay under the daily peaks")
+	}
+}
+
+func TestHoltWintersFallsBackToLinear(t *testing.T) {
+	series := dailySeries(1, 100, 1, 0)
+	actual, method := forecast(series, MethodHoltWinters, 2)
+	if method != MethodLinear {
+		t.Fatalf("expected linear to be used for less than two seasons, got %s", method)
+	}
+	if !almostEqual(actual[0], 124, 1e-9) || !almostEqual(actual[1], 125, 1e-9) {
+		t.Errorf("expected linear forecast, got %v", actual)
+	}
+}
+
+func TestExhaustion(t *testing.T) {
+	forecast := []float64{1, 5, 10, 20}
+	if step := exhaustion(forecast, 10); step != 3 {
+		t.Errorf("expected exhaustion at step 3, got %d", step)
+	}
+	if step := exhaustion(forecast, 21); step != 0 {
+		t.Errorf("expected no exhaustion, got %d", step)
+	}
+	if p := peak(25, forecast); p != 25 {
+		t.Errorf("expected the current value as peak, got %v", p)
+	}
+	if p := peak(-1, []float64{-5}); p != 0 {
+		t.Errorf("expected the peak not to be negative, got %v", p)
+	}
+}
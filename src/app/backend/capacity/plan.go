@@ -0,0 +1,239 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"math"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+const (
+	// DefaultHorizonDays is the default number of days the capacity is projected for.
+	DefaultHorizonDays = 30
+	// MaxHorizonDays is the longest projection.
+	MaxHorizonDays = 365
+	// History is the time range of the samples the projection is based on.
+	History = 28 * 24 * time.Hour
+	// TargetUtilization is the share of the capacity the projected demand should stay under. The rest
+	// is kept as headroom for spikes and node failures.
+	TargetUtilization = 0.8
+)
+
+// Resources of a partition that are planned.
+const (
+	ResourceCPU    = "cpu"
+	ResourceMemory = "memory"
+	ResourcePods   = "pods"
+)
+
+// Plan is the capacity projection of a resource partition with headroom recommendations.
+type Plan struct {
+	Partition string `json:"partition"`
+	// Method is the forecasting method that was used, which is linear if there was not enough history
+	// for Holt-Winters.
+	Method      string    `json:"method"`
+	HorizonDays int       `json:"horizonDays"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	// Samples is the number of hourly records the projection is based on.
+	Samples           int64   `json:"samples"`
+	TargetUtilization float64 `json:"targetUtilization"`
+
+	// Current is the latest hourly record.
+	Current   Capacity           `json:"current"`
+	Resources []ResourceForecast `json:"resources"`
+
+	// AdditionalNodes is the number of nodes of the average size of the partition that should be
+	// added, so that the projected demand of all resources stays under the target utilization.
+	AdditionalNodes int64 `json:"additionalNodes"`
+	// RemovableNodes is the number of nodes that can be removed to save cost while the projected
+	// demand of all resources stays under the target utilization.
+	RemovableNodes int64 `json:"removableNodes"`
+
+	// NodeCost is the monthly cost of a node, which is 0 if no cost is configured.
+	NodeCost float64 `json:"nodeCost"`
+	// CurrentCost is the monthly cost of the current nodes.
+	CurrentCost float64 `json:"currentCost"`
+	// CostChange is the change of the monthly cost if the recommended nodes are added or removed. It
+	// is negative if nodes can be removed.
+	CostChange float64 `json:"costChange"`
+}
+
+// PlanList contains the capacity plans of resource partitions.
+type PlanList struct {
+	Plans []Plan `json:"plans"`
+}
+
+// ResourceForecast is the projection of a single resource. The demand is the larger one of the
+// requests and the actual usage, CPU is in millicores, memory in bytes.
+type ResourceForecast struct {
+	Resource string `json:"resource"`
+	Capacity int64  `json:"capacity"`
+	Demand   int64  `json:"demand"`
+	Headroom int64  `json:"headroom"`
+	// Projected is the peak demand within the horizon.
+	Projected int64 `json:"projected"`
+	// ExhaustionTime is the hour the demand is projected to reach the capacity, or nil if it is not
+	// reached within the horizon.
+	ExhaustionTime  *time.Time `json:"exhaustionTime"`
+	AdditionalNodes int64      `json:"additionalNodes"`
+	RemovableNodes  int64      `json:"removableNodes"`
+}
+
+// resourceSeries returns the capacity and the demand of a resource.
+var resourceSeries = []struct {
+	resource string
+	capacity func(Capacity) int64
+	demand   func(Capacity) int64
+}{
+	{ResourceCPU,
+		func(c Capacity) int64 { return c.CPUCapacity },
+		func(c Capacity) int64 { return max(c.CPURequests, c.CPUUsage) }},
+	{ResourceMemory,
+		func(c Capacity) int64 { return c.MemoryCapacity },
+		func(c Capacity) int64 { return max(c.MemoryRequests, c.MemoryUsage) }},
+	{ResourcePods,
+		func(c Capacity) int64 { return c.PodCapacity },
+		func(c Capacity) int64 { return c.Pods }},
+}
+
+// GetPlan projects the capacity of the partition for the given number of days from the history
+// before now. The cost impact of the recommendations is reported with the monthly cost of a node.
+func GetPlan(store Store, partition, method string, horizonDays int, nodeCost float64, now time.Time) (*Plan, error) {
+	if method != MethodLinear && method != MethodHoltWinters {
+		return nil, errors.NewInvalid("method must be " + MethodLinear + " or " + MethodHoltWinters)
+	}
+	if horizonDays < 1 || horizonDays > MaxHorizonDays {
+		return nil, errors.NewInvalid("horizon must be between 1 and 365 days")
+	}
+	if nodeCost < 0 {
+		return nil, errors.NewInvalid("node cost must not be negative")
+	}
+
+	to := now.UTC().Truncate(time.Hour).Add(time.Hour)
+	from := to.Add(-History)
+	records, err := store.List(partition, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Plan{
+		Partition:         partition,
+		Method:            method,
+		HorizonDays:       horizonDays,
+		From:              from,
+		To:                to,
+		Samples:           int64(len(records)),
+		TargetUtilization: TargetUtilization,
+		Resources:         make([]ResourceForecast, 0),
+		NodeCost:          nodeCost,
+	}
+	if len(records) == 0 {
+		return result, nil
+	}
+
+	hourly := fillGaps(records)
+	last := hourly[len(hourly)-1]
+	result.Current = last.Capacity
+
+	horizon := horizonDays * 24
+	for _, s := range resourceSeries {
+		series := make([]float64, len(hourly))
+		for i, record := range hourly {
+			series[i] = float64(s.demand(record.Capacity))
+		}
+
+		values, used := forecast(series, method, horizon)
+		result.Method = used
+		r := ResourceForecast{
+			Resource:  s.resource,
+			Capacity:  s.capacity(last.Capacity),
+			Demand:    s.demand(last.Capacity),
+			Projected: int64(math.Ceil(peak(series[len(series)-1], values))),
+		}
+		r.Headroom = r.Capacity - r.Demand
+		if r.Demand >= r.Capacity {
+			exhausted := last.Hour
+			r.ExhaustionTime = &exhausted
+		} else if step := exhaustion(values, float64(r.Capacity)); step > 0 {
+			exhausted := last.Hour.Add(time.Duration(step) * time.Hour)
+			r.ExhaustionTime = &exhausted
+		}
+		r.AdditionalNodes, r.RemovableNodes = recommendNodes(r.Capacity, r.Projected, last.Nodes)
+		result.Resources = append(result.Resources, r)
+	}
+
+	// Nodes can only be removed if no resource needs more of them.
+	result.RemovableNodes = math.MaxInt64
+	for _, r := range result.Resources {
+		result.AdditionalNodes = max(result.AdditionalNodes, r.AdditionalNodes)
+		result.RemovableNodes = min(result.RemovableNodes, r.RemovableNodes)
+	}
+	if result.AdditionalNodes > 0 {
+		result.RemovableNodes = 0
+	}
+	result.CurrentCost = float64(last.Nodes) * nodeCost
+	result.CostChange = float64(result.AdditionalNodes-result.RemovableNodes) * nodeCost
+	return result, nil
+}
+
+// fillGaps returns a record for every hour between the first and the last record. Missing hours
+// repeat the previous record.
+func fillGaps(records []Record) []Record {
+	result := []Record{records[0]}
+	for _, record := range records[1:] {
+		previous := result[len(result)-1]
+		for hour := previous.Hour.Add(time.Hour); hour.Before(record.Hour); hour = hour.Add(time.Hour) {
+			filled := previous
+			filled.Hour = hour
+			result = append(result, filled)
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+// recommendNodes returns the number of nodes of average size to add or to remove, so that the
+// projected demand is as close as possible to, but not above, the target utilization of the
+// capacity. At least one node is kept.
+func recommendNodes(capacity, projected, nodes int64) (additional int64, removable int64) {
+	if nodes <= 0 || capacity <= 0 {
+		return 0, 0
+	}
+
+	nodeSize := float64(capacity) / float64(nodes)
+	needed := float64(projected)/TargetUtilization - float64(capacity)
+	if needed > 0 {
+		return int64(math.Ceil(needed / nodeSize)), 0
+	}
+	removable = int64(math.Floor(-needed / nodeSize))
+	return 0, min(removable, nodes-1)
+}
+
+func max(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"testing"
+	"time"
+)
+
+// addHours adds a record of a partition with 10 nodes for each of the given number of hours before
+// end. The CPU requests grow by cpuGrowth per hour.
+func addHours(store *memoryStore, partition string, end time.Time, hours int, cpuRequests, cpuGrowth int64) {
+	for i := 0; i < hours; i++ {
+		store.Add(partition, end.Add(time.Duration(i-hours)*time.Hour), Capacity{
+			Nodes:          10,
+			CPUCapacity:    10000,
+			CPURequests:    cpuRequests + int64(i)*cpuGrowth,
+			MemoryCapacity: 10000,
+			MemoryRequests: 500,
+			MemoryUsage:    1000,
+			PodCapacity:    1100,
+			Pods:           10,
+		})
+	}
+}
+
+func TestGetPlan(t *testing.T) {
+	now := time.Date(2020, 6, 10, 12, 30, 0, 0, time.UTC)
+	end := time.Date(2020, 6, 10, 13, 0, 0, 0, time.UTC)
+	store := newMemoryStore()
+	addHours(store, "growing", end, 48, 5000, 7)
+	addHours(store, "idle", end, 48, 2000, 0)
+
+	plan, err := GetPlan(store, "growing", MethodLinear, DefaultHorizonDays, 100, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if plan.Samples != 48 || !plan.To.Equal(end) || !plan.From.Equal(end.Add(-History)) || plan.Method != MethodLinear {
+		t.Errorf("unexpected plan range: %+v", plan)
+	}
+	if len(plan.Resources) != 3 {
+		t.Fatalf("expected forecasts of 3 resources, got %+v", plan.Resources)
+	}
+
+	cpu := plan.Resources[0]
+	if cpu.Resource != ResourceCPU || cpu.Demand != 5329 || cpu.Headroom != 4671 {
+		t.Errorf("unexpected CPU demand: %+v", cpu)
+	}
+	if cpu.Projected < 10369 || cpu.Projected > 10370 {
+		t.Errorf("expected projected CPU demand of 10369, got %d", cpu.Projected)
+	}
+	last := end.Add(-time.Hour)
+	if cpu.ExhaustionTime == nil || !cpu.ExhaustionTime.Equal(last.Add(668*time.Hour)) {
+		t.Errorf("expected CPU exhaustion after 668 hours, got %v", cpu.ExhaustionTime)
+	}
+	if cpu.AdditionalNodes != 3 || cpu.RemovableNodes != 0 {
+		t.Errorf("expected 3 additional nodes for CPU, got %+v", cpu)
+	}
+
+	memory := plan.Resources[1]
+	if memory.Demand != 1000 || memory.ExhaustionTime != nil || memory.RemovableNodes != 8 {
+		t.Errorf("expected memory usage as demand and 8 removable nodes, got %+v", memory)
+	}
+	pods := plan.Resources[2]
+	if pods.Demand != 10 || pods.RemovableNodes != 9 {
+		t.Errorf("expected at least one node to be kept, got %+v", pods)
+	}
+	if plan.AdditionalNodes != 3 || plan.RemovableNodes != 0 {
+		t.Errorf("expected nodes not to be removed while CPU needs more, got %+v", plan)
+	}
+	if plan.NodeCost != 100 || plan.CurrentCost != 1000 || plan.CostChange != 300 {
+		t.Errorf("expected the cost of 3 additional nodes, got %+v", plan)
+	}
+
+	plan, err = GetPlan(store, "idle", MethodHoltWinters, 7, 100, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if plan.Method != MethodHoltWinters || plan.AdditionalNodes != 0 || plan.RemovableNodes != 7 {
+		t.Errorf("expected 7 removable nodes, got %+v", plan)
+	}
+	if plan.CurrentCost != 1000 || plan.CostChange != -700 {
+		t.Errorf("expected the savings of 7 removable nodes, got %+v", plan)
+	}
+	for _, r := range plan.Resources {
+		if r.ExhaustionTime != nil {
+			t.Errorf("expected %s not to be exhausted, got %v", r.Resource, r.ExhaustionTime)
+		}
+	}
+
+	plan, err = GetPlan(store, "unknown", MethodLinear, 7, 0, now)
+	if err != nil || plan.Samples != 0 || len(plan.Resources) != 0 {
+		t.Errorf("expected empty plan without history, got %+v, %v", plan, err)
+	}
+}
+
+func TestGetPlanInvalid(t *testing.T) {
+	cases := []struct {
+		method      string
+		horizonDays int
+		nodeCost    float64
+	}{
+		{"arima", 30, 0},
+		{MethodLinear, 0, 0},
+		{MethodLinear, MaxHorizonDays + 1, 0},
+		{MethodLinear, 30, -1},
+	}
+
+	for _, c := range cases {
+		if _, err := GetPlan(newMemoryStore(), "rp", c.method, c.horizonDays, c.nodeCost, time.Now()); err == nil {
+			t.Errorf("expected error for method %s, horizon %d and node cost %v", c.method, c.horizonDays,
+				c.nodeCost)
+		}
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Hour: start, Capacity: Capacity{Pods: 1}},
+		{Hour: start.Add(3 * time.Hour), Capacity: Capacity{Pods: 4}},
+		{Hour: start.Add(4 * time.Hour), Capacity: Capacity{Pods: 5}},
+	}
+
+	actual := fillGaps(records)
+	expected := []int64{1, 1, 1, 4, 5}
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d hourly records, got %+v", len(expected), actual)
+	}
+	for i, record := range actual {
+		if !record.Hour.Equal(start.Add(time.Duration(i)*time.Hour)) || record.Pods != expected[i] {
+			t.Errorf("unexpected record %d: %+v", i, record)
+		}
+	}
+}
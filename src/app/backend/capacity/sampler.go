@@ -0,0 +1,38 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+)
+
+// NewSampler creates a rollup that samples the capacity of the resource partitions every
+// rollup.DefaultInterval and stores it as hourly records.
+func NewSampler(store Store, partitions []rollup.Partition) *rollup.Rollup {
+	return rollup.New("capacity", partitions, sampleInto(store))
+}
+
+// sampleInto returns a function that samples the capacity of a partition and adds it to the store.
+func sampleInto(store Store) rollup.CollectFunc {
+	return func(partition rollup.Partition, hour time.Time) error {
+		sample, err := Collect(partition.Client, partition.Metrics())
+		if err != nil {
+			return err
+		}
+		return store.Add(partition.Name, hour, *sample)
+	}
+}
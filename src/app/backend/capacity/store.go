@@ -0,0 +1,141 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capacity
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+)
+
+// Record is the hourly rollup of the capacity of a resource partition. The capacity is the average
+// of the samples collected within the hour.
+type Record struct {
+	Partition string    `json:"partition"`
+	Hour      time.Time `json:"hour"`
+	Samples   int64     `json:"samples"`
+	Capacity
+}
+
+// Store persists hourly capacity rollups.
+type Store interface {
+	// Add merges the sample of the partition into the record of the given hour.
+	Add(partition string, hour time.Time, sample Capacity) error
+	// List returns the records of the partition within [from, to), ordered by hour.
+	List(partition string, from, to time.Time) ([]Record, error)
+}
+
+// The table keeps the sums of the samples of every hour, the same way as the tenant usage table.
+const createTableStatement = `CREATE TABLE IF NOT EXISTS partitioncapacity (
+	partition TEXT NOT NULL,
+	hour TIMESTAMP NOT NULL,
+	samples BIGINT NOT NULL,
+	nodes BIGINT NOT NULL,
+	cpucapacity BIGINT NOT NULL,
+	cpurequests BIGINT NOT NULL,
+	cpuusage BIGINT NOT NULL,
+	memorycapacity BIGINT NOT NULL,
+	memoryrequests BIGINT NOT NULL,
+	memoryusage BIGINT NOT NULL,
+	podcapacity BIGINT NOT NULL,
+	pods BIGINT NOT NULL,
+	PRIMARY KEY (partition, hour));`
+
+const upsertStatement = `INSERT INTO partitioncapacity (partition, hour, samples, nodes, cpucapacity,
+	cpurequests, cpuusage, memorycapacity, memoryrequests, memoryusage, podcapacity, pods)
+	VALUES ($1, $2, 1, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (partition, hour) DO UPDATE SET
+	samples = partitioncapacity.samples + 1,
+	nodes = partitioncapacity.nodes + EXCLUDED.nodes,
+	cpucapacity = partitioncapacity.cpucapacity + EXCLUDED.cpucapacity,
+	cpurequests = partitioncapacity.cpurequests + EXCLUDED.cpurequests,
+	cpuusage = partitioncapacity.cpuusage + EXCLUDED.cpuusage,
+	memorycapacity = partitioncapacity.memorycapacity + EXCLUDED.memorycapacity,
+	memoryrequests = partitioncapacity.memoryrequests + EXCLUDED.memoryrequests,
+	memoryusage = partitioncapacity.memoryusage + EXCLUDED.memoryusage,
+	podcapacity = partitioncapacity.podcapacity + EXCLUDED.podcapacity,
+	pods = partitioncapacity.pods + EXCLUDED.pods;`
+
+const selectStatement = `SELECT partition, hour, samples, nodes, cpucapacity, cpurequests, cpuusage,
+	memorycapacity, memoryrequests, memoryusage, podcapacity, pods FROM partitioncapacity
+	WHERE partition = $1 AND hour >= $2 AND hour < $3 ORDER BY hour;`
+
+// Implements Store interface backed by postgres.
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the capacity table if it does not exist yet and returns a store backed
+// by it.
+func NewPostgresStore(db *sql.DB) (Store, error) {
+	if _, err := db.Exec(createTableStatement); err != nil {
+		return nil, err
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// Add implements Store interface. See Store for more information.
+func (self *postgresStore) Add(partition string, hour time.Time, s Capacity) error {
+	defer instrumentation.ObserveDBQuery("add_capacity", time.Now())
+	_, err := self.db.Exec(upsertStatement, partition, hour.UTC(), s.Nodes, s.CPUCapacity, s.CPURequests,
+		s.CPUUsage, s.MemoryCapacity, s.MemoryRequests, s.MemoryUsage, s.PodCapacity, s.Pods)
+	return err
+}
+
+// List implements Store interface. See Store for more information.
+func (self *postgresStore) List(partition string, from, to time.Time) ([]Record, error) {
+	defer instrumentation.ObserveDBQuery("list_capacity", time.Now())
+	rows, err := self.db.Query(selectStatement, partition, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Record, 0)
+	for rows.Next() {
+		var r Record
+		err := rows.Scan(&r.Partition, &r.Hour, &r.Samples, &r.Nodes, &r.CPUCapacity, &r.CPURequests,
+			&r.CPUUsage, &r.MemoryCapacity, &r.MemoryRequests, &r.MemoryUsage, &r.PodCapacity, &r.Pods)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, toAverage(r))
+	}
+	return result, rows.Err()
+}
+
+// toAverage turns a record with the sums of its samples into a record with their rounded averages.
+func toAverage(record Record) Record {
+	if record.Samples <= 0 {
+		return record
+	}
+
+	n := record.Samples
+	record.Capacity = Capacity{
+		Nodes:          rollup.Average(record.Nodes, n),
+		CPUCapacity:    rollup.Average(record.CPUCapacity, n),
+		CPURequests:    rollup.Average(record.CPURequests, n),
+		CPUUsage:       rollup.Average(record.CPUUsage, n),
+		MemoryCapacity: rollup.Average(record.MemoryCapacity, n),
+		MemoryRequests: rollup.Average(record.MemoryRequests, n),
+		MemoryUsage:    rollup.Average(record.MemoryUsage, n),
+		PodCapacity:    rollup.Average(record.PodCapacity, n),
+		Pods:           rollup.Average(record.Pods, n),
+	}
+	record.Hour = record.Hour.UTC()
+	return record
+}
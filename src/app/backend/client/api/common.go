@@ -34,6 +34,15 @@ func ToSelfSubjectAccessReview(namespace, name, resource, verb string) *v1.SelfS
 	}
 }
 
+// ToTenantSelfSubjectAccessReview creates kubernetes API object for a resource or subresource in the
+// given tenant.
+func ToTenantSelfSubjectAccessReview(tenant, namespace, name, resource, subresource, verb string) *v1.SelfSubjectAccessReview {
+	ssar := ToSelfSubjectAccessReview(namespace, name, resource, verb)
+	ssar.Spec.ResourceAttributes.Tenant = tenant
+	ssar.Spec.ResourceAttributes.Subresource = subresource
+	return ssar
+}
+
 // GenerateCSRFKey generates random csrf key
 func GenerateCSRFKey() string {
 	bytes := make([]byte, 256)
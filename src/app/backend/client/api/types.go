@@ -21,7 +21,9 @@ import (
 	restful "github.com/emicklei/go-restful"
 	v1 "k8s.io/api/authorization/v1"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -44,11 +46,13 @@ type ClientManager interface {
 	PluginClient(req *restful.Request) (pluginclientset.Interface, error)
 	InsecureAPIExtensionsClient() apiextensionsclientset.Interface
 	CanI(req *restful.Request, ssar *v1.SelfSubjectAccessReview) bool
+	Identity(req *restful.Request) (string, error)
 	Config(req *restful.Request) (*rest.Config, error)
 	ClientCmdConfig(req *restful.Request) (clientcmd.ClientConfig, error)
 	CSRFKey() string
 	HasAccess(authInfo api.AuthInfo) error
 	VerberClient(req *restful.Request, config *rest.Config) (ResourceVerber, error)
+	RESTMapper() meta.RESTMapper
 	SetTokenManager(manager authApi.TokenManager)
 	GetTenant(authInfo api.AuthInfo, nameSpace string, tenant string) (string, error)
 	GetClusterName() string
@@ -64,6 +68,12 @@ type ResourceVerber interface {
 	GetWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string, name string) (runtime.Object, error)
 	Delete(kind string, namespaceSet bool, namespace string, name string) error
 	DeleteWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string, name string) error
+	Patch(kind string, namespaceSet bool, namespace string, name string, patchType types.PatchType,
+		data []byte) (runtime.Object, error)
+	PatchWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string, name string,
+		patchType types.PatchType, data []byte) (runtime.Object, error)
+	List(kind string, namespaceSet bool, namespace string, labelSelector string) (runtime.Object, error)
+	ListWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string, labelSelector string) (runtime.Object, error)
 }
 
 // CanIResponse is used to as response to check whether or not user is allowed to access given endpoint.
@@ -16,28 +16,38 @@
 package client
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	pluginclientset "github.com/CentaurusInfra/dashboard/src/app/backend/plugin/client/clientset/versioned"
 	restful "github.com/emicklei/go-restful"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/authorization/v1"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/transport"
 
 	"github.com/CentaurusInfra/dashboard/src/app/backend/args"
 	authApi "github.com/CentaurusInfra/dashboard/src/app/backend/auth/api"
 	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/client/csrf"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/tracing"
 )
 
 // Dashboard UI default values for client configs.
@@ -91,6 +101,10 @@ type clientManager struct {
 	// init.
 	insecureConfig *rest.Config
 	clustername    string
+	// Maps kinds to resources of the cluster. Created on first use with the insecure client, because
+	// discovery information is not user specific, and shared by all requests.
+	restMapper     meta.RESTMapper
+	restMapperOnce sync.Once
 }
 
 func (self *clientManager) GetClusterName() string {
@@ -101,21 +115,47 @@ func (self *clientManager) GetClusterName() string {
 // login page is disabled only secure client will be returned, otherwise insecure client will be
 // used.
 func (self *clientManager) Client(req *restful.Request) (kubernetes.Interface, error) {
+	defer self.startSpan(req, "Client").End()
+
 	if req == nil {
 		return nil, errors.NewBadRequest("request can not be nil")
 	}
 
+	ctx := tracing.RequestContext(req)
+	cfg, client := self.insecureConfig, self.InsecureClient()
 	if self.isSecureModeEnabled(req) {
-		return self.secureClient(req)
+		var err error
+		if cfg, err = self.clientConfig(req); err != nil {
+			return nil, err
+		}
+		if !tracing.Enabled(ctx) {
+			return kubernetes.NewForConfig(cfg)
+		}
+		if client, err = self.newTracedClient(cfg, ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	return self.InsecureClient(), nil
+	return tracing.WithContext(client, ctx, func(ctx context.Context) (kubernetes.Interface, error) {
+		return self.newTracedClient(cfg, ctx)
+	}), nil
+}
+
+// newTracedClient creates a kubernetes client for the config whose calls to the apiserver are
+// children of the span of the context.
+func (self *clientManager) newTracedClient(cfg *rest.Config, ctx context.Context) (kubernetes.Interface, error) {
+	traced := rest.CopyConfigs(cfg)
+	traced.GetConfig().WrapTransport = transport.Wrappers(traced.GetConfig().WrapTransport,
+		tracing.WrapTransport(ctx))
+	return kubernetes.NewForConfig(traced)
 }
 
 // APIExtensionsClient returns an API Extensions client. In case dashboard login is enabled and
 // option to skip login page is disabled only secure client will be returned, otherwise insecure
 // client will be used.
 func (self *clientManager) APIExtensionsClient(req *restful.Request) (apiextensionsclientset.Interface, error) {
+	defer self.startSpan(req, "APIExtensionsClient").End()
+
 	if req == nil {
 		return nil, errors.NewBadRequest("request can not be nil!")
 	}
@@ -131,6 +171,8 @@ func (self *clientManager) APIExtensionsClient(req *restful.Request) (apiextensi
 // option to skip login page is disabled only secure client will be returned, otherwise insecure
 // client will be used.
 func (self *clientManager) PluginClient(req *restful.Request) (pluginclientset.Interface, error) {
+	defer self.startSpan(req, "PluginClient").End()
+
 	if req == nil {
 		return nil, errors.NewBadRequest("request can not be nil!")
 	}
@@ -146,6 +188,8 @@ func (self *clientManager) PluginClient(req *restful.Request) (pluginclientset.I
 // login page is disabled only secure config will be returned, otherwise insecure config will be
 // used.
 func (self *clientManager) Config(req *restful.Request) (*rest.Config, error) {
+	defer self.startSpan(req, "Config").End()
+
 	if req == nil {
 		return nil, errors.NewBadRequest("request can not be nil")
 	}
@@ -186,6 +230,8 @@ func (self *clientManager) InsecureConfig() *rest.Config {
 
 // CanI returns true when user is allowed to access data provided within SelfSubjectAccessReview, false otherwise.
 func (self *clientManager) CanI(req *restful.Request, ssar *v1.SelfSubjectAccessReview) bool {
+	defer self.startSpan(req, "CanI").End()
+
 	// In case user is not authenticated (uses skip option) do not allow access.
 	info, _ := self.extractAuthInfo(req)
 	if info == nil && len(args.Holder.GetCertFile()) > 0 && len(args.Holder.GetKeyFile()) > 0 {
@@ -207,6 +253,31 @@ func (self *clientManager) CanI(req *restful.Request, ssar *v1.SelfSubjectAccess
 	return response.Status.Allowed
 }
 
+// Identity returns an opaque identifier of the credentials used by the request. Requests made with
+// the same token or username share the identity, so it can be used to bind server side state like
+// background jobs to the user that created it. Requests without auth info share an empty identity.
+func (self *clientManager) Identity(req *restful.Request) (string, error) {
+	if !self.containsAuthInfo(req) {
+		return "", nil
+	}
+
+	authInfo, err := self.extractAuthInfo(req)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if len(authInfo.Token) > 0 {
+		fmt.Fprintf(hash, "token:%s\n", authInfo.Token)
+	} else {
+		fmt.Fprintf(hash, "user:%s\n", authInfo.Username)
+	}
+	if len(authInfo.Impersonate) > 0 {
+		fmt.Fprintf(hash, "impersonate:%s\n", authInfo.Impersonate)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 // ClientCmdConfig creates ClientCmd Config based on authentication information extracted from request.
 // Currently request header is only checked for existence of 'Authentication: BearerToken'
 func (self *clientManager) ClientCmdConfig(req *restful.Request) (clientcmd.ClientConfig, error) {
@@ -284,6 +355,8 @@ func (self *clientManager) HasAccess(authInfo api.AuthInfo) error {
 
 // VerberClient returns new verber client based on authentication information extracted from request
 func (self *clientManager) VerberClient(req *restful.Request, config *rest.Config) (clientapi.ResourceVerber, error) {
+	defer self.startSpan(req, "VerberClient").End()
+
 	k8sClient, err := self.Client(req)
 	if err != nil {
 		return nil, err
@@ -297,7 +370,23 @@ func (self *clientManager) VerberClient(req *restful.Request, config *rest.Confi
 	return NewResourceVerber(k8sClient.CoreV1().RESTClient(),
 		k8sClient.ExtensionsV1beta1().RESTClient(), k8sClient.AppsV1().RESTClient(),
 		k8sClient.BatchV1().RESTClient(), k8sClient.BatchV1beta1().RESTClient(), k8sClient.AutoscalingV1().RESTClient(),
-		k8sClient.StorageV1().RESTClient(), k8sClient.RbacV1().RESTClient(), apiextensionsclient.ApiextensionsV1beta1().RESTClient(), config), nil
+		k8sClient.StorageV1().RESTClient(), k8sClient.RbacV1().RESTClient(), apiextensionsclient.ApiextensionsV1beta1().RESTClient(), config,
+		self.RESTMapper()), nil
+}
+
+// RESTMapper returns the cached RESTMapper of the cluster this client manager connects to.
+func (self *clientManager) RESTMapper() meta.RESTMapper {
+	self.restMapperOnce.Do(func() {
+		self.restMapper = NewRESTMapper(self.insecureClient.Discovery())
+	})
+	return self.restMapper
+}
+
+// startSpan starts the span of a call to the client manager as a child of the span of the request.
+func (self *clientManager) startSpan(req *restful.Request, name string) trace.Span {
+	_, span := tracing.StartSpan(tracing.RequestContext(req), "ClientManager."+name,
+		attribute.String("dashboard.partition", self.clustername))
+	return span
 }
 
 // SetTokenManager sets the token manager that will be used for token decryption.
@@ -311,6 +400,9 @@ func (self *clientManager) initConfig(cfg *rest.Config) {
 	cfg.GetConfig().Burst = DefaultBurst
 	cfg.GetConfig().ContentType = DefaultContentType
 	cfg.GetConfig().UserAgent = DefaultUserAgent + "/" + Version
+	// Records the latency and the errors of the calls to the apiserver of the partition.
+	cfg.GetConfig().WrapTransport = transport.Wrappers(cfg.GetConfig().WrapTransport,
+		instrumentation.InstrumentTransport(self.clustername))
 }
 
 // Returns rest Config based on provided apiserverHost and kubeConfigPath flags. If both are
@@ -424,14 +516,13 @@ func (self *clientManager) isSecureModeEnabled(req *restful.Request) bool {
 	return self.isLoginEnabled(req) && args.Holder.GetEnableSkipLogin() && self.containsAuthInfo(req)
 }
 
-func (self *clientManager) secureClient(req *restful.Request) (kubernetes.Interface, error) {
+func (self *clientManager) secureAPIExtensionsClient(req *restful.Request) (apiextensionsclientset.Interface, error) {
 	cfg, err := self.secureConfig(req)
 	if err != nil {
 		return nil, err
-
 	}
 
-	client, err := kubernetes.NewForConfig(cfg)
+	client, err := apiextensionsclientset.NewForConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -439,13 +530,13 @@ func (self *clientManager) secureClient(req *restful.Request) (kubernetes.Interf
 	return client, nil
 }
 
-func (self *clientManager) secureAPIExtensionsClient(req *restful.Request) (apiextensionsclientset.Interface, error) {
+func (self *clientManager) securePluginClient(req *restful.Request) (pluginclientset.Interface, error) {
 	cfg, err := self.secureConfig(req)
 	if err != nil {
 		return nil, err
 	}
 
-	client, err := apiextensionsclientset.NewForConfig(cfg)
+	client, err := pluginclientset.NewForConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -453,21 +544,21 @@ func (self *clientManager) secureAPIExtensionsClient(req *restful.Request) (apie
 	return client, nil
 }
 
-func (self *clientManager) securePluginClient(req *restful.Request) (pluginclientset.Interface, error) {
-	cfg, err := self.secureConfig(req)
-	if err != nil {
-		return nil, err
-	}
-
-	client, err := pluginclientset.NewForConfig(cfg)
+func (self *clientManager) secureConfig(req *restful.Request) (*rest.Config, error) {
+	cfg, err := self.clientConfig(req)
 	if err != nil {
 		return nil, err
 	}
 
-	return client, nil
+	// Traces the calls to the apiserver as part of the request.
+	cfg.GetConfig().WrapTransport = transport.Wrappers(cfg.GetConfig().WrapTransport,
+		tracing.WrapTransport(tracing.RequestContext(req)))
+	return cfg, nil
 }
 
-func (self *clientManager) secureConfig(req *restful.Request) (*rest.Config, error) {
+// clientConfig returns the config of the user of the request without tracing the calls to the
+// apiserver.
+func (self *clientManager) clientConfig(req *restful.Request) (*rest.Config, error) {
 	cmdConfig, err := self.ClientCmdConfig(req)
 	if err != nil {
 		log.Println("ClientCmdConfig Failed")
@@ -193,6 +193,32 @@ func TestSecureAPIExtensionsClient(t *testing.T) {
 	}
 }
 
+func TestIdentity(t *testing.T) {
+	newRequest := func(header map[string][]string) *restful.Request {
+		return &restful.Request{Request: &http.Request{Header: http.Header(header)}}
+	}
+	manager := &clientManager{}
+
+	anonymous, err := manager.Identity(newRequest(map[string][]string{}))
+	if err != nil || anonymous != "" {
+		t.Fatalf("Identity() of anonymous request == %q, %v, expected empty identity", anonymous, err)
+	}
+
+	first, _ := manager.Identity(newRequest(map[string][]string{"Authorization": {"Bearer token-1"}}))
+	again, _ := manager.Identity(newRequest(map[string][]string{"Authorization": {"Bearer token-1"}}))
+	other, _ := manager.Identity(newRequest(map[string][]string{"Authorization": {"Bearer token-2"}}))
+	impersonated, _ := manager.Identity(newRequest(map[string][]string{"Authorization": {"Bearer token-1"},
+		"Impersonate-User": {"alice"}}))
+
+	if len(first) == 0 || first != again {
+		t.Errorf("expected same non-empty identity for the same token, got %q and %q", first, again)
+	}
+	if first == other || first == impersonated {
+		t.Errorf("expected different identities for different credentials, got %q, %q and %q", first, other,
+			impersonated)
+	}
+}
+
 func TestCSRFKey(t *testing.T) {
 	manager := NewClientManager("", "http://localhost:8080")
 	key := manager.CSRFKey()
@@ -0,0 +1,86 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// DefaultRESTMapperResetInterval is the minimum time between two refreshes of the discovery cache
+// caused by unknown kinds.
+const DefaultRESTMapperResetInterval = 10 * time.Second
+
+// discoveryRESTMapper maps kinds to resources with discovery information that is cached in memory.
+// Discovery is deferred until the first mapping is requested. When a kind is not known, the cache is
+// refreshed, so that resources of CRDs installed after startup can be found. Refreshes are rate
+// limited, so that requests for kinds that do not exist do not hammer the API server.
+type discoveryRESTMapper struct {
+	*restmapper.DeferredDiscoveryRESTMapper
+
+	resetInterval time.Duration
+	lastReset     time.Time
+	lock          sync.Mutex
+}
+
+// NewRESTMapper creates a RESTMapper with cached deferred discovery for the given discovery client.
+func NewRESTMapper(client discovery.DiscoveryInterface) meta.RESTMapper {
+	return &discoveryRESTMapper{
+		DeferredDiscoveryRESTMapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(client)),
+		resetInterval:               DefaultRESTMapperResetInterval,
+		lastReset:                   time.Now(),
+	}
+}
+
+// KindFor implements meta.RESTMapper.
+func (self *discoveryRESTMapper) KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	gvk, err := self.DeferredDiscoveryRESTMapper.KindFor(resource)
+	if meta.IsNoMatchError(err) && self.reset() {
+		return self.DeferredDiscoveryRESTMapper.KindFor(resource)
+	}
+	return gvk, err
+}
+
+// RESTMapping implements meta.RESTMapper.
+func (self *discoveryRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	mapping, err := self.DeferredDiscoveryRESTMapper.RESTMapping(gk, versions...)
+	if meta.IsNoMatchError(err) && self.reset() {
+		return self.DeferredDiscoveryRESTMapper.RESTMapping(gk, versions...)
+	}
+	return mapping, err
+}
+
+// reset invalidates the discovery cache unless it was refreshed recently. It returns true if the
+// cache has been invalidated.
+func (self *discoveryRESTMapper) reset() bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if time.Since(self.lastReset) < self.resetInterval {
+		return false
+	}
+
+	log.Print("Unknown kind requested, refreshing discovery information")
+	self.lastReset = time.Now()
+	self.DeferredDiscoveryRESTMapper.Reset()
+	return true
+}
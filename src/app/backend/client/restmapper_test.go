@@ -0,0 +1,83 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRESTMapper(t *testing.T) {
+	discovery := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metaV1.APIResourceList{
+		{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metaV1.APIResource{
+				{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true},
+			},
+		},
+		{
+			GroupVersion: "storage.k8s.io/v1",
+			APIResources: []metaV1.APIResource{
+				{Name: "storageclasses", Kind: "StorageClass", Namespaced: false},
+			},
+		},
+	}
+	mapper := NewRESTMapper(discovery)
+
+	cases := []struct {
+		gk       schema.GroupKind
+		resource string
+		scope    meta.RESTScopeName
+	}{
+		{schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}, "networkpolicies", meta.RESTScopeNameNamespace},
+		{schema.GroupKind{Group: "storage.k8s.io", Kind: "StorageClass"}, "storageclasses", meta.RESTScopeNameRoot},
+	}
+	for _, c := range cases {
+		mapping, err := mapper.RESTMapping(c.gk)
+		if err != nil {
+			t.Fatalf("RESTMapping(%v) returned unexpected error: %v", c.gk, err)
+		}
+		if mapping.Resource.Resource != c.resource || mapping.Scope.Name() != c.scope {
+			t.Errorf("RESTMapping(%v) == %s %s, expected %s %s", c.gk, mapping.Resource.Resource,
+				mapping.Scope.Name(), c.resource, c.scope)
+		}
+	}
+
+	// A CRD is installed after the discovery information has been cached.
+	widget := schema.GroupKind{Group: "example.com", Kind: "Widget"}
+	discovery.Resources = append(discovery.Resources, &metaV1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metaV1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true}},
+	})
+	if _, err := mapper.RESTMapping(widget); !meta.IsNoMatchError(err) {
+		t.Errorf("expected no match error before the reset interval passed, got %v", err)
+	}
+
+	mapper.(*discoveryRESTMapper).lastReset = time.Now().Add(-DefaultRESTMapperResetInterval)
+	mapping, err := mapper.RESTMapping(widget)
+	if err != nil {
+		t.Fatalf("RESTMapping(%v) returned unexpected error: %v", widget, err)
+	}
+	if mapping.Resource.Resource != "widgets" {
+		t.Errorf("expected widgets resource, got %s", mapping.Resource.Resource)
+	}
+}
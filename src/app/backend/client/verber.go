@@ -22,8 +22,11 @@ import (
 	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/customresourcedefinition"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	restclient "k8s.io/client-go/rest"
 )
 
@@ -40,6 +43,7 @@ type resourceVerber struct {
 	rbacClient          RESTClient
 	apiExtensionsClient RESTClient
 	config              *restclient.Config
+	mapper              meta.RESTMapper
 }
 
 func (verber *resourceVerber) getRESTClientByType(clientType api.ClientType) RESTClient {
@@ -65,28 +69,26 @@ func (verber *resourceVerber) getRESTClientByType(clientType api.ClientType) RES
 	}
 }
 
-func (verber *resourceVerber) getResourceSpecFromKind(kind string, namespaceSet bool) (client RESTClient, resourceSpec api.APIMapping, err error) {
-	resourceSpec, ok := api.KindToAPIMapping[kind]
-	if !ok {
-		// check if kind is CRD
-		var crd apiextensions.CustomResourceDefinition
-		err = verber.apiExtensionsClient.Get().Resource("customresourcedefinitions").Name(kind).Do().Into(&crd)
-		if err != nil {
-			if errors.IsNotFoundError(err) {
-				err = errors.NewInvalid(fmt.Sprintf("Unknown resource kind: %s", kind))
-			}
-			return
-		}
-
-		client, err = customresourcedefinition.NewRESTClient(verber.config, &crd)
-		if err != nil {
-			return
-		}
+// clientTypesByGroupVersion maps the group versions served by the typed clients of the verber to
+// their client type. Resources of other group versions get a dedicated REST client.
+var clientTypesByGroupVersion = map[schema.GroupVersion]api.ClientType{
+	{Group: "", Version: "v1"}:                          api.ClientTypeDefault,
+	{Group: "extensions", Version: "v1beta1"}:           api.ClientTypeExtensionClient,
+	{Group: "apps", Version: "v1"}:                      api.ClientTypeAppsClient,
+	{Group: "batch", Version: "v1"}:                     api.ClientTypeBatchClient,
+	{Group: "batch", Version: "v1beta1"}:                api.ClientTypeBetaBatchClient,
+	{Group: "autoscaling", Version: "v1"}:               api.ClientTypeAutoscalingClient,
+	{Group: "storage.k8s.io", Version: "v1"}:            api.ClientTypeStorageClient,
+	{Group: "rbac.authorization.k8s.io", Version: "v1"}: api.ClientTypeRbacClient,
+	{Group: "apiextensions.k8s.io", Version: "v1beta1"}: api.ClientTypeAPIExtensionsClient,
+}
 
-		resourceSpec = api.APIMapping{
-			Resource:   crd.Status.AcceptedNames.Plural,
-			Namespaced: crd.Spec.Scope == apiextensions.NamespaceScoped,
-		}
+// getResourceSpecFromKind resolves the kind and checks that a namespace is set exactly for namespaced
+// kinds.
+func (verber *resourceVerber) getResourceSpecFromKind(kind string, namespaceSet bool) (client RESTClient, resourceSpec api.APIMapping, err error) {
+	client, resourceSpec, err = verber.resolveKind(kind)
+	if err != nil {
+		return
 	}
 
 	if namespaceSet != resourceSpec.Namespaced {
@@ -98,6 +100,37 @@ func (verber *resourceVerber) getResourceSpecFromKind(kind string, namespaceSet
 			return
 		}
 	}
+	return
+}
+
+// getListResourceSpecFromKind resolves the kind of a list. Unlike single resources, namespaced kinds can
+// be listed without namespace, which lists the resources of all namespaces.
+func (verber *resourceVerber) getListResourceSpecFromKind(kind string, namespaceSet bool) (client RESTClient, resourceSpec api.APIMapping, err error) {
+	client, resourceSpec, err = verber.resolveKind(kind)
+	if err == nil && namespaceSet && !resourceSpec.Namespaced {
+		err = errors.NewInvalid(fmt.Sprintf("Set namespace for not-namespaced resource kind: %s", kind))
+	}
+	return
+}
+
+// resolveKind resolves the kind with the RESTMapper of the cluster. The static KindToAPIMapping is only
+// used if there is no mapper or the mapper does not know the kind, e.g. because discovery failed.
+func (verber *resourceVerber) resolveKind(kind string) (client RESTClient, resourceSpec api.APIMapping, err error) {
+	resolved := false
+	if verber.mapper != nil {
+		client, resourceSpec, err = verber.getResourceSpecFromMapper(kind)
+		resolved = err == nil
+		if err != nil && !meta.IsNoMatchError(err) {
+			return
+		}
+	}
+
+	if !resolved {
+		client, resourceSpec, err = verber.getStaticResourceSpec(kind)
+		if err != nil {
+			return
+		}
+	}
 
 	if client == nil {
 		client = verber.getRESTClientByType(resourceSpec.ClientType)
@@ -105,19 +138,81 @@ func (verber *resourceVerber) getResourceSpecFromKind(kind string, namespaceSet
 	return
 }
 
+// getStaticResourceSpec resolves kinds with the built in KindToAPIMapping. Other kinds are looked up
+// as names of custom resource definitions.
+func (verber *resourceVerber) getStaticResourceSpec(kind string) (client RESTClient, resourceSpec api.APIMapping, err error) {
+	resourceSpec, ok := api.KindToAPIMapping[kind]
+	if ok {
+		return
+	}
+
+	// check if kind is CRD
+	var crd apiextensions.CustomResourceDefinition
+	err = verber.apiExtensionsClient.Get().Resource("customresourcedefinitions").Name(kind).Do().Into(&crd)
+	if err != nil {
+		if errors.IsNotFoundError(err) {
+			err = errors.NewInvalid(fmt.Sprintf("Unknown resource kind: %s", kind))
+		}
+		return
+	}
+
+	client, err = customresourcedefinition.NewRESTClient(verber.config, &crd)
+	if err != nil {
+		return
+	}
+
+	resourceSpec = api.APIMapping{
+		Resource:   crd.Status.AcceptedNames.Plural,
+		Namespaced: crd.Spec.Scope == apiextensions.NamespaceScoped,
+	}
+	return
+}
+
+// getResourceSpecFromMapper resolves kinds with the RESTMapper of the cluster. Built in kinds are the
+// lower case kind names, e.g. 'deployment', kinds of custom resources are the names of their
+// definitions, e.g. 'crontabs.stable.example.com', which is the resource name and group. Resources
+// of group versions served by the typed clients use those, other ones get a dedicated REST client.
+// Kinds unknown to the mapper are reported with a no match error.
+func (verber *resourceVerber) getResourceSpecFromMapper(kind string) (client RESTClient, resourceSpec api.APIMapping, err error) {
+	gvk, err := verber.mapper.KindFor(schema.ParseGroupResource(kind).WithVersion(""))
+	if err != nil {
+		return
+	}
+
+	mapping, err := verber.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return
+	}
+
+	resourceSpec = api.APIMapping{
+		Resource:   mapping.Resource.Resource,
+		Namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+	}
+
+	if clientType, ok := clientTypesByGroupVersion[gvk.GroupVersion()]; ok {
+		resourceSpec.ClientType = clientType
+		return
+	}
+
+	client, err = customresourcedefinition.NewRESTClientForGroupVersion(verber.config, gvk.GroupVersion())
+	return
+}
+
 // RESTClient is an interface for REST operations used in this file.
 type RESTClient interface {
 	Delete() *restclient.Request
 	Put() *restclient.Request
 	Get() *restclient.Request
+	Patch(pt types.PatchType) *restclient.Request
 }
 
 // NewResourceVerber creates a new resource verber that uses the given client for performing operations.
+// Kinds that are not built into the dashboard are resolved with the given mapper.
 func NewResourceVerber(client, extensionsClient, appsClient,
 	batchClient, betaBatchClient, autoscalingClient, storageClient,
-	rbacClient, apiExtensionsClient RESTClient, config *restclient.Config) clientapi.ResourceVerber {
+	rbacClient, apiExtensionsClient RESTClient, config *restclient.Config, mapper meta.RESTMapper) clientapi.ResourceVerber {
 	return &resourceVerber{client, extensionsClient, appsClient,
-		batchClient, betaBatchClient, autoscalingClient, storageClient, rbacClient, apiExtensionsClient, config}
+		batchClient, betaBatchClient, autoscalingClient, storageClient, rbacClient, apiExtensionsClient, config, mapper}
 }
 
 // Delete deletes the resource of the given kind in the given namespace with the given name.
@@ -209,6 +304,57 @@ func (verber *resourceVerber) PutWithMultiTenancy(kind string, tenant string, na
 	return req.Do().Error()
 }
 
+// Patch patches the resource of the given kind in the given namespace with the given name and returns
+// the patched resource.
+func (verber *resourceVerber) Patch(kind string, namespaceSet bool, namespace string, name string,
+	patchType types.PatchType, data []byte) (runtime.Object, error) {
+
+	client, resourceSpec, err := verber.getResourceSpecFromKind(kind, namespaceSet)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &runtime.Unknown{}
+	req := client.Patch(patchType).
+		Resource(resourceSpec.Resource).
+		Name(name).
+		SetHeader("Accept", "application/json").
+		Body(data)
+
+	if resourceSpec.Namespaced {
+		req.Namespace(namespace)
+	}
+
+	err = req.Do().Into(result)
+	return result, err
+}
+
+// PatchWithMultiTenancy patches the resource of the given kind in the given namespace with the given name
+// and returns the patched resource.
+func (verber *resourceVerber) PatchWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	name string, patchType types.PatchType, data []byte) (runtime.Object, error) {
+
+	client, resourceSpec, err := verber.getResourceSpecFromKind(kind, namespaceSet)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &runtime.Unknown{}
+	req := client.Patch(patchType).
+		Tenant(tenant).
+		Resource(resourceSpec.Resource).
+		Name(name).
+		SetHeader("Accept", "application/json").
+		Body(data)
+
+	if resourceSpec.Namespaced {
+		req.Namespace(namespace)
+	}
+
+	err = req.Do().Into(result)
+	return result, err
+}
+
 // Get gets the resource of the given kind in the given namespace with the given name.
 func (verber *resourceVerber) Get(kind string, namespaceSet bool, namespace string, name string) (runtime.Object, error) {
 	client, resourceSpec, err := verber.getResourceSpecFromKind(kind, namespaceSet)
@@ -244,3 +390,47 @@ func (verber *resourceVerber) GetWithMultiTenancy(kind string, tenant string, na
 	err = req.Do().Into(result)
 	return result, err
 }
+
+// List lists the resources of the given kind in the given namespace that match the label selector. If
+// no namespace is set for a namespaced kind, the resources of all namespaces are listed.
+func (verber *resourceVerber) List(kind string, namespaceSet bool, namespace string, labelSelector string) (runtime.Object, error) {
+	client, resourceSpec, err := verber.getListResourceSpecFromKind(kind, namespaceSet)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &runtime.Unknown{}
+	req := client.Get().Resource(resourceSpec.Resource).SetHeader("Accept", "application/json")
+
+	if resourceSpec.Namespaced && namespaceSet {
+		req.Namespace(namespace)
+	}
+	if len(labelSelector) > 0 {
+		req.Param("labelSelector", labelSelector)
+	}
+
+	err = req.Do().Into(result)
+	return result, err
+}
+
+// ListWithMultiTenancy lists the resources of the given kind in the given namespace that match the label selector.
+// If no namespace is set for a namespaced kind, the resources of all namespaces of the tenant are listed.
+func (verber *resourceVerber) ListWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string, labelSelector string) (runtime.Object, error) {
+	client, resourceSpec, err := verber.getListResourceSpecFromKind(kind, namespaceSet)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &runtime.Unknown{}
+	req := client.Get().Tenant(tenant).Resource(resourceSpec.Resource).SetHeader("Accept", "application/json")
+
+	if resourceSpec.Namespaced && namespaceSet {
+		req.Namespace(namespace)
+	}
+	if len(labelSelector) > 0 {
+		req.Param("labelSelector", labelSelector)
+	}
+
+	err = req.Do().Into(result)
+	return result, err
+}
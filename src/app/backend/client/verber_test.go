@@ -20,10 +20,12 @@ import (
 	"testing"
 
 	"k8s.io/apimachinery/pkg/api/apitesting"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 	restclient "k8s.io/client-go/rest"
 
 	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
@@ -38,6 +40,9 @@ func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
 type FakeRESTClient struct {
 	response *http.Response
 	err      error
+
+	// Path of the last GET request.
+	path string
 }
 
 func (c *FakeRESTClient) Delete() *restclient.Request {
@@ -64,10 +69,18 @@ func (c *FakeRESTClient) Put() *restclient.Request {
 
 func (c *FakeRESTClient) Get() *restclient.Request {
 	return restclient.NewRequest(clientFunc(func(req *http.Request) (*http.Response, error) {
+		c.path = req.URL.Path
 		return c.response, c.err
 	}), "GET", nil, "/api/v1", restclient.ContentConfig{}, restclient.Serializers{}, nil, nil, 0)
 }
 
+func (c *FakeRESTClient) Patch(pt types.PatchType) *restclient.Request {
+	return restclient.NewRequest(clientFunc(func(req *http.Request) (*http.Response, error) {
+		return c.response, c.err
+	}), "PATCH", nil, "/api/v1", restclient.ContentConfig{}, restclient.Serializers{}, nil, nil, 0).
+		SetHeader("Content-Type", string(pt))
+}
+
 func TestDeleteShouldPropagateErrorsAndChoseClient(t *testing.T) {
 	verber := resourceVerber{
 		client:           &FakeRESTClient{err: errors.NewInvalid("err")},
@@ -120,6 +133,59 @@ func TestGetShouldPropagateErrorsAndChoseClient(t *testing.T) {
 	}
 }
 
+func TestListShouldPropagateErrorsAndRespectNamespacedness(t *testing.T) {
+	verber := resourceVerber{
+		client:     &FakeRESTClient{err: errors.NewInvalid("err")},
+		appsClient: &FakeRESTClient{err: errors.NewInvalid("err from apps")},
+	}
+
+	_, err := verber.ListWithMultiTenancy("statefulset", "foo", true, "bar", "app=baz")
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("err from apps")) {
+		t.Fatalf("Expected error on verber list but got %#v", err)
+	}
+
+	_, err = verber.List("namespace", true, "bar", "app=baz")
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("Set namespace for not-namespaced resource kind: namespace")) {
+		t.Fatalf("Expected error on verber list but got %#v", err)
+	}
+}
+
+func TestListShouldListAllNamespacesWithoutNamespace(t *testing.T) {
+	client := &FakeRESTClient{err: errors.NewInvalid("err")}
+	verber := resourceVerber{client: client}
+
+	verber.ListWithMultiTenancy("service", "foo", false, "", "app=baz")
+	if client.path != "/api/v1/tenants/foo/services" {
+		t.Errorf("Expected services of all namespaces of the tenant to be listed, got %s", client.path)
+	}
+
+	verber.ListWithMultiTenancy("service", "foo", true, "bar", "app=baz")
+	if client.path != "/api/v1/tenants/foo/namespaces/bar/services" {
+		t.Errorf("Expected services of the namespace to be listed, got %s", client.path)
+	}
+}
+
+func TestPatchShouldPropagateErrorsAndRespectNamespacedness(t *testing.T) {
+	verber := resourceVerber{
+		client:     &FakeRESTClient{err: errors.NewInvalid("err")},
+		appsClient: &FakeRESTClient{err: errors.NewInvalid("err from apps")},
+	}
+
+	_, err := verber.PatchWithMultiTenancy("statefulset", "foo", true, "bar", "baz", types.MergePatchType, []byte("{}"))
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("err from apps")) {
+		t.Fatalf("Expected error on verber patch but got %#v", err)
+	}
+
+	_, err = verber.Patch("namespace", true, "bar", "baz", types.MergePatchType, []byte("{}"))
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("Set namespace for not-namespaced resource kind: namespace")) {
+		t.Fatalf("Expected error on verber patch but got %#v", err)
+	}
+}
+
 func TestDeleteShouldThrowErrorOnUnknownResourceKind(t *testing.T) {
 	verber := resourceVerber{
 		client:              &FakeRESTClient{},
@@ -218,3 +284,40 @@ func TestDeleteShouldRespectNotNamespacednessOfResourceKind(t *testing.T) {
 		t.Fatalf("Expected error on verber delete but got %#v", err)
 	}
 }
+
+func TestGetShouldResolveKindsWithMapper(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}, {Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}, meta.RESTScopeNamespace)
+	verber := resourceVerber{
+		client:           &FakeRESTClient{err: errors.NewInvalid("err")},
+		extensionsClient: &FakeRESTClient{err: errors.NewInvalid("err from extensions")},
+		appsClient:       &FakeRESTClient{err: errors.NewInvalid("err from apps")},
+		mapper:           mapper,
+	}
+
+	// The mapper resolves replica sets to apps/v1 instead of the extensions group of the static table.
+	_, err := verber.Get("replicaset", true, "bar", "baz")
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("err from apps")) {
+		t.Fatalf("Expected error on verber get but got %#v", err)
+	}
+
+	_, err = verber.Get("serviceaccount", false, "", "baz")
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("Set no namespace for namespaced resource kind: serviceaccount")) {
+		t.Fatalf("Expected error on verber get but got %#v", err)
+	}
+
+	// Kinds unknown to the mapper fall back to the static table.
+	_, err = verber.Get("namespace", true, "bar", "baz")
+
+	if !reflect.DeepEqual(err, errors.NewInvalid("Set namespace for not-namespaced resource kind: namespace")) {
+		t.Fatalf("Expected error on verber get but got %#v", err)
+	}
+
+	_, resourceSpec, err := verber.getResourceSpecFromKind("serviceaccount", true)
+	if err != nil || resourceSpec.Resource != "serviceaccounts" {
+		t.Fatalf("Expected serviceaccount to be resolved to serviceaccounts but got %#v, %v", resourceSpec, err)
+	}
+}
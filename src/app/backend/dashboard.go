@@ -16,6 +16,7 @@
 package main
 
 import (
+	"context"
 	"crypto/elliptic"
 	"crypto/tls"
 	"encoding/base64"
@@ -29,9 +30,11 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/CentaurusInfra/dashboard/src/app/backend/iam/db"
@@ -39,20 +42,28 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/pflag"
 
+	"github.com/CentaurusInfra/dashboard/src/app/backend/alert"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/args"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/auth"
 	authApi "github.com/CentaurusInfra/dashboard/src/app/backend/auth/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/auth/jwe"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/capacity"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/cert"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/cert/ecdsa"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/client"
 	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/handler"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/integration"
 	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/settings"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/sync"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/systembanner"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/tracing"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/usage"
 )
 
 var (
@@ -77,6 +88,25 @@ var (
 		"to connect to in the format of protocol://address:port, e.g., "+
 		"http://localhost:8000. If not specified, the assumption is that the binary runs inside a "+
 		"Kubernetes cluster and service proxy will be used.")
+	argPrometheusHost = pflag.String("prometheus-host", "", "The address of the Prometheus server "+
+		"to connect to in the format of protocol://address:port, e.g., "+
+		"http://localhost:9090. Required if the prometheus metrics provider is selected.")
+	argPartitionMetricsHosts = pflag.StringToString("partition-metrics-hosts", map[string]string{}, "The addresses "+
+		"of the metrics providers of the tenant and resource partitions by cluster name, e.g., "+
+		"tp1=http://10.0.0.1:8000,rp1=http://10.0.0.2:8000. Partitions without an address use the service "+
+		"proxy of their own apiserver, except for prometheus, which falls back to '--prometheus-host'.")
+	argAlertSMTPHost = pflag.String("alert-smtp-host", "", "The address of the SMTP server that alert "+
+		"notifications of email sinks are sent through in the format of host:port, e.g., smtp.example.com:25. "+
+		"If not specified, email sinks are not notified.")
+	argAlertSMTPFrom  = pflag.String("alert-smtp-from", "", "The sender address of alert notification emails.")
+	argAlertSinkHosts = pflag.StringSlice("alert-sink-hosts", []string{"hooks.slack.com"}, "The hosts that "+
+		"webhook and slack sinks of alert rules may post to. Wildcards like '*.example.com' match all subdomains.")
+	argTracingOTLPEndpoint = pflag.String("tracing-otlp-endpoint", "", "The OTLP/HTTP endpoint that traces "+
+		"are exported to in the format of protocol://address:port, e.g., http://otel-collector:4318. "+
+		"If not specified, tracing is disabled.")
+	argCapacityNodeCost = pflag.Float64("capacity-node-cost", 0, "The monthly cost of a node of the resource "+
+		"partitions, which capacity plans report the cost impact of their recommendations with. If not specified, "+
+		"no cost is reported.")
 	argKubeConfigFile     = pflag.String("kubeconfig", defaultKubeconfig, "Path to kubeconfig file with authorization and master location information.")
 	argTokenTTL           = pflag.Int("token-ttl", int(authApi.DefaultTokenTTL), "Expiration time (in seconds) of JWE tokens generated by dashboard. '0' never expires")
 	argAuthenticationMode = pflag.StringSlice("authentication-mode", []string{authApi.Token.String()}, "Enables authentication options that will be reflected on login screen. Supported values: token, basic. "+
@@ -91,6 +121,10 @@ var (
 	argDisableSettingsAuthorizer = pflag.Bool("disable-settings-authorizer", false, "When enabled, Dashboard settings page will not require user to be logged in and authorized to access settings page. (default false)")
 	argNamespace                 = pflag.String("namespace", getEnv("POD_NAMESPACE", "kube-system"), "When non-default namespace is used, create encryption key in the specified namespace.")
 	localeConfig                 = pflag.String("locale-config", "./locale_conf.json", "File containing the configuration of locales")
+	argHelmRepository            = pflag.String("helm-repository", "", "The URL of the Helm chart repository that charts can be installed from, e.g. 'https://charts.example.com'. "+
+		"If not set, only uploaded charts can be installed.")
+	argVMVNCPort = pflag.Int("vm-vnc-port", 0, "The port on which the VM runtime serves the VNC display inside of virtual machine pods. "+
+		"If not set, the port has to be passed with every VNC request.")
 )
 
 const TENANTPARTITION = "TP"
@@ -116,6 +150,10 @@ func main() {
 		log.Printf("Using namespace: %s", args.Holder.GetNamespace())
 	}
 	log.Printf("Using locale config: %s", *localeConfig)
+	shutdownTracing, err := tracing.Init(args.Holder.GetTracingOTLPEndpoint())
+	if err != nil {
+		log.Fatalf("Error while initializing tracing. Reason: %s", err)
+	}
 	configs, err := CreateOrConfigureKubeconfig()
 	if err != nil {
 		log.Printf("No RPs & TPs config files found")
@@ -143,6 +181,7 @@ func main() {
 			sharedoption := informers.WithNamespaceWithMultiTenancy("", "all")
 			informerfactory := informers.NewSharedInformerFactoryWithOptions(newclientmanager.InsecureClient(), 1*time.Minute, sharedoption)
 			podinformer := informerfactory.Core().V1().Pods().Informer()
+			instrumentation.RegisterInformer(newclientmanager.GetClusterName(), "pods", podinformer.HasSynced)
 			stopch := make(chan struct{})
 			informerfactory.Start(stopch)
 			informerfactory.WaitForCacheSync(stopch)
@@ -150,12 +189,13 @@ func main() {
 			tpclients = append(tpclients, newclientmanager)
 		}
 	}
-	log.Printf("tp-clients before: %v", tpclients[0].GetClusterName())
 	// sort client according to TP
 	sort.Slice(tpclients[:], func(i, j int) bool {
 		return tpclients[i].GetClusterName() < tpclients[j].GetClusterName()
 	})
-	log.Printf("tp-clients after: %v", tpclients[0].GetClusterName())
+	if len(tpclients) > 0 {
+		log.Printf("tp-clients after: %v", tpclients[0].GetClusterName())
+	}
 	log.Printf("Successful initial request to the apiserver, version: %s", versionInfo.String())
 
 	// Create table in Postgres Database
@@ -178,31 +218,125 @@ func main() {
 	// Init integrations
 	integrationManager := integration.NewIntegrationManager(clientManager)
 
-	switch metricsProvider := args.Holder.GetMetricsProvider(); metricsProvider {
-	case "sidecar":
-		integrationManager.Metric().ConfigureSidecar(args.Holder.GetSidecarHost()).
-			EnableWithRetry(integrationapi.SidecarIntegrationID, time.Duration(args.Holder.GetMetricClientCheckPeriod()))
-	case "heapster":
-		integrationManager.Metric().ConfigureHeapster(args.Holder.GetHeapsterHost()).
-			EnableWithRetry(integrationapi.HeapsterIntegrationID, time.Duration(args.Holder.GetMetricClientCheckPeriod()))
-	case "none":
-		log.Print("no metrics provider selected, will not check metrics.")
-	default:
-		log.Printf("Invalid metrics provider selected: %s", metricsProvider)
-		log.Print("Defaulting to use the Sidecar provider.")
-		integrationManager.Metric().ConfigureSidecar(args.Holder.GetSidecarHost()).
-			EnableWithRetry(integrationapi.SidecarIntegrationID, time.Duration(args.Holder.GetMetricClientCheckPeriod()))
+	configureMetricsProvider(integrationManager.Metric(), args.Holder.GetSidecarHost(),
+		args.Holder.GetHeapsterHost(), args.Holder.GetPrometheusHost())
+
+	// Every partition gets its own metric clients, so that metrics of its resources are read from its
+	// own metrics provider.
+	partitionIntegrationManagers := make(map[clientapi.ClientManager]integration.IntegrationManager)
+	for _, partitionClient := range append(append([]clientapi.ClientManager{}, tpclients...), rpclients...) {
+		partitionIntegrationManager := integration.NewIntegrationManager(partitionClient)
+		host := args.Holder.GetPartitionMetricsHosts()[partitionClient.GetClusterName()]
+		prometheusHost := host
+		if prometheusHost == "" {
+			prometheusHost = args.Holder.GetPrometheusHost()
+		}
+		log.Printf("Configuring metrics provider of %s cluster", partitionClient.GetClusterName())
+		configureMetricsProvider(partitionIntegrationManager.Metric(), host, host, prometheusHost)
+		partitionIntegrationManagers[partitionClient] = partitionIntegrationManager
+	}
+
+	// In single cluster mode there are no partitions, so the cluster itself is collected.
+	tenantClients, resourceClients := tpclients, rpclients
+	if len(tenantClients) == 0 {
+		tenantClients = []clientapi.ClientManager{clientManager}
+	}
+	if len(resourceClients) == 0 {
+		resourceClients = tenantClients
+	}
+	metricClientOf := func(partitionClient clientapi.ClientManager) func() metricapi.MetricClient {
+		if partitionIntegrationManager, exists := partitionIntegrationManagers[partitionClient]; exists {
+			return partitionIntegrationManager.Metric().Client
+		}
+		return integrationManager.Metric().Client
+	}
+
+	// Usage collection, alerting and capacity planning share a single connection pool and are
+	// disabled if the database can not be reached.
+	featureDB, err := db.Connect()
+	if err != nil {
+		log.Printf("Failed to connect to the database, usage collection, alerting and capacity planning "+
+			"are disabled: %s", err.Error())
+	}
+
+	// Collect the usage of all tenant partitions for chargeback reports.
+	var usageStore usage.Store
+	if featureDB != nil {
+		if usageStore, err = usage.NewPostgresStore(featureDB); err != nil {
+			log.Printf("Failed to create usage table, usage collection is disabled: %s", err.Error())
+		} else {
+			var partitions []rollup.Partition
+			for _, tpclient := range tenantClients {
+				partitions = append(partitions, rollup.Partition{
+					Name:         tpclient.GetClusterName(),
+					Client:       tpclient.InsecureClient(),
+					MetricClient: metricClientOf(tpclient),
+				})
+			}
+			usage.NewAggregator(usageStore, partitions).Start()
+		}
+	}
+
+	// Evaluate alert rules of all tenants and partitions. Nodes are evaluated on the tenant
+	// partitions if there are no resource partitions.
+	var alertController *alert.Controller
+	if featureDB != nil {
+		if alertStore, err := alert.NewPostgresStore(featureDB); err != nil {
+			log.Printf("Failed to create alert tables, alerting is disabled: %s", err.Error())
+		} else {
+			var tenantPartitions, resourcePartitions []alert.Partition
+			for _, tpclient := range tenantClients {
+				tenantPartitions = append(tenantPartitions, alert.Partition{
+					Name:   tpclient.GetClusterName(),
+					Client: tpclient.InsecureClient(),
+				})
+			}
+			for _, rpclient := range resourceClients {
+				resourcePartitions = append(resourcePartitions, alert.Partition{
+					Name:   rpclient.GetClusterName(),
+					Client: rpclient.InsecureClient(),
+				})
+			}
+			alertController = alert.NewController(alertStore,
+				alert.NewNotifier(args.Holder.GetAlertSMTPHost(), args.Holder.GetAlertSMTPFrom(),
+					args.Holder.GetAlertSinkHosts()),
+				tenantPartitions, resourcePartitions)
+			alertController.Start()
+		}
+	}
+
+	// Sample the capacity of all resource partitions for capacity planning. The tenant partitions
+	// are sampled if there are no resource partitions.
+	var capacityStore capacity.Store
+	if featureDB != nil {
+		if capacityStore, err = capacity.NewPostgresStore(featureDB); err != nil {
+			log.Printf("Failed to create capacity table, capacity planning is disabled: %s", err.Error())
+		} else {
+			var partitions []rollup.Partition
+			for _, cpclient := range resourceClients {
+				partitions = append(partitions, rollup.Partition{
+					Name:         cpclient.GetClusterName(),
+					Client:       cpclient.InsecureClient(),
+					MetricClient: metricClientOf(cpclient),
+				})
+			}
+			capacity.NewSampler(capacityStore, partitions).Start()
+		}
 	}
 
 	apiHandler, err := handler.CreateHTTPAPIHandler(
 		integrationManager,
+		partitionIntegrationManagers,
 		clientManager,
 		tpclients,
 		rpclients,
 		authManager,
 		settingsManager,
 		systemBannerManager,
-		tppodinformer)
+		tppodinformer,
+		usageStore,
+		alertController,
+		capacityStore)
 	if err != nil {
 		handleFatalInitError(err)
 	}
@@ -250,7 +384,17 @@ func main() {
 		addr := fmt.Sprintf("%s:%d", args.Holder.GetInsecureBindAddress(), args.Holder.GetInsecurePort())
 		go func() { log.Fatal(http.ListenAndServe(addr, nil)) }()
 	}
-	select {}
+
+	// Flushes the remaining spans before exiting when the process is stopped.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("Received %s, shutting down", <-signals)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Error while flushing the remaining spans. Reason: %s", err)
+	}
+	cancel()
+	os.Exit(0)
 }
 
 func initAuthManager(tpManager []clientapi.ClientManager) []authApi.AuthManager {
@@ -305,6 +449,13 @@ func initArgHolder() {
 	builder.SetMetricsProvider(*argMetricsProvider)
 	builder.SetHeapsterHost(*argHeapsterHost)
 	builder.SetSidecarHost(*argSidecarHost)
+	builder.SetPrometheusHost(*argPrometheusHost)
+	builder.SetPartitionMetricsHosts(*argPartitionMetricsHosts)
+	builder.SetAlertSMTPHost(*argAlertSMTPHost)
+	builder.SetAlertSMTPFrom(*argAlertSMTPFrom)
+	builder.SetAlertSinkHosts(*argAlertSinkHosts)
+	builder.SetTracingOTLPEndpoint(*argTracingOTLPEndpoint)
+	builder.SetCapacityNodeCost(*argCapacityNodeCost)
 	builder.SetKubeConfigFile(*argKubeConfigFile)
 	builder.SetSystemBanner(*argSystemBanner)
 	builder.SetSystemBannerSeverity(*argSystemBannerSeverity)
@@ -316,6 +467,8 @@ func initArgHolder() {
 	builder.SetEnableSkipLogin(*argEnableSkip)
 	builder.SetNamespace(*argNamespace)
 	builder.SetLocaleConfig(*localeConfig)
+	builder.SetHelmRepository(*argHelmRepository)
+	builder.SetVMVNCPort(*argVMVNCPort)
 }
 
 /**
@@ -464,3 +617,30 @@ func CreateOrConfigureKubeconfig() (configDetails map[string]string, err error)
 	}
 	return configDetails, nil
 }
+
+// configureMetricsProvider configures the selected metrics provider of the metric manager and
+// enables it.
+func configureMetricsProvider(metricManager metric.MetricManager, sidecarHost, heapsterHost, prometheusHost string) {
+	period := time.Duration(args.Holder.GetMetricClientCheckPeriod())
+	switch metricsProvider := args.Holder.GetMetricsProvider(); metricsProvider {
+	case "sidecar":
+		metricManager.ConfigureSidecar(sidecarHost).
+			EnableWithRetry(integrationapi.SidecarIntegrationID, period)
+	case "heapster":
+		metricManager.ConfigureHeapster(heapsterHost).
+			EnableWithRetry(integrationapi.HeapsterIntegrationID, period)
+	case "prometheus":
+		metricManager.ConfigurePrometheus(prometheusHost).
+			EnableWithRetry(integrationapi.PrometheusIntegrationID, period)
+	case "metrics-server":
+		metricManager.ConfigureMetricsServer().
+			EnableWithRetry(integrationapi.MetricsServerIntegrationID, period)
+	case "none":
+		log.Print("no metrics provider selected, will not check metrics.")
+	default:
+		log.Printf("Invalid metrics provider selected: %s", metricsProvider)
+		log.Print("Defaulting to use the Sidecar provider.")
+		metricManager.ConfigureSidecar(sidecarHost).
+			EnableWithRetry(integrationapi.SidecarIntegrationID, period)
+	}
+}
@@ -66,6 +66,28 @@ func NewNotFound(reason string) *errors.StatusError {
 	}
 }
 
+func NewForbidden(reason string) *errors.StatusError {
+	return &errors.StatusError{
+		ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: reason,
+		},
+	}
+}
+
+func NewTooManyRequests(reason string) *errors.StatusError {
+	return &errors.StatusError{
+		ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusTooManyRequests,
+			Reason:  metav1.StatusReasonTooManyRequests,
+			Message: reason,
+		},
+	}
+}
+
 func NewInternal(reason string) *errors.StatusError {
 	return &errors.StatusError{ErrStatus: metav1.Status{
 		Status: metav1.StatusFailure,
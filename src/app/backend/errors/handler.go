@@ -123,13 +123,18 @@ func IsTokenExpiredError(err error) bool {
 
 // HandleInternalError writes the given error to the response and sets appropriate HTTP status headers.
 func HandleInternalError(response *restful.Response, err error) {
-	statusCode := http.StatusInternalServerError
+	response.AddHeader("Content-Type", "text/plain")
+	response.WriteErrorString(StatusCode(err), err.Error()+"\n")
+}
+
+// StatusCode returns the HTTP status code of the error. It is 500 unless the error is a status error
+// with a code.
+func StatusCode(err error) int {
 	statusError, ok := err.(*errors.StatusError)
 	if ok && statusError.Status().Code > 0 {
-		statusCode = int(statusError.Status().Code)
+		return int(statusError.Status().Code)
 	}
-	response.AddHeader("Content-Type", "text/plain")
-	response.WriteErrorString(statusCode, err.Error()+"\n")
+	return http.StatusInternalServerError
 }
 
 // Handle HTTP Errors more accurately based on the localized consts
@@ -0,0 +1,52 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	restful "github.com/emicklei/go-restful"
+	authorizationv1 "k8s.io/api/authorization/v1"
+
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// authorizeRequest asks the apiserver with a SelfSubjectAccessReview whether the user of the request
+// is allowed to perform the reviewed action. Creating a client for the request does not contact the
+// apiserver, so handlers acting on behalf of the user outside of its own apiserver calls have to
+// check access explicitly. A forbidden error is written to the response if access is denied.
+func authorizeRequest(cManager clientapi.ClientManager, request *restful.Request, response *restful.Response,
+	ssar *authorizationv1.SelfSubjectAccessReview) bool {
+	if cManager.CanI(request, ssar) {
+		return true
+	}
+
+	attributes := ssar.Spec.ResourceAttributes
+	resource := attributes.Resource
+	if len(attributes.Subresource) > 0 {
+		resource += "/" + attributes.Subresource
+	}
+	target := []string{}
+	for _, part := range []string{attributes.Tenant, attributes.Namespace, attributes.Name} {
+		if len(part) > 0 {
+			target = append(target, part)
+		}
+	}
+	errors.HandleInternalError(response, errors.NewForbidden(fmt.Sprintf("user is not allowed to %s %s %s",
+		attributes.Verb, resource, strings.Join(target, "/"))))
+	return false
+}
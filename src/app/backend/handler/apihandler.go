@@ -16,9 +16,11 @@
 package handler
 
 import (
+  "bytes"
   "encoding/base64"
   er "errors"
   "fmt"
+  "io/ioutil"
   "github.com/CentaurusInfra/dashboard/src/app/backend/iam"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/partition"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/vm"
@@ -27,6 +29,7 @@ import (
   "k8s.io/client-go/tools/cache"
   "log"
   "net/http"
+  "path"
   "strconv"
   "strings"
   "time"
@@ -35,12 +38,18 @@ import (
   "github.com/CentaurusInfra/dashboard/src/app/backend/iam/model"
   _ "github.com/lib/pq" // postgres golang driver
 
+  "github.com/CentaurusInfra/dashboard/src/app/backend/alert"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/capacity"
   "github.com/CentaurusInfra/dashboard/src/app/backend/api"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/args"
   "github.com/CentaurusInfra/dashboard/src/app/backend/auth"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/bulk"
   authApi "github.com/CentaurusInfra/dashboard/src/app/backend/auth/api"
   clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
   "github.com/CentaurusInfra/dashboard/src/app/backend/errors"
   "github.com/CentaurusInfra/dashboard/src/app/backend/integration"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/labeling"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/usage"
   metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
   "github.com/CentaurusInfra/dashboard/src/app/backend/plugin"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/clusterrole"
@@ -49,16 +58,19 @@ import (
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/configmap"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/container"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/controller"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/resource/controllerrevision"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/cronjob"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/customresourcedefinition"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/daemonset"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/dataselect"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/deployment"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/event"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/resource/helm"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/horizontalpodautoscaler"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/ingress"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/job"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/logs"
+  "github.com/CentaurusInfra/dashboard/src/app/backend/resource/logs/export"
   ns "github.com/CentaurusInfra/dashboard/src/app/backend/resource/namespace"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/node"
   "github.com/CentaurusInfra/dashboard/src/app/backend/resource/persistentvolume"
@@ -83,7 +95,10 @@ import (
   restful "github.com/emicklei/go-restful"
   "golang.org/x/net/xsrftoken"
   v1 "k8s.io/api/core/v1"
+  metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
   "k8s.io/apimachinery/pkg/runtime"
+  "k8s.io/apimachinery/pkg/types"
+  "k8s.io/client-go/dynamic"
   "k8s.io/client-go/tools/remotecommand"
 )
 
@@ -103,11 +118,16 @@ type APIHandler struct {
 }
 type APIHandlerV2 struct {
 	iManager             integration.IntegrationManager
+	iManagers            map[clientapi.ClientManager]integration.IntegrationManager
 	defaultClientmanager clientapi.ClientManager
 	tpManager            []clientapi.ClientManager
 	rpManager            []clientapi.ClientManager
 	sManager             settingsApi.SettingsManager
 	podInformerManager   []cache.SharedIndexInformer
+	logExportManager     *export.Manager
+	usageStore           usage.Store
+	alertController      *alert.Controller
+	capacityStore        capacity.Store
 }
 
 // TerminalResponse is sent by handleExecShell. The Id is a random session id that binds the original REST request and the SockJS connection.
@@ -144,22 +164,34 @@ func ResourceAllocator(partition string, tenant string, clients []clientapi.Clie
 	return clients[0]
 }
 
+// metricClient returns the active metric client of the partition of the given client manager. The
+// metric client of the default integration manager is used for client managers without their own.
+func (apiHandler *APIHandlerV2) metricClient(cManager clientapi.ClientManager) metricapi.MetricClient {
+	if iManager, exists := apiHandler.iManagers[cManager]; exists {
+		return iManager.Metric().Client()
+	}
+	return apiHandler.iManager.Metric().Client()
+}
+
 //struct for already exists
 type ErrorMsg struct {
 	Msg string `json:"msg"`
 }
 
 // CreateHTTPAPIHandler creates a new HTTP handler that handles all requests to the API of the backend.
-func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager clientapi.ClientManager, tpManagers []clientapi.ClientManager, rpManagers []clientapi.ClientManager,
+func CreateHTTPAPIHandler(iManager integration.IntegrationManager,
+	iManagers map[clientapi.ClientManager]integration.IntegrationManager, tpManager clientapi.ClientManager, tpManagers []clientapi.ClientManager, rpManagers []clientapi.ClientManager,
 	authManager []authApi.AuthManager, sManager settingsApi.SettingsManager,
-	sbManager systembanner.SystemBannerManager, podInformers []cache.SharedIndexInformer) (
+	sbManager systembanner.SystemBannerManager, podInformers []cache.SharedIndexInformer, usageStore usage.Store,
+	alertController *alert.Controller, capacityStore capacity.Store) (
 
 	http.Handler, error) {
 	//apiHandler1 := APIHandler{iManager: iManager, tpManager: tpManager, sManager: sManager}
-	apiHandler := APIHandlerV2{iManager: iManager, defaultClientmanager: tpManager, tpManager: tpManagers, rpManager: rpManagers, sManager: sManager, podInformerManager: podInformers}
+	apiHandler := APIHandlerV2{iManager: iManager, iManagers: iManagers, defaultClientmanager: tpManager, tpManager: tpManagers, rpManager: rpManagers, sManager: sManager, podInformerManager: podInformers, logExportManager: export.NewManager(), usageStore: usageStore, alertController: alertController, capacityStore: capacityStore}
 	wsContainer := restful.NewContainer()
 	wsContainer.EnableContentEncoding(true)
 
+	registerPartitions(tpManagers, rpManagers)
 	apiV1Ws := new(restful.WebService)
 	for _, cManager := range tpManagers {
 		InstallFilters(apiV1Ws, cManager)
@@ -189,6 +221,14 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/resourcepartition").
 			To(apiHandler.handleGetResourcePartitionDetail).
 			Writes(partition.ResourcePartitionList{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/resourcepartition/capacityplan").
+			To(apiHandler.handleGetCapacityPlans).
+			Writes(capacity.PlanList{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/resourcepartition/{partition}/capacityplan").
+			To(apiHandler.handleGetCapacityPlan).
+			Writes(capacity.Plan{}))
 	apiV1Ws.Route(
 		apiV1Ws.GET("/tenantpartition").
 			To(apiHandler.handleGetTenantPartitionDetail).
@@ -225,6 +265,11 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 			To(apiHandler.handleDeploy).
 			Reads(deployment.AppDeploymentSpec{}).
 			Writes(deployment.AppDeploymentSpec{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/appdeployment/vm").
+			To(apiHandler.handleDeployVirtualMachine).
+			Reads(deployment.VirtualMachineDeploymentSpec{}).
+			Writes(deployment.VirtualMachineDeploymentSpec{}))
 	apiV1Ws.Route(
 		apiV1Ws.POST("/appdeployment/validate/name").
 			To(apiHandler.handleNameValidity).
@@ -251,6 +296,42 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 			Reads(deployment.AppDeploymentFromFileSpec{}).
 			Writes(deployment.AppDeploymentFromFileResponse{}))
 
+	apiV1Ws.Route(
+		apiV1Ws.GET("/helm/chart").
+			To(apiHandler.handleGetHelmChartList).
+			Writes(helm.RepositoryChartList{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/helmrelease/{namespace}").
+			To(apiHandler.handleGetHelmReleaseList).
+			Writes(helm.ReleaseList{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/helmrelease/{namespace}").
+			To(apiHandler.handleInstallHelmRelease).
+			Reads(helm.InstallSpec{}).
+			Writes(helm.ReleaseDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/helmrelease/{namespace}/{release}").
+			To(apiHandler.handleGetHelmRelease).
+			Writes(helm.ReleaseDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/helmrelease/{namespace}/{release}").
+			To(apiHandler.handleUpgradeHelmRelease).
+			Reads(helm.UpgradeSpec{}).
+			Writes(helm.ReleaseDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.DELETE("/tenants/{tenant}/helmrelease/{namespace}/{release}").
+			To(apiHandler.handleUninstallHelmRelease).
+			Writes(helm.ReleaseDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/helmrelease/{namespace}/{release}/history").
+			To(apiHandler.handleGetHelmReleaseHistory).
+			Writes(helm.ReleaseHistory{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/helmrelease/{namespace}/{release}/rollback").
+			To(apiHandler.handleRollbackHelmRelease).
+			Reads(helm.RollbackSpec{}).
+			Writes(helm.ReleaseDetail{}))
+
 	apiV1Ws.Route(
 		apiV1Ws.GET("/replicationcontroller").
 			To(apiHandler.handleGetReplicationControllerList).
@@ -403,6 +484,24 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/pod/{namespace}/{pod}/shell/{container}").
 			To(apiHandler.handleExecShell).
 			Writes(TerminalResponse{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/pod/{namespace}/{pod}/exec/{container}").
+			To(apiHandler.handleExecWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/pod/{namespace}/{pod}/attach/{container}").
+			To(apiHandler.handleAttachWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/pod/{namespace}/{pod}/portforward/{port}").
+			To(apiHandler.handlePortForwardWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/pod/{namespace}/{pod}/file/{container}").
+			To(apiHandler.handleDownloadFileWithMultiTenancy).
+			Produces("application/x-tar", restful.MIME_JSON))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/pod/{namespace}/{pod}/file/{container}").
+			To(apiHandler.handleUploadFileWithMultiTenancy).
+			Consumes("application/octet-stream").
+			Writes(container.FileCopyResult{}))
 	apiV1Ws.Route(
 		apiV1Ws.GET("/pod/{namespace}/{pod}/persistentvolumeclaim").
 			To(apiHandler.handleGetPodPersistentVolumeClaims).
@@ -440,6 +539,16 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/tenants/{tenant}/virtualmachine/{namespace}/{virtualmachine}").
 			To(apiHandler.handleGetVMDetailWithMultiTenancy).
 			Writes(vm.VirtualMachineDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/virtualmachine/{namespace}/{virtualmachine}/console").
+			To(apiHandler.handleVMConsole).
+			Writes(TerminalResponse{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/virtualmachine/{namespace}/{virtualmachine}/console/ws").
+			To(apiHandler.handleVMConsoleWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/virtualmachine/{namespace}/{virtualmachine}/vnc").
+			To(apiHandler.handleVMVNCWebSocket))
 	apiV1Ws.Route(
 		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/container").
 			To(apiHandler.handleGetPodContainersWithMultiTenancy).
@@ -452,6 +561,24 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/shell/{container}").
 			To(apiHandler.handleExecShellWithMultiTenancy).
 			Writes(TerminalResponse{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/exec/{container}").
+			To(apiHandler.handleExecWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/attach/{container}").
+			To(apiHandler.handleAttachWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/portforward/{port}").
+			To(apiHandler.handlePortForwardWebSocket))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/file/{container}").
+			To(apiHandler.handleDownloadFileWithMultiTenancy).
+			Produces("application/x-tar", restful.MIME_JSON))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/pod/{namespace}/{pod}/file/{container}").
+			To(apiHandler.handleUploadFileWithMultiTenancy).
+			Consumes("application/octet-stream").
+			Writes(container.FileCopyResult{}))
 	apiV1Ws.Route(
 		apiV1Ws.GET("/tenants/{tenant}/pod/{namespace}/{pod}/persistentvolumeclaim").
 			To(apiHandler.handleGetPodPersistentVolumeClaims).
@@ -526,6 +653,31 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/partition/{partition}/tenants/{tenant}/deployment/{namespace}/{deployment}/newreplicaset").
 			To(apiHandler.handleGetDeploymentNewReplicaSetWithMultiTenancy).
 			Writes(replicaset.ReplicaSet{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/deployment/{namespace}/{deployment}/rollout").
+			To(apiHandler.handleGetDeploymentRolloutStatus).
+			Writes(deployment.RolloutStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/deployment/{namespace}/{deployment}/rollout/history").
+			To(apiHandler.handleGetDeploymentRolloutHistory).
+			Writes(deployment.RolloutHistory{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/deployment/{namespace}/{deployment}/pause").
+			To(apiHandler.handlePauseDeployment).
+			Writes(deployment.RolloutStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/deployment/{namespace}/{deployment}/resume").
+			To(apiHandler.handleResumeDeployment).
+			Writes(deployment.RolloutStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/deployment/{namespace}/{deployment}/restart").
+			To(apiHandler.handleRestartDeployment).
+			Writes(deployment.RolloutStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/deployment/{namespace}/{deployment}/rollback").
+			To(apiHandler.handleRollbackDeployment).
+			Reads(deployment.RollbackSpec{}).
+			Writes(deployment.RolloutStatus{}))
 	apiV1Ws.Route(
 		apiV1Ws.PUT("/scale/{kind}/{namespace}/{name}/").
 			To(apiHandler.handleScaleResource).
@@ -542,6 +694,11 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/tenants/{tenant}/scale/{kind}/{namespace}/{name}").
 			To(apiHandler.handleGetReplicaCountWithMultiTenancy).
 			Writes(scaling.ReplicaCounts{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/bulk").
+			To(apiHandler.handleBulkAction).
+			Reads(bulk.ActionSpec{}).
+			Writes(bulk.ActionResult{}))
 
 	apiV1Ws.Route(
 		apiV1Ws.GET("/daemonset").
@@ -592,6 +749,19 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/tenants/{tenant}/daemonset/{namespace}/{daemonSet}/event").
 			To(apiHandler.handleGetDaemonSetEventsWithMultiTenancy).
 			Writes(common.EventList{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/daemonset/{namespace}/{daemonSet}/restart").
+			To(apiHandler.handleRestartDaemonSet).
+			Writes(daemonset.UpdateStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/daemonset/{namespace}/{daemonSet}/rollback").
+			To(apiHandler.handleRollbackDaemonSet).
+			Reads(controllerrevision.RollbackSpec{}).
+			Writes(daemonset.UpdateStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/daemonset/{namespace}/{daemonSet}/history").
+			To(apiHandler.handleGetDaemonSetRevisionHistory).
+			Writes(controllerrevision.RevisionHistory{}))
 
 	apiV1Ws.Route(
 		apiV1Ws.GET("/horizontalpodautoscaler").
@@ -605,6 +775,27 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/horizontalpodautoscaler/{namespace}/{horizontalpodautoscaler}").
 			To(apiHandler.handleGetHorizontalPodAutoscalerDetail).
 			Writes(horizontalpodautoscaler.HorizontalPodAutoscalerDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/horizontalpodautoscaler/{namespace}").
+			To(apiHandler.handleCreateHorizontalPodAutoscaler).
+			Reads(horizontalpodautoscaler.HorizontalPodAutoscalerSpec{}).
+			Writes(horizontalpodautoscaler.HorizontalPodAutoscalerDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/horizontalpodautoscaler/{namespace}/{horizontalpodautoscaler}").
+			To(apiHandler.handleGetHorizontalPodAutoscalerDetailWithMultiTenancy).
+			Writes(horizontalpodautoscaler.HorizontalPodAutoscalerDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/horizontalpodautoscaler/{namespace}/{horizontalpodautoscaler}").
+			To(apiHandler.handleUpdateHorizontalPodAutoscaler).
+			Reads(horizontalpodautoscaler.HorizontalPodAutoscalerSpec{}).
+			Writes(horizontalpodautoscaler.HorizontalPodAutoscalerDetail{}))
+	apiV1Ws.Route(
+		apiV1Ws.DELETE("/tenants/{tenant}/horizontalpodautoscaler/{namespace}/{horizontalpodautoscaler}").
+			To(apiHandler.handleDeleteHorizontalPodAutoscaler))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/horizontalpodautoscaler/{namespace}/{horizontalpodautoscaler}/event").
+			To(apiHandler.handleGetHorizontalPodAutoscalerEvents).
+			Writes(common.EventList{}))
 
 	apiV1Ws.Route(
 		apiV1Ws.GET("/job").
@@ -965,6 +1156,24 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/tenants/{tenant}/statefulset/{namespace}/{statefulset}/event").
 			To(apiHandler.handleGetStatefulSetEventsWithMultiTenancy).
 			Writes(common.EventList{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/statefulset/{namespace}/{statefulset}/restart").
+			To(apiHandler.handleRestartStatefulSet).
+			Writes(statefulset.UpdateStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/statefulset/{namespace}/{statefulset}/partition").
+			To(apiHandler.handleUpdateStatefulSetPartition).
+			Reads(statefulset.PartitionSpec{}).
+			Writes(statefulset.UpdateStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/statefulset/{namespace}/{statefulset}/rollback").
+			To(apiHandler.handleRollbackStatefulSet).
+			Reads(controllerrevision.RollbackSpec{}).
+			Writes(statefulset.UpdateStatus{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/statefulset/{namespace}/{statefulset}/history").
+			To(apiHandler.handleGetStatefulSetRevisionHistory).
+			Writes(controllerrevision.RevisionHistory{}))
 
 	apiV1Ws.Route(
 		apiV1Ws.GET("/node").
@@ -982,6 +1191,10 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/node/{name}/pod").
 			To(apiHandler.handleGetNodePods).
 			Writes(pod.PodList{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/node/{name}/toppods").
+			To(apiHandler.handleGetNodeTopPods).
+			Writes(pod.PodList{}))
 
 	apiV1Ws.Route(
 		apiV1Ws.DELETE("/_raw/{kind}/namespace/{namespace}/name/{name}").
@@ -1031,6 +1244,25 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.PUT("/tenants/{tenant}/_raw/{kind}/name/{name}").
 			To(apiHandler.handlePutResourceWithMultiTenancy))
 
+	apiV1Ws.Route(
+		apiV1Ws.PATCH("/tenants/{tenant}/_raw/{kind}/namespace/{namespace}/name/{name}").
+			Consumes(string(types.MergePatchType), string(types.StrategicMergePatchType), string(types.JSONPatchType)).
+			To(apiHandler.handlePatchResourceWithMultiTenancy))
+	apiV1Ws.Route(
+		apiV1Ws.PATCH("/tenants/{tenant}/_raw/{kind}/name/{name}").
+			Consumes(string(types.MergePatchType), string(types.StrategicMergePatchType), string(types.JSONPatchType)).
+			To(apiHandler.handlePatchResourceWithMultiTenancy))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/_raw/{kind}/namespace/{namespace}/name/{name}/{field}/{operation}").
+			To(apiHandler.handleUpdateResourceMetadata).
+			Reads(labeling.MetadataSpec{}).
+			Writes(labeling.Metadata{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/_raw/{kind}/name/{name}/{field}/{operation}").
+			To(apiHandler.handleUpdateResourceMetadata).
+			Reads(labeling.MetadataSpec{}).
+			Writes(labeling.Metadata{}))
+
 	apiV1Ws.Route(
 		apiV1Ws.GET("/role/{namespace}").
 			To(apiHandler.handleGetRoleList).
@@ -1355,6 +1587,10 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/log/file/{namespace}/{pod}/{container}").
 			To(apiHandler.handleLogFile).
 			Writes(logs.LogDetails{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/log/instances/{namespace}/{pod}").
+			To(apiHandler.handleLogInstances).
+			Writes(container.ContainerInstanceList{}))
 
 	apiV1Ws.Route(
 		apiV1Ws.GET("/tenants/{tenant}/log/source/{namespace}/{resourceName}/{resourceType}").
@@ -1372,6 +1608,58 @@ func CreateHTTPAPIHandler(iManager integration.IntegrationManager, tpManager cli
 		apiV1Ws.GET("/tenants/{tenant}/log/file/{namespace}/{pod}/{container}").
 			To(apiHandler.handleLogFileWithMultiTenancy).
 			Writes(logs.LogDetails{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/log/instances/{namespace}/{pod}").
+			To(apiHandler.handleLogInstancesWithMultiTenancy).
+			Writes(container.ContainerInstanceList{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/log/export").
+			To(apiHandler.handleStartLogExportWithMultiTenancy).
+			Reads(export.ExportSpec{}).
+			Writes(export.Job{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/log/export/{job}").
+			To(apiHandler.handleGetLogExportWithMultiTenancy).
+			Writes(export.Job{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/log/export/{job}/archive").
+			To(apiHandler.handleLogExportArchiveWithMultiTenancy).
+			Produces("application/gzip"))
+
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/usage").
+			To(apiHandler.handleGetTenantUsage).
+			Produces(restful.MIME_JSON, "text/csv").
+			Writes(usage.TenantUsage{}))
+
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/alerts").
+			To(apiHandler.handleGetAlerts).
+			Writes(alert.AlertList{}))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/alertrules").
+			To(apiHandler.handleGetAlertRules).
+			Writes([]alert.Rule{}))
+	apiV1Ws.Route(
+		apiV1Ws.PUT("/tenants/{tenant}/alertrules/{name}").
+			To(apiHandler.handlePutAlertRule).
+			Reads(alert.Rule{}).
+			Writes(alert.Rule{}))
+	apiV1Ws.Route(
+		apiV1Ws.DELETE("/tenants/{tenant}/alertrules/{name}").
+			To(apiHandler.handleDeleteAlertRule))
+	apiV1Ws.Route(
+		apiV1Ws.GET("/tenants/{tenant}/alertsilences").
+			To(apiHandler.handleGetAlertSilences).
+			Writes([]alert.Silence{}))
+	apiV1Ws.Route(
+		apiV1Ws.POST("/tenants/{tenant}/alertsilences").
+			To(apiHandler.handleCreateAlertSilence).
+			Reads(alert.Silence{}).
+			Writes(alert.Silence{}))
+	apiV1Ws.Route(
+		apiV1Ws.DELETE("/tenants/{tenant}/alertsilences/{id}").
+			To(apiHandler.handleDeleteAlertSilence))
 
 	// IAM User related routes
 	apiV1Ws.Route(
@@ -1415,7 +1703,7 @@ func (apiHandler *APIHandlerV2) handleCreateTenant(request *restful.Request, res
 		return
 	}
 
-	userdetail, _ := db.GetUser(tenantSpec.Username)
+	userdetail, _ := db.GetUser(request.Request.Context(), tenantSpec.Username)
 	if userdetail.ObjectMeta.Username != "" {
 		errors.HandleInternalError(response, errors.NewInternal("User already exists"))
 		return
@@ -1450,7 +1738,7 @@ func (apiHandler *APIHandlerV2) handleCreateTenant(request *restful.Request, res
 	if err != nil {
 		log.Printf("Error creating tenant admin user: %s", err.Error())
 	}
-	_ = db.InsertUser(user)
+	_ = db.InsertUser(request.Request.Context(), user)
 	tenantSpec.Username = user.Username
 	tenantSpec.Password = user.Password
 	response.WriteHeaderAndEntity(http.StatusCreated, tenantSpec)
@@ -1470,7 +1758,7 @@ func (apiHandler *APIHandlerV2) handleDeleteTenant(request *restful.Request, res
 		errors.HandleInternalError(response, err)
 		return
 	} else {
-		db.DeleteTenantUser(tenantName)
+		db.DeleteTenantUser(request.Request.Context(), tenantName)
 	}
 	response.WriteHeader(http.StatusOK)
 }
@@ -1712,7 +2000,7 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetList(request *restful.Reques
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
 	result, err := statefulset.GetStatefulSetList(k8sClient, namespace, dataSelect,
-		apiHandler.iManager.Metric().Client())
+		apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -1735,7 +2023,7 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetListWithMultitenancy(request
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
 	result, err := statefulset.GetStatefulSetListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect,
-		apiHandler.iManager.Metric().Client())
+		apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -1757,7 +2045,7 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetDetail(request *restful.Requ
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("statefulset")
-	result, err := statefulset.GetStatefulSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	result, err := statefulset.GetStatefulSetDetail(k8sClient, apiHandler.metricClient(client), namespace, name)
 
 	if err != nil {
 		errors.HandleInternalError(response, err)
@@ -1779,7 +2067,7 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetDetailWithMultiTenancy(reque
 	}
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("statefulset")
-	result, err := statefulset.GetStatefulSetDetailWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, name)
+	result, err := statefulset.GetStatefulSetDetailWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, name)
 
 	if err != nil {
 		errors.HandleInternalError(response, err)
@@ -1804,7 +2092,7 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetPods(request *restful.Reques
 	name := request.PathParameter("statefulset")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := statefulset.GetStatefulSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name, namespace)
+	result, err := statefulset.GetStatefulSetPods(k8sClient, apiHandler.metricClient(client), dataSelect, name, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -1828,7 +2116,7 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetPodsWithMultiTenancy(request
 	name := request.PathParameter("statefulset")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := statefulset.GetStatefulSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name, namespace)
+	result, err := statefulset.GetStatefulSetPods(k8sClient, apiHandler.metricClient(client), dataSelect, name, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -1882,6 +2170,106 @@ func (apiHandler *APIHandlerV2) handleGetStatefulSetEventsWithMultiTenancy(reque
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
+func (apiHandler *APIHandlerV2) handleRestartStatefulSet(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("statefulset")
+	result, err := statefulset.RestartStatefulSet(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleUpdateStatefulSetPartition(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(statefulset.PartitionSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("statefulset")
+	result, err := statefulset.UpdateStatefulSetPartition(k8sClient, tenant, namespace, name, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleRollbackStatefulSet(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(controllerrevision.RollbackSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("statefulset")
+	result, err := statefulset.RollbackStatefulSet(k8sClient, tenant, namespace, name, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetStatefulSetRevisionHistory(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("statefulset")
+	result, err := statefulset.GetStatefulSetRevisionHistory(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
 func (apiHandler *APIHandlerV2) handleGetServiceList(request *restful.Request, response *restful.Response) {
 	tenant := request.PathParameter("tenant")
 	if len(apiHandler.tpManager) == 0 {
@@ -2149,7 +2537,7 @@ func (apiHandler *APIHandlerV2) handleGetServicePods(request *restful.Request, r
 	name := request.PathParameter("service")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := resourceService.GetServicePods(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name, dataSelect)
+	result, err := resourceService.GetServicePods(k8sClient, apiHandler.metricClient(client), namespace, name, dataSelect)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2187,7 +2575,7 @@ func (apiHandler *APIHandlerV2) handleGetServicePodsWithMultiTenancy(request *re
 	name := request.PathParameter("service")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := resourceService.GetServicePodsWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, name, dataSelect)
+	result, err := resourceService.GetServicePodsWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, name, dataSelect)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2211,7 +2599,7 @@ func (apiHandler *APIHandlerV2) handleGetNodeLists(request *restful.Request, res
 		//}
 		dataSelect := parseDataSelectPathParameter(request)
 		dataSelect.MetricQuery = dataselect.StandardMetrics
-		result, err := node.GetNodeList(k8sClient, dataSelect, apiHandler.iManager.Metric().Client(), tpManager.GetClusterName())
+		result, err := node.GetNodeList(k8sClient, dataSelect, apiHandler.metricClient(tpManager), tpManager.GetClusterName())
 		if err != nil {
 			errors.HandleInternalError(response, err)
 			return
@@ -2234,7 +2622,7 @@ func (apiHandler *APIHandlerV2) handleGetNodeLists(request *restful.Request, res
 		k8sClient := rpManager.InsecureClient()
 		dataSelect := parseDataSelectPathParameter(request)
 		dataSelect.MetricQuery = dataselect.StandardMetrics
-		result, err := node.GetNodeList(k8sClient, dataSelect, apiHandler.iManager.Metric().Client(), rpManager.GetClusterName())
+		result, err := node.GetNodeList(k8sClient, dataSelect, apiHandler.metricClient(rpManager), rpManager.GetClusterName())
 		if err != nil {
 			errors.HandleInternalError(response, err)
 			return
@@ -2260,8 +2648,10 @@ func (apiHandler *APIHandlerV2) handleGetResourcePartitionDetail(request *restfu
 		//	return
 		//}
 		dataSelect := parseDataSelectPathParameter(request)
-		dataSelect.MetricQuery = dataselect.StandardMetrics
-		partitionDetail, err := partition.GetResourcePartitionDetail(k8sClient, rpManager.GetClusterName())
+		dataSelect.MetricQuery = dataselect.NewMetricQueryWithWindow(dataselect.StandardMetrics.MetricNames,
+			dataselect.StandardMetrics.Aggregations, dataSelect.MetricQuery.Window)
+		partitionDetail, err := partition.GetResourcePartitionDetail(k8sClient, apiHandler.metricClient(rpManager),
+			dataSelect, rpManager.GetClusterName())
 		if err != nil {
 			errors.HandleInternalError(response, err)
 			return
@@ -2328,12 +2718,14 @@ func (apiHandler *APIHandlerV2) handleGetNodeDetail(request *restful.Request, re
 	var k8sClient kubernetes.Interface
 	var err error
 	var clusterName string
+	var cManager clientapi.ClientManager
 	for _, rpManager := range apiHandler.rpManager {
 		k8sClient = rpManager.InsecureClient()
+		cManager = rpManager
 		dataSelect := parseDataSelectPathParameter(request)
 		dataSelect.MetricQuery = dataselect.StandardMetrics
 		clusterName = rpManager.GetClusterName()
-		_, err = node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+		_, err = node.GetNodeDetail(k8sClient, apiHandler.metricClient(rpManager), name, dataSelect, clusterName)
 		if err != nil {
 			log.Printf("Invalid Client or Internal Error %s", err.Error())
 			//errors.HandleInternalError(response, err)
@@ -2344,10 +2736,11 @@ func (apiHandler *APIHandlerV2) handleGetNodeDetail(request *restful.Request, re
 	if err != nil {
 		for _, tpManager := range apiHandler.tpManager {
 			k8sClient = tpManager.InsecureClient()
+			cManager = tpManager
 			dataSelect := parseDataSelectPathParameter(request)
 			clusterName = tpManager.GetClusterName()
 			dataSelect.MetricQuery = dataselect.StandardMetrics
-			_, err = node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+			_, err = node.GetNodeDetail(k8sClient, apiHandler.metricClient(tpManager), name, dataSelect, clusterName)
 			if err != nil {
 				log.Printf("Invalid Client or Internal Error %s", err.Error())
 				//errors.HandleInternalError(response, err)
@@ -2357,8 +2750,9 @@ func (apiHandler *APIHandlerV2) handleGetNodeDetail(request *restful.Request, re
 		}
 	}
 	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+	dataSelect.MetricQuery = dataselect.NewMetricQueryWithWindow(node.NodeMetrics, metricapi.OnlySumAggregation,
+		dataSelect.MetricQuery.Window)
+	result, err := node.GetNodeDetail(k8sClient, apiHandler.metricClient(cManager), name, dataSelect, clusterName)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2385,7 +2779,7 @@ func (apiHandler *APIHandlerV2) handleGetNodeEvents(request *restful.Request, re
 		dataSelect := parseDataSelectPathParameter(request)
 		dataSelect.MetricQuery = dataselect.StandardMetrics
 		clusterName = rpManager.GetClusterName()
-		_, err = node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+		_, err = node.GetNodeDetail(k8sClient, apiHandler.metricClient(rpManager), name, dataSelect, clusterName)
 		if err != nil {
 			log.Printf("Invalid Client or Internal Error %s", err.Error())
 			//errors.HandleInternalError(response, err)
@@ -2399,7 +2793,7 @@ func (apiHandler *APIHandlerV2) handleGetNodeEvents(request *restful.Request, re
 			dataSelect := parseDataSelectPathParameter(request)
 			dataSelect.MetricQuery = dataselect.StandardMetrics
 			clusterName = tpManager.GetClusterName()
-			_, err = node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+			_, err = node.GetNodeDetail(k8sClient, apiHandler.metricClient(tpManager), name, dataSelect, clusterName)
 			if err != nil {
 				log.Printf("Invalid Client or Internal Error %s", err.Error())
 				//errors.HandleInternalError(response, err)
@@ -2436,12 +2830,14 @@ func (apiHandler *APIHandlerV2) handleGetNodePods(request *restful.Request, resp
 	var k8sClient kubernetes.Interface
 	var err error
 	var clusterName string
+	var cManager clientapi.ClientManager
 	for _, rpManager := range apiHandler.rpManager {
 		k8sClient = rpManager.InsecureClient()
+		cManager = rpManager
 		dataSelect := parseDataSelectPathParameter(request)
 		dataSelect.MetricQuery = dataselect.StandardMetrics
 		clusterName = rpManager.GetClusterName()
-		_, err = node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+		_, err = node.GetNodeDetail(k8sClient, apiHandler.metricClient(rpManager), name, dataSelect, clusterName)
 		if err != nil {
 			log.Printf("Invalid Client or Internal Error %s", err.Error())
 			//errors.HandleInternalError(response, err)
@@ -2452,10 +2848,11 @@ func (apiHandler *APIHandlerV2) handleGetNodePods(request *restful.Request, resp
 	if err != nil {
 		for _, tpManager := range apiHandler.tpManager {
 			k8sClient = tpManager.InsecureClient()
+			cManager = tpManager
 			dataSelect := parseDataSelectPathParameter(request)
 			dataSelect.MetricQuery = dataselect.StandardMetrics
 			clusterName = tpManager.GetClusterName()
-			_, err = node.GetNodeDetail(k8sClient, apiHandler.iManager.Metric().Client(), name, dataSelect, clusterName)
+			_, err = node.GetNodeDetail(k8sClient, apiHandler.metricClient(tpManager), name, dataSelect, clusterName)
 			if err != nil {
 				log.Printf("Invalid Client or Internal Error %s", err.Error())
 				//errors.HandleInternalError(response, err)
@@ -2465,8 +2862,9 @@ func (apiHandler *APIHandlerV2) handleGetNodePods(request *restful.Request, resp
 		}
 	}
 	dataSelect := parseDataSelectPathParameter(request)
-	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := node.GetNodePods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name)
+	dataSelect.MetricQuery = dataselect.NewMetricQueryWithWindow(dataselect.StandardMetrics.MetricNames,
+		dataselect.StandardMetrics.Aggregations, dataSelect.MetricQuery.Window)
+	result, err := node.GetNodePods(k8sClient, apiHandler.metricClient(cManager), dataSelect, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2474,6 +2872,47 @@ func (apiHandler *APIHandlerV2) handleGetNodePods(request *restful.Request, resp
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
+func (apiHandler *APIHandlerV2) handleGetNodeTopPods(request *restful.Request, response *restful.Response) {
+	if len(apiHandler.rpManager) == 0 {
+		apiHandler.rpManager = append(apiHandler.rpManager, apiHandler.defaultClientmanager)
+	}
+	name := request.PathParameter("name")
+	metricName := request.QueryParameter("metricName")
+	if metricName == "" {
+		metricName = metricapi.CpuUsage
+	}
+	limit := node.DefaultTopPodsLimit
+	if limitParam := request.QueryParameter("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed <= 0 {
+			errors.HandleInternalError(response, errors.NewBadRequest("limit has to be a positive number"))
+			return
+		}
+		limit = parsed
+	}
+
+	// Nodes are looked up in the resource partitions first, same as for the node detail.
+	var err error
+	managers := append(append([]clientapi.ClientManager{}, apiHandler.rpManager...), apiHandler.tpManager...)
+	for _, cManager := range managers {
+		k8sClient := cManager.InsecureClient()
+		if _, err = k8sClient.CoreV1().Nodes().Get(name, metaV1.GetOptions{}); err != nil {
+			log.Printf("Invalid Client or Internal Error %s", err.Error())
+			continue
+		}
+
+		result, err := node.GetNodeTopPods(k8sClient, apiHandler.metricClient(cManager), name, metricName, limit,
+			parseMetricWindowParameter(request))
+		if err != nil {
+			errors.HandleInternalError(response, err)
+			return
+		}
+		response.WriteHeaderAndEntity(http.StatusOK, result)
+		return
+	}
+	errors.HandleInternalError(response, err)
+}
+
 func (apiHandler *APIHandlerV2) handleDeploy(request *restful.Request, response *restful.Response) {
 
 	appDeploymentSpec := new(deployment.AppDeploymentSpec)
@@ -2499,6 +2938,30 @@ func (apiHandler *APIHandlerV2) handleDeploy(request *restful.Request, response
 	response.WriteHeaderAndEntity(http.StatusCreated, appDeploymentSpec)
 }
 
+func (apiHandler *APIHandlerV2) handleDeployVirtualMachine(request *restful.Request, response *restful.Response) {
+	vmDeploymentSpec := new(deployment.VirtualMachineDeploymentSpec)
+	if err := request.ReadEntity(vmDeploymentSpec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", vmDeploymentSpec.Tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	if err := deployment.DeployVirtualMachine(vmDeploymentSpec, k8sClient); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, vmDeploymentSpec)
+}
+
 func (apiHandler *APIHandlerV2) handleScaleResource(request *restful.Request, response *restful.Response) {
 
 	tenant := request.PathParameter("tenant")
@@ -2548,6 +3011,45 @@ func (apiHandler *APIHandlerV2) handleScaleResourceWithMultiTenancy(request *res
 	response.WriteHeaderAndEntity(http.StatusOK, replicaCountSpec)
 }
 
+func (apiHandler *APIHandlerV2) handleBulkAction(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	config, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	verber, err := client.VerberClient(request, config)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	// Created after the verber, since the scale client changes the group version of the config.
+	scaler, err := scaling.NewScaleClient(config)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(bulk.ActionSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := bulk.Execute(verber, scaler, tenant, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
 func (apiHandler *APIHandlerV2) handleGetReplicaCount(request *restful.Request, response *restful.Response) {
 	log.Println("handleGetReplicaCount")
 	tenant := request.PathParameter("tenant")
@@ -2613,25 +3115,45 @@ func (apiHandler *APIHandlerV2) handleDeployFromFile(request *restful.Request, r
 		return
 	}
 
-	isDeployed, err := deployment.DeployAppFromFile(cfg, deploymentSpec)
-	if !isDeployed {
+	documents, err := deployment.DeployAppFromFile(cfg, client.RESTMapper(), deploymentSpec)
+	if err != nil && documents == nil {
 		errors.HandleInternalError(response, err)
 		return
 	}
 
+	status := http.StatusCreated
+	if deploymentSpec.DryRun || deploymentSpec.Preview {
+		status = http.StatusOK
+	}
+
+	// The deployment has been rolled back. The status of every document is reported with the error.
 	errorMessage := ""
 	if err != nil {
 		errorMessage = err.Error()
+		status = errors.StatusCode(err)
 	}
 
-	response.WriteHeaderAndEntity(http.StatusCreated, deployment.AppDeploymentFromFileResponse{
-		Name:    deploymentSpec.Name,
-		Content: deploymentSpec.Content,
-		Error:   errorMessage,
+	response.WriteHeaderAndEntity(status, deployment.AppDeploymentFromFileResponse{
+		Name:      deploymentSpec.Name,
+		Content:   deploymentSpec.Content,
+		Error:     errorMessage,
+		DryRun:    deploymentSpec.DryRun,
+		Preview:   deploymentSpec.Preview,
+		Documents: documents,
 	})
 }
 
-func (apiHandler *APIHandlerV2) handleNameValidity(request *restful.Request, response *restful.Response) {
+func (apiHandler *APIHandlerV2) handleGetHelmChartList(request *restful.Request, response *restful.Response) {
+	result, err := helm.GetRepositoryChartList(args.Holder.GetHelmRepository())
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// helmReleaseClient returns a client for the releases of the tenant and namespace of the request path.
+func (apiHandler *APIHandlerV2) helmReleaseClient(request *restful.Request) (*helm.ReleaseClient, error) {
 	tenant := request.PathParameter("tenant")
 	if len(apiHandler.tpManager) == 0 {
 		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
@@ -2639,13 +3161,163 @@ func (apiHandler *APIHandlerV2) handleNameValidity(request *restful.Request, res
 	client := ResourceAllocator("", tenant, apiHandler.tpManager)
 	k8sClient, err := client.Client(request)
 	if err != nil {
-		errors.HandleInternalError(response, err)
-		return
+		return nil, err
 	}
-
-	spec := new(validation.AppNameValiditySpec)
-	if err := request.ReadEntity(spec); err != nil {
-		errors.HandleInternalError(response, err)
+	cfg, err := client.Config(request)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return helm.NewReleaseClient(k8sClient, dynamicClient, client.RESTMapper(), nil,
+		request.PathParameter("namespace"), tenant), nil
+}
+
+func (apiHandler *APIHandlerV2) handleGetHelmReleaseList(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := releaseClient.List()
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleInstallHelmRelease(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(helm.InstallSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := releaseClient.Install(args.Holder.GetHelmRepository(), spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	status := http.StatusCreated
+	if spec.DryRun {
+		status = http.StatusOK
+	}
+	response.WriteHeaderAndEntity(status, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetHelmRelease(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := releaseClient.Get(request.PathParameter("release"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleUpgradeHelmRelease(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(helm.UpgradeSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := releaseClient.Upgrade(args.Holder.GetHelmRepository(), request.PathParameter("release"), spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleUninstallHelmRelease(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	keepHistory := request.QueryParameter("keepHistory") == "true"
+	result, err := releaseClient.Uninstall(request.PathParameter("release"), keepHistory)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetHelmReleaseHistory(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := releaseClient.History(request.PathParameter("release"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleRollbackHelmRelease(request *restful.Request, response *restful.Response) {
+	releaseClient, err := apiHandler.helmReleaseClient(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(helm.RollbackSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := releaseClient.Rollback(request.PathParameter("release"), spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleNameValidity(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(validation.AppNameValiditySpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
 		return
 	}
 
@@ -2701,7 +3373,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicationControllerList(request *rest
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicationcontroller.GetReplicationControllerList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := replicationcontroller.GetReplicationControllerList(k8sClient, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2723,7 +3395,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicationControllerListWithMultiTenan
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicationcontroller.GetReplicationControllerListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := replicationcontroller.GetReplicationControllerListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2746,7 +3418,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicaSets(request *restful.Request, r
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicaset.GetReplicaSetList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := replicaset.GetReplicaSetList(k8sClient, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2783,7 +3455,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicaSetsWithMultiTenancy(request *re
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicaset.GetReplicaSetListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := replicaset.GetReplicaSetListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2805,7 +3477,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicaSetDetail(request *restful.Reque
 
 	namespace := request.PathParameter("namespace")
 	replicaSet := request.PathParameter("replicaSet")
-	result, err := replicaset.GetReplicaSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, replicaSet)
+	result, err := replicaset.GetReplicaSetDetail(k8sClient, apiHandler.metricClient(client), namespace, replicaSet)
 
 	if err != nil {
 		errors.HandleInternalError(response, err)
@@ -2843,7 +3515,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicaSetDetailWithMultiTenancy(reques
 	}
 	namespace := request.PathParameter("namespace")
 	replicaSet := request.PathParameter("replicaSet")
-	result, err := replicaset.GetReplicaSetDetailWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, replicaSet)
+	result, err := replicaset.GetReplicaSetDetailWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, replicaSet)
 
 	if err != nil {
 		errors.HandleInternalError(response, err)
@@ -2869,7 +3541,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicaSetPods(request *restful.Request
 	replicaSet := request.PathParameter("replicaSet")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicaset.GetReplicaSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, replicaSet, namespace)
+	result, err := replicaset.GetReplicaSetPods(k8sClient, apiHandler.metricClient(client), dataSelect, replicaSet, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -2908,7 +3580,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicaSetPodsWithMutiTenancy(request *
 	replicaSet := request.PathParameter("replicaSet")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicaset.GetReplicaSetPodsWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, dataSelect, replicaSet, namespace)
+	result, err := replicaset.GetReplicaSetPodsWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, dataSelect, replicaSet, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3134,9 +3806,11 @@ func (apiHandler *APIHandlerV2) handleExecShell(request *restful.Request, respon
 	}
 
 	terminalSessions.Set(sessionId, TerminalSession{
-		id:       sessionId,
-		bound:    make(chan error),
-		sizeChan: make(chan remotecommand.TerminalSize),
+		id:        sessionId,
+		bound:     make(chan error),
+		sizeChan:  make(chan remotecommand.TerminalSize),
+		partition: client.GetClusterName(),
+		tenant:    tenant,
 	})
 	go WaitForTerminal(k8sClient, cfg, request, sessionId)
 	response.WriteHeaderAndEntity(http.StatusOK, TerminalResponse{Id: sessionId})
@@ -3168,9 +3842,11 @@ func (apiHandler *APIHandlerV2) handleExecShellWithMultiTenancy(request *restful
 	}
 
 	terminalSessions.Set(sessionId, TerminalSession{
-		id:       sessionId,
-		bound:    make(chan error),
-		sizeChan: make(chan remotecommand.TerminalSize),
+		id:        sessionId,
+		bound:     make(chan error),
+		sizeChan:  make(chan remotecommand.TerminalSize),
+		partition: client.GetClusterName(),
+		tenant:    tenant,
 	})
 
 	go WaitForTerminalWithMultiTenancy(k8sClient, cfg, request, sessionId, tenant)
@@ -3192,7 +3868,7 @@ func (apiHandler *APIHandlerV2) handleGetDeployments(request *restful.Request, r
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := deployment.GetDeploymentList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := deployment.GetDeploymentList(k8sClient, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3230,7 +3906,7 @@ func (apiHandler *APIHandlerV2) handleGetDeploymentsWithMultiTenancy(request *re
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := deployment.GetDeploymentListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := deployment.GetDeploymentListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3423,6 +4099,144 @@ func (apiHandler *APIHandlerV2) handleGetDeploymentOldReplicaSetsWithMultiTenanc
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
+func (apiHandler *APIHandlerV2) handleGetDeploymentRolloutStatus(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := deployment.GetDeploymentRolloutStatus(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetDeploymentRolloutHistory(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := deployment.GetDeploymentRolloutHistory(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handlePauseDeployment(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := deployment.PauseDeployment(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleResumeDeployment(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := deployment.ResumeDeployment(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleRestartDeployment(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := deployment.RestartDeployment(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleRollbackDeployment(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(deployment.RollbackSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("deployment")
+	result, err := deployment.RollbackDeployment(k8sClient, tenant, namespace, name, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
 func (apiHandler *APIHandlerV2) handleGetDeploymentNewReplicaSet(request *restful.Request, response *restful.Response) {
 	tenant := request.PathParameter("tenant")
 	if len(apiHandler.tpManager) == 0 {
@@ -3501,7 +4315,7 @@ func (apiHandler *APIHandlerV2) handleGetPods(request *restful.Request, response
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics // download standard metrics - cpu, and memory - by default
-	result, err := pod.GetPodList(k8sClient, apiHandler.iManager.Metric().Client(), namespace, dataSelect)
+	result, err := pod.GetPodList(k8sClient, apiHandler.metricClient(client), namespace, dataSelect)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3539,7 +4353,7 @@ func (apiHandler *APIHandlerV2) handleGetPodsWithMultiTenancy(request *restful.R
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics // download standard metrics - cpu, and memory - by default
-	result, err := pod.GetPodListWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, dataSelect)
+	result, err := pod.GetPodListWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, dataSelect)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3561,7 +4375,7 @@ func (apiHandler *APIHandlerV2) handleGetVMsWithMultiTenancy(request *restful.Re
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics // download standard metrics - cpu, and memory - by default
-	result, err := vm.GetVMListWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, dataSelect)
+	result, err := vm.GetVMListWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, dataSelect)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3583,7 +4397,7 @@ func (apiHandler *APIHandlerV2) handleGetPodDetail(request *restful.Request, res
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("pod")
-	result, err := pod.GetPodDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	result, err := pod.GetPodDetail(k8sClient, apiHandler.metricClient(client), namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3619,7 +4433,7 @@ func (apiHandler *APIHandlerV2) handleGetPodDetailWithMultiTenancy(request *rest
 	}
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("pod")
-	result, err := pod.GetPodDetailWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, name)
+	result, err := pod.GetPodDetailWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3641,7 +4455,7 @@ func (apiHandler *APIHandlerV2) handleGetVMDetailWithMultiTenancy(request *restf
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("virtualmachine")
-	result, err := vm.GetVirtualMachineDetailWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, name)
+	result, err := vm.GetVirtualMachineDetailWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -3892,7 +4706,7 @@ func (apiHandler *APIHandlerV2) handlePutResourceWithMultiTenancy(request *restf
 	response.WriteHeader(http.StatusCreated)
 }
 
-func (apiHandler *APIHandlerV2) handleDeleteResource(request *restful.Request, response *restful.Response) {
+func (apiHandler *APIHandlerV2) handlePatchResourceWithMultiTenancy(request *restful.Request, response *restful.Response) {
 	tenant := request.PathParameter("tenant")
 	if len(apiHandler.tpManager) == 0 {
 		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
@@ -3913,15 +4727,90 @@ func (apiHandler *APIHandlerV2) handleDeleteResource(request *restful.Request, r
 	kind := request.PathParameter("kind")
 	namespace, ok := request.PathParameters()["namespace"]
 	name := request.PathParameter("name")
+	patchType := types.PatchType(strings.TrimSpace(strings.Split(request.HeaderParameter("Content-Type"), ";")[0]))
+	data, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
 
-	if err := verber.Delete(kind, ok, namespace, name); err != nil {
+	result, err := verber.PatchWithMultiTenancy(kind, tenant, ok, namespace, name, patchType, data)
+	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
 	}
 
-	// Try to unpin resource if it was pinned.
-	pinnedResource := &settingsApi.PinnedResource{
-		Name:      name,
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleUpdateResourceMetadata(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	config, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	verber, err := client.VerberClient(request, config)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	kind := request.PathParameter("kind")
+	namespace, ok := request.PathParameters()["namespace"]
+	name := request.PathParameter("name")
+	field := labeling.Field(request.PathParameter("field"))
+	operation := labeling.Operation(request.PathParameter("operation"))
+	spec := new(labeling.MetadataSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	result, err := labeling.UpdateMetadata(verber, tenant, kind, ok, namespace, name, field, operation, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleDeleteResource(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	config, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	verber, err := client.VerberClient(request, config)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	kind := request.PathParameter("kind")
+	namespace, ok := request.PathParameters()["namespace"]
+	name := request.PathParameter("name")
+
+	if err := verber.Delete(kind, ok, namespace, name); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	// Try to unpin resource if it was pinned.
+	pinnedResource := &settingsApi.PinnedResource{
+		Name:      name,
 		Kind:      kind,
 		Namespace: namespace,
 	}
@@ -4003,7 +4892,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicationControllerPods(request *rest
 	rc := request.PathParameter("replicationController")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicationcontroller.GetReplicationControllerPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, rc, namespace)
+	result, err := replicationcontroller.GetReplicationControllerPods(k8sClient, apiHandler.metricClient(client), dataSelect, rc, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -4026,7 +4915,7 @@ func (apiHandler *APIHandlerV2) handleGetReplicationControllerPodsWithMultiTenan
 	rc := request.PathParameter("replicationController")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := replicationcontroller.GetReplicationControllerPodsWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, tenant, rc, namespace)
+	result, err := replicationcontroller.GetReplicationControllerPodsWithMultiTenancy(k8sClient, apiHandler.metricClient(client), dataSelect, tenant, rc, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5514,7 +6403,7 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetList(request *restful.Request,
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := daemonset.GetDaemonSetList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := daemonset.GetDaemonSetList(k8sClient, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5536,7 +6425,7 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetListWithMultiTenancy(request *
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := daemonset.GetDaemonSetListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := daemonset.GetDaemonSetListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5559,7 +6448,7 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetDetail(
 
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("daemonSet")
-	result, err := daemonset.GetDaemonSetDetail(k8sClient, apiHandler.iManager.Metric().Client(), namespace, name)
+	result, err := daemonset.GetDaemonSetDetail(k8sClient, apiHandler.metricClient(client), namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5581,7 +6470,7 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetDetailWithMultiTenancy(
 	}
 	namespace := request.PathParameter("namespace")
 	name := request.PathParameter("daemonSet")
-	result, err := daemonset.GetDaemonSetDetailWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), tenant, namespace, name)
+	result, err := daemonset.GetDaemonSetDetailWithMultiTenancy(k8sClient, apiHandler.metricClient(client), tenant, namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5605,7 +6494,7 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetPods(request *restful.Request,
 	name := request.PathParameter("daemonSet")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := daemonset.GetDaemonSetPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, name, namespace)
+	result, err := daemonset.GetDaemonSetPods(k8sClient, apiHandler.metricClient(client), dataSelect, name, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5628,7 +6517,7 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetPodsWithMultiTenancy(request *
 	name := request.PathParameter("daemonSet")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := daemonset.GetDaemonSetPodsWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, tenant, name, namespace)
+	result, err := daemonset.GetDaemonSetPodsWithMultiTenancy(k8sClient, apiHandler.metricClient(client), dataSelect, tenant, name, namespace)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5726,6 +6615,78 @@ func (apiHandler *APIHandlerV2) handleGetDaemonSetEventsWithMultiTenancy(request
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
+func (apiHandler *APIHandlerV2) handleRestartDaemonSet(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("daemonSet")
+	result, err := daemonset.RestartDaemonSet(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleRollbackDaemonSet(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(controllerrevision.RollbackSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("daemonSet")
+	result, err := daemonset.RollbackDaemonSet(k8sClient, tenant, namespace, name, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetDaemonSetRevisionHistory(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("daemonSet")
+	result, err := daemonset.GetDaemonSetRevisionHistory(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
 func (apiHandler *APIHandlerV2) handleGetHorizontalPodAutoscalerList(request *restful.Request,
 	response *restful.Response) {
 	tenant := request.PathParameter("tenant")
@@ -5771,6 +6732,128 @@ func (apiHandler *APIHandlerV2) handleGetHorizontalPodAutoscalerDetail(request *
 	response.WriteHeaderAndEntity(http.StatusOK, result)
 }
 
+func (apiHandler *APIHandlerV2) handleCreateHorizontalPodAutoscaler(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(horizontalpodautoscaler.HorizontalPodAutoscalerSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	result, err := horizontalpodautoscaler.CreateHorizontalPodAutoscalerWithMultiTenancy(k8sClient, tenant, namespace, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetHorizontalPodAutoscalerDetailWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("horizontalpodautoscaler")
+	result, err := horizontalpodautoscaler.GetHorizontalPodAutoscalerDetailWithMultiTenancy(k8sClient, tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleUpdateHorizontalPodAutoscaler(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := new(horizontalpodautoscaler.HorizontalPodAutoscalerSpec)
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("horizontalpodautoscaler")
+	result, err := horizontalpodautoscaler.UpdateHorizontalPodAutoscalerWithMultiTenancy(k8sClient, tenant, namespace, name, spec)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleDeleteHorizontalPodAutoscaler(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("horizontalpodautoscaler")
+	if err := horizontalpodautoscaler.DeleteHorizontalPodAutoscalerWithMultiTenancy(k8sClient, tenant, namespace, name); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeader(http.StatusOK)
+}
+
+func (apiHandler *APIHandlerV2) handleGetHorizontalPodAutoscalerEvents(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	name := request.PathParameter("horizontalpodautoscaler")
+	dataSelect := parseDataSelectPathParameter(request)
+	result, err := horizontalpodautoscaler.GetEventsForHorizontalPodAutoscalerWithMultiTenancy(k8sClient, dataSelect,
+		tenant, namespace, name)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
 func (apiHandler *APIHandlerV2) handleGetJobList(request *restful.Request, response *restful.Response) {
 	tenant := request.PathParameter("tenant")
 	if len(apiHandler.tpManager) == 0 {
@@ -5786,7 +6869,7 @@ func (apiHandler *APIHandlerV2) handleGetJobList(request *restful.Request, respo
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := job.GetJobList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := job.GetJobList(k8sClient, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5808,7 +6891,7 @@ func (apiHandler *APIHandlerV2) handleGetJobListWithMultiTenancy(request *restfu
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := job.GetJobListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := job.GetJobListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5879,7 +6962,7 @@ func (apiHandler *APIHandlerV2) handleGetJobPods(request *restful.Request, respo
 	name := request.PathParameter("name")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := job.GetJobPods(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, namespace, name)
+	result, err := job.GetJobPods(k8sClient, apiHandler.metricClient(client), dataSelect, namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5902,7 +6985,7 @@ func (apiHandler *APIHandlerV2) handleGetJobPodsWithMultiTenancy(request *restfu
 	name := request.PathParameter("name")
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := job.GetJobPodsWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, tenant, namespace, name)
+	result, err := job.GetJobPodsWithMultiTenancy(k8sClient, apiHandler.metricClient(client), dataSelect, tenant, namespace, name)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5970,7 +7053,7 @@ func (apiHandler *APIHandlerV2) handleGetCronJobList(request *restful.Request, r
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := cronjob.GetCronJobList(k8sClient, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := cronjob.GetCronJobList(k8sClient, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -5992,7 +7075,7 @@ func (apiHandler *APIHandlerV2) handleGetCronJobListWithMultiTenancy(request *re
 	namespace := parseNamespacePathParameter(request)
 	dataSelect := parseDataSelectPathParameter(request)
 	dataSelect.MetricQuery = dataselect.StandardMetrics
-	result, err := cronjob.GetCronJobListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.iManager.Metric().Client())
+	result, err := cronjob.GetCronJobListWithMultiTenancy(k8sClient, tenant, namespace, dataSelect, apiHandler.metricClient(client))
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -6067,7 +7150,7 @@ func (apiHandler *APIHandlerV2) handleGetCronJobJobs(request *restful.Request, r
 	}
 
 	dataSelect := parseDataSelectPathParameter(request)
-	result, err := cronjob.GetCronJobJobs(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, namespace, name, active)
+	result, err := cronjob.GetCronJobJobs(k8sClient, apiHandler.metricClient(client), dataSelect, namespace, name, active)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -6094,7 +7177,7 @@ func (apiHandler *APIHandlerV2) handleGetCronJobJobsWithMultiTenancy(request *re
 	}
 
 	dataSelect := parseDataSelectPathParameter(request)
-	result, err := cronjob.GetCronJobJobsWithMultiTenancy(k8sClient, apiHandler.iManager.Metric().Client(), dataSelect, tenant, namespace, name, active)
+	result, err := cronjob.GetCronJobJobsWithMultiTenancy(k8sClient, apiHandler.metricClient(client), dataSelect, tenant, namespace, name, active)
 	if err != nil {
 		errors.HandleInternalError(response, err)
 		return
@@ -6950,13 +8033,477 @@ func (apiHandler *APIHandlerV2) handleLogFileWithMultiTenancy(request *restful.R
 	handleDownload(response, logStream)
 }
 
-// parseNamespacePathParameter parses namespace selector for list pages in path parameter.
-// The namespace selector is a comma separated list of namespaces that are trimmed.
-// No namespaces means "view all user namespaces", i.e., everything except kube-system.
-func parseNamespacePathParameter(request *restful.Request) *common.NamespaceQuery {
-	namespace := request.PathParameter("namespace")
+func (apiHandler *APIHandlerV2) handleLogInstances(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
 
-	namespaces := strings.Split(namespace, ",")
+	namespace := request.PathParameter("namespace")
+	podID := request.PathParameter("pod")
+	result, err := container.GetContainerInstances(k8sClient, namespace, podID)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleLogInstancesWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	podID := request.PathParameter("pod")
+	result, err := container.GetContainerInstancesWithMultiTenancy(k8sClient, tenant, namespace, podID)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleStartLogExportWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	owner, err := client.Identity(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	spec := export.NewExportSpec()
+	if err := request.ReadEntity(spec); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	// Jobs are always bound to the tenant of the request path.
+	spec.Tenant = tenant
+
+	job, err := apiHandler.logExportManager.Start(k8sClient, *spec, owner, export.NewLogStreamer(k8sClient, tenant))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusAccepted, job)
+}
+
+func (apiHandler *APIHandlerV2) handleGetLogExportWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	owner, err := client.Identity(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	job, err := apiHandler.logExportManager.Get(tenant, owner, request.PathParameter("job"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, job)
+}
+
+func (apiHandler *APIHandlerV2) handleLogExportArchiveWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	owner, err := client.Identity(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	jobID := request.PathParameter("job")
+	archive, err := apiHandler.logExportManager.Archive(tenant, owner, jobID)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	handleFileDownload(response, archive, "application/gzip", "logs-"+jobID+".tar.gz")
+}
+
+func (apiHandler *APIHandlerV2) handleGetTenantUsage(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	if _, err := client.Client(request); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	// The report is read from the store, so the user has to be allowed to see the pods it is built from.
+	namespace := request.QueryParameter("namespace")
+	if !authorizeRequest(client, request, response, clientapi.ToTenantSelfSubjectAccessReview(tenant, namespace,
+		"", "pods", "", "list")) {
+		return
+	}
+
+	if apiHandler.usageStore == nil {
+		errors.HandleInternalError(response, errors.NewGenericResponse(http.StatusServiceUnavailable,
+			"usage collection is not enabled"))
+		return
+	}
+
+	// The report covers the last day by default.
+	to := time.Now()
+	if value := request.QueryParameter("to"); len(value) > 0 {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors.HandleInternalError(response, errors.NewBadRequest("invalid to: "+err.Error()))
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-24 * time.Hour)
+	if value := request.QueryParameter("from"); len(value) > 0 {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errors.HandleInternalError(response, errors.NewBadRequest("invalid from: "+err.Error()))
+			return
+		}
+		from = parsed
+	}
+
+	result, err := usage.GetTenantUsage(apiHandler.usageStore, tenant, namespace, from, to)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	if request.QueryParameter("format") == "csv" {
+		buffer := new(bytes.Buffer)
+		if err := usage.WriteCSV(buffer, result); err != nil {
+			errors.HandleInternalError(response, err)
+			return
+		}
+		handleFileDownload(response, ioutil.NopCloser(buffer), "text/csv", "usage-"+tenant+".csv")
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+// capacityPlanParameters checks that the user is a system administrator, because the capacity of the
+// partitions covers all tenants, and returns the forecasting method and the horizon in days of a
+// capacity plan request. Errors are written to the response.
+func (apiHandler *APIHandlerV2) capacityPlanParameters(request *restful.Request, response *restful.Response) (string, int, bool) {
+	if _, err := apiHandler.defaultClientmanager.Client(request); err != nil {
+		errors.HandleInternalError(response, err)
+		return "", 0, false
+	}
+	if !authorizeRequest(apiHandler.defaultClientmanager, request, response,
+		clientapi.ToTenantSelfSubjectAccessReview(metaV1.TenantSystem, "", "", "*", "", "*")) {
+		return "", 0, false
+	}
+
+	if apiHandler.capacityStore == nil {
+		errors.HandleInternalError(response, errors.NewGenericResponse(http.StatusServiceUnavailable,
+			"capacity planning is not enabled"))
+		return "", 0, false
+	}
+
+	method := capacity.MethodLinear
+	if value := request.QueryParameter("method"); len(value) > 0 {
+		method = value
+	}
+	horizonDays := capacity.DefaultHorizonDays
+	if value := request.QueryParameter("horizon"); len(value) > 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			errors.HandleInternalError(response, errors.NewBadRequest("invalid horizon: "+err.Error()))
+			return "", 0, false
+		}
+		horizonDays = parsed
+	}
+	return method, horizonDays, true
+}
+
+func (apiHandler *APIHandlerV2) handleGetCapacityPlans(request *restful.Request, response *restful.Response) {
+	method, horizonDays, ok := apiHandler.capacityPlanParameters(request, response)
+	if !ok {
+		return
+	}
+
+	if len(apiHandler.rpManager) == 0 {
+		apiHandler.rpManager = append(apiHandler.rpManager, apiHandler.defaultClientmanager)
+	}
+	result := capacity.PlanList{Plans: make([]capacity.Plan, 0)}
+	now := time.Now()
+	for _, rpManager := range apiHandler.rpManager {
+		plan, err := capacity.GetPlan(apiHandler.capacityStore, rpManager.GetClusterName(), method, horizonDays, args.Holder.GetCapacityNodeCost(), now)
+		if err != nil {
+			errors.HandleInternalError(response, err)
+			return
+		}
+		result.Plans = append(result.Plans, *plan)
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleGetCapacityPlan(request *restful.Request, response *restful.Response) {
+	method, horizonDays, ok := apiHandler.capacityPlanParameters(request, response)
+	if !ok {
+		return
+	}
+
+	if len(apiHandler.rpManager) == 0 {
+		apiHandler.rpManager = append(apiHandler.rpManager, apiHandler.defaultClientmanager)
+	}
+	name := request.PathParameter("partition")
+	for _, rpManager := range apiHandler.rpManager {
+		if rpManager.GetClusterName() != name {
+			continue
+		}
+		plan, err := capacity.GetPlan(apiHandler.capacityStore, name, method, horizonDays, args.Holder.GetCapacityNodeCost(), time.Now())
+		if err != nil {
+			errors.HandleInternalError(response, err)
+			return
+		}
+		response.WriteHeaderAndEntity(http.StatusOK, plan)
+		return
+	}
+	errors.HandleInternalError(response, errors.NewNotFound("resource partition "+name+" not found"))
+}
+
+// getAlertController checks that the user has access to the tenant of the request and returns the
+// alert controller. Reading alerts needs access to the pods of the tenant, changing rules and
+// silences needs full access to the tenant. Rules of the system tenant cover all tenants, the
+// partitions and their nodes, so they are limited to system administrators. Errors are written to
+// the response and nil is returned.
+func (apiHandler *APIHandlerV2) getAlertController(request *restful.Request, response *restful.Response,
+	write bool) *alert.Controller {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	if _, err := client.Client(request); err != nil {
+		errors.HandleInternalError(response, err)
+		return nil
+	}
+
+	ssar := clientapi.ToTenantSelfSubjectAccessReview(tenant, "", "", "pods", "", "list")
+	if write || tenant == alert.SystemTenant {
+		ssar = clientapi.ToTenantSelfSubjectAccessReview(tenant, "", "", "*", "", "*")
+	}
+	if !authorizeRequest(client, request, response, ssar) {
+		return nil
+	}
+
+	if apiHandler.alertController == nil {
+		errors.HandleInternalError(response, errors.NewGenericResponse(http.StatusServiceUnavailable,
+			"alerting is not enabled"))
+		return nil
+	}
+	return apiHandler.alertController
+}
+
+func (apiHandler *APIHandlerV2) handleGetAlerts(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, false)
+	if controller == nil {
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, controller.Alerts(request.PathParameter("tenant")))
+}
+
+func (apiHandler *APIHandlerV2) handleGetAlertRules(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, false)
+	if controller == nil {
+		return
+	}
+
+	result, err := controller.Rules(request.PathParameter("tenant"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handlePutAlertRule(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, true)
+	if controller == nil {
+		return
+	}
+
+	rule := new(alert.Rule)
+	if err := request.ReadEntity(rule); err != nil {
+		errors.HandleInternalError(response, errors.NewBadRequest(err.Error()))
+		return
+	}
+	rule.Tenant = request.PathParameter("tenant")
+	rule.Name = request.PathParameter("name")
+
+	if err := controller.SaveRule(*rule); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, rule)
+}
+
+func (apiHandler *APIHandlerV2) handleDeleteAlertRule(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, true)
+	if controller == nil {
+		return
+	}
+
+	if err := controller.DeleteRule(request.PathParameter("tenant"), request.PathParameter("name")); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeader(http.StatusOK)
+}
+
+func (apiHandler *APIHandlerV2) handleGetAlertSilences(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, false)
+	if controller == nil {
+		return
+	}
+
+	result, err := controller.Silences(request.PathParameter("tenant"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusOK, result)
+}
+
+func (apiHandler *APIHandlerV2) handleCreateAlertSilence(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, true)
+	if controller == nil {
+		return
+	}
+
+	silence := new(alert.Silence)
+	if err := request.ReadEntity(silence); err != nil {
+		errors.HandleInternalError(response, errors.NewBadRequest(err.Error()))
+		return
+	}
+	silence.Tenant = request.PathParameter("tenant")
+
+	result, err := controller.AddSilence(*silence)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
+}
+
+func (apiHandler *APIHandlerV2) handleDeleteAlertSilence(request *restful.Request, response *restful.Response) {
+	controller := apiHandler.getAlertController(request, response, true)
+	if controller == nil {
+		return
+	}
+
+	if err := controller.DeleteSilence(request.PathParameter("tenant"), request.PathParameter("id")); err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeader(http.StatusOK)
+}
+
+func (apiHandler *APIHandlerV2) handleDownloadFileWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	podID := request.PathParameter("pod")
+	containerID := request.PathParameter("container")
+	srcPath := request.QueryParameter("path")
+
+	archive, err := container.CopyFromContainerWithMultiTenancy(k8sClient, cfg, tenant, namespace, podID, containerID, srcPath)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	handleFileDownload(response, archive, "application/x-tar", path.Base(srcPath)+".tar")
+}
+
+func (apiHandler *APIHandlerV2) handleUploadFileWithMultiTenancy(request *restful.Request, response *restful.Response) {
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	namespace := request.PathParameter("namespace")
+	podID := request.PathParameter("pod")
+	containerID := request.PathParameter("container")
+	destPath := request.QueryParameter("path")
+
+	defer request.Request.Body.Close()
+	result, err := container.CopyToContainerWithMultiTenancy(k8sClient, cfg, tenant, namespace, podID, containerID,
+		destPath, request.Request.Body)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+	response.WriteHeaderAndEntity(http.StatusCreated, result)
+}
+
+// parseNamespacePathParameter parses namespace selector for list pages in path parameter.
+// The namespace selector is a comma separated list of namespaces that are trimmed.
+// No namespaces means "view all user namespaces", i.e., everything except kube-system.
+func parseNamespacePathParameter(request *restful.Request) *common.NamespaceQuery {
+	namespace := request.PathParameter("namespace")
+
+	namespaces := strings.Split(namespace, ",")
 	var nonEmptyNamespaces []string
 	for _, n := range namespaces {
 		n = strings.Trim(n, " ")
@@ -7012,8 +8559,18 @@ func parseMetricPathParameter(request *restful.Request) *dataselect.MetricQuery
 	for _, e := range rawAggregations {
 		aggregationModes = append(aggregationModes, metricapi.AggregationMode(e))
 	}
-	return dataselect.NewMetricQuery(metricNames, aggregationModes)
+	return dataselect.NewMetricQueryWithWindow(metricNames, aggregationModes, parseMetricWindowParameter(request))
+
+}
 
+// Parses the metricWindow query parameter, e.g. 1h. Invalid windows are ignored and the default
+// window of the metric client is used instead.
+func parseMetricWindowParameter(request *restful.Request) time.Duration {
+	window, err := time.ParseDuration(request.QueryParameter("metricWindow"))
+	if err != nil || window < 0 || window > dataselect.MaxMetricWindow {
+		return 0
+	}
+	return window
 }
 
 // Parses query parameters of the request and returns a DataSelectQuery object
@@ -7061,7 +8618,7 @@ func (apiHandler *APIHandlerV2) handleCreateUser(w *restful.Request, r *restful.
 	}
 
 	user.CreationTimestamp = time.Now().Truncate(time.Second)
-	insertID := db.InsertUser(user)
+	insertID := db.InsertUser(w.Request.Context(), user)
 	res := response{
 		ID:      insertID,
 		Message: "User created successfully",
@@ -7080,7 +8637,7 @@ func (apiHandler *APIHandlerV2) handleGetUser(w *restful.Request, r *restful.Res
 	}
 
 	substrings := strings.Split(string(decode), "+")
-	user, err := db.GetUser(substrings[0])
+	user, err := db.GetUser(w.Request.Context(), substrings[0])
 
 	if err != nil {
 		log.Printf("Unable to get user. %v", err)
@@ -7093,7 +8650,7 @@ func (apiHandler *APIHandlerV2) handleGetUser(w *restful.Request, r *restful.Res
 
 func (apiHandler *APIHandlerV2) handleGetUserDetail(w *restful.Request, r *restful.Response) {
 	username := w.PathParameter("username")
-	user, err := db.GetUser(username)
+	user, err := db.GetUser(w.Request.Context(), username)
 
 	if err != nil {
 		log.Printf("Unable to get user. %v", err)
@@ -7118,7 +8675,7 @@ func (apiHandler *APIHandlerV2) handleGetAllUser(request *restful.Request, respo
 		return
 	}
 
-	users, err := db.GetAllUsers(tenant)
+	users, err := db.GetAllUsers(request.Request.Context(), tenant)
 
 	if err != nil {
 		log.Fatalf("Unable to get all user. %v", err)
@@ -7175,7 +8732,7 @@ func (apiHandler *APIHandlerV2) handleDeleteUser(w *restful.Request, r *restful.
 
 	userName := w.PathParameter("username")
 	userid := w.PathParameter("userid")
-	userDetail, _ := db.GetUser(userName)
+	userDetail, _ := db.GetUser(w.Request.Context(), userName)
 	if userDetail.ObjectMeta.Username == "" {
 		errors.HandleInternalError(r, errors.NewInternal("User do not exists"))
 		return
@@ -7243,7 +8800,7 @@ func (apiHandler *APIHandlerV2) handleDeleteUser(w *restful.Request, r *restful.
 	id, err := strconv.Atoi(userid)
 	//if userDetail.ObjectMeta.Type != `tenant-admin` {
 
-	deletedRows := db.DeleteUser(int64(id))
+	deletedRows := db.DeleteUser(w.Request.Context(), int64(id))
 
 	if err != nil {
 		log.Printf("Unable to get user. %v", err)
@@ -17,20 +17,14 @@ package handler
 import (
 	"bytes"
 	"encoding/json"
-	restful "github.com/emicklei/go-restful"
-	"github.com/kubernetes/dashboard/src/app/backend/args"
-	"github.com/kubernetes/dashboard/src/app/backend/systembanner"
 	"net/http"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/CentaurusInfra/dashboard/src/app/backend/args"
-	"github.com/CentaurusInfra/dashboard/src/app/backend/auth"
 	authApi "github.com/CentaurusInfra/dashboard/src/app/backend/auth/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/auth/jwe"
-	"github.com/CentaurusInfra/dashboard/src/app/backend/client"
-	"github.com/CentaurusInfra/dashboard/src/app/backend/settings"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/sync"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/systembanner"
 	"github.com/emicklei/go-restful"
@@ -46,7 +40,7 @@ func getTokenManager() authApi.TokenManager {
 
 func TestCreateHTTPAPIHandler(t *testing.T) {
 	sbManager := systembanner.NewSystemBannerManager("Hello world!", "INFO")
-	_, err := CreateHTTPAPIHandler(nil, nil, nil, nil, nil, nil, sbManager, nil)
+	_, err := CreateHTTPAPIHandler(nil, nil, nil, nil, nil, nil, nil, sbManager, nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal("CreateHTTPAPIHandler() cannot create HTTP API handler")
 	}
@@ -0,0 +1,190 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	restful "github.com/emicklei/go-restful"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/args"
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/vm"
+)
+
+// handleVMConsole creates a terminal session for the serial console of a virtual machine. The returned
+// id has to be bound over SockJS, the same way as for handleExecShell.
+func (apiHandler *APIHandlerV2) handleVMConsole(request *restful.Request, response *restful.Response) {
+	sessionId, err := genTerminalSessionId()
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	target, err := vm.GetConsoleTargetWithMultiTenancy(k8sClient, tenant, request.PathParameter("namespace"),
+		request.PathParameter("virtualmachine"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	terminalSessions.Set(sessionId, TerminalSession{
+		id:        sessionId,
+		bound:     make(chan error),
+		sizeChan:  make(chan remotecommand.TerminalSize),
+		partition: client.GetClusterName(),
+		tenant:    tenant,
+	})
+
+	go WaitForConsoleWithMultiTenancy(k8sClient, cfg, target, sessionId)
+	response.WriteHeaderAndEntity(http.StatusOK, TerminalResponse{Id: sessionId})
+}
+
+// handleVMConsoleWebSocket attaches a native WebSocket speaking the channel.k8s.io protocols to the
+// serial console of a virtual machine.
+func (apiHandler *APIHandlerV2) handleVMConsoleWebSocket(request *restful.Request, response *restful.Response) {
+	authenticateWebSocketRequest(request)
+
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	target, err := vm.GetConsoleTargetWithMultiTenancy(k8sClient, tenant, request.PathParameter("namespace"),
+		request.PathParameter("virtualmachine"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	if !authorizeRequest(client, request, response, clientapi.ToTenantSelfSubjectAccessReview(target.Tenant,
+		target.Namespace, target.PodName, "pods", "attach", "create")) {
+		return
+	}
+
+	conn, err := webSocketUpgrader.Upgrade(response.ResponseWriter, request.Request, nil)
+	if err != nil {
+		log.Printf("handleVMConsoleWebSocket: can't upgrade connection: %v", err)
+		return
+	}
+
+	session := NewWebSocketSession(conn)
+	err = streamPodSubresource(k8sClient, cfg, target.Tenant, target.Namespace, target.PodName, "attach",
+		&v1.PodAttachOptions{
+			Container: target.Workload,
+			Stdin:     true,
+			Stdout:    true,
+			TTY:       true,
+		}, streamOptionsForSession(session, true, true, false, true))
+	session.Close(err)
+}
+
+// handleVMVNCWebSocket tunnels the VNC display of a virtual machine over a raw binary WebSocket that
+// can be consumed directly by noVNC. The display is reached on the port given by the port parameter
+// or the --vm-vnc-port argument.
+func (apiHandler *APIHandlerV2) handleVMVNCWebSocket(request *restful.Request, response *restful.Response) {
+	authenticateWebSocketRequest(request)
+
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	target, err := vm.GetConsoleTargetWithMultiTenancy(k8sClient, tenant, request.PathParameter("namespace"),
+		request.PathParameter("virtualmachine"))
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	vncPort := uint16(args.Holder.GetVMVNCPort())
+	if port := request.QueryParameter("port"); len(port) > 0 {
+		value, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || value == 0 {
+			errors.HandleInternalError(response, errors.NewBadRequest("invalid port: "+port))
+			return
+		}
+		vncPort = uint16(value)
+	}
+	if vncPort == 0 {
+		errors.HandleInternalError(response, errors.NewBadRequest(
+			"the VNC port of virtual machines is not configured, set --vm-vnc-port or the port parameter"))
+		return
+	}
+
+	if !authorizeRequest(client, request, response, clientapi.ToTenantSelfSubjectAccessReview(target.Tenant,
+		target.Namespace, target.PodName, "pods", "portforward", "create")) {
+		return
+	}
+
+	conn, err := rawWebSocketUpgrader.Upgrade(response.ResponseWriter, request.Request, nil)
+	if err != nil {
+		log.Printf("handleVMVNCWebSocket: can't upgrade connection: %v", err)
+		return
+	}
+
+	session := NewRawWebSocketSession(conn)
+	if err := forwardPort(k8sClient, cfg, target.Tenant, target.Namespace, target.PodName, vncPort,
+		session); err != nil {
+		log.Printf("handleVMVNCWebSocket: %v", err)
+	}
+	session.closeConnection()
+}
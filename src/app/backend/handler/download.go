@@ -15,6 +15,7 @@
 package handler
 
 import (
+	"fmt"
 	"io"
 
 	restful "github.com/emicklei/go-restful"
@@ -31,3 +32,14 @@ func handleDownload(response *restful.Response, result io.ReadCloser) {
 		return
 	}
 }
+
+func handleFileDownload(response *restful.Response, result io.ReadCloser, contentType, fileName string) {
+	response.AddHeader(restful.HEADER_ContentType, contentType)
+	response.AddHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	defer result.Close()
+	_, err := io.Copy(response, result)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+}
@@ -31,10 +31,12 @@ import (
 	authApi "github.com/CentaurusInfra/dashboard/src/app/backend/auth/api"
 	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/tracing"
 )
 
 // InstallFilters installs defined filter for given web service
 func InstallFilters(ws *restful.WebService, manager clientapi.ClientManager) {
+	ws.Filter(tracing.Filter)
 	ws.Filter(requestAndResponseLogger)
 	ws.Filter(metricsFilter)
 	//ws.Filter(validateXSRFFilter(manager.CSRFKey()))
@@ -117,18 +119,28 @@ func checkSensitiveURL(url *string) bool {
 
 func metricsFilter(req *restful.Request, resp *restful.Response,
 	chain *restful.FilterChain) {
-	resource := mapUrlToResource(req.SelectedRoutePath())
-	httpClient := utilnet.GetHTTPClient(req.Request)
+	start := time.Now()
 
 	chain.ProcessFilter(req, resp)
 
+	monitor(
+		req.Request.Method,
+		req.SelectedRoutePath(),
+		req.PathParameter("partition"),
+		req.PathParameter("tenant"),
+		resp.StatusCode(),
+		start,
+	)
+
+	resource := mapUrlToResource(req.SelectedRoutePath())
 	if resource != nil {
-		monitor(
+		monitorLegacy(
 			req.Request.Method,
-			*resource, httpClient,
+			*resource,
+			utilnet.GetHTTPClient(req.Request),
 			resp.Header().Get("Content-Type"),
 			resp.StatusCode(),
-			time.Now(),
+			start,
 		)
 	}
 }
@@ -171,7 +183,7 @@ func shouldDoCsrfValidation(req *restful.Request) bool {
 // Ignores potential subresources.
 func mapUrlToResource(url string) *string {
 	parts := strings.Split(url, "/")
-	if len(parts) < 3 {
+	if len(parts) < 4 {
 		return nil
 	}
 	return &parts[3]
@@ -16,50 +16,122 @@ package handler
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
 )
 
+const otherLabel = instrumentation.OtherLabel
+
 var (
 	requestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dashboard_http_requests_total",
+			Help: "Counter of dashboard API requests broken out for each verb, route, partition, tenant and HTTP response code.",
+		},
+		[]string{"verb", "route", "partition", "tenant", "code"},
+	)
+	requestLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "dashboard_http_request_duration_seconds",
+			Help:    "Response latency distribution in seconds for each verb, route, partition and tenant.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"verb", "route", "partition", "tenant"},
+	)
+	terminalSessionsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dashboard_terminal_sessions",
+			Help: "Number of open shell and console sessions for each partition and tenant.",
+		},
+		[]string{"partition", "tenant"},
+	)
+
+	// Deprecated: the following metrics are replaced by dashboard_http_requests_total and
+	// dashboard_http_request_duration_seconds and will be removed in the next release.
+	legacyRequestCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "apiserver_request_count",
-			Help: "Counter of apiserver requests broken out for each verb, API resource, client, and HTTP response contentType and code.",
+			Help: "Deprecated, use dashboard_http_requests_total instead. Counter of apiserver requests broken out for each verb, API resource, client, and HTTP response contentType and code.",
 		},
 		[]string{"verb", "resource", "client", "contentType", "code"},
 	)
-	requestLatencies = prometheus.NewHistogramVec(
+	legacyRequestLatencies = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name: "apiserver_request_latencies",
-			Help: "Response latency distribution in microseconds for each verb, resource and client.",
+			Help: "Deprecated, use dashboard_http_request_duration_seconds instead. Response latency distribution in microseconds for each verb, resource and client.",
 			// Use buckets ranging from 125 ms to 8 seconds.
 			Buckets: prometheus.ExponentialBuckets(125000, 2.0, 7),
 		},
 		[]string{"verb", "resource"},
 	)
-	requestLatenciesSummary = prometheus.NewSummaryVec(
+	legacyRequestLatenciesSummary = prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
 			Name: "apiserver_request_latencies_summary",
-			Help: "Response latency summary in microseconds for each verb and resource.",
+			Help: "Deprecated, use dashboard_http_request_duration_seconds instead. Response latency summary in microseconds for each verb and resource.",
 			// Make the sliding window of 1h.
 			MaxAge: time.Hour,
 		},
 		[]string{"verb", "resource"},
 	)
+
+	// knownPartitions are the cluster names of the partitions, which are used as partition labels.
+	knownPartitions   = make(map[string]bool)
+	knownPartitionsMu sync.RWMutex
 )
 
 // Initialize all metrics in prometheus
 func init() {
 	prometheus.MustRegister(requestCounter)
 	prometheus.MustRegister(requestLatencies)
-	prometheus.MustRegister(requestLatenciesSummary)
+	prometheus.MustRegister(terminalSessionsGauge)
+	prometheus.MustRegister(legacyRequestCounter)
+	prometheus.MustRegister(legacyRequestLatencies)
+	prometheus.MustRegister(legacyRequestLatenciesSummary)
+}
+
+// registerPartitions adds the cluster names of the partitions to the known partition labels.
+func registerPartitions(managers ...[]clientapi.ClientManager) {
+	knownPartitionsMu.Lock()
+	defer knownPartitionsMu.Unlock()
+	for _, partitionManagers := range managers {
+		for _, manager := range partitionManagers {
+			knownPartitions[manager.GetClusterName()] = true
+		}
+	}
+}
+
+// partitionLabel returns the partition if it is known and otherLabel otherwise.
+func partitionLabel(partition string) string {
+	if len(partition) == 0 {
+		return partition
+	}
+
+	knownPartitionsMu.RLock()
+	defer knownPartitionsMu.RUnlock()
+	if knownPartitions[partition] {
+		return partition
+	}
+	return otherLabel
+}
+
+// Track API call in prometheus. The route is the template of the matched route, e.g.
+// /api/v1/tenants/{tenant}/pod, so that the cardinality of the metrics does not depend on the names of
+// the resources.
+func monitor(verb, route, partition, tenant string, httpCode int, reqStart time.Time) {
+	partition, tenant = partitionLabel(partition), instrumentation.TenantLabel(tenant, httpCode)
+	requestCounter.WithLabelValues(verb, route, partition, tenant, strconv.Itoa(httpCode)).Inc()
+	requestLatencies.WithLabelValues(verb, route, partition, tenant).Observe(time.Since(reqStart).Seconds())
 }
 
-// Track API call in prometheus
-func monitor(verb, resource string, client, contentType string, httpCode int, reqStart time.Time) {
+// Track API call in the deprecated metrics.
+func monitorLegacy(verb, resource string, client, contentType string, httpCode int, reqStart time.Time) {
 	elapsed := float64((time.Since(reqStart)) / time.Microsecond)
-	requestCounter.WithLabelValues(verb, resource, client, contentType, strconv.Itoa(httpCode)).Inc()
-	requestLatencies.WithLabelValues(verb, resource).Observe(elapsed)
-	requestLatenciesSummary.WithLabelValues(verb, resource).Observe(elapsed)
+	legacyRequestCounter.WithLabelValues(verb, resource, client, contentType, strconv.Itoa(httpCode)).Inc()
+	legacyRequestLatencies.WithLabelValues(verb, resource).Observe(elapsed)
+	legacyRequestLatenciesSummary.WithLabelValues(verb, resource).Observe(elapsed)
 }
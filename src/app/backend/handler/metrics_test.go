@@ -0,0 +1,32 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"testing"
+)
+
+func TestMetricLabels(t *testing.T) {
+	knownPartitionsMu.Lock()
+	knownPartitions["tp1"] = true
+	knownPartitionsMu.Unlock()
+
+	partitions := map[string]string{"": "", "tp1": "tp1", "tp-made-up": otherLabel}
+	for partition, expected := range partitions {
+		if actual := partitionLabel(partition); actual != expected {
+			t.Errorf("partitionLabel(%q) == %q, expected %q", partition, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+
+	restful "github.com/emicklei/go-restful"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// Channels used for port forwarding. They follow the kubelet WebSocket port forward protocol
+// (portforward.k8s.io): every binary message starts with the channel byte and the first frame sent
+// on each channel carries the forwarded port as little endian uint16.
+const (
+	portForwardDataChannel = iota
+	portForwardErrorChannel
+)
+
+// forwardPort tunnels the data sent over the WebSocket session to the given pod port. It returns
+// once either side closes the connection.
+func forwardPort(k8sClient kubernetes.Interface, cfg *rest.Config, tenant, namespace, podName string, port uint16,
+	session *WebSocketSession) error {
+	transport, upgrader, err := spdy.RoundTripperFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	req := k8sClient.CoreV1().RESTClient().Post().
+		Tenant(tenant).
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("portforward")
+
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
+	streamConn, _, err := dialer.Dial(portforward.PortForwardProtocolV1Name)
+	if err != nil {
+		return fmt.Errorf("error upgrading connection: %s", err)
+	}
+	defer streamConn.Close()
+
+	headers := http.Header{}
+	headers.Set(v1.StreamType, v1.StreamTypeError)
+	headers.Set(v1.PortHeader, strconv.Itoa(int(port)))
+	headers.Set(v1.PortForwardRequestIDHeader, "0")
+	errorStream, err := streamConn.CreateStream(headers)
+	if err != nil {
+		return err
+	}
+	// we're not writing to this stream
+	errorStream.Close()
+
+	headers.Set(v1.StreamType, v1.StreamTypeData)
+	dataStream, err := streamConn.CreateStream(headers)
+	if err != nil {
+		return err
+	}
+
+	if !session.isRaw() {
+		portPrefix := make([]byte, 2)
+		binary.LittleEndian.PutUint16(portPrefix, port)
+		for _, channel := range []byte{portForwardDataChannel, portForwardErrorChannel} {
+			if _, err := session.writeChannel(channel, portPrefix); err != nil {
+				return err
+			}
+		}
+	}
+
+	remoteDone := make(chan struct{})
+	go func() {
+		// Copy from the pod to the WebSocket.
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := dataStream.Read(buffer)
+			if n > 0 {
+				if _, err := session.writeChannel(portForwardDataChannel, buffer[:n]); err != nil {
+					break
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		close(remoteDone)
+	}()
+
+	localDone := make(chan struct{})
+	go func() {
+		// Copy from the WebSocket to the pod. Inform the pod that no more data is sent once the
+		// client goes away.
+		defer dataStream.Close()
+		defer close(localDone)
+		for {
+			channel, data, err := session.readFrame()
+			if err != nil {
+				return
+			}
+			if channel != portForwardDataChannel {
+				continue
+			}
+			if _, err := dataStream.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-remoteDone:
+	case <-localDone:
+	}
+
+	message, err := ioutil.ReadAll(errorStream)
+	if err != nil {
+		return err
+	}
+	if len(message) > 0 {
+		if !session.isRaw() {
+			session.writeChannel(portForwardErrorChannel, message)
+		}
+		return fmt.Errorf("an error occurred forwarding port %d: %s", port, string(message))
+	}
+	return nil
+}
+
+// handlePortForwardWebSocket upgrades the request to a WebSocket and tunnels it to a port of the pod.
+// One connection forwards exactly one TCP connection to the pod port.
+func (apiHandler *APIHandlerV2) handlePortForwardWebSocket(request *restful.Request, response *restful.Response) {
+	authenticateWebSocketRequest(request)
+
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	port, err := strconv.ParseUint(request.PathParameter("port"), 10, 16)
+	if err != nil || port == 0 {
+		errors.HandleInternalError(response, errors.NewBadRequest("invalid port: "+request.PathParameter("port")))
+		return
+	}
+
+	if !authorizeRequest(client, request, response, clientapi.ToTenantSelfSubjectAccessReview(tenant,
+		request.PathParameter("namespace"), request.PathParameter("pod"), "pods", "portforward", "create")) {
+		return
+	}
+
+	conn, err := portForwardWebSocketUpgrader.Upgrade(response.ResponseWriter, request.Request, nil)
+	if err != nil {
+		log.Printf("handlePortForwardWebSocket: can't upgrade connection: %v", err)
+		return
+	}
+
+	session := NewWebSocketSession(conn)
+	if err := forwardPort(k8sClient, cfg, tenant, request.PathParameter("namespace"), request.PathParameter("pod"),
+		uint16(port), session); err != nil {
+		log.Printf("handlePortForwardWebSocket: %v", err)
+	}
+	session.closeConnection()
+}
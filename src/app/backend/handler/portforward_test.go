@@ -0,0 +1,90 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/portforward"
+)
+
+func TestForwardPort(t *testing.T) {
+	cases := []struct {
+		name          string
+		remoteError   string
+		expectedError string
+	}{
+		{"echo", "", ""},
+		{"remote error", "connection refused", "an error occurred forwarding port 8080: connection refused"},
+	}
+
+	for _, c := range cases {
+		server := newFakeStreamServer(t, portforward.PortForwardProtocolV1Name, 2, func(server *fakeStreamServer) {
+			if port := server.streams[v1.StreamTypeData].Headers().Get(v1.PortHeader); port != "8080" {
+				t.Errorf("%s: forwarded port %q, expected 8080", c.name, port)
+			}
+
+			buffer := make([]byte, 4)
+			if _, err := io.ReadFull(server.streams[v1.StreamTypeData], buffer); err != nil {
+				t.Errorf("%s: can't read data: %v", c.name, err)
+			}
+			server.streams[v1.StreamTypeData].Write(buffer)
+			server.streams[v1.StreamTypeError].Write([]byte(c.remoteError))
+			server.streams[v1.StreamTypeError].Close()
+			server.streams[v1.StreamTypeData].Close()
+		})
+		k8sClient, cfg := server.client(t)
+		session, conn := dialWebSocketSession(t, PortForwardWebSocketProtocol)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- forwardPort(k8sClient, cfg, "tenant-1", "ns-1", "pod-1", 8080, session)
+		}()
+
+		// The first frame of both channels carries the port, 8080 as little endian uint16.
+		for _, expected := range []string{"\x00\x90\x1f", "\x01\x90\x1f"} {
+			if _, message, err := conn.ReadMessage(); err != nil || string(message) != expected {
+				t.Fatalf("%s: got port frame %q (%v), expected %q", c.name, message, err, expected)
+			}
+		}
+
+		conn.WriteMessage(websocket.BinaryMessage, []byte("\x00ping"))
+		if _, message, err := conn.ReadMessage(); err != nil || string(message) != "\x00ping" {
+			t.Fatalf("%s: got data frame %q (%v), expected %q", c.name, message, err, "\x00ping")
+		}
+
+		if len(c.remoteError) > 0 {
+			expected := "\x01" + c.remoteError
+			if _, message, err := conn.ReadMessage(); err != nil || string(message) != expected {
+				t.Errorf("%s: got error frame %q (%v), expected %q", c.name, message, err, expected)
+			}
+		}
+
+		err := <-errs
+		if len(c.expectedError) == 0 && err != nil {
+			t.Errorf("%s: forwardPort() returned error: %v", c.name, err)
+		}
+		if len(c.expectedError) > 0 && (err == nil || !strings.Contains(err.Error(), c.expectedError)) {
+			t.Errorf("%s: forwardPort() == %v, expected %q", c.name, err, c.expectedError)
+		}
+		if !strings.HasSuffix(server.request.URL.Path, "/tenants/tenant-1/namespaces/ns-1/pods/pod-1/portforward") {
+			t.Errorf("%s: unexpected request path %q", c.name, server.request.URL.Path)
+		}
+	}
+}
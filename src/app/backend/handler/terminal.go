@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/vm"
 	restful "github.com/emicklei/go-restful"
 	"gopkg.in/igm/sockjs-go.v2/sockjs"
 	v1 "k8s.io/api/core/v1"
@@ -49,6 +50,9 @@ type TerminalSession struct {
 	sockJSSession sockjs.Session
 	sizeChan      chan remotecommand.TerminalSize
 	doneChan      chan struct{}
+	// partition and tenant of the session, used to label the terminal sessions metric.
+	partition string
+	tenant    string
 }
 
 // TerminalMessage is the messaging protocol between ShellController and TerminalSession.
@@ -152,6 +156,9 @@ func (sm *SessionMap) Get(sessionId string) TerminalSession {
 func (sm *SessionMap) Set(sessionId string, session TerminalSession) {
 	sm.Lock.Lock()
 	defer sm.Lock.Unlock()
+	if _, exists := sm.Sessions[sessionId]; !exists {
+		terminalSessionsGauge.WithLabelValues(session.partition, session.tenant).Inc()
+	}
 	sm.Sessions[sessionId] = session
 }
 
@@ -161,12 +168,17 @@ func (sm *SessionMap) Set(sessionId string, session TerminalSession) {
 func (sm *SessionMap) Close(sessionId string, status uint32, reason string) {
 	sm.Lock.Lock()
 	defer sm.Lock.Unlock()
-	err := sm.Sessions[sessionId].sockJSSession.Close(status, reason)
+	session, exists := sm.Sessions[sessionId]
+	if !exists {
+		return
+	}
+	err := session.sockJSSession.Close(status, reason)
 	if err != nil {
 		log.Println(err)
 	}
 
 	delete(sm.Sessions, sessionId)
+	terminalSessionsGauge.WithLabelValues(session.partition, session.tenant).Dec()
 }
 
 var terminalSessions = SessionMap{Sessions: make(map[string]TerminalSession)}
@@ -387,3 +399,32 @@ func WaitForTerminalWithMultiTenancy(k8sClient kubernetes.Interface, cfg *rest.C
 		terminalSessions.Close(sessionId, 1, "Process exited")
 	}
 }
+
+// WaitForConsoleWithMultiTenancy is called from apihandler.handleVMConsole as a goroutine
+// Waits for the SockJS connection to be bound and attaches it to the serial console of the virtual machine
+func WaitForConsoleWithMultiTenancy(k8sClient kubernetes.Interface, cfg *rest.Config, target *vm.ConsoleTarget, sessionId string) {
+	select {
+	case <-terminalSessions.Get(sessionId).bound:
+		close(terminalSessions.Get(sessionId).bound)
+
+		session := terminalSessions.Get(sessionId)
+		err := streamPodSubresource(k8sClient, cfg, target.Tenant, target.Namespace, target.PodName, "attach",
+			&v1.PodAttachOptions{
+				Container: target.Workload,
+				Stdin:     true,
+				Stdout:    true,
+				TTY:       true,
+			}, remotecommand.StreamOptions{
+				Stdin:             session,
+				Stdout:            session,
+				TerminalSizeQueue: session,
+				Tty:               true,
+			})
+		if err != nil {
+			terminalSessions.Close(sessionId, 2, err.Error())
+			return
+		}
+
+		terminalSessions.Close(sessionId, 1, "Console closed")
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/client"
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// Subprotocols of the Kubernetes streaming WebSocket protocol. Every binary message starts with a
+// single byte that identifies the channel the rest of the message belongs to. Base64 variants use
+// text messages with an ASCII digit as channel and base64 encoded payload.
+const (
+	ChannelWebSocketProtocol         = "channel.k8s.io"
+	Base64ChannelWebSocketProtocol   = "base64.channel.k8s.io"
+	V4ChannelWebSocketProtocol       = "v4.channel.k8s.io"
+	V4Base64ChannelWebSocketProtocol = "v4.base64.channel.k8s.io"
+
+	// PortForwardWebSocketProtocol is the kubelet WebSocket port forward protocol. It uses the
+	// binary channel framing with a data and an error channel per forwarded port.
+	PortForwardWebSocketProtocol = "portforward.k8s.io"
+
+	// BinaryWebSocketProtocol carries raw bytes without channel prefix, as expected by noVNC.
+	BinaryWebSocketProtocol = "binary"
+)
+
+// Channels used by the channel.k8s.io protocol family.
+const (
+	stdinChannel = iota
+	stdoutChannel
+	stderrChannel
+	errorChannel
+	resizeChannel
+)
+
+// Name of the query parameter that can carry the JWE token. Browsers are not able to set custom
+// headers on WebSocket handshakes, so the token is accepted inline as well.
+const jweTokenQueryParameter = "jweToken"
+
+// Time given to the peer to receive the close frame before the connection is dropped.
+const webSocketCloseTimeout = time.Second
+
+var webSocketUpgrader = websocket.Upgrader{
+	Subprotocols: []string{
+		V4ChannelWebSocketProtocol,
+		V4Base64ChannelWebSocketProtocol,
+		ChannelWebSocketProtocol,
+		Base64ChannelWebSocketProtocol,
+	},
+}
+
+// Upgrader for port forwarding.
+var portForwardWebSocketUpgrader = websocket.Upgrader{
+	Subprotocols: []string{PortForwardWebSocketProtocol},
+}
+
+// Upgrader for raw streams like VNC.
+var rawWebSocketUpgrader = websocket.Upgrader{
+	Subprotocols: []string{BinaryWebSocketProtocol},
+}
+
+// WebSocketSession implements PtyHandler on top of a native WebSocket connection speaking one of
+// the channel.k8s.io subprotocols.
+type WebSocketSession struct {
+	conn      *websocket.Conn
+	protocol  string
+	sizeChan  chan remotecommand.TerminalSize
+	doneChan  chan struct{}
+	writeLock sync.Mutex
+	// Remainder of the last stdin frame that did not fit into the buffer passed to Read.
+	pending []byte
+}
+
+// NewWebSocketSession creates a session for an already upgraded connection.
+func NewWebSocketSession(conn *websocket.Conn) *WebSocketSession {
+	return &WebSocketSession{
+		conn:     conn,
+		protocol: conn.Subprotocol(),
+		sizeChan: make(chan remotecommand.TerminalSize),
+		doneChan: make(chan struct{}),
+	}
+}
+
+// NewRawWebSocketSession creates a session that sends and receives raw bytes regardless of the
+// negotiated subprotocol.
+func NewRawWebSocketSession(conn *websocket.Conn) *WebSocketSession {
+	session := NewWebSocketSession(conn)
+	session.protocol = BinaryWebSocketProtocol
+	return session
+}
+
+// Next handles pty->process resize events
+// Called in a loop from remotecommand as long as the process is running
+func (t *WebSocketSession) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-t.sizeChan:
+		return &size
+	case <-t.doneChan:
+		return nil
+	}
+}
+
+// Read handles pty->process messages (stdin, resize)
+// Called in a loop from remotecommand as long as the process is running
+func (t *WebSocketSession) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		channel, data, err := t.readFrame()
+		if err != nil {
+			return copy(p, END_OF_TRANSMISSION), err
+		}
+
+		switch channel {
+		case stdinChannel:
+			t.pending = data
+		case resizeChannel:
+			size := remotecommand.TerminalSize{}
+			if err := json.Unmarshal(data, &size); err != nil {
+				return copy(p, END_OF_TRANSMISSION), err
+			}
+			select {
+			case t.sizeChan <- size:
+			case <-t.doneChan:
+			}
+		default:
+			return copy(p, END_OF_TRANSMISSION), fmt.Errorf("unexpected channel %d", channel)
+		}
+	}
+
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// Write handles process->pty stdout
+// Called from remotecommand whenever there is any output
+func (t *WebSocketSession) Write(p []byte) (int, error) {
+	return t.writeChannel(stdoutChannel, p)
+}
+
+// Stderr returns a writer that sends process output over the stderr channel.
+func (t *WebSocketSession) Stderr() io.Writer {
+	return channelWriter{session: t, channel: stderrChannel}
+}
+
+// Close writes the final status to the error channel and closes the connection. The v4 protocols
+// expect a serialized metav1.Status while the older ones only carry an error message.
+func (t *WebSocketSession) Close(err error) {
+	close(t.doneChan)
+
+	if t.isV4() {
+		status := metaV1.Status{Status: metaV1.StatusSuccess}
+		if err != nil {
+			status = metaV1.Status{Status: metaV1.StatusFailure, Message: err.Error(), Reason: metaV1.StatusReasonInternalError}
+		}
+		if data, marshalErr := json.Marshal(status); marshalErr == nil {
+			t.writeChannel(errorChannel, data)
+		}
+	} else if err != nil {
+		t.writeChannel(errorChannel, []byte(err.Error()))
+	}
+
+	t.closeConnection()
+}
+
+// closeConnection sends a close frame to the peer and closes the underlying connection.
+func (t *WebSocketSession) closeConnection() {
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+	t.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(webSocketCloseTimeout))
+	if err := t.conn.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+func (t *WebSocketSession) isBase64() bool {
+	return t.protocol == Base64ChannelWebSocketProtocol || t.protocol == V4Base64ChannelWebSocketProtocol
+}
+
+// isRaw returns true if the session does not use channels at all.
+func (t *WebSocketSession) isRaw() bool {
+	return t.protocol == BinaryWebSocketProtocol
+}
+
+func (t *WebSocketSession) isV4() bool {
+	return t.protocol == V4ChannelWebSocketProtocol || t.protocol == V4Base64ChannelWebSocketProtocol
+}
+
+func (t *WebSocketSession) readFrame() (byte, []byte, error) {
+	for {
+		_, message, err := t.conn.ReadMessage()
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(message) == 0 {
+			continue
+		}
+
+		if t.isRaw() {
+			return stdinChannel, message, nil
+		}
+
+		if !t.isBase64() {
+			return message[0], message[1:], nil
+		}
+
+		data, err := base64.StdEncoding.DecodeString(string(message[1:]))
+		if err != nil {
+			return 0, nil, err
+		}
+		return message[0] - '0', data, nil
+	}
+}
+
+func (t *WebSocketSession) writeChannel(channel byte, p []byte) (int, error) {
+	var (
+		messageType int
+		message     []byte
+	)
+
+	if t.isRaw() {
+		messageType = websocket.BinaryMessage
+		message = p
+	} else if t.isBase64() {
+		messageType = websocket.TextMessage
+		message = append([]byte{'0' + channel}, base64.StdEncoding.EncodeToString(p)...)
+	} else {
+		messageType = websocket.BinaryMessage
+		message = append([]byte{channel}, p...)
+	}
+
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+	if err := t.conn.WriteMessage(messageType, message); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// channelWriter writes everything it gets to a single channel of the session.
+type channelWriter struct {
+	session *WebSocketSession
+	channel byte
+}
+
+func (w channelWriter) Write(p []byte) (int, error) {
+	return w.session.writeChannel(w.channel, p)
+}
+
+// streamPodSubresource opens a SPDY stream to the given pod subresource (exec or attach) in the
+// tenant and connects it with the provided stream options.
+func streamPodSubresource(k8sClient kubernetes.Interface, cfg *rest.Config, tenant, namespace, podName,
+	subresource string, params runtime.Object, options remotecommand.StreamOptions) error {
+	req := k8sClient.CoreV1().RESTClient().Post().
+		Tenant(tenant).
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource(subresource)
+
+	req.VersionedParams(params, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(cfg, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	return exec.Stream(options)
+}
+
+// streamOptionsForSession wires the requested streams of the session into remotecommand options.
+func streamOptionsForSession(session *WebSocketSession, stdin, stdout, stderr, tty bool) remotecommand.StreamOptions {
+	options := remotecommand.StreamOptions{Tty: tty}
+	if stdin {
+		options.Stdin = session
+	}
+	if stdout {
+		options.Stdout = session
+	}
+	if stderr && !tty {
+		options.Stderr = session.Stderr()
+	}
+	if tty {
+		options.TerminalSizeQueue = session
+	}
+	return options
+}
+
+// authenticateWebSocketRequest moves the JWE token passed as query parameter into the header
+// read by the client manager, so the usual secure client can be created for the request.
+func authenticateWebSocketRequest(request *restful.Request) {
+	token := request.QueryParameter(jweTokenQueryParameter)
+	if len(token) > 0 && len(request.HeaderParameter(client.JWETokenHeader)) == 0 {
+		request.Request.Header.Set(client.JWETokenHeader, token)
+	}
+}
+
+// parseBoolQueryParameter returns the value of a boolean query parameter or the default if it is
+// not set or invalid.
+func parseBoolQueryParameter(request *restful.Request, name string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(request.QueryParameter(name))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// handleExecWebSocket upgrades the request to a WebSocket speaking the channel.k8s.io protocols and
+// executes the requested command in the container. Unlike handleExecShell it does not require
+// a separate bind step. Access to pods/exec is checked with a SelfSubjectAccessReview before the
+// upgrade, so unauthorized users get a plain HTTP error instead of a failing stream.
+func (apiHandler *APIHandlerV2) handleExecWebSocket(request *restful.Request, response *restful.Response) {
+	authenticateWebSocketRequest(request)
+
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	if !authorizeRequest(client, request, response, clientapi.ToTenantSelfSubjectAccessReview(tenant,
+		request.PathParameter("namespace"), request.PathParameter("pod"), "pods", "exec", "create")) {
+		return
+	}
+
+	tty := parseBoolQueryParameter(request, "tty", false)
+	options := &v1.PodExecOptions{
+		Container: request.PathParameter("container"),
+		Command:   request.QueryParameters("command"),
+		Stdin:     parseBoolQueryParameter(request, "stdin", true),
+		Stdout:    parseBoolQueryParameter(request, "stdout", true),
+		Stderr:    parseBoolQueryParameter(request, "stderr", !tty),
+		TTY:       tty,
+	}
+	if len(options.Command) == 0 {
+		errors.HandleInternalError(response, errors.NewBadRequest("at least one command parameter is required"))
+		return
+	}
+
+	conn, err := webSocketUpgrader.Upgrade(response.ResponseWriter, request.Request, nil)
+	if err != nil {
+		// Upgrader has already replied with an HTTP error.
+		log.Printf("handleExecWebSocket: can't upgrade connection: %v", err)
+		return
+	}
+
+	session := NewWebSocketSession(conn)
+	err = streamPodSubresource(k8sClient, cfg, tenant, request.PathParameter("namespace"),
+		request.PathParameter("pod"), "exec", options,
+		streamOptionsForSession(session, options.Stdin, options.Stdout, options.Stderr, options.TTY))
+	session.Close(err)
+}
+
+// handleAttachWebSocket upgrades the request to a WebSocket speaking the channel.k8s.io protocols and
+// attaches it to the main process of a running container.
+func (apiHandler *APIHandlerV2) handleAttachWebSocket(request *restful.Request, response *restful.Response) {
+	authenticateWebSocketRequest(request)
+
+	tenant := request.PathParameter("tenant")
+	if len(apiHandler.tpManager) == 0 {
+		apiHandler.tpManager = append(apiHandler.tpManager, apiHandler.defaultClientmanager)
+	}
+	client := ResourceAllocator("", tenant, apiHandler.tpManager)
+	k8sClient, err := client.Client(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	cfg, err := client.Config(request)
+	if err != nil {
+		errors.HandleInternalError(response, err)
+		return
+	}
+
+	if !authorizeRequest(client, request, response, clientapi.ToTenantSelfSubjectAccessReview(tenant,
+		request.PathParameter("namespace"), request.PathParameter("pod"), "pods", "attach", "create")) {
+		return
+	}
+
+	tty := parseBoolQueryParameter(request, "tty", true)
+	options := &v1.PodAttachOptions{
+		Container: request.PathParameter("container"),
+		Stdin:     parseBoolQueryParameter(request, "stdin", true),
+		Stdout:    parseBoolQueryParameter(request, "stdout", true),
+		Stderr:    parseBoolQueryParameter(request, "stderr", !tty),
+		TTY:       tty,
+	}
+
+	conn, err := webSocketUpgrader.Upgrade(response.ResponseWriter, request.Request, nil)
+	if err != nil {
+		log.Printf("handleAttachWebSocket: can't upgrade connection: %v", err)
+		return
+	}
+
+	session := NewWebSocketSession(conn)
+	err = streamPodSubresource(k8sClient, cfg, tenant, request.PathParameter("namespace"),
+		request.PathParameter("pod"), "attach", options,
+		streamOptionsForSession(session, options.Stdin, options.Stdout, options.Stderr, options.TTY))
+	session.Close(err)
+}
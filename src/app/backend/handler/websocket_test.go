@@ -0,0 +1,311 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	remotecommandconsts "k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// fakeStreamServer is an apiserver stand-in that accepts SPDY stream upgrades like the exec,
+// attach and portforward subresources do.
+type fakeStreamServer struct {
+	*httptest.Server
+
+	// Request that was upgraded.
+	request *http.Request
+	// Streams created by the client, by stream type.
+	streams map[string]httpstream.Stream
+}
+
+// newFakeStreamServer starts a server that negotiates the protocol, waits for the expected number
+// of streams and passes them to the handler.
+func newFakeStreamServer(t *testing.T, protocol string, expectedStreams int,
+	handler func(server *fakeStreamServer)) *fakeStreamServer {
+	server := &fakeStreamServer{streams: map[string]httpstream.Stream{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := httpstream.Handshake(r, w, []string{protocol}); err != nil {
+			t.Errorf("handshake failed: %v", err)
+			return
+		}
+
+		streams := make(chan httpstream.Stream, expectedStreams)
+		conn := spdy.NewResponseUpgrader().UpgradeResponse(w, r,
+			func(stream httpstream.Stream, replySent <-chan struct{}) error {
+				streams <- stream
+				return nil
+			})
+		if conn == nil {
+			t.Errorf("can't upgrade connection")
+			return
+		}
+		defer conn.Close()
+
+		server.request = r
+		for i := 0; i < expectedStreams; i++ {
+			select {
+			case stream := <-streams:
+				server.streams[stream.Headers().Get(v1.StreamType)] = stream
+			case <-time.After(wait.ForeverTestTimeout):
+				t.Errorf("timed out waiting for streams, got %d of %d", i, expectedStreams)
+				return
+			}
+		}
+		handler(server)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// client returns a client and config for the server.
+func (server *fakeStreamServer) client(t *testing.T) (kubernetes.Interface, *rest.Config) {
+	cfg := &rest.Config{}
+	cfg.AddConfig(&rest.KubeConfig{Host: server.URL})
+	k8sClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return k8sClient, cfg
+}
+
+// dialWebSocketSession connects a client speaking the given subprotocol to a server side session.
+func dialWebSocketSession(t *testing.T, protocol string) (*WebSocketSession, *websocket.Conn) {
+	upgrader := webSocketUpgrader
+	switch protocol {
+	case BinaryWebSocketProtocol:
+		upgrader = rawWebSocketUpgrader
+	case PortForwardWebSocketProtocol:
+		upgrader = portForwardWebSocketUpgrader
+	}
+
+	sessions := make(chan *WebSocketSession, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("can't upgrade connection: %v", err)
+			return
+		}
+		if protocol == BinaryWebSocketProtocol {
+			sessions <- NewRawWebSocketSession(conn)
+		} else {
+			sessions <- NewWebSocketSession(conn)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{protocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("can't dial %s: %v", protocol, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	session := <-sessions
+	if session.protocol != protocol {
+		t.Fatalf("negotiated protocol %q, expected %q", session.protocol, protocol)
+	}
+	return session, conn
+}
+
+func TestWebSocketSessionWriteChannel(t *testing.T) {
+	cases := []struct {
+		protocol    string
+		channel     byte
+		payload     string
+		messageType int
+		expected    string
+	}{
+		{ChannelWebSocketProtocol, stdoutChannel, "hello", websocket.BinaryMessage, "\x01hello"},
+		{V4ChannelWebSocketProtocol, stderrChannel, "oops", websocket.BinaryMessage, "\x02oops"},
+		{Base64ChannelWebSocketProtocol, stdoutChannel, "hello", websocket.TextMessage, "1aGVsbG8="},
+		{V4Base64ChannelWebSocketProtocol, stderrChannel, "oops", websocket.TextMessage, "2b29wcw=="},
+		{BinaryWebSocketProtocol, stdoutChannel, "RFB 003.008\n", websocket.BinaryMessage, "RFB 003.008\n"},
+	}
+
+	for _, c := range cases {
+		session, conn := dialWebSocketSession(t, c.protocol)
+
+		n, err := session.writeChannel(c.channel, []byte(c.payload))
+		if err != nil || n != len(c.payload) {
+			t.Fatalf("%s: writeChannel() == %d, %v, expected %d, nil", c.protocol, n, err, len(c.payload))
+		}
+
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("%s: can't read message: %v", c.protocol, err)
+		}
+		if messageType != c.messageType || string(message) != c.expected {
+			t.Errorf("%s: got message (%d, %q), expected (%d, %q)", c.protocol, messageType, message,
+				c.messageType, c.expected)
+		}
+	}
+}
+
+func TestWebSocketSessionReadFrame(t *testing.T) {
+	cases := []struct {
+		protocol        string
+		message         string
+		expectedChannel byte
+		expectedData    string
+		expectedErr     bool
+	}{
+		{ChannelWebSocketProtocol, "\x00ls -l\n", stdinChannel, "ls -l\n", false},
+		{V4ChannelWebSocketProtocol, "\x04{\"Width\":80,\"Height\":24}", resizeChannel, "{\"Width\":80,\"Height\":24}", false},
+		{Base64ChannelWebSocketProtocol, "0bHMgLWwK", stdinChannel, "ls -l\n", false},
+		{V4Base64ChannelWebSocketProtocol, "4eyJXaWR0aCI6ODB9", resizeChannel, "{\"Width\":80}", false},
+		{V4Base64ChannelWebSocketProtocol, "0not base64!", 0, "", true},
+		{BinaryWebSocketProtocol, "\x03\x00\x00\x00", stdinChannel, "\x03\x00\x00\x00", false},
+	}
+
+	for _, c := range cases {
+		session, conn := dialWebSocketSession(t, c.protocol)
+
+		if err := conn.WriteMessage(websocket.BinaryMessage, []byte(c.message)); err != nil {
+			t.Fatalf("%s: can't write message: %v", c.protocol, err)
+		}
+
+		channel, data, err := session.readFrame()
+		if c.expectedErr {
+			if err == nil {
+				t.Errorf("%s: readFrame(%q) expected error", c.protocol, c.message)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: readFrame(%q) returned error: %v", c.protocol, c.message, err)
+		}
+		if channel != c.expectedChannel || string(data) != c.expectedData {
+			t.Errorf("%s: readFrame(%q) == (%d, %q), expected (%d, %q)", c.protocol, c.message, channel, data,
+				c.expectedChannel, c.expectedData)
+		}
+	}
+}
+
+func TestWebSocketSessionReadResize(t *testing.T) {
+	session, conn := dialWebSocketSession(t, V4Base64ChannelWebSocketProtocol)
+
+	// Resize event followed by stdin: Read has to hand the size to Next and return the stdin data.
+	conn.WriteMessage(websocket.TextMessage, []byte("4eyJXaWR0aCI6ODAsIkhlaWdodCI6MjR9"))
+	conn.WriteMessage(websocket.TextMessage, []byte("0aWQK"))
+
+	sizes := make(chan *remotecommand.TerminalSize, 1)
+	go func() { sizes <- session.Next() }()
+
+	buffer := make([]byte, 2)
+	data := []byte{}
+	for len(data) < 3 {
+		n, err := session.Read(buffer)
+		if err != nil {
+			t.Fatalf("Read() returned error: %v", err)
+		}
+		data = append(data, buffer[:n]...)
+	}
+
+	if string(data) != "id\n" {
+		t.Errorf("Read() == %q, expected %q", data, "id\n")
+	}
+	expectedSize := &remotecommand.TerminalSize{Width: 80, Height: 24}
+	if size := <-sizes; !reflect.DeepEqual(size, expectedSize) {
+		t.Errorf("Next() == %v, expected %v", size, expectedSize)
+	}
+}
+
+func TestWebSocketSessionClose(t *testing.T) {
+	cases := []struct {
+		protocol string
+		err      error
+		expected string
+	}{
+		{ChannelWebSocketProtocol, errors.New("command terminated"), "\x03command terminated"},
+		{Base64ChannelWebSocketProtocol, errors.New("failed"), "3ZmFpbGVk"},
+		{V4ChannelWebSocketProtocol, nil, "\x03{\"metadata\":{},\"status\":\"Success\"}"},
+		{V4ChannelWebSocketProtocol, errors.New("failed"),
+			"\x03{\"metadata\":{},\"status\":\"Failure\",\"message\":\"failed\",\"reason\":\"InternalError\"}"},
+	}
+
+	for _, c := range cases {
+		session, conn := dialWebSocketSession(t, c.protocol)
+		go session.Close(c.err)
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("%s: can't read status message: %v", c.protocol, err)
+		}
+		if string(message) != c.expected {
+			t.Errorf("%s: Close(%v) sent %q, expected %q", c.protocol, c.err, message, c.expected)
+		}
+
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("%s: expected normal closure, got %v", c.protocol, err)
+		}
+	}
+}
+
+func TestStreamPodSubresourceAttach(t *testing.T) {
+	server := newFakeStreamServer(t, remotecommandconsts.StreamProtocolV4Name, 3, func(server *fakeStreamServer) {
+		// Echo the first stdin bytes in upper case and end the stream successfully.
+		buffer := make([]byte, 5)
+		if _, err := io.ReadFull(server.streams[v1.StreamTypeStdin], buffer); err != nil {
+			t.Errorf("can't read stdin: %v", err)
+		}
+		server.streams[v1.StreamTypeStdout].Write([]byte(strings.ToUpper(string(buffer))))
+		server.streams[v1.StreamTypeStdout].Close()
+		server.streams[v1.StreamTypeError].Close()
+	})
+	k8sClient, cfg := server.client(t)
+	session, conn := dialWebSocketSession(t, ChannelWebSocketProtocol)
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte("\x00hello")); err != nil {
+		t.Fatalf("can't write stdin: %v", err)
+	}
+
+	options := &v1.PodAttachOptions{Container: "app", Stdin: true, Stdout: true}
+	err := streamPodSubresource(k8sClient, cfg, "tenant-1", "ns-1", "pod-1", "attach", options,
+		streamOptionsForSession(session, true, true, false, false))
+	if err != nil {
+		t.Fatalf("streamPodSubresource() returned error: %v", err)
+	}
+
+	if path := server.request.URL.Path; !strings.HasSuffix(path, "/tenants/tenant-1/namespaces/ns-1/pods/pod-1/attach") {
+		t.Errorf("unexpected request path %q", path)
+	}
+	if query := server.request.URL.Query(); query.Get("container") != "app" || query.Get("stdin") != "true" {
+		t.Errorf("unexpected request query %q", server.request.URL.RawQuery)
+	}
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("can't read stdout: %v", err)
+	}
+	if string(message) != "\x01HELLO" {
+		t.Errorf("got message %q, expected %q", message, "\x01HELLO")
+	}
+}
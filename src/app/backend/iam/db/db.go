@@ -16,16 +16,32 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/iam/model"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/tracing"
 	"log"
 	"os"
+	"time"
 )
 
 // CreateConnection creates connection with postgres db
 func CreateConnection() *sql.DB {
+	db, err := Connect()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	fmt.Println("Successfully connected!")
+	// return the connection
+	return db
+}
+
+// Connect opens a connection with the postgres db and checks that it can be reached. Unlike
+// CreateConnection it returns errors, so that callers can run without the db.
+func Connect() (*sql.DB, error) {
 
 	DB_HOST := os.Getenv("DB_HOST")
 	DB_PORT := os.Getenv("DB_PORT")
@@ -40,22 +56,21 @@ func CreateConnection() *sql.DB {
 	db, err := sql.Open("postgres", connStr)
 
 	if err != nil {
-		log.Fatalf("Error opening database: %q", err)
+		return nil, fmt.Errorf("Error opening database: %q", err)
 	}
 	// check the connection
 	err = db.Ping()
 
 	if err != nil {
-		log.Fatalf("Error connecting to the database: %s", err)
+		db.Close()
+		return nil, fmt.Errorf("Error connecting to the database: %s", err)
 	}
-	fmt.Println("Successfully connected!")
-	// return the connection
-	return db
+	return db, nil
 }
 
 // insert one user in the DB
 
-func InsertUser(user model.User) int64 {
+func InsertUser(ctx context.Context, user model.User) int64 {
 
 	// create the postgres db connection
 	db := CreateConnection()
@@ -63,6 +78,10 @@ func InsertUser(user model.User) int64 {
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("insert_user", time.Now())
+	defer tracing.StartDBSpan(ctx, "insert_user").End()
+
 	// create the insert sql query
 	// returning userid will return the id of the inserted user
 	sqlStatement := `INSERT INTO userdetails (username, password, token, type, tenant, role, creationtime, namespace) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT ON CONSTRAINT userdetails_username_key DO UPDATE SET token=EXCLUDED.token RETURNING userid;`
@@ -83,13 +102,17 @@ func InsertUser(user model.User) int64 {
 }
 
 // GetUser gets a user from the DB by its userid
-func GetUser(param string) (model.UserDetails, error) {
+func GetUser(ctx context.Context, param string) (model.UserDetails, error) {
 	// create the postgres db connection
 	db := CreateConnection()
 
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("get_user", time.Now())
+	defer tracing.StartDBSpan(ctx, "get_user").End()
+
 	// create a user of model.User type
 	var user model.UserDetails
 
@@ -119,13 +142,17 @@ func GetUser(param string) (model.UserDetails, error) {
 	return user, err
 }
 
-func GetUserDetail(param string) (model.UserDetails, error) {
+func GetUserDetail(ctx context.Context, param string) (model.UserDetails, error) {
 	// create the postgres db connection
 	db := CreateConnection()
 
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("get_user_detail", time.Now())
+	defer tracing.StartDBSpan(ctx, "get_user_detail").End()
+
 	// create a user of model.User type
 	var user model.UserDetails
 
@@ -156,13 +183,17 @@ func GetUserDetail(param string) (model.UserDetails, error) {
 }
 
 // GetAllUsers gets all the users from the DB
-func GetAllUsers(tenant string) (*model.UserList, error) {
+func GetAllUsers(ctx context.Context, tenant string) (*model.UserList, error) {
 	// create the postgres db connection
 	db := CreateConnection()
 
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("list_users", time.Now())
+	defer tracing.StartDBSpan(ctx, "list_users").End()
+
 	userList := new(model.UserList)
 
 	// create the select sql query
@@ -216,7 +247,7 @@ func GetAllUsers(tenant string) (*model.UserList, error) {
 }
 
 // DeleteUser deletes a user from database
-func DeleteUser(id int64) int64 {
+func DeleteUser(ctx context.Context, id int64) int64 {
 
 	// create the postgres db connection
 	db := CreateConnection()
@@ -224,6 +255,10 @@ func DeleteUser(id int64) int64 {
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("delete_user", time.Now())
+	defer tracing.StartDBSpan(ctx, "delete_user").End()
+
 	// create the delete sql query
 	sqlStatement := `DELETE FROM userdetails WHERE userid=$1`
 
@@ -246,7 +281,7 @@ func DeleteUser(id int64) int64 {
 	return rowsAffected
 }
 
-func DeleteAllUser() int64 {
+func DeleteAllUser(ctx context.Context) int64 {
 
 	// create the postgres db connection
 	db := CreateConnection()
@@ -254,6 +289,10 @@ func DeleteAllUser() int64 {
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("delete_all_users", time.Now())
+	defer tracing.StartDBSpan(ctx, "delete_all_users").End()
+
 	// create the delete sql query
 	sqlStatement := `DELETE * FROM userdetails`
 
@@ -276,7 +315,7 @@ func DeleteAllUser() int64 {
 	return rowsAffected
 }
 
-func DeleteTenantUser(tenant string) int64 {
+func DeleteTenantUser(ctx context.Context, tenant string) int64 {
 
 	// create the postgres db connection
 	db := CreateConnection()
@@ -284,6 +323,10 @@ func DeleteTenantUser(tenant string) int64 {
 	// close the db connection
 	defer db.Close()
 
+	// record the latency and the span of the query
+	defer instrumentation.ObserveDBQuery("delete_tenant_users", time.Now())
+	defer tracing.StartDBSpan(ctx, "delete_tenant_users").End()
+
 	// create the delete sql query
 	sqlStatement := `DELETE FROM userdetails WHERE tenant=$1`
 
@@ -16,6 +16,7 @@
 package iam
 
 import (
+	"context"
 	"errors"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/args"
@@ -153,16 +154,16 @@ func CreateClusterAdmin() error {
 		NameSpace:         "default",
 		CreationTimestamp: time.Now(),
 	}
-	userDetail, err := db.GetUser(user.Username)
+	userDetail, err := db.GetUser(context.Background(), user.Username)
 	if err != nil {
 		log.Printf("Get user for admin user failed, err:%s \n", err.Error())
 	}
 	if userDetail.ObjectMeta.Username == admin && userDetail.ObjectMeta.Token != string(token) {
-		db.DeleteAllUser()
+		db.DeleteAllUser(context.Background())
 	}
 
 	// call insertUser function and pass the user data
-	insertID := db.InsertUser(user)
+	insertID := db.InsertUser(context.Background(), user)
 
 	log.Printf("\nUser Id: %d", insertID)
 	return nil
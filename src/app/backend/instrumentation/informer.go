@@ -0,0 +1,67 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var informerSyncedDesc = prometheus.NewDesc(
+	"dashboard_informer_synced",
+	"Whether the informer of the resource of the partition has synced its cache (1) or not (0).",
+	[]string{"partition", "resource"}, nil,
+)
+
+var informers = &informerCollector{}
+
+type informer struct {
+	partition string
+	resource  string
+	hasSynced func() bool
+}
+
+// informerCollector reports the sync state of the registered informers when it is collected.
+type informerCollector struct {
+	mu        sync.Mutex
+	informers []informer
+}
+
+// RegisterInformer reports the sync state of the informer of the resource of the partition. hasSynced
+// is usually the HasSynced method of a shared informer.
+func RegisterInformer(partition, resource string, hasSynced func() bool) {
+	informers.mu.Lock()
+	defer informers.mu.Unlock()
+	informers.informers = append(informers.informers, informer{partition, resource, hasSynced})
+}
+
+// Describe implements prometheus.Collector.
+func (self *informerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- informerSyncedDesc
+}
+
+// Collect implements prometheus.Collector.
+func (self *informerCollector) Collect(ch chan<- prometheus.Metric) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, i := range self.informers {
+		synced := 0.0
+		if i.hasSynced() {
+			synced = 1
+		}
+		ch <- prometheus.MustNewConstMetric(informerSyncedDesc, prometheus.GaugeValue, synced, i.partition, i.resource)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func histogramCount(t *testing.T, observer prometheus.Observer) uint64 {
+	metric := &dto.Metric{}
+	if err := observer.(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
+// collectTenants returns the values of the tenant label of the collected series.
+func collectTenants(t *testing.T, collector prometheus.Collector) map[string]bool {
+	ch := make(chan prometheus.Metric, 100)
+	collector.Collect(ch)
+	close(ch)
+
+	result := map[string]bool{}
+	for m := range ch {
+		metric := &dto.Metric{}
+		if err := m.Write(metric); err != nil {
+			t.Fatal(err)
+		}
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "tenant" {
+				result[label.GetValue()] = true
+			}
+		}
+	}
+	return result
+}
+
+func TestRequestTenant(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/v1/tenants/t1/namespaces/default/pods", "t1"},
+		{"/apis/apps/v1/tenants/t2/namespaces/default/deployments", "t2"},
+		{"/api/v1/tenants/t3", "t3"},
+		{"/api/v1/tenants", ""},
+		{"/api/v1/nodes", ""},
+		{"/api/v1/namespaces/default/pods/tenants", ""},
+	}
+	for _, c := range cases {
+		if actual := requestTenant(c.path); actual != c.expected {
+			t.Errorf("requestTenant(%q) = %q, expected %q", c.path, actual, c.expected)
+		}
+	}
+}
+
+func TestInstrumentTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/tenants/t1/pods":
+			w.WriteHeader(http.StatusOK)
+		case "/api/v1/tenants/made-up/pods":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: InstrumentTransport("tp1")(http.DefaultTransport)}
+	for _, path := range []string{"/api/v1/tenants/t1/pods", "/api/v1/tenants/t1/pods", "/api/v1/nodes",
+		"/api/v1/tenants/made-up/pods"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if count := histogramCount(t, apiserverRequestLatencies.WithLabelValues("tp1", "t1", "GET", "200")); count != 2 {
+		t.Errorf("expected 2 observed requests, got %d", count)
+	}
+	if count := testutil.ToFloat64(apiserverRequestErrors.WithLabelValues("tp1", "t1", "GET", "200")); count != 0 {
+		t.Errorf("expected no errors for successful requests, got %v", count)
+	}
+	if count := testutil.ToFloat64(apiserverRequestErrors.WithLabelValues("tp1", "", "GET", "503")); count != 1 {
+		t.Errorf("expected 1 server error, got %v", count)
+	}
+	if tenants := collectTenants(t, apiserverRequestLatencies); tenants["made-up"] {
+		t.Errorf("expected no series of the made up tenant, got %v", tenants)
+	}
+	if count := histogramCount(t, apiserverRequestLatencies.WithLabelValues("tp1", OtherLabel, "GET", "403")); count != 1 {
+		t.Errorf("expected the forbidden request to be observed as other tenant, got %d", count)
+	}
+}
+
+func TestInstrumentTransportError(t *testing.T) {
+	failing := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	client := &http.Client{Transport: InstrumentTransport("rp1")(failing)}
+	if _, err := client.Get("http://rp1/api/v1/tenants/t1/pods?watch=true"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if count := testutil.ToFloat64(apiserverRequestErrors.WithLabelValues("rp1", OtherLabel, "WATCH", CodeError)); count != 1 {
+		t.Errorf("expected 1 transport error, got %v", count)
+	}
+	if count := histogramCount(t, apiserverRequestLatencies.WithLabelValues("rp1", OtherLabel, "WATCH", CodeError)); count != 0 {
+		t.Errorf("expected the latency of watches not to be observed, got %d", count)
+	}
+}
+
+func TestRegisterInformer(t *testing.T) {
+	synced := false
+	RegisterInformer("tp1", "pods", func() bool { return synced })
+
+	ch := make(chan prometheus.Metric, 1)
+	informers.Collect(ch)
+	if len(ch) != 1 {
+		t.Fatalf("expected 1 informer metric, got %d", len(ch))
+	}
+	metric := &dto.Metric{}
+	if err := (<-ch).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	if value := metric.GetGauge().GetValue(); value != 0 {
+		t.Errorf("expected unsynced informer to be reported as 0, got %v", value)
+	}
+
+	synced = true
+	informers.Collect(ch)
+	if err := (<-ch).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	if value := metric.GetGauge().GetValue(); value != 1 {
+		t.Errorf("expected synced informer to be reported as 1, got %v", value)
+	}
+}
+
+func TestObserveDBQuery(t *testing.T) {
+	ObserveDBQuery("get_user", time.Now())
+	if count := histogramCount(t, dbQueryLatencies.WithLabelValues("get_user")); count != 1 {
+		t.Errorf("expected 1 observed query, got %d", count)
+	}
+}
+
+func TestRecordAuthFailure(t *testing.T) {
+	RecordAuthFailure(AuthFailureLogin)
+	RecordAuthFailure(AuthFailureLogin)
+	if count := testutil.ToFloat64(authFailures.WithLabelValues(AuthFailureLogin)); count != 2 {
+		t.Errorf("expected 2 auth failures, got %v", count)
+	}
+}
+
+func TestTenantLabel(t *testing.T) {
+	cases := []struct {
+		tenant   string
+		code     int
+		expected string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"t1", http.StatusOK, "t1"},
+		{"t1", http.StatusNotModified, "t1"},
+		{"made-up", http.StatusUnauthorized, OtherLabel},
+		{"made-up", http.StatusForbidden, OtherLabel},
+		{"made-up", http.StatusNotFound, OtherLabel},
+		{"made-up", 0, OtherLabel},
+	}
+	for _, c := range cases {
+		if actual := TenantLabel(c.tenant, c.code); actual != c.expected {
+			t.Errorf("TenantLabel(%q, %d) == %q, expected %q", c.tenant, c.code, actual, c.expected)
+		}
+	}
+}
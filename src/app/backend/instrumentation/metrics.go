@@ -0,0 +1,96 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instrumentation exposes operational metrics of the dashboard backend, e.g. the latency of
+// the calls to the apiservers of the partitions, in the default prometheus registry.
+package instrumentation
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiserverRequestLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "dashboard_apiserver_request_duration_seconds",
+			Help:    "Latency of the requests sent to the apiservers of the partitions, by partition, tenant, verb and code.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"partition", "tenant", "verb", "code"},
+	)
+	apiserverRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dashboard_apiserver_request_errors_total",
+			Help: "Number of the requests sent to the apiservers of the partitions that failed with a transport error or a server error.",
+		},
+		[]string{"partition", "tenant", "verb", "code"},
+	)
+	dbQueryLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "dashboard_db_query_duration_seconds",
+			Help:    "Latency of the queries sent to the database, by operation.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	authFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dashboard_auth_failures_total",
+			Help: "Number of failed logins and token refreshes, by reason.",
+		},
+		[]string{"reason"},
+	)
+)
+
+// Reasons of the auth failures.
+// OtherLabel replaces partition and tenant label values that could be chosen freely by clients, so
+// that they cannot create an unbounded number of series.
+const OtherLabel = "other"
+
+const (
+	AuthFailureMissingTenant = "missing_tenant"
+	AuthFailureLogin         = "login"
+	AuthFailureTokenRefresh  = "token_refresh"
+)
+
+func init() {
+	prometheus.MustRegister(apiserverRequestLatencies)
+	prometheus.MustRegister(apiserverRequestErrors)
+	prometheus.MustRegister(dbQueryLatencies)
+	prometheus.MustRegister(authFailures)
+	prometheus.MustRegister(informers)
+}
+
+// ObserveDBQuery records the latency of a database query started at the given time. It is meant to
+// be deferred right before the query, i.e. defer ObserveDBQuery("get_user", time.Now()).
+func ObserveDBQuery(operation string, start time.Time) {
+	dbQueryLatencies.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// RecordAuthFailure counts a failed login or token refresh. The tenant of the login is not recorded,
+// because it is chosen freely by unauthenticated clients.
+func RecordAuthFailure(reason string) {
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// TenantLabel returns the tenant of requests that succeeded, as the user had to be authenticated and
+// authorized for the tenant, and OtherLabel for the others.
+func TenantLabel(tenant string, httpCode int) string {
+	if len(tenant) == 0 || (httpCode >= 200 && httpCode < 400) {
+		return tenant
+	}
+	return OtherLabel
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instrumentation
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CodeError is the code label of the requests that did not get a response.
+const CodeError = "error"
+
+// InstrumentTransport returns a wrapper of the transport of the clients of the given partition, which
+// records the latency and the errors of every request sent to its apiserver. It is meant to be set as
+// the WrapTransport of a rest config.
+func InstrumentTransport(partition string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &instrumentedRoundTripper{partition: partition, delegate: rt}
+	}
+}
+
+type instrumentedRoundTripper struct {
+	partition string
+	delegate  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (self *instrumentedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := self.delegate.RoundTrip(req)
+
+	verb := requestVerb(req)
+	status, code := 0, CodeError
+	if err == nil {
+		status, code = resp.StatusCode, strconv.Itoa(resp.StatusCode)
+	}
+	// The tenant of failed requests is not recorded, as it could be a made up one.
+	tenant := TenantLabel(requestTenant(req.URL.Path), status)
+
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		apiserverRequestErrors.WithLabelValues(self.partition, tenant, verb, code).Inc()
+	}
+	// Watches are long running, their latency only tells how long the watch was kept open.
+	if verb != "WATCH" {
+		apiserverRequestLatencies.WithLabelValues(self.partition, tenant, verb, code).
+			Observe(time.Since(start).Seconds())
+	}
+	return resp, err
+}
+
+// requestVerb returns the method of the request, or WATCH for watch requests.
+func requestVerb(req *http.Request) string {
+	if req.Method == http.MethodGet && req.URL.Query().Get("watch") == "true" {
+		return "WATCH"
+	}
+	return req.Method
+}
+
+// requestTenant returns the tenant of an apiserver path, i.e. /api/v1/tenants/<tenant>/... or
+// /apis/<group>/<version>/tenants/<tenant>/..., or an empty string for requests which are not
+// scoped to a tenant.
+func requestTenant(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+1 < len(parts) && i < 4; i++ {
+		if parts[i] == "tenants" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
@@ -45,6 +45,13 @@ type MetricClient interface {
 	integrationapi.Integration
 }
 
+// WindowedMetricClient is implemented by metric clients that are able to download metrics over a
+// time window other than their default one.
+type WindowedMetricClient interface {
+	// WithWindow returns a copy of the client that downloads metrics of the given time window.
+	WithWindow(window time.Duration) MetricClient
+}
+
 // CachedResources contains all resources that may be required by DataSelect functions for metric
 // gathering. Depending on the need you may have to provide DataSelect with resources it
 // requires, for example resource like deployment will need Pods in order to calculate its metrics.
@@ -94,6 +101,8 @@ var DerivedResources = map[api.ResourceKind]api.ResourceKind{
 // ResourceSelector is a structure used to quickly and uniquely identify given resource.
 // This struct can be later used for heapster data download etc.
 type ResourceSelector struct {
+	// Tenant of this resource.
+	Tenant string
 	// Namespace of this resource.
 	Namespace string
 	// Type of this resource
@@ -107,8 +116,11 @@ type ResourceSelector struct {
 }
 
 const (
-	CpuUsage    = "cpu/usage_rate"
-	MemoryUsage = "memory/usage"
+	CpuUsage        = "cpu/usage_rate"
+	MemoryUsage     = "memory/usage"
+	NetworkRxRate   = "network/rx_rate"
+	NetworkTxRate   = "network/tx_rate"
+	FilesystemUsage = "filesystem/usage"
 )
 
 type DataPoints []DataPoint
@@ -0,0 +1,93 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"time"
+
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+)
+
+// WithWindow returns a metric client that downloads metrics of the given time window. Clients
+// implementing WindowedMetricClient download the window themselves. Metrics of other clients are
+// trimmed to the window, so that windows longer than the history kept by them have no effect.
+func WithWindow(metricClient metricapi.MetricClient, window time.Duration) metricapi.MetricClient {
+	if metricClient == nil || window <= 0 {
+		return metricClient
+	}
+
+	if windowed, ok := metricClient.(metricapi.WindowedMetricClient); ok {
+		return windowed.WithWindow(window)
+	}
+
+	return windowedClient{MetricClient: metricClient, window: window, now: time.Now}
+}
+
+// windowedClient drops all points of the downloaded metrics that are older than the window.
+type windowedClient struct {
+	metricapi.MetricClient
+	window time.Duration
+	now    func() time.Time
+}
+
+// DownloadMetric implements metric client interface. See MetricClient for more information.
+func (self windowedClient) DownloadMetric(selectors []metricapi.ResourceSelector, metricName string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	return self.trim(self.MetricClient.DownloadMetric(selectors, metricName, cachedResources))
+}
+
+// DownloadMetrics implements metric client interface. See MetricClient for more information.
+func (self windowedClient) DownloadMetrics(selectors []metricapi.ResourceSelector, metricNames []string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	return self.trim(self.MetricClient.DownloadMetrics(selectors, metricNames, cachedResources))
+}
+
+func (self windowedClient) trim(metricPromises metricapi.MetricPromises) metricapi.MetricPromises {
+	start := self.now().Add(-self.window)
+	result := metricapi.NewMetricPromises(len(metricPromises))
+	for i, metricPromise := range metricPromises {
+		go func(from metricapi.MetricPromise, to metricapi.MetricPromise) {
+			metric, err := from.GetMetric()
+			if metric != nil {
+				trimmed := trimMetric(*metric, start)
+				metric = &trimmed
+			}
+			to.Metric <- metric
+			to.Error <- err
+		}(metricPromise, result[i])
+	}
+	return result
+}
+
+// trimMetric returns a copy of the metric without the points older than start.
+func trimMetric(metric metricapi.Metric, start time.Time) metricapi.Metric {
+	dataPoints := metricapi.DataPoints{}
+	for _, point := range metric.DataPoints {
+		if point.X >= start.Unix() {
+			dataPoints = append(dataPoints, point)
+		}
+	}
+
+	metricPoints := []metricapi.MetricPoint{}
+	for _, point := range metric.MetricPoints {
+		if !point.Timestamp.Before(start) {
+			metricPoints = append(metricPoints, point)
+		}
+	}
+
+	metric.DataPoints = dataPoints
+	metric.MetricPoints = metricPoints
+	return metric
+}
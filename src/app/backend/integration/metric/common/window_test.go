@@ -0,0 +1,98 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+)
+
+type fakeMetricClient struct {
+	metric metricapi.Metric
+	window time.Duration
+}
+
+func (self fakeMetricClient) DownloadMetric(selectors []metricapi.ResourceSelector, metricName string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	return getMetricPromises([]metricapi.Metric{self.metric})
+}
+
+func (self fakeMetricClient) DownloadMetrics(selectors []metricapi.ResourceSelector, metricNames []string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	return getMetricPromises([]metricapi.Metric{self.metric})
+}
+
+func (self fakeMetricClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return AggregateMetricPromises(metrics, metricName, aggregations, nil)
+}
+
+func (self fakeMetricClient) HealthCheck() error {
+	return nil
+}
+
+func (self fakeMetricClient) ID() integrationapi.IntegrationID {
+	return "fake"
+}
+
+type fakeWindowedMetricClient struct {
+	fakeMetricClient
+}
+
+func (self fakeWindowedMetricClient) WithWindow(window time.Duration) metricapi.MetricClient {
+	self.window = window
+	return self
+}
+
+func TestWithWindow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	metric := metricapi.Metric{
+		MetricName: metricapi.CpuUsage,
+		DataPoints: metricapi.DataPoints{{X: 700, Y: 1}, {X: 800, Y: 2}, {X: 1000, Y: 3}},
+		MetricPoints: []metricapi.MetricPoint{
+			{Timestamp: time.Unix(700, 0), Value: 1},
+			{Timestamp: time.Unix(800, 0), Value: 2},
+			{Timestamp: time.Unix(1000, 0), Value: 3},
+		},
+	}
+
+	client := WithWindow(fakeMetricClient{metric: metric}, 200*time.Second).(windowedClient)
+	client.now = func() time.Time { return now }
+
+	metrics, err := client.DownloadMetric(nil, metricapi.CpuUsage, metricapi.NoResourceCache).GetMetrics()
+	if err != nil {
+		t.Fatalf("DownloadMetric() returned unexpected error: %v", err)
+	}
+	expected := []metricapi.Metric{{
+		MetricName:   metricapi.CpuUsage,
+		DataPoints:   metricapi.DataPoints{{X: 800, Y: 2}, {X: 1000, Y: 3}},
+		MetricPoints: []metricapi.MetricPoint{{Timestamp: time.Unix(800, 0), Value: 2}, {Timestamp: time.Unix(1000, 0), Value: 3}},
+	}}
+	if !reflect.DeepEqual(metrics, expected) {
+		t.Errorf("DownloadMetric() == %v, expected %v", metrics, expected)
+	}
+
+	if actual := WithWindow(fakeWindowedMetricClient{}, time.Hour).(fakeWindowedMetricClient); actual.window != time.Hour {
+		t.Errorf("WithWindow() did not set window of windowed client, got %v", actual.window)
+	}
+
+	if _, ok := WithWindow(fakeMetricClient{}, 0).(fakeMetricClient); !ok {
+		t.Error("WithWindow() with zero window should return the client itself")
+	}
+}
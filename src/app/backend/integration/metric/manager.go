@@ -23,6 +23,8 @@ import (
 	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
 	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/heapster"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/metricsserver"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/prometheus"
 	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/sidecar"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
@@ -45,6 +47,10 @@ type MetricManager interface {
 	ConfigureSidecar(host string) MetricManager
 	// ConfigureHeapster configures and adds sidecar to clients list.
 	ConfigureHeapster(host string) MetricManager
+	// ConfigurePrometheus configures and adds prometheus to clients list.
+	ConfigurePrometheus(host string) MetricManager
+	// ConfigureMetricsServer configures and adds metrics-server to clients list.
+	ConfigureMetricsServer() MetricManager
 }
 
 // Implements MetricManager interface.
@@ -143,6 +149,31 @@ func (self *metricManager) ConfigureHeapster(host string) MetricManager {
 	return self
 }
 
+// ConfigurePrometheus implements metric manager interface. See MetricManager for more information.
+func (self *metricManager) ConfigurePrometheus(host string) MetricManager {
+	metricClient, err := prometheus.CreatePrometheusClient(host)
+	if err != nil {
+		log.Printf("There was an error during prometheus client creation: %s", err.Error())
+		return self
+	}
+
+	self.clients[metricClient.ID()] = metricClient
+	return self
+}
+
+// ConfigureMetricsServer implements metric manager interface. See MetricManager for more information.
+func (self *metricManager) ConfigureMetricsServer() MetricManager {
+	kubeClient := self.manager.InsecureClient()
+	metricClient, err := metricsserver.CreateMetricsServerClient(kubeClient)
+	if err != nil {
+		log.Printf("There was an error during metrics-server client creation: %s", err.Error())
+		return self
+	}
+
+	self.clients[metricClient.ID()] = metricClient
+	return self
+}
+
 // NewMetricManager creates metric manager.
 func NewMetricManager(manager clientapi.ClientManager) MetricManager {
 	return &metricManager{
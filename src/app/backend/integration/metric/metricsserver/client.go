@@ -0,0 +1,300 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/common"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// MetricsAPIPath is the path of the metrics.k8s.io API served by metrics-server.
+	MetricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+	// HistorySize is the number of points kept per resource and metric to draw sparklines.
+	HistorySize = 15
+
+	// Resolution is the interval the timestamps of the recorded points are truncated to, so that the
+	// points of different resources can be aggregated.
+	Resolution = 10 * time.Second
+
+	// historyMaxAge is the time after which the history of resources that are not requested anymore
+	// is dropped.
+	historyMaxAge = 15 * time.Minute
+)
+
+// usageResources maps the supported metrics to the resources of the metrics.k8s.io usage.
+var usageResources = map[string]v1.ResourceName{
+	metricapi.CpuUsage:    v1.ResourceCPU,
+	metricapi.MemoryUsage: v1.ResourceMemory,
+}
+
+// Metrics-server client implements MetricClient and Integration interfaces. Metrics-server only
+// provides the current usage, so the client records the usage of every downloaded resource to
+// return a short history.
+type metricsServerClient struct {
+	client  rest.Interface
+	history *history
+	now     func() time.Time
+}
+
+// Implement Integration interface.
+
+// HealthCheck implements integration app interface. See Integration interface for more information.
+func (self metricsServerClient) HealthCheck() error {
+	if self.client == nil {
+		return errors.New("Metrics-server not configured")
+	}
+
+	_, err := self.client.Get().AbsPath(MetricsAPIPath).DoRaw()
+	return err
+}
+
+// ID implements integration app interface. See Integration interface for more information.
+func (self metricsServerClient) ID() integrationapi.IntegrationID {
+	return integrationapi.MetricsServerIntegrationID
+}
+
+// Implement MetricClient interface
+
+// DownloadMetrics implements metric client interface. See MetricClient for more information.
+func (self metricsServerClient) DownloadMetrics(selectors []metricapi.ResourceSelector,
+	metricNames []string, cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		collectedMetrics := self.DownloadMetric(selectors, metricName, cachedResources)
+		result = append(result, collectedMetrics...)
+	}
+	return result
+}
+
+// DownloadMetric implements metric client interface. See MetricClient for more information.
+func (self metricsServerClient) DownloadMetric(selectors []metricapi.ResourceSelector,
+	metricName string, cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	metricsServerSelectors := getMetricsServerSelectors(selectors, cachedResources)
+	result := metricapi.NewMetricPromises(len(metricsServerSelectors))
+	go self.downloadMetric(metricsServerSelectors, metricName, result)
+	return result
+}
+
+// AggregateMetrics implements metric client interface. See MetricClient for more information.
+func (self metricsServerClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return common.AggregateMetricPromises(metrics, metricName, aggregations, nil)
+}
+
+// downloadMetric downloads the current usage of all selectors with one request per resource type,
+// tenant and namespace, records the usage of each selector, summed over its resources, and puts the
+// recorded history into the promises.
+func (self metricsServerClient) downloadMetric(selectors []metricsServerSelector, metricName string,
+	result metricapi.MetricPromises) {
+	resourceName, ok := usageResources[metricName]
+	if !ok {
+		err := fmt.Errorf(`Metric "%s" is not supported by metrics-server`, metricName)
+		for i := range selectors {
+			result[i].Metric <- nil
+			result[i].Error <- err
+		}
+		return
+	}
+
+	compressed := compress(selectors)
+
+	lock := sync.Mutex{}
+	downloaded := make(map[string]map[string]v1.ResourceList, len(compressed))
+	failed := make(map[string]error)
+	wg := sync.WaitGroup{}
+	for key, selector := range compressed {
+		wg.Add(1)
+		go func(key string, selector metricsServerSelector) {
+			defer wg.Done()
+			usage, err := self.downloadUsage(selector)
+
+			lock.Lock()
+			defer lock.Unlock()
+			downloaded[key] = usage
+			failed[key] = err
+		}(key, selector)
+	}
+	wg.Wait()
+
+	timestamp := self.now().Truncate(Resolution)
+	for i, selector := range selectors {
+		if err := failed[selector.key()]; err != nil {
+			result[i].Metric <- nil
+			result[i].Error <- err
+			continue
+		}
+
+		metric := metricapi.Metric{
+			DataPoints:   metricapi.DataPoints{},
+			MetricPoints: []metricapi.MetricPoint{},
+			MetricName:   metricName,
+			Label:        selector.Label,
+			Aggregate:    metricapi.SumAggregation,
+		}
+
+		var sum int64
+		found := false
+		for _, name := range selector.Resources {
+			usage, exists := downloaded[selector.key()][name]
+			if !exists {
+				continue
+			}
+			sum += toValue(usage, resourceName)
+			found = true
+		}
+
+		if found {
+			point := metricapi.MetricPoint{Timestamp: timestamp, Value: uint64(sum)}
+			metric.MetricPoints = self.history.record(selector.ID+"/"+metricName, point)
+			metric.DataPoints = toDataPoints(metric.MetricPoints)
+		}
+
+		result[i].Metric <- &metric
+		result[i].Error <- nil
+	}
+}
+
+// downloadUsage lists the current usage of all resources of the given type in the tenant and
+// namespace of the selector and returns the usage of the selected resources by name. Resources
+// without metrics, e.g. pods that were just started, are missing in the result.
+func (self metricsServerClient) downloadUsage(selector metricsServerSelector) (
+	map[string]v1.ResourceList, error) {
+	request := self.client.Get().AbsPath(MetricsAPIPath).Tenant(selector.Tenant)
+	if selector.TargetResourceType == api.ResourceKindPod {
+		request = request.Namespace(selector.Namespace).Resource("pods")
+	} else {
+		request = request.Resource("nodes")
+	}
+
+	rawResult, err := request.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	requested := make(map[string]bool, len(selector.Resources))
+	for _, name := range selector.Resources {
+		requested[name] = true
+	}
+
+	result := make(map[string]v1.ResourceList)
+	if selector.TargetResourceType == api.ResourceKindPod {
+		list := new(PodMetricsList)
+		if err := json.Unmarshal(rawResult, list); err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			if requested[item.Name] {
+				result[item.Name] = sumContainers(item.Containers)
+			}
+		}
+		return result, nil
+	}
+
+	list := new(NodeMetricsList)
+	if err := json.Unmarshal(rawResult, list); err != nil {
+		return nil, err
+	}
+	for _, item := range list.Items {
+		if requested[item.Name] {
+			result[item.Name] = item.Usage
+		}
+	}
+	return result, nil
+}
+
+// compress merges all selectors that can be downloaded with a single request.
+func compress(selectors []metricsServerSelector) map[string]metricsServerSelector {
+	result := make(map[string]metricsServerSelector)
+	for _, selector := range selectors {
+		if len(selector.Resources) == 0 {
+			continue
+		}
+
+		compressed, exists := result[selector.key()]
+		if !exists {
+			compressed = metricsServerSelector{
+				TargetResourceType: selector.TargetResourceType,
+				Tenant:             selector.Tenant,
+				Namespace:          selector.Namespace,
+			}
+		}
+		compressed.Resources = append(compressed.Resources, selector.Resources...)
+		result[selector.key()] = compressed
+	}
+	return result
+}
+
+// sumContainers returns the usage of a pod as the sum of the usage of its containers.
+func sumContainers(containers []ContainerMetrics) v1.ResourceList {
+	result := v1.ResourceList{}
+	for _, container := range containers {
+		for name, quantity := range container.Usage {
+			sum := result[name]
+			sum.Add(quantity)
+			result[name] = sum
+		}
+	}
+	return result
+}
+
+// toValue returns the usage of the resource in the units of the metric, i.e. millicores for cpu and
+// bytes for memory.
+func toValue(usage v1.ResourceList, resourceName v1.ResourceName) int64 {
+	quantity, exists := usage[resourceName]
+	if !exists {
+		return 0
+	}
+	if resourceName == v1.ResourceCPU {
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
+
+func toDataPoints(metricPoints []metricapi.MetricPoint) metricapi.DataPoints {
+	result := make(metricapi.DataPoints, 0, len(metricPoints))
+	for _, point := range metricPoints {
+		result = append(result, metricapi.DataPoint{X: point.Timestamp.Unix(), Y: int64(point.Value)})
+	}
+	return result
+}
+
+// CreateMetricsServerClient creates new metrics-server client. It talks to the metrics.k8s.io API
+// through the API server of the given client.
+func CreateMetricsServerClient(k8sClient kubernetes.Interface) (metricapi.MetricClient, error) {
+	if k8sClient == nil {
+		return metricsServerClient{}, errors.New("Kubernetes client is not configured")
+	}
+
+	log.Print("Creating metrics-server client")
+	return metricsServerClient{
+		client:  k8sClient.CoreV1().RESTClient(),
+		history: newHistory(HistorySize, historyMaxAge),
+		now:     time.Now,
+	}, nil
+}
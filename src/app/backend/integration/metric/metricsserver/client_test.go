@@ -0,0 +1,240 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const testStart = int64(1470999600)
+
+// fakeResponses contains the responses of the fake metrics API by path.
+var fakeResponses = map[string]string{
+	MetricsAPIPath: `{"kind":"APIResourceList"}`,
+	MetricsAPIPath + "/tenants/t1/namespaces/a/pods": `{"items":[
+		{"metadata":{"name":"P1"},"containers":[
+			{"name":"c1","usage":{"cpu":"100m","memory":"1Mi"}},
+			{"name":"c2","usage":{"cpu":"250m","memory":"2Mi"}}]},
+		{"metadata":{"name":"P2"},"containers":[{"name":"c1","usage":{"cpu":"1","memory":"1Ki"}}]},
+		{"metadata":{"name":"P3"},"containers":[{"name":"c1","usage":{"cpu":"5m","memory":"0"}}]}]}`,
+	MetricsAPIPath + "/namespaces/a/pods": `{"items":[
+		{"metadata":{"name":"P1"},"containers":[{"name":"c1","usage":{"cpu":"7m","memory":"0"}}]}]}`,
+	MetricsAPIPath + "/nodes": `{"items":[{"metadata":{"name":"N1"},"usage":{"cpu":"2","memory":"4Gi"}}]}`,
+}
+
+// fakeMetricsAPI serves the fake responses and counts the requests of the pod and node metrics.
+type fakeMetricsAPI struct {
+	requests int32
+	healthy  bool
+}
+
+func (self *fakeMetricsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response, exists := fakeResponses[r.URL.Path]
+	if !exists || !self.healthy {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.URL.Path != MetricsAPIPath {
+		atomic.AddInt32(&self.requests, 1)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, response)
+}
+
+func newTestClient(t *testing.T, metricsAPI *fakeMetricsAPI) (metricsServerClient, *time.Time, func()) {
+	server := httptest.NewServer(metricsAPI)
+	cfg := &rest.Config{}
+	cfg.AddConfig(&rest.KubeConfig{Host: server.URL})
+	k8sClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("Received unexpected error: %v", err)
+	}
+	metricClient, err := CreateMetricsServerClient(k8sClient)
+	if err != nil {
+		t.Fatalf("CreateMetricsServerClient() returned unexpected error: %v", err)
+	}
+
+	now := time.Unix(testStart, 0)
+	c := metricClient.(metricsServerClient)
+	c.now = func() time.Time { return now }
+	return c, &now, server.Close
+}
+
+func newDps(ys ...int64) metricapi.DataPoints {
+	result := metricapi.DataPoints{}
+	for i, y := range ys {
+		result = append(result, metricapi.DataPoint{X: testStart + int64(10*i), Y: y})
+	}
+	return result
+}
+
+func newPod(tenant, namespace, name, uid string, labels map[string]string) v1.Pod {
+	return v1.Pod{ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: namespace, Name: name,
+		UID: types.UID(uid), Labels: labels}}
+}
+
+func getDataPoints(t *testing.T, promises metricapi.MetricPromises) []metricapi.DataPoints {
+	metrics, err := promises.GetMetrics()
+	if err != nil {
+		t.Fatalf("Received unexpected error: %v", err)
+	}
+
+	var result []metricapi.DataPoints
+	for _, metric := range metrics {
+		result = append(result, metric.DataPoints)
+	}
+	return result
+}
+
+func TestDownloadMetric(t *testing.T) {
+	cachedResources := &metricapi.CachedResources{Pods: []v1.Pod{
+		newPod("t1", "a", "P1", "U1", map[string]string{"app": "foo"}),
+		newPod("t1", "a", "P2", "U2", map[string]string{"app": "foo"}),
+		newPod("t1", "a", "P3", "U3", map[string]string{"app": "bar"}),
+	}}
+
+	cases := []struct {
+		info       string
+		selectors  []metricapi.ResourceSelector
+		metricName string
+		expected   []metricapi.DataPoints
+		requests   int32
+	}{
+		{
+			"pods of the same tenant and namespace are downloaded with a single request",
+			[]metricapi.ResourceSelector{
+				{Tenant: "t1", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U1"},
+				{Tenant: "t1", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P3", UID: "U3"},
+				{Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U4"},
+			},
+			metricapi.CpuUsage,
+			[]metricapi.DataPoints{newDps(350), newDps(5), newDps(7)},
+			2,
+		},
+		{
+			"metrics of derived resources are summed over their pods",
+			[]metricapi.ResourceSelector{
+				{Tenant: "t1", Namespace: "a", ResourceType: api.ResourceKindDeployment, ResourceName: "foo",
+					Selector: map[string]string{"app": "foo"}, UID: "D1"},
+			},
+			metricapi.MemoryUsage,
+			[]metricapi.DataPoints{newDps(3*1024*1024 + 1024)},
+			1,
+		},
+		{
+			"nodes and missing metrics",
+			[]metricapi.ResourceSelector{
+				{ResourceType: api.ResourceKindNode, ResourceName: "N1", UID: "N1"},
+				{Tenant: "t1", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P9", UID: "U9"},
+			},
+			metricapi.CpuUsage,
+			[]metricapi.DataPoints{newDps(2000), {}},
+			2,
+		},
+	}
+
+	for _, c := range cases {
+		metricsAPI := &fakeMetricsAPI{healthy: true}
+		client, _, done := newTestClient(t, metricsAPI)
+
+		actual := getDataPoints(t, client.DownloadMetric(c.selectors, c.metricName, cachedResources))
+		done()
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Test case: %s. Expected data points %v, got %v", c.info, c.expected, actual)
+		}
+		if metricsAPI.requests != c.requests {
+			t.Errorf("Test case: %s. Expected %d requests, got %d", c.info, c.requests, metricsAPI.requests)
+		}
+	}
+}
+
+func TestDownloadMetricRecordsHistory(t *testing.T) {
+	client, now, done := newTestClient(t, &fakeMetricsAPI{healthy: true})
+	defer done()
+
+	selectors := []metricapi.ResourceSelector{
+		{Tenant: "t1", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P2", UID: "U2"},
+	}
+	for i := 0; i < HistorySize+2; i++ {
+		*now = time.Unix(testStart+int64(10*i), 0)
+		getDataPoints(t, client.DownloadMetric(selectors, metricapi.CpuUsage, metricapi.NoResourceCache))
+	}
+
+	// A download within the same resolution interval replaces the latest point.
+	*now = now.Add(Resolution / 2)
+	metrics, err := client.DownloadMetric(selectors, metricapi.CpuUsage, metricapi.NoResourceCache).GetMetrics()
+	if err != nil {
+		t.Fatalf("Received unexpected error: %v", err)
+	}
+
+	points := metrics[0].MetricPoints
+	if len(points) != HistorySize {
+		t.Fatalf("Expected %d metric points, got %d", HistorySize, len(points))
+	}
+	if first := points[0].Timestamp.Unix(); first != testStart+20 {
+		t.Errorf("Expected oldest point at %d, got %d", testStart+20, first)
+	}
+	if last := points[len(points)-1]; last.Timestamp.Unix() != testStart+10*(HistorySize+1) || last.Value != 1000 {
+		t.Errorf("Expected latest point with value 1000 at %d, got %v", testStart+10*(HistorySize+1), last)
+	}
+}
+
+func TestDownloadMetricsWithErrors(t *testing.T) {
+	client, _, done := newTestClient(t, &fakeMetricsAPI{healthy: true})
+	defer done()
+
+	selectors := []metricapi.ResourceSelector{
+		{Tenant: "t2", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U1"},
+	}
+	if _, err := client.DownloadMetrics(selectors, []string{metricapi.CpuUsage},
+		metricapi.NoResourceCache).GetMetrics(); err == nil {
+		t.Error("Expected error for failed request")
+	}
+
+	if _, err := client.DownloadMetric(selectors, metricapi.NetworkRxRate,
+		metricapi.NoResourceCache).GetMetrics(); err == nil {
+		t.Error("Expected error for unsupported metric")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	for _, healthy := range []bool{true, false} {
+		client, _, done := newTestClient(t, &fakeMetricsAPI{healthy: healthy})
+		err := client.HealthCheck()
+		done()
+		if (err == nil) != healthy {
+			t.Errorf("Expected health check of healthy=%t metrics-server to return error=%t, got %v", healthy,
+				!healthy, err)
+		}
+	}
+
+	if err := (metricsServerClient{}).HealthCheck(); err == nil {
+		t.Error("Expected health check of unconfigured metrics-server to fail")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"sync"
+	"time"
+
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+)
+
+// ringBuffer keeps the latest metric points of a single resource, oldest first.
+type ringBuffer struct {
+	points []metricapi.MetricPoint
+	next   int
+	full   bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{points: make([]metricapi.MetricPoint, size)}
+}
+
+// add stores the point and overwrites the oldest one if the buffer is full. A point with the same
+// timestamp as the latest one replaces it and older points are ignored.
+func (self *ringBuffer) add(point metricapi.MetricPoint) {
+	if latest, ok := self.latest(); ok && !point.Timestamp.After(latest.Timestamp) {
+		if point.Timestamp.Equal(latest.Timestamp) {
+			self.points[(self.next+len(self.points)-1)%len(self.points)] = point
+		}
+		return
+	}
+
+	self.points[self.next] = point
+	self.next = (self.next + 1) % len(self.points)
+	if self.next == 0 {
+		self.full = true
+	}
+}
+
+func (self *ringBuffer) latest() (metricapi.MetricPoint, bool) {
+	if !self.full && self.next == 0 {
+		return metricapi.MetricPoint{}, false
+	}
+	return self.points[(self.next+len(self.points)-1)%len(self.points)], true
+}
+
+// list returns a copy of the stored points, oldest first.
+func (self *ringBuffer) list() []metricapi.MetricPoint {
+	if !self.full {
+		return append([]metricapi.MetricPoint{}, self.points[:self.next]...)
+	}
+	return append(append([]metricapi.MetricPoint{}, self.points[self.next:]...), self.points[:self.next]...)
+}
+
+// history keeps a ring buffer for each resource and metric. Buffers that were not updated for the
+// maximum age are dropped, so that deleted resources do not leak memory.
+type history struct {
+	mu        sync.Mutex
+	size      int
+	maxAge    time.Duration
+	buffers   map[string]*ringBuffer
+	lastPurge time.Time
+}
+
+func newHistory(size int, maxAge time.Duration) *history {
+	return &history{size: size, maxAge: maxAge, buffers: make(map[string]*ringBuffer)}
+}
+
+// record adds the point to the buffer with the given key and returns all points of the buffer.
+func (self *history) record(key string, point metricapi.MetricPoint) []metricapi.MetricPoint {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	if point.Timestamp.Sub(self.lastPurge) > self.maxAge {
+		self.purge(point.Timestamp)
+	}
+
+	buffer, exists := self.buffers[key]
+	if !exists {
+		buffer = newRingBuffer(self.size)
+		self.buffers[key] = buffer
+	}
+	buffer.add(point)
+	return buffer.list()
+}
+
+func (self *history) purge(now time.Time) {
+	for key, buffer := range self.buffers {
+		if latest, ok := buffer.latest(); !ok || now.Sub(latest.Timestamp) > self.maxAge {
+			delete(self.buffers, key)
+		}
+	}
+	self.lastPurge = now
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The types below are the subset of the metrics.k8s.io/v1beta1 API used by this client. They are
+// declared here, because the upstream k8s.io/metrics package is not compatible with the apimachinery
+// version used by the dashboard.
+
+// PodMetricsList is a list of PodMetrics.
+type PodMetricsList struct {
+	Items []PodMetrics `json:"items"`
+}
+
+// PodMetrics sets resource usage metrics of a pod.
+type PodMetrics struct {
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+	// Timestamp is the end of the window the usage was collected over.
+	Timestamp metaV1.Time `json:"timestamp"`
+	// Window is the length of the window the usage was collected over.
+	Window     metaV1.Duration    `json:"window"`
+	Containers []ContainerMetrics `json:"containers"`
+}
+
+// ContainerMetrics sets resource usage metrics of a container.
+type ContainerMetrics struct {
+	Name  string          `json:"name"`
+	Usage v1.ResourceList `json:"usage"`
+}
+
+// NodeMetricsList is a list of NodeMetrics.
+type NodeMetricsList struct {
+	Items []NodeMetrics `json:"items"`
+}
+
+// NodeMetrics sets resource usage metrics of a node.
+type NodeMetrics struct {
+	metaV1.ObjectMeta `json:"metadata,omitempty"`
+	Timestamp         metaV1.Time     `json:"timestamp"`
+	Window            metaV1.Duration `json:"window"`
+	Usage             v1.ResourceList `json:"usage"`
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metricsserver
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// metricsServerSelector selects the metrics of native resources, i.e. pods or nodes, of a single
+// tenant and namespace that are summed into the metric of a single requested resource.
+type metricsServerSelector struct {
+	TargetResourceType api.ResourceKind
+	Tenant             string
+	Namespace          string
+	Resources          []string
+	metricapi.Label
+	// ID identifies the requested resource in the metric history.
+	ID string
+}
+
+// key identifies the metrics of all selectors that can be downloaded with a single request.
+func (self metricsServerSelector) key() string {
+	return string(self.TargetResourceType) + "/" + self.Tenant + "/" + self.Namespace
+}
+
+func getMetricsServerSelectors(selectors []metricapi.ResourceSelector,
+	cachedResources *metricapi.CachedResources) []metricsServerSelector {
+	result := make([]metricsServerSelector, len(selectors))
+	for i, selector := range selectors {
+		metricsServerSelector, err := getMetricsServerSelector(selector, cachedResources)
+		if err != nil {
+			log.Printf("There was an error during transformation to metrics-server selector: %s", err.Error())
+			continue
+		}
+
+		result[i] = metricsServerSelector
+	}
+
+	return result
+}
+
+func getMetricsServerSelector(selector metricapi.ResourceSelector,
+	cachedResources *metricapi.CachedResources) (result metricsServerSelector, err error) {
+	summingResource, isDerivedResource := metricapi.DerivedResources[selector.ResourceType]
+	if !isDerivedResource {
+		result, err = newMetricsServerSelectorFromNativeResource(selector.ResourceType, selector.Tenant,
+			selector.Namespace, []string{selector.ResourceName}, []types.UID{selector.UID})
+	} else if summingResource != api.ResourceKindPod {
+		// We are dealing with derived resource. Convert derived resource to its native resources.
+		// For example, convert deployment to the list of pod names that belong to this deployment
+		return result, fmt.Errorf(`Internal Error: Requested summing resources not supported. Requested "%s"`, summingResource)
+	} else {
+		var myPods []v1.Pod
+		myPods, err = getMyPodsFromCache(selector, cachedResources.Pods)
+		if err != nil {
+			return
+		}
+		result, err = newMetricsServerSelectorFromNativeResource(api.ResourceKindPod, selector.Tenant,
+			selector.Namespace, podListToNameList(myPods), podListToUIDList(myPods))
+	}
+
+	result.ID = fmt.Sprintf("%s/%s/%s/%s/%s", selector.ResourceType, selector.Tenant, selector.Namespace,
+		selector.ResourceName, selector.UID)
+	return
+}
+
+// getMyPodsFromCache returns a full list of pods that belong to this resource.
+// It is important that cachedPods include ALL pods from the namespace of this resource (but they
+// can also include pods from other namespaces).
+func getMyPodsFromCache(selector metricapi.ResourceSelector, cachedPods []v1.Pod) (matchingPods []v1.Pod, err error) {
+	switch {
+	case cachedPods == nil:
+		err = fmt.Errorf(`Pods were not available in cache. Required for resource type: "%s"`,
+			selector.ResourceType)
+	case selector.ResourceType == api.ResourceKindDeployment:
+		for _, pod := range cachedPods {
+			if pod.ObjectMeta.Namespace == selector.Namespace && api.IsSelectorMatching(selector.Selector, pod.Labels) {
+				matchingPods = append(matchingPods, pod)
+			}
+		}
+	default:
+		for _, pod := range cachedPods {
+			if pod.Namespace == selector.Namespace {
+				for _, ownerRef := range pod.OwnerReferences {
+					if ownerRef.Controller != nil && *ownerRef.Controller == true &&
+						ownerRef.UID == selector.UID {
+						matchingPods = append(matchingPods, pod)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// newMetricsServerSelectorFromNativeResource returns new metrics-server selector for native
+// resources specified in arguments. Returns error if requested resource is not native or is not
+// supported.
+func newMetricsServerSelectorFromNativeResource(resourceType api.ResourceKind, tenant, namespace string,
+	resourceNames []string, resourceUIDs []types.UID) (metricsServerSelector, error) {
+	switch resourceType {
+	case api.ResourceKindPod:
+		return metricsServerSelector{
+			TargetResourceType: api.ResourceKindPod,
+			Tenant:             tenant,
+			Namespace:          namespace,
+			Resources:          resourceNames,
+			Label:              metricapi.Label{resourceType: resourceUIDs},
+		}, nil
+	case api.ResourceKindNode:
+		return metricsServerSelector{
+			TargetResourceType: api.ResourceKindNode,
+			Resources:          resourceNames,
+			Label:              metricapi.Label{resourceType: resourceUIDs},
+		}, nil
+	default:
+		return metricsServerSelector{}, fmt.Errorf(`Resource "%s" is not a native metrics-server resource type or is not supported`, resourceType)
+	}
+}
+
+// podListToNameList converts list of pods to the list of pod names.
+func podListToNameList(podList []v1.Pod) (result []string) {
+	for _, pod := range podList {
+		result = append(result, pod.Name)
+	}
+	return
+}
+
+func podListToUIDList(podList []v1.Pod) (result []types.UID) {
+	for _, pod := range podList {
+		result = append(result, pod.UID)
+	}
+	return
+}
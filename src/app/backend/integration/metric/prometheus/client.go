@@ -0,0 +1,350 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/common"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DefaultWindow is the length of the downloaded metric history. It is the same as the history kept
+	// by the sidecar.
+	DefaultWindow = 15 * time.Minute
+
+	// DefaultStep is the resolution of the downloaded metric history.
+	DefaultStep = time.Minute
+
+	// maxPoints limits the number of points downloaded per resource for long windows.
+	maxPoints = 60
+
+	// requestTimeout limits the duration of a single request to prometheus.
+	requestTimeout = 30 * time.Second
+)
+
+// Prometheus client implements MetricClient and Integration interfaces.
+type prometheusClient struct {
+	host   string
+	client *http.Client
+	window time.Duration
+	step   time.Duration
+	now    func() time.Time
+}
+
+// queryResponse is the response of the prometheus HTTP API.
+type queryResponse struct {
+	Status    string    `json:"status"`
+	Data      queryData `json:"data"`
+	ErrorType string    `json:"errorType"`
+	Error     string    `json:"error"`
+}
+
+type queryData struct {
+	ResultType string   `json:"resultType"`
+	Result     []series `json:"result"`
+}
+
+// series is a range vector of a single resource. Every value is a pair of a unix timestamp and the
+// sample value as string.
+type series struct {
+	Metric map[string]string `json:"metric"`
+	Values [][2]interface{}  `json:"values"`
+}
+
+// Implement Integration interface.
+
+// HealthCheck implements integration app interface. See Integration interface for more information.
+func (self prometheusClient) HealthCheck() error {
+	if self.client == nil {
+		return errors.New("Prometheus not configured")
+	}
+
+	response, err := self.client.Get(self.host + "/-/healthy")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Prometheus is not healthy: %s", response.Status)
+	}
+	return nil
+}
+
+// ID implements integration app interface. See Integration interface for more information.
+func (self prometheusClient) ID() integrationapi.IntegrationID {
+	return integrationapi.PrometheusIntegrationID
+}
+
+// Implement MetricClient interface
+
+// WithWindow implements windowed metric client interface. The step is increased for long windows, so
+// that at most maxPoints points are downloaded per resource.
+func (self prometheusClient) WithWindow(window time.Duration) metricapi.MetricClient {
+	self.window = window
+	self.step = DefaultStep
+	if step := window / maxPoints; step > self.step {
+		self.step = step
+	}
+	return self
+}
+
+// DownloadMetrics implements metric client interface. See MetricClient for more information.
+func (self prometheusClient) DownloadMetrics(selectors []metricapi.ResourceSelector,
+	metricNames []string, cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		collectedMetrics := self.DownloadMetric(selectors, metricName, cachedResources)
+		result = append(result, collectedMetrics...)
+	}
+	return result
+}
+
+// DownloadMetric implements metric client interface. See MetricClient for more information.
+func (self prometheusClient) DownloadMetric(selectors []metricapi.ResourceSelector,
+	metricName string, cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	prometheusSelectors := getPrometheusSelectors(selectors, cachedResources)
+	result := metricapi.NewMetricPromises(len(prometheusSelectors))
+	go self.downloadMetric(prometheusSelectors, metricName, result)
+	return result
+}
+
+// AggregateMetrics implements metric client interface. See MetricClient for more information.
+func (self prometheusClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return common.AggregateMetricPromises(metrics, metricName, aggregations, nil)
+}
+
+// downloadMetric downloads the metric of all selectors with one query per resource type and
+// namespace, and puts the metric of each selector, summed over its resources, into the promises.
+func (self prometheusClient) downloadMetric(selectors []prometheusSelector, metricName string,
+	result metricapi.MetricPromises) {
+	compressed := compress(selectors)
+
+	lock := sync.Mutex{}
+	downloaded := make(map[string]map[string]metricapi.Metric, len(compressed))
+	failed := make(map[string]error)
+	wg := sync.WaitGroup{}
+	for key, selector := range compressed {
+		wg.Add(1)
+		go func(key string, selector prometheusSelector) {
+			defer wg.Done()
+			metrics, err := self.downloadSeries(selector, metricName)
+
+			lock.Lock()
+			defer lock.Unlock()
+			downloaded[key] = metrics
+			failed[key] = err
+		}(key, selector)
+	}
+	wg.Wait()
+
+	for i, selector := range selectors {
+		if err := failed[selector.key()]; err != nil {
+			result[i].Metric <- nil
+			result[i].Error <- err
+			continue
+		}
+
+		requestedResources := []metricapi.Metric{}
+		for j, name := range selector.Resources {
+			metric, exists := downloaded[selector.key()][name]
+			if !exists {
+				continue
+			}
+			metric.Label = metricapi.Label{
+				selector.TargetResourceType: []types.UID{selector.Label[selector.TargetResourceType][j]},
+			}
+			requestedResources = append(requestedResources, metric)
+		}
+
+		aggregatedMetric := common.AggregateData(requestedResources, metricName, metricapi.SumAggregation)
+		result[i].Metric <- &aggregatedMetric
+		result[i].Error <- nil
+	}
+}
+
+// downloadSeries runs the range query of the metric for all resources of the selector and returns
+// the metric of each resource by name.
+func (self prometheusClient) downloadSeries(selector prometheusSelector, metricName string) (
+	map[string]metricapi.Metric, error) {
+	query, err := newQuery(selector.TargetResourceType, selector.Tenant, selector.Namespace, selector.Resources,
+		metricName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := self.queryRange(query)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceLabel := resourceLabels[selector.TargetResourceType]
+	result := make(map[string]metricapi.Metric, len(data.Result))
+	for _, s := range data.Result {
+		metricPoints, err := toMetricPoints(s.Values)
+		if err != nil {
+			return nil, err
+		}
+		result[s.Metric[resourceLabel]] = metricapi.Metric{
+			DataPoints:   toDataPoints(metricPoints),
+			MetricPoints: metricPoints,
+			MetricName:   metricName,
+		}
+	}
+	return result, nil
+}
+
+// queryRange runs the query over the configured window and returns the resulting range vectors.
+func (self prometheusClient) queryRange(query string) (*queryData, error) {
+	end := self.now()
+	params := url.Values{
+		"query": []string{query},
+		"start": []string{strconv.FormatInt(end.Add(-self.window).Unix(), 10)},
+		"end":   []string{strconv.FormatInt(end.Unix(), 10)},
+		"step":  []string{strconv.FormatFloat(self.step.Seconds(), 'f', -1, 64)},
+	}
+
+	response, err := self.client.Get(self.host + "/api/v1/query_range?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// Prometheus returns the same format with an error status for failed queries.
+	result := new(queryResponse)
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("Received invalid response from prometheus (%s): %s", response.Status, err.Error())
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("Prometheus query failed with %s: %s", result.ErrorType, result.Error)
+	}
+	if result.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("Received unexpected result type from prometheus: %s", result.Data.ResultType)
+	}
+	return &result.Data, nil
+}
+
+// compress merges all selectors that can be downloaded with a single query.
+func compress(selectors []prometheusSelector) map[string]prometheusSelector {
+	result := make(map[string]prometheusSelector)
+	for _, selector := range selectors {
+		if len(selector.Resources) == 0 {
+			continue
+		}
+
+		compressed, exists := result[selector.key()]
+		if !exists {
+			compressed = prometheusSelector{
+				TargetResourceType: selector.TargetResourceType,
+				Tenant:             selector.Tenant,
+				Namespace:          selector.Namespace,
+			}
+		}
+		compressed.Resources = toUniqueSlice(append(compressed.Resources, selector.Resources...))
+		result[selector.key()] = compressed
+	}
+	return result
+}
+
+func toUniqueSlice(strings []string) []string {
+	result := make([]string, 0)
+	uniquenessMap := make(map[string]bool)
+	for _, s := range strings {
+		if _, exists := uniquenessMap[s]; !exists {
+			result = append(result, s)
+		}
+
+		uniquenessMap[s] = true
+	}
+
+	return result
+}
+
+// toMetricPoints converts the values of a range vector. Values that are not numbers, e.g. NaN of
+// rates without samples, and negative values are stored as 0.
+func toMetricPoints(values [][2]interface{}) ([]metricapi.MetricPoint, error) {
+	result := make([]metricapi.MetricPoint, 0, len(values))
+	for _, value := range values {
+		timestamp, ok := value[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("Received invalid timestamp from prometheus: %v", value[0])
+		}
+		raw, ok := value[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("Received invalid sample value from prometheus: %v", value[1])
+		}
+		sample, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(sample) || sample < 0 {
+			sample = 0
+		}
+
+		result = append(result, metricapi.MetricPoint{
+			Timestamp: time.Unix(int64(timestamp), 0),
+			Value:     uint64(sample),
+		})
+	}
+	return result, nil
+}
+
+func toDataPoints(metricPoints []metricapi.MetricPoint) metricapi.DataPoints {
+	result := make(metricapi.DataPoints, 0, len(metricPoints))
+	for _, point := range metricPoints {
+		result = append(result, metricapi.DataPoint{X: point.Timestamp.Unix(), Y: int64(point.Value)})
+	}
+	return result
+}
+
+// CreatePrometheusClient creates new Prometheus client. The host param is in the format of
+// protocol://address:port, e.g., http://localhost:9090.
+func CreatePrometheusClient(host string) (metricapi.MetricClient, error) {
+	if host == "" {
+		return prometheusClient{}, errors.New("Prometheus host is not configured")
+	}
+
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return prometheusClient{}, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return prometheusClient{}, fmt.Errorf("Invalid prometheus host %s: protocol has to be http or https", host)
+	}
+
+	log.Printf("Creating Prometheus client for %s", host)
+	return prometheusClient{
+		host:   strings.TrimSuffix(host, "/"),
+		client: &http.Client{Timeout: requestTimeout},
+		window: DefaultWindow,
+		step:   DefaultStep,
+		now:    time.Now,
+	}, nil
+}
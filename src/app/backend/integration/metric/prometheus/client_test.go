@@ -0,0 +1,304 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const testStart = int64(1470999600)
+
+// fakeSamples contains the samples of the fake prometheus by metric and resource, which is identified
+// by tenant, namespace and name. The samples start at testStart and are one minute apart.
+var fakeSamples = map[string]map[string][]string{
+	"container_cpu_usage_seconds_total": {
+		"t/a/P1":  {"0", "5", "10"},
+		"t/a/P2":  {"15", "20", "25"},
+		"t/a/P3":  {"30", "35", "NaN"},
+		"t/b/P1":  {"1000", "1100", "1200"},
+		"t2/a/P1": {"7", "7", "7"},
+		"//N1":    {"100", "200", "300"},
+	},
+}
+
+var matcherRegexp = regexp.MustCompile(`(?:tenant="([^"]*)",namespace="([^"]*)",)?(pod|node)=~"([^"]*)"`)
+
+// fakePrometheus serves range queries of the templates of this package from the fake samples and
+// counts the queries.
+type fakePrometheus struct {
+	queries int32
+	healthy bool
+	err     string
+}
+
+func (self *fakePrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/-/healthy":
+		if !self.healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	case "/api/v1/query_range":
+		atomic.AddInt32(&self.queries, 1)
+		if len(self.err) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(queryResponse{Status: "error", ErrorType: "bad_data", Error: self.err})
+			return
+		}
+		json.NewEncoder(w).Encode(queryResponse{Status: "success", Data: self.query(r)})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (self *fakePrometheus) query(r *http.Request) queryData {
+	query := r.URL.Query().Get("query")
+	data := queryData{ResultType: "matrix", Result: []series{}}
+	for metric, samples := range fakeSamples {
+		if !strings.Contains(query, metric) {
+			continue
+		}
+
+		matcher := matcherRegexp.FindStringSubmatch(query)
+		for _, name := range strings.Split(matcher[4], "|") {
+			values, ok := samples[matcher[1]+"/"+matcher[2]+"/"+name]
+			if !ok {
+				continue
+			}
+			s := series{Metric: map[string]string{matcher[3]: name}}
+			for i, value := range values {
+				s.Values = append(s.Values, [2]interface{}{float64(testStart + int64(60*i)), value})
+			}
+			data.Result = append(data.Result, s)
+		}
+	}
+	return data
+}
+
+func newTestClient(t *testing.T, prometheus *fakePrometheus) (prometheusClient, func()) {
+	server := httptest.NewServer(prometheus)
+	client, err := CreatePrometheusClient(server.URL + "/")
+	if err != nil {
+		t.Fatalf("CreatePrometheusClient() returned unexpected error: %v", err)
+	}
+
+	c := client.(prometheusClient)
+	c.now = func() time.Time { return time.Unix(testStart+120, 0) }
+	return c, server.Close
+}
+
+func newDps(ys ...int64) metricapi.DataPoints {
+	result := metricapi.DataPoints{}
+	for i, y := range ys {
+		result = append(result, metricapi.DataPoint{X: testStart + int64(60*i), Y: y})
+	}
+	return result
+}
+
+func newPod(tenant, namespace, name, uid string, labels map[string]string) v1.Pod {
+	return v1.Pod{ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: namespace, Name: name, UID: types.UID(uid),
+		Labels: labels}}
+}
+
+func TestDownloadMetric(t *testing.T) {
+	cachedResources := &metricapi.CachedResources{Pods: []v1.Pod{
+		newPod("t", "a", "P1", "U1", map[string]string{"app": "foo"}),
+		newPod("t", "a", "P2", "U2", map[string]string{"app": "foo"}),
+		newPod("t", "a", "P3", "U3", map[string]string{"app": "bar"}),
+		newPod("t", "b", "P1", "U4", map[string]string{"app": "foo"}),
+		newPod("t2", "a", "P1", "U5", map[string]string{"app": "foo"}),
+	}}
+
+	cases := []struct {
+		info       string
+		selectors  []metricapi.ResourceSelector
+		metricName string
+		expected   []metricapi.DataPoints
+		queries    int32
+	}{
+		{
+			"pods of the same namespace are downloaded with a single query",
+			[]metricapi.ResourceSelector{
+				{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U1"},
+				{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P3", UID: "U3"},
+				{Tenant: "t", Namespace: "b", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U4"},
+			},
+			metricapi.CpuUsage,
+			[]metricapi.DataPoints{newDps(0, 5, 10), newDps(30, 35, 0), newDps(1000, 1100, 1200)},
+			2,
+		},
+		{
+			"metrics of derived resources are summed over their pods",
+			[]metricapi.ResourceSelector{
+				{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindDeployment, ResourceName: "foo",
+					Selector: map[string]string{"app": "foo"}, UID: "D1"},
+				{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P2", UID: "U2"},
+			},
+			metricapi.CpuUsage,
+			[]metricapi.DataPoints{newDps(15, 25, 35), newDps(15, 20, 25)},
+			1,
+		},
+		{
+			"pods of the same name in different tenants are not merged",
+			[]metricapi.ResourceSelector{
+				{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U1"},
+				{Tenant: "t2", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U5"},
+			},
+			metricapi.CpuUsage,
+			[]metricapi.DataPoints{newDps(0, 5, 10), newDps(7, 7, 7)},
+			2,
+		},
+		{
+			"nodes and missing series",
+			[]metricapi.ResourceSelector{
+				{ResourceType: api.ResourceKindNode, ResourceName: "N1", UID: "N1"},
+				{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P9", UID: "U9"},
+			},
+			metricapi.CpuUsage,
+			[]metricapi.DataPoints{newDps(100, 200, 300), {}},
+			2,
+		},
+	}
+
+	for _, c := range cases {
+		prometheus := &fakePrometheus{healthy: true}
+		client, done := newTestClient(t, prometheus)
+
+		metrics, err := client.DownloadMetric(c.selectors, c.metricName, cachedResources).GetMetrics()
+		done()
+		if err != nil {
+			t.Fatalf("Test case: %s. Received unexpected error: %v", c.info, err)
+		}
+
+		var actual []metricapi.DataPoints
+		for _, metric := range metrics {
+			actual = append(actual, metric.DataPoints)
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Test case: %s. Expected data points %v, got %v", c.info, c.expected, actual)
+		}
+		if prometheus.queries != c.queries {
+			t.Errorf("Test case: %s. Expected %d queries, got %d", c.info, c.queries, prometheus.queries)
+		}
+	}
+}
+
+func TestDownloadMetricsWithQueryError(t *testing.T) {
+	client, done := newTestClient(t, &fakePrometheus{err: "parse error"})
+	defer done()
+
+	selectors := []metricapi.ResourceSelector{
+		{Tenant: "t", Namespace: "a", ResourceType: api.ResourceKindPod, ResourceName: "P1", UID: "U1"},
+	}
+	_, err := client.DownloadMetrics(selectors, []string{metricapi.CpuUsage, metricapi.NetworkRxRate},
+		metricapi.NoResourceCache).GetMetrics()
+	expected := "Prometheus query failed with bad_data: parse error"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+
+	_, err = client.DownloadMetric(selectors, "cpu/limit", metricapi.NoResourceCache).GetMetrics()
+	if err == nil {
+		t.Error("Expected error for unsupported metric")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	for _, healthy := range []bool{true, false} {
+		client, done := newTestClient(t, &fakePrometheus{healthy: healthy})
+		err := client.HealthCheck()
+		done()
+		if (err == nil) != healthy {
+			t.Errorf("Expected health check of healthy=%t prometheus to return error=%t, got %v", healthy,
+				!healthy, err)
+		}
+	}
+
+	if err := (prometheusClient{}).HealthCheck(); err == nil {
+		t.Error("Expected health check of unconfigured prometheus to fail")
+	}
+}
+
+func TestCreatePrometheusClient(t *testing.T) {
+	for _, host := range []string{"", "localhost:9090", "ftp://localhost"} {
+		if _, err := CreatePrometheusClient(host); err == nil {
+			t.Errorf("Expected error for host %q", host)
+		}
+	}
+}
+
+func TestNewQuery(t *testing.T) {
+	cases := []struct {
+		resourceType api.ResourceKind
+		tenant       string
+		namespace    string
+		names        []string
+		metricName   string
+		expected     string
+	}{
+		{
+			api.ResourceKindPod, "t", "a", []string{"P1", "P.2"}, metricapi.CpuUsage,
+			`sum by (tenant, namespace, pod) (rate(container_cpu_usage_seconds_total{container!="",container!="POD",` +
+				`tenant="t",namespace="a",pod=~"P1|P\\.2"}[5m])) * 1000`,
+		},
+		{
+			api.ResourceKindNode, "", "", []string{"N1"}, metricapi.FilesystemUsage,
+			`sum by (node) (container_fs_usage_bytes{id="/",node=~"N1"})`,
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := newQuery(c.resourceType, c.tenant, c.namespace, c.names, c.metricName)
+		if err != nil {
+			t.Fatalf("newQuery(%s, %s) returned unexpected error: %v", c.resourceType, c.metricName, err)
+		}
+		if actual != c.expected {
+			t.Errorf("newQuery(%s, %s) == %s, expected %s", c.resourceType, c.metricName, actual, c.expected)
+		}
+	}
+}
+
+func TestWithWindow(t *testing.T) {
+	cases := []struct {
+		window time.Duration
+		step   time.Duration
+	}{
+		{15 * time.Minute, DefaultStep},
+		{time.Hour, DefaultStep},
+		{24 * time.Hour, 24 * time.Minute},
+	}
+
+	for _, c := range cases {
+		client := prometheusClient{window: DefaultWindow, step: DefaultStep}
+		actual := client.WithWindow(c.window).(prometheusClient)
+		if actual.window != c.window || actual.step != c.step {
+			t.Errorf("WithWindow(%v) has window %v and step %v, expected step %v", c.window, actual.window,
+				actual.step, c.step)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+)
+
+// RateInterval is the range over which counters are turned into per second rates.
+const RateInterval = "5m"
+
+// TenantLabel is the label of the cAdvisor series of containers that holds the tenant of their pod.
+// Pods of different tenants can have the same namespace and name, so their series are told apart by it.
+const TenantLabel = "tenant"
+
+// podQueries contains the PromQL templates of the supported metrics of pods. The cAdvisor series of
+// the pause container and the pod cgroup itself are skipped, so that usage is not counted twice. Series
+// are summed per tenant, namespace and pod, so that pods of the same name in different tenants are
+// not merged. The first placeholder is the label matcher of the pods, the second one the rate interval.
+var podQueries = map[string]string{
+	metricapi.CpuUsage:        `sum by (tenant, namespace, pod) (rate(container_cpu_usage_seconds_total{container!="",container!="POD",%s}[%s])) * 1000`,
+	metricapi.MemoryUsage:     `sum by (tenant, namespace, pod) (container_memory_working_set_bytes{container!="",container!="POD",%s})`,
+	metricapi.NetworkRxRate:   `sum by (tenant, namespace, pod) (rate(container_network_receive_bytes_total{%s}[%s]))`,
+	metricapi.NetworkTxRate:   `sum by (tenant, namespace, pod) (rate(container_network_transmit_bytes_total{%s}[%s]))`,
+	metricapi.FilesystemUsage: `sum by (tenant, namespace, pod) (container_fs_usage_bytes{container!="",container!="POD",%s})`,
+}
+
+// nodeQueries contains the PromQL templates of the supported metrics of nodes. The root cgroup
+// contains the usage of the whole node.
+var nodeQueries = map[string]string{
+	metricapi.CpuUsage:        `sum by (node) (rate(container_cpu_usage_seconds_total{id="/",%s}[%s])) * 1000`,
+	metricapi.MemoryUsage:     `sum by (node) (container_memory_working_set_bytes{id="/",%s})`,
+	metricapi.NetworkRxRate:   `sum by (node) (rate(container_network_receive_bytes_total{id="/",%s}[%s]))`,
+	metricapi.NetworkTxRate:   `sum by (node) (rate(container_network_transmit_bytes_total{id="/",%s}[%s]))`,
+	metricapi.FilesystemUsage: `sum by (node) (container_fs_usage_bytes{id="/",%s})`,
+}
+
+// resourceLabels are the labels of the series that identify the resources.
+var resourceLabels = map[api.ResourceKind]string{
+	api.ResourceKindPod:  "pod",
+	api.ResourceKindNode: "node",
+}
+
+// newQuery returns the PromQL query of the metric for the resources of the given type with the given
+// names. Pods are selected in the given tenant and namespace.
+func newQuery(resourceType api.ResourceKind, tenant, namespace string, names []string, metricName string) (
+	string, error) {
+	templates := podQueries
+	if resourceType == api.ResourceKindNode {
+		templates = nodeQueries
+	}
+
+	template, ok := templates[metricName]
+	if !ok {
+		return "", fmt.Errorf(`Metric "%s" is not supported for resource type "%s"`, metricName, resourceType)
+	}
+
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	matcher := fmt.Sprintf("%s=~%s", resourceLabels[resourceType], strconv.Quote(strings.Join(quoted, "|")))
+	if resourceType == api.ResourceKindPod {
+		matcher = fmt.Sprintf("%s=%s,namespace=%s,%s", TenantLabel, strconv.Quote(tenant), strconv.Quote(namespace),
+			matcher)
+	}
+
+	// Templates of gauges have no placeholder for the rate interval.
+	if strings.Count(template, "%s") == 1 {
+		return fmt.Sprintf(template, matcher), nil
+	}
+	return fmt.Sprintf(template, matcher, RateInterval), nil
+}
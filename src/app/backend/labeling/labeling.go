@@ -0,0 +1,204 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package labeling edits the labels and annotations of resources of any kind with merge patches, so
+// that concurrent changes to other parts of the resource are not lost.
+package labeling
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Field is the metadata field that is edited.
+type Field string
+
+const (
+	FieldLabels      Field = "labels"
+	FieldAnnotations Field = "annotations"
+)
+
+// Operation is the change of the labels or annotations.
+type Operation string
+
+const (
+	// OperationAdd adds the values and overwrites existing values with the same keys.
+	OperationAdd Operation = "add"
+
+	// OperationRemove removes the keys.
+	OperationRemove Operation = "remove"
+
+	// OperationReplace replaces all labels or annotations with the values.
+	OperationReplace Operation = "replace"
+)
+
+// MetadataSpec is a specification of a change of the labels or annotations of a resource.
+type MetadataSpec struct {
+	// Resource version the change is based on. If set, the change is rejected with a conflict when the
+	// resource was modified in the meantime.
+	ResourceVersion string `json:"resourceVersion"`
+
+	// Values to add or to replace all labels or annotations with.
+	Values map[string]string `json:"values"`
+
+	// Keys to remove.
+	Keys []string `json:"keys"`
+}
+
+// Metadata contains the labels or annotations of a resource after the change.
+type Metadata struct {
+	ResourceVersion string            `json:"resourceVersion"`
+	Values          map[string]string `json:"values"`
+}
+
+// UpdateMetadata changes the labels or annotations of the resource of the given kind in the given
+// namespace with the given name.
+func UpdateMetadata(verber clientapi.ResourceVerber, tenant, kind string, namespaceSet bool, namespace, name string,
+	field Field, operation Operation, spec *MetadataSpec) (*Metadata, error) {
+	log.Printf("Updating %s of %s %s in %s namespace for %s with %s", field, kind, name, namespace, tenant,
+		operation)
+
+	if err := validateField(field); err != nil {
+		return nil, err
+	}
+
+	set, remove, resourceVersion := spec.Values, spec.Keys, spec.ResourceVersion
+	switch operation {
+	case OperationAdd:
+		if len(set) == 0 {
+			return nil, errors.NewInvalid(fmt.Sprintf("No %s to add", field))
+		}
+		remove = nil
+	case OperationRemove:
+		if len(remove) == 0 {
+			return nil, errors.NewInvalid(fmt.Sprintf("No %s to remove", field))
+		}
+		set = nil
+	case OperationReplace:
+		// Keys that are not replaced have to be removed explicitly. The resource version of the read
+		// object guarantees that no keys are added in between.
+		current, err := getMetadata(verber, tenant, kind, namespaceSet, namespace, name, field)
+		if err != nil {
+			return nil, err
+		}
+		if len(resourceVersion) == 0 {
+			resourceVersion = current.ResourceVersion
+		}
+		remove = nil
+		for key := range current.Values {
+			if _, ok := set[key]; !ok {
+				remove = append(remove, key)
+			}
+		}
+	default:
+		return nil, errors.NewInvalid(fmt.Sprintf("Unknown operation %q", operation))
+	}
+
+	patch, err := NewPatch(field, set, remove, resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := verber.PatchWithMultiTenancy(kind, tenant, namespaceSet, namespace, name, types.MergePatchType,
+		patch)
+	if err != nil {
+		return nil, err
+	}
+	return toMetadata(result, field)
+}
+
+// NewPatch returns a JSON merge patch that sets and removes the given keys of the labels or annotations.
+// If the resource version is set, the patch is only applied to this version of the resource.
+func NewPatch(field Field, set map[string]string, remove []string, resourceVersion string) ([]byte, error) {
+	if err := validateField(field); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(set)+len(remove))
+	for _, key := range remove {
+		values[key] = nil
+	}
+	for key, value := range set {
+		if err := validate(field, key, value); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	metadata := map[string]interface{}{string(field): values}
+	if len(resourceVersion) > 0 {
+		metadata["resourceVersion"] = resourceVersion
+	}
+	return json.Marshal(map[string]interface{}{"metadata": metadata})
+}
+
+func getMetadata(verber clientapi.ResourceVerber, tenant, kind string, namespaceSet bool, namespace, name string,
+	field Field) (*Metadata, error) {
+	result, err := verber.GetWithMultiTenancy(kind, tenant, namespaceSet, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return toMetadata(result, field)
+}
+
+func toMetadata(object runtime.Object, field Field) (*Metadata, error) {
+	unknown, ok := object.(*runtime.Unknown)
+	if !ok {
+		return nil, errors.NewUnexpectedObject(object)
+	}
+
+	u := new(unstructured.Unstructured)
+	if err := u.UnmarshalJSON(unknown.Raw); err != nil {
+		return nil, err
+	}
+
+	metadata := &Metadata{ResourceVersion: u.GetResourceVersion(), Values: u.GetLabels()}
+	if field == FieldAnnotations {
+		metadata.Values = u.GetAnnotations()
+	}
+	if metadata.Values == nil {
+		metadata.Values = make(map[string]string)
+	}
+	return metadata, nil
+}
+
+func validateField(field Field) error {
+	if field != FieldLabels && field != FieldAnnotations {
+		return errors.NewInvalid(fmt.Sprintf("Unknown metadata field %q", field))
+	}
+	return nil
+}
+
+// validate checks the key and value the same way the API server does, to return all problems of a
+// key at once.
+func validate(field Field, key, value string) error {
+	problems := validation.IsQualifiedName(strings.ToLower(key))
+	if field == FieldLabels {
+		problems = append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...)
+	}
+	if len(problems) > 0 {
+		return errors.NewInvalid(fmt.Sprintf("Invalid %s %q: %s", strings.TrimSuffix(string(field), "s"), key,
+			strings.Join(problems, "; ")))
+	}
+	return nil
+}
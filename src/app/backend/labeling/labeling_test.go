@@ -0,0 +1,128 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeling
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	clientapi "github.com/CentaurusInfra/dashboard/src/app/backend/client/api"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fakeVerber returns the same object for every get and patch and records the patches.
+type fakeVerber struct {
+	clientapi.ResourceVerber
+
+	object  string
+	patches []string
+}
+
+func (v *fakeVerber) GetWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	name string) (runtime.Object, error) {
+	return &runtime.Unknown{Raw: []byte(v.object)}, nil
+}
+
+func (v *fakeVerber) PatchWithMultiTenancy(kind string, tenant string, namespaceSet bool, namespace string,
+	name string, patchType types.PatchType, data []byte) (runtime.Object, error) {
+	if patchType != types.MergePatchType {
+		panic("unexpected patch type " + patchType)
+	}
+	v.patches = append(v.patches, string(data))
+	return &runtime.Unknown{Raw: []byte(v.object)}, nil
+}
+
+const testObject = `{"kind":"Pod","metadata":{"name":"foo","resourceVersion":"7",` +
+	`"labels":{"app":"foo","tier":"web"},"annotations":{"note":"bar"}}}`
+
+func TestUpdateMetadata(t *testing.T) {
+	cases := []struct {
+		field     Field
+		operation Operation
+		spec      *MetadataSpec
+		patch     map[string]interface{}
+		values    map[string]string
+	}{
+		{
+			FieldLabels, OperationAdd,
+			&MetadataSpec{Values: map[string]string{"team": "a"}, Keys: []string{"ignored"}},
+			map[string]interface{}{"labels": map[string]interface{}{"team": "a"}},
+			map[string]string{"app": "foo", "tier": "web"},
+		},
+		{
+			FieldAnnotations, OperationRemove,
+			&MetadataSpec{ResourceVersion: "5", Keys: []string{"note"}},
+			map[string]interface{}{"resourceVersion": "5", "annotations": map[string]interface{}{"note": nil}},
+			map[string]string{"note": "bar"},
+		},
+		{
+			FieldLabels, OperationReplace,
+			&MetadataSpec{Values: map[string]string{"app": "bar"}},
+			map[string]interface{}{"resourceVersion": "7",
+				"labels": map[string]interface{}{"app": "bar", "tier": nil}},
+			map[string]string{"app": "foo", "tier": "web"},
+		},
+	}
+
+	for _, c := range cases {
+		verber := &fakeVerber{object: testObject}
+		metadata, err := UpdateMetadata(verber, "foo", "pod", true, "bar", "foo", c.field, c.operation, c.spec)
+		if err != nil {
+			t.Fatalf("UpdateMetadata(%s, %s) returned unexpected error: %v", c.field, c.operation, err)
+		}
+		if len(verber.patches) != 1 {
+			t.Fatalf("Expected a single patch, got %v", verber.patches)
+		}
+
+		patch := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(verber.patches[0]), &patch); err != nil {
+			t.Fatalf("could not decode patch: %v", err)
+		}
+		if !reflect.DeepEqual(patch, map[string]interface{}{"metadata": c.patch}) {
+			t.Errorf("UpdateMetadata(%s, %s) patched with %s", c.field, c.operation, verber.patches[0])
+		}
+		if metadata.ResourceVersion != "7" || !reflect.DeepEqual(metadata.Values, c.values) {
+			t.Errorf("UpdateMetadata(%s, %s) == %+v", c.field, c.operation, metadata)
+		}
+	}
+}
+
+func TestUpdateMetadataInvalid(t *testing.T) {
+	cases := []struct {
+		field     Field
+		operation Operation
+		spec      *MetadataSpec
+	}{
+		{"finalizers", OperationAdd, &MetadataSpec{Values: map[string]string{"a": "b"}}},
+		{FieldLabels, "merge", &MetadataSpec{Values: map[string]string{"a": "b"}}},
+		{FieldLabels, OperationAdd, &MetadataSpec{}},
+		{FieldLabels, OperationRemove, &MetadataSpec{Values: map[string]string{"a": "b"}}},
+		{FieldLabels, OperationAdd, &MetadataSpec{Values: map[string]string{"a b": "c"}}},
+		{FieldLabels, OperationAdd, &MetadataSpec{Values: map[string]string{"a": "not a label value"}}},
+		{FieldAnnotations, OperationAdd, &MetadataSpec{Values: map[string]string{"-a": "any value"}}},
+	}
+
+	for _, c := range cases {
+		verber := &fakeVerber{object: testObject}
+		if _, err := UpdateMetadata(verber, "foo", "pod", true, "bar", "foo", c.field, c.operation, c.spec); err == nil {
+			t.Errorf("Expected error for %s %s %+v", c.field, c.operation, c.spec)
+		}
+		if len(verber.patches) > 0 {
+			t.Errorf("Expected no patch for %s %s %+v, got %v", c.field, c.operation, c.spec, verber.patches)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around every change of a diff.
+const diffContextLines = 3
+
+// diffLine is a single line of a diff. Kind is ' ' for unchanged, '-' for removed and '+' for added lines.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns the difference between both texts in unified format. It returns an empty string
+// if they are equal.
+func UnifiedDiff(fromName, toName string, from, to []string) string {
+	lines := diffLines(from, to)
+
+	changed := false
+	for _, line := range lines {
+		if line.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Line numbers of the current position in both texts, starting at 1.
+	fromLine, toLine := 1, 1
+	for start := 0; start < len(lines); {
+		if lines[start].kind == ' ' {
+			start++
+			fromLine++
+			toLine++
+			continue
+		}
+
+		// Extend the hunk until there are more than two times the context lines without change.
+		hunkStart := start - diffContextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		hunkEnd := start
+		unchanged := 0
+		for i := start; i < len(lines) && unchanged <= 2*diffContextLines; i++ {
+			if lines[i].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+				hunkEnd = i
+			}
+		}
+		hunkEnd += diffContextLines
+		if hunkEnd >= len(lines) {
+			hunkEnd = len(lines) - 1
+		}
+
+		hunkFromStart := fromLine - (start - hunkStart)
+		hunkToStart := toLine - (start - hunkStart)
+		fromCount, toCount := 0, 0
+		for _, line := range lines[hunkStart : hunkEnd+1] {
+			if line.kind != '+' {
+				fromCount++
+			}
+			if line.kind != '-' {
+				toCount++
+			}
+		}
+		// An empty range starts at the line before it.
+		if fromCount == 0 {
+			hunkFromStart--
+		}
+		if toCount == 0 {
+			hunkToStart--
+		}
+		fmt.Fprintf(builder, "@@ -%d,%d +%d,%d @@\n", hunkFromStart, fromCount, hunkToStart, toCount)
+		for _, line := range lines[hunkStart : hunkEnd+1] {
+			fmt.Fprintf(builder, "%c%s\n", line.kind, line.text)
+		}
+
+		for _, line := range lines[start : hunkEnd+1] {
+			if line.kind != '+' {
+				fromLine++
+			}
+			if line.kind != '-' {
+				toLine++
+			}
+		}
+		start = hunkEnd + 1
+	}
+
+	return builder.String()
+}
+
+// diffLines computes the longest common subsequence of both texts and returns the edit script.
+func diffLines(from, to []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of from[i:] and to[j:].
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	result := make([]diffLine, 0, len(from)+len(to))
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			result = append(result, diffLine{' ', from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, diffLine{'-', from[i]})
+			i++
+		default:
+			result = append(result, diffLine{'+', to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		result = append(result, diffLine{'-', from[i]})
+	}
+	for ; j < len(to); j++ {
+		result = append(result, diffLine{'+', to[j]})
+	}
+	return result
+}
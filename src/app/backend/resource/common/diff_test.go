@@ -0,0 +1,38 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		from, to []string
+		expected string
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, ""},
+		{[]string{}, []string{"a", "b"}, "--- live\n+++ dry-run\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{
+			[]string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"},
+			[]string{"1", "2", "3", "4", "5", "six", "7", "8", "9", "10", "11", "12"},
+			"--- live\n+++ dry-run\n@@ -3,7 +3,7 @@\n 3\n 4\n 5\n-6\n+six\n 7\n 8\n 9\n",
+		},
+	}
+	for _, c := range cases {
+		actual := UnifiedDiff("live", "dry-run", c.from, c.to)
+		if actual != c.expected {
+			t.Errorf("UnifiedDiff(%v, %v) ==\n%q\nexpected\n%q", c.from, c.to, actual, c.expected)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package common
 
 import (
 	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/tracing"
 	apps "k8s.io/api/apps/v1"
 	autoscaling "k8s.io/api/autoscaling/v1"
 	batch "k8s.io/api/batch/v1"
@@ -139,6 +140,8 @@ func GetServiceListChannel(client client.Interface, nsQuery *NamespaceQuery,
 		Error: make(chan error, numReads),
 	}
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ServiceList")
+		defer span.End()
 		list, err := client.CoreV1().ServicesWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		var filteredItems []v1.Service
 		for _, item := range list.Items {
@@ -166,6 +169,8 @@ func GetServiceListChannelWithMultiTenancy(client client.Interface, tenant strin
 		Error: make(chan error, numReads),
 	}
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ServiceList")
+		defer span.End()
 		list, err := client.CoreV1().ServicesWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []v1.Service
 		for _, item := range list.Items {
@@ -199,6 +204,8 @@ func GetIngressListChannel(client client.Interface, nsQuery *NamespaceQuery,
 		Error: make(chan error, numReads),
 	}
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "IngressList")
+		defer span.End()
 		list, err := client.ExtensionsV1beta1().Ingresses(nsQuery.ToRequestParam()).List(api.ListEverything)
 		var filteredItems []extensions.Ingress
 		for _, item := range list.Items {
@@ -233,6 +240,8 @@ func GetLimitRangeListChannel(client client.Interface, nsQuery *NamespaceQuery,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "LimitRangeList")
+		defer span.End()
 		list, err := client.CoreV1().LimitRanges(nsQuery.ToRequestParam()).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -258,6 +267,8 @@ func GetNodeListChannel(client client.Interface, numReads int) NodeListChannel {
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "NodeList")
+		defer span.End()
 		list, err := client.CoreV1().Nodes().List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -284,6 +295,8 @@ func GetNamespaceListChannel(client client.Interface, numReads int) NamespaceLis
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "NamespaceList")
+		defer span.End()
 		list, err := client.CoreV1().Namespaces().List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -316,6 +329,8 @@ func GetEventListChannelWithOptions(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "EventList")
+		defer span.End()
 		list, err := client.CoreV1().EventsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(options)
 		var filteredItems []v1.Event
 		for _, item := range list.Items {
@@ -349,6 +364,8 @@ func GetEventListChannelWithMultiTenancyAndOptions(client client.Interface, tena
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "EventList")
+		defer span.End()
 		list, err := client.CoreV1().EventsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(options)
 		var filteredItems []v1.Event
 		for _, item := range list.Items {
@@ -385,6 +402,8 @@ func GetEndpointListChannelWithOptions(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "EndpointList")
+		defer span.End()
 		list, err := client.CoreV1().EndpointsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(opt)
 
 		for i := 0; i < numReads; i++ {
@@ -409,6 +428,8 @@ func GetEndpointListChannelWithMultiTenancyAndOptions(client client.Interface, t
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "EndpointList")
+		defer span.End()
 		list, err := client.CoreV1().EndpointsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(opt)
 
 		for i := 0; i < numReads; i++ {
@@ -448,6 +469,8 @@ func GetPodListChannelWithOptions(client client.Interface, nsQuery *NamespaceQue
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "PodList")
+		defer span.End()
 		list, err := client.CoreV1().PodsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(options)
 		var filteredItems []v1.Pod
 		for _, item := range list.Items {
@@ -482,6 +505,8 @@ func GetPodListChannelWithMultiTenancyAndOptions(client client.Interface, tenant
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "PodList")
+		defer span.End()
 		list, err := client.CoreV1().PodsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(options)
 		var filteredItems []v1.Pod
 		for _, item := range list.Items {
@@ -508,6 +533,8 @@ func GetVMListChannelWithMultiTenancyAndOptions(client client.Interface, tenant
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "VMList")
+		defer span.End()
 		list, err := client.CoreV1().PodsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(options)
 		var filteredItems []v1.Pod
 		for _, item := range list.Items {
@@ -543,6 +570,8 @@ func GetReplicationControllerListChannel(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ReplicationControllerList")
+		defer span.End()
 		list, err := client.CoreV1().ReplicationControllersWithMultiTenancy(nsQuery.ToRequestParam(), "").
 			List(api.ListEverything)
 		var filteredItems []v1.ReplicationController
@@ -573,6 +602,8 @@ func GetReplicationControllerListChannelWithMultiTenancy(client client.Interface
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ReplicationControllerList")
+		defer span.End()
 		list, err := client.CoreV1().ReplicationControllersWithMultiTenancy(nsQuery.ToRequestParam(), tenant).
 			List(api.ListEverything)
 		var filteredItems []v1.ReplicationController
@@ -607,6 +638,8 @@ func GetDeploymentListChannel(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "DeploymentList")
+		defer span.End()
 		list, err := client.AppsV1().DeploymentsWithMultiTenancy(nsQuery.ToRequestParam(), "").
 			List(api.ListEverything)
 		var filteredItems []apps.Deployment
@@ -635,6 +668,8 @@ func GetDeploymentListChannelWithMultiTenancy(client client.Interface, tenant st
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "DeploymentList")
+		defer span.End()
 		list, err := client.AppsV1().DeploymentsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).
 			List(api.ListEverything)
 		var filteredItems []apps.Deployment
@@ -676,6 +711,8 @@ func GetReplicaSetListChannelWithOptions(client client.Interface, nsQuery *Names
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ReplicaSetList")
+		defer span.End()
 		list, err := client.AppsV1().ReplicaSetsWithMultiTenancy(nsQuery.ToRequestParam(), "").
 			List(options)
 		var filteredItems []apps.ReplicaSet
@@ -711,6 +748,8 @@ func GetReplicaSetListChannelWithMultiTenancyAndOptions(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ReplicaSetList")
+		defer span.End()
 		list, err := client.AppsV1().ReplicaSetsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).
 			List(options)
 		var filteredItems []apps.ReplicaSet
@@ -744,6 +783,8 @@ func GetDaemonSetListChannel(client client.Interface, nsQuery *NamespaceQuery, n
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "DaemonSetList")
+		defer span.End()
 		list, err := client.AppsV1().DaemonSetsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		var filteredItems []apps.DaemonSet
 		for _, item := range list.Items {
@@ -770,6 +811,8 @@ func GetDaemonSetListChannelWithMultiTenancy(client client.Interface, tenant str
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "DaemonSetList")
+		defer span.End()
 		list, err := client.AppsV1().DaemonSetsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []apps.DaemonSet
 		for _, item := range list.Items {
@@ -802,6 +845,8 @@ func GetJobListChannel(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "JobList")
+		defer span.End()
 		list, err := client.BatchV1().JobsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		var filteredItems []batch.Job
 		for _, item := range list.Items {
@@ -828,6 +873,8 @@ func GetJobListChannelWithMultiTenancy(client client.Interface, tenant string,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "JobList")
+		defer span.End()
 		list, err := client.BatchV1().JobsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []batch.Job
 		for _, item := range list.Items {
@@ -859,6 +906,8 @@ func GetCronJobListChannel(client client.Interface, nsQuery *NamespaceQuery, num
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "CronJobList")
+		defer span.End()
 		list, err := client.BatchV1beta1().CronJobsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		var filteredItems []batch2.CronJob
 		for _, item := range list.Items {
@@ -884,6 +933,8 @@ func GetCronJobListChannelWithMultiTenancy(client client.Interface, tenant strin
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "CronJobList")
+		defer span.End()
 		list, err := client.BatchV1beta1().CronJobsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []batch2.CronJob
 		for _, item := range list.Items {
@@ -917,6 +968,8 @@ func GetStatefulSetListChannel(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "StatefulSetList")
+		defer span.End()
 		statefulSets, err := client.AppsV1().StatefulSetsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		var filteredItems []apps.StatefulSet
 		for _, item := range statefulSets.Items {
@@ -944,6 +997,8 @@ func GetStatefulSetListChannelWithMultiTenancy(client client.Interface, tenant s
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "StatefulSetList")
+		defer span.End()
 		statefulSets, err := client.AppsV1().StatefulSetsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []apps.StatefulSet
 		for _, item := range statefulSets.Items {
@@ -980,6 +1035,8 @@ func GetConfigMapListChannel(client client.Interface, nsQuery *NamespaceQuery,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ConfigMapList")
+		defer span.End()
 		list, err := client.CoreV1().ConfigMapsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []v1.ConfigMap
 		for _, item := range list.Items {
@@ -1008,6 +1065,8 @@ func GetConfigMapListChannelWithMultiTenancy(client client.Interface, tenant str
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ConfigMapList")
+		defer span.End()
 		list, err := client.CoreV1().ConfigMapsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []v1.ConfigMap
 		for _, item := range list.Items {
@@ -1042,6 +1101,8 @@ func GetSecretListChannel(client client.Interface, nsQuery *NamespaceQuery,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "SecretList")
+		defer span.End()
 		list, err := client.CoreV1().SecretsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		var filteredItems []v1.Secret
 		for _, item := range list.Items {
@@ -1070,6 +1131,8 @@ func GetSecretListChannelWithMultiTenancy(client client.Interface, tenant string
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "SecretList")
+		defer span.End()
 		list, err := client.CoreV1().SecretsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		var filteredItems []v1.Secret
 		for _, item := range list.Items {
@@ -1102,6 +1165,8 @@ func GetRoleListChannel(client client.Interface, numReads int) RoleListChannel {
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "RoleList")
+		defer span.End()
 		list, err := client.RbacV1().Roles("").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1127,6 +1192,8 @@ func GetClusterRoleListChannel(client client.Interface, numReads int) ClusterRol
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ClusterRoleList")
+		defer span.End()
 		list, err := client.RbacV1().ClusterRolesWithMultiTenancy("").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1146,6 +1213,8 @@ func GetClusterRoleListChannelWithMultiTenancy(client client.Interface, tenant s
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ClusterRoleList")
+		defer span.End()
 		list, err := client.RbacV1().ClusterRolesWithMultiTenancy(tenant).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1171,6 +1240,8 @@ func GetRoleBindingListChannel(client client.Interface, numReads int) RoleBindin
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "RoleBindingList")
+		defer span.End()
 		list, err := client.RbacV1().RoleBindings("").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1197,6 +1268,8 @@ func GetClusterRoleBindingListChannel(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ClusterRoleBindingList")
+		defer span.End()
 		list, err := client.RbacV1().ClusterRoleBindings().List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1223,6 +1296,8 @@ func GetPersistentVolumeListChannel(client client.Interface,
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "PersistentVolumeList")
+		defer span.End()
 		list, err := client.CoreV1().PersistentVolumesWithMultiTenancy("").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1243,6 +1318,8 @@ func GetPersistentVolumeListChannelWithMultiTenancy(client client.Interface, ten
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "PersistentVolumeList")
+		defer span.End()
 		list, err := client.CoreV1().PersistentVolumesWithMultiTenancy(tenant).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1270,6 +1347,8 @@ func GetPersistentVolumeClaimListChannel(client client.Interface, nsQuery *Names
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "PersistentVolumeClaimList")
+		defer span.End()
 		list, err := client.CoreV1().PersistentVolumeClaimsWithMultiTenancy(nsQuery.ToRequestParam(), "").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1291,6 +1370,8 @@ func GetPersistentVolumeClaimListChannelWithMultiTenancy(client client.Interface
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "PersistentVolumeClaimList")
+		defer span.End()
 		list, err := client.CoreV1().PersistentVolumeClaimsWithMultiTenancy(nsQuery.ToRequestParam(), tenant).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1316,6 +1397,8 @@ func GetCustomResourceDefinitionChannel(client apiextensionsclientset.Interface,
 	}
 
 	go func() {
+		_, span := tracing.StartSpan(tracing.ContextOf(client), "ResourceChannel CustomResourceDefinition")
+		defer span.End()
 		list, err := client.ApiextensionsV1beta1().CustomResourceDefinitionsWithMultiTenancy("").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1335,6 +1418,8 @@ func GetCustomResourceDefinitionChannelWithMultiTenancy(client apiextensionsclie
 	}
 
 	go func() {
+		_, span := tracing.StartSpan(tracing.ContextOf(client), "ResourceChannel CustomResourceDefinition")
+		defer span.End()
 		list, err := client.ApiextensionsV1beta1().CustomResourceDefinitionsWithMultiTenancy(tenant).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1362,6 +1447,8 @@ func GetResourceQuotaListChannel(client client.Interface, nsQuery *NamespaceQuer
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "ResourceQuotaList")
+		defer span.End()
 		list, err := client.CoreV1().ResourceQuotas(nsQuery.ToRequestParam()).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1388,6 +1475,8 @@ func GetHorizontalPodAutoscalerListChannel(client client.Interface, nsQuery *Nam
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "HorizontalPodAutoscalerList")
+		defer span.End()
 		list, err := client.AutoscalingV1().HorizontalPodAutoscalersWithMultiTenancy(nsQuery.ToRequestParam(), "").
 			List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
@@ -1409,6 +1498,8 @@ func GetHorizontalPodAutoscalerListChannelWithMultiTenancy(client client.Interfa
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "HorizontalPodAutoscalerList")
+		defer span.End()
 		list, err := client.AutoscalingV1().HorizontalPodAutoscalersWithMultiTenancy(nsQuery.ToRequestParam(), tenant).
 			List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
@@ -1435,6 +1526,8 @@ func GetStorageClassListChannel(client client.Interface, numReads int) StorageCl
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "StorageClassList")
+		defer span.End()
 		list, err := client.StorageV1().StorageClassesWithMultiTenancy("").List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
@@ -1454,6 +1547,8 @@ func GetStorageClassListChannelWithMultiTenancy(client client.Interface, tenant
 	}
 
 	go func() {
+		client, span := tracing.StartResourceChannelSpan(client, "StorageClassList")
+		defer span.End()
 		list, err := client.StorageV1().StorageClassesWithMultiTenancy(tenant).List(api.ListEverything)
 		for i := 0; i < numReads; i++ {
 			channel.List <- list
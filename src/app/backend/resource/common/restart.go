@@ -0,0 +1,38 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RestartedAtAnnotation is set on the pod template to trigger a rolling restart, the same way
+// 'kubectl rollout restart' does.
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// RestartPatch returns a patch that triggers a rolling restart of all pods of a deployment, stateful set
+// or daemon set. It is both a valid strategic merge patch and a valid JSON merge patch.
+func RestartPatch() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{RestartedAtAnnotation: time.Now().Format(time.RFC3339)},
+				},
+			},
+		},
+	})
+}
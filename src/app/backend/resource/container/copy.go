@@ -0,0 +1,203 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// MaxUploadSize is the largest file in bytes that can be uploaded to a container. Uploads are
+// spooled to a temporary file on the node running the dashboard, so the limit also bounds the disk
+// space a single request can take.
+const MaxUploadSize int64 = 100 * 1024 * 1024
+
+// uploadSizeLimit is the limit enforced on uploads, it only differs from MaxUploadSize in tests.
+var uploadSizeLimit = MaxUploadSize
+
+// FileCopyResult is returned after a file has been uploaded to a container.
+type FileCopyResult struct {
+	// Absolute path of the file inside of the container.
+	Path string `json:"path"`
+
+	// Number of bytes written.
+	Size int64 `json:"size"`
+}
+
+// CopyToContainerWithMultiTenancy uploads content as a single file to the given path in the
+// container. Like 'kubectl cp' it streams a tar archive to 'tar -x' running in the container, so
+// the image has to provide a tar binary.
+func CopyToContainerWithMultiTenancy(client kubernetes.Interface, cfg *rest.Config, tenant, namespace, podID,
+	container, destPath string, content io.Reader) (*FileCopyResult, error) {
+	destPath = path.Clean(destPath)
+	if !path.IsAbs(destPath) || destPath == "/" {
+		return nil, fmt.Errorf("destination path has to be an absolute file path, got %q", destPath)
+	}
+
+	// Tar headers need the size up front, so the content is spooled to a temporary file first. The
+	// file is removed on every return path.
+	spool, err := ioutil.TempFile("", "dashboard-cp-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		spool.Close()
+		if err := os.Remove(spool.Name()); err != nil {
+			log.Printf("Could not remove upload spool file %s: %v", spool.Name(), err)
+		}
+	}()
+
+	// Read one byte past the limit to tell a file of exactly the limit from a larger one.
+	size, err := io.Copy(spool, io.LimitReader(content, uploadSizeLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > uploadSizeLimit {
+		return nil, errors.NewBadRequest(fmt.Sprintf("file exceeds the upload limit of %d bytes", uploadSizeLimit))
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTarArchive(writer, path.Base(destPath), size, spool))
+	}()
+
+	log.Printf("Copying %d bytes to %s in container %s of pod %s/%s", size, destPath, container, namespace, podID)
+	cmd := []string{"tar", "-xmf", "-", "-C", path.Dir(destPath)}
+	stderr := &bytes.Buffer{}
+	err = execInContainer(client, cfg, tenant, namespace, podID, container, cmd, remotecommand.StreamOptions{
+		Stdin:  reader,
+		Stderr: stderr,
+	})
+	reader.Close()
+	if err != nil {
+		return nil, execError(err, stderr)
+	}
+
+	return &FileCopyResult{Path: destPath, Size: size}, nil
+}
+
+// CopyFromContainerWithMultiTenancy returns a stream with a tar archive of the file or directory
+// at the given path in the container. The archive contains the path relative to its parent
+// directory, the same layout 'kubectl cp' uses.
+func CopyFromContainerWithMultiTenancy(client kubernetes.Interface, cfg *rest.Config, tenant, namespace, podID,
+	container, srcPath string) (io.ReadCloser, error) {
+	srcPath = path.Clean(srcPath)
+	if !path.IsAbs(srcPath) {
+		return nil, fmt.Errorf("source path has to be absolute, got %q", srcPath)
+	}
+
+	reader, writer := io.Pipe()
+	cmd := []string{"tar", "-cf", "-", "-C", path.Dir(srcPath), path.Base(srcPath)}
+	go func() {
+		stderr := &bytes.Buffer{}
+		err := execInContainer(client, cfg, tenant, namespace, podID, container, cmd, remotecommand.StreamOptions{
+			Stdout: writer,
+			Stderr: stderr,
+		})
+		if err != nil {
+			err = execError(err, stderr)
+		}
+		writer.CloseWithError(err)
+	}()
+
+	// Wait for the first chunk of the archive, so that errors like a missing source path are
+	// reported before anything is sent to the client.
+	buffered := bufio.NewReader(reader)
+	if _, err := buffered.Peek(1); err != nil && err != io.EOF {
+		reader.Close()
+		return nil, err
+	}
+
+	return &bufferedReadCloser{Reader: buffered, Closer: reader}, nil
+}
+
+// bufferedReadCloser reads from a buffered reader and closes the underlying stream.
+type bufferedReadCloser struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// writeTarArchive writes a tar archive with a single regular file to the writer.
+func writeTarArchive(writer io.Writer, name string, size int64, content io.Reader) error {
+	tarWriter := tar.NewWriter(writer)
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tarWriter, content, size); err != nil {
+		return err
+	}
+	return tarWriter.Close()
+}
+
+func execInContainer(client kubernetes.Interface, cfg *rest.Config, tenant, namespace, podID, container string,
+	cmd []string, options remotecommand.StreamOptions) error {
+	req := client.CoreV1().RESTClient().Post().
+		Tenant(tenant).
+		Resource("pods").
+		Name(podID).
+		Namespace(namespace).
+		SubResource("exec")
+
+	req.VersionedParams(&v1.PodExecOptions{
+		Container: container,
+		Command:   cmd,
+		Stdin:     options.Stdin != nil,
+		Stdout:    options.Stdout != nil,
+		Stderr:    options.Stderr != nil,
+		TTY:       false,
+	}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(cfg, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	return exec.Stream(options)
+}
+
+// execError adds the stderr output of the command to the error returned by the executor.
+func execError(err error, stderr *bytes.Buffer) error {
+	message := strings.TrimSpace(stderr.String())
+	if len(message) == 0 {
+		return err
+	}
+	return fmt.Errorf("%s: %s", err.Error(), message)
+}
@@ -0,0 +1,275 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/apimachinery/pkg/util/remotecommand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newFakeExecServer starts a server that accepts exec requests like the apiserver does. Once the
+// expected streams are created, the handler is called with the request and the streams by type.
+func newFakeExecServer(t *testing.T, expectedStreams int,
+	handler func(r *http.Request, streams map[string]httpstream.Stream)) (kubernetes.Interface, *rest.Config) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := httpstream.Handshake(r, w, []string{remotecommand.StreamProtocolV4Name}); err != nil {
+			t.Errorf("handshake failed: %v", err)
+			return
+		}
+
+		created := make(chan httpstream.Stream, expectedStreams)
+		conn := spdy.NewResponseUpgrader().UpgradeResponse(w, r,
+			func(stream httpstream.Stream, replySent <-chan struct{}) error {
+				created <- stream
+				return nil
+			})
+		if conn == nil {
+			t.Errorf("can't upgrade connection")
+			return
+		}
+		defer conn.Close()
+
+		streams := map[string]httpstream.Stream{}
+		for i := 0; i < expectedStreams; i++ {
+			select {
+			case stream := <-created:
+				streams[stream.Headers().Get(v1.StreamType)] = stream
+			case <-time.After(wait.ForeverTestTimeout):
+				t.Errorf("timed out waiting for streams, got %d of %d", i, expectedStreams)
+				return
+			}
+		}
+		handler(r, streams)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &rest.Config{}
+	cfg.AddConfig(&rest.KubeConfig{Host: server.URL})
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return client, cfg
+}
+
+// useTempDir points temporary files to a new empty directory for the duration of the test.
+func useTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "copy-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Getenv("TMPDIR")
+	os.Setenv("TMPDIR", dir)
+	t.Cleanup(func() {
+		os.Setenv("TMPDIR", previous)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+// assertEmptyDir fails the test if the upload left files behind.
+func assertEmptyDir(t *testing.T, dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) > 0 {
+		t.Errorf("expected spool file to be removed, found %d files in %s", len(files), dir)
+	}
+}
+
+func TestWriteTarArchive(t *testing.T) {
+	content := "hello from the dashboard"
+	buffer := &bytes.Buffer{}
+
+	if err := writeTarArchive(buffer, "greeting.txt", int64(len(content)), strings.NewReader(content)); err != nil {
+		t.Fatalf("writeTarArchive() returned unexpected error: %v", err)
+	}
+
+	tarReader := tar.NewReader(buffer)
+	header, err := tarReader.Next()
+	if err != nil {
+		t.Fatalf("could not read tar header: %v", err)
+	}
+	if header.Name != "greeting.txt" || header.Size != int64(len(content)) {
+		t.Errorf("unexpected header: name %q, size %d", header.Name, header.Size)
+	}
+
+	data, err := ioutil.ReadAll(tarReader)
+	if err != nil {
+		t.Fatalf("could not read tar content: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("expected content %q, got %q", content, string(data))
+	}
+}
+
+func TestCopyToContainerInvalidPath(t *testing.T) {
+	cases := []string{"relative/file", "/", ""}
+	for _, c := range cases {
+		_, err := CopyToContainerWithMultiTenancy(nil, nil, "", "ns", "pod", "container", c, strings.NewReader(""))
+		if err == nil {
+			t.Errorf("CopyToContainerWithMultiTenancy(%q) expected error, got nil", c)
+		}
+	}
+}
+
+func TestExecError(t *testing.T) {
+	cases := []struct {
+		stderr   string
+		expected string
+	}{
+		{"", "command terminated with exit code 2"},
+		{"tar: /missing: No such file or directory\n",
+			"command terminated with exit code 2: tar: /missing: No such file or directory"},
+	}
+	for _, c := range cases {
+		actual := execError(errors.New("command terminated with exit code 2"), bytes.NewBufferString(c.stderr))
+		if actual.Error() != c.expected {
+			t.Errorf("execError() = %q, expected %q", actual.Error(), c.expected)
+		}
+	}
+}
+
+func TestCopyToContainer(t *testing.T) {
+	tempDir := useTempDir(t)
+	content := "key: value\n"
+
+	client, cfg := newFakeExecServer(t, 3, func(r *http.Request, streams map[string]httpstream.Stream) {
+		expectedCommand := []string{"tar", "-xmf", "-", "-C", "/etc/app"}
+		if command := r.URL.Query()["command"]; !reflect.DeepEqual(command, expectedCommand) {
+			t.Errorf("exec command %v, expected %v", command, expectedCommand)
+		}
+
+		tarReader := tar.NewReader(streams[v1.StreamTypeStdin])
+		header, err := tarReader.Next()
+		if err != nil {
+			t.Errorf("can't read tar header: %v", err)
+			return
+		}
+		data, _ := ioutil.ReadAll(tarReader)
+		if header.Name != "config.yaml" || string(data) != content {
+			t.Errorf("received file %q with %q, expected config.yaml with %q", header.Name, data, content)
+		}
+		io.Copy(ioutil.Discard, streams[v1.StreamTypeStdin])
+		streams[v1.StreamTypeStderr].Close()
+		streams[v1.StreamTypeError].Close()
+	})
+
+	result, err := CopyToContainerWithMultiTenancy(client, cfg, "tenant-1", "ns", "pod", "app", "/etc/app/config.yaml",
+		strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("CopyToContainerWithMultiTenancy() returned error: %v", err)
+	}
+	expected := &FileCopyResult{Path: "/etc/app/config.yaml", Size: int64(len(content))}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("CopyToContainerWithMultiTenancy() == %v, expected %v", result, expected)
+	}
+	assertEmptyDir(t, tempDir)
+}
+
+func TestCopyToContainerSizeLimit(t *testing.T) {
+	tempDir := useTempDir(t)
+	defer func(limit int64) { uploadSizeLimit = limit }(uploadSizeLimit)
+	uploadSizeLimit = 8
+
+	cases := []struct {
+		content     string
+		expectedErr bool
+	}{
+		{"12345678", false},
+		{"123456789", true},
+	}
+	for _, c := range cases {
+		client, cfg := newFakeExecServer(t, 3, func(r *http.Request, streams map[string]httpstream.Stream) {
+			io.Copy(ioutil.Discard, streams[v1.StreamTypeStdin])
+			streams[v1.StreamTypeStderr].Close()
+			streams[v1.StreamTypeError].Close()
+		})
+
+		_, err := CopyToContainerWithMultiTenancy(client, cfg, "", "ns", "pod", "app", "/file",
+			strings.NewReader(c.content))
+		if (err != nil) != c.expectedErr {
+			t.Errorf("CopyToContainerWithMultiTenancy() with %d bytes returned %v, expected error: %t",
+				len(c.content), err, c.expectedErr)
+		}
+		assertEmptyDir(t, tempDir)
+	}
+}
+
+func TestCopyToContainerExecError(t *testing.T) {
+	tempDir := useTempDir(t)
+
+	client, cfg := newFakeExecServer(t, 3, func(r *http.Request, streams map[string]httpstream.Stream) {
+		streams[v1.StreamTypeStderr].Write([]byte("tar: /readonly: Read-only file system\n"))
+		streams[v1.StreamTypeStderr].Close()
+		streams[v1.StreamTypeError].Write([]byte(`{"status":"Failure","message":"command terminated with non-zero exit code"}`))
+		streams[v1.StreamTypeError].Close()
+	})
+
+	_, err := CopyToContainerWithMultiTenancy(client, cfg, "", "ns", "pod", "app", "/readonly/file",
+		strings.NewReader("data"))
+	if err == nil || !strings.HasSuffix(err.Error(), "tar: /readonly: Read-only file system") {
+		t.Errorf("CopyToContainerWithMultiTenancy() == %v, expected error with tar output", err)
+	}
+	assertEmptyDir(t, tempDir)
+}
+
+func TestCopyFromContainer(t *testing.T) {
+	client, cfg := newFakeExecServer(t, 3, func(r *http.Request, streams map[string]httpstream.Stream) {
+		expectedCommand := []string{"tar", "-cf", "-", "-C", "/var/log", "app.log"}
+		if command := r.URL.Query()["command"]; !reflect.DeepEqual(command, expectedCommand) {
+			t.Errorf("exec command %v, expected %v", command, expectedCommand)
+		}
+		writeTarArchive(streams[v1.StreamTypeStdout], "app.log", 5, strings.NewReader("hello"))
+		streams[v1.StreamTypeStdout].Close()
+		streams[v1.StreamTypeStderr].Close()
+		streams[v1.StreamTypeError].Close()
+	})
+
+	archive, err := CopyFromContainerWithMultiTenancy(client, cfg, "", "ns", "pod", "app", "/var/log/app.log")
+	if err != nil {
+		t.Fatalf("CopyFromContainerWithMultiTenancy() returned error: %v", err)
+	}
+	defer archive.Close()
+
+	tarReader := tar.NewReader(archive)
+	header, err := tarReader.Next()
+	if err != nil {
+		t.Fatalf("can't read tar header: %v", err)
+	}
+	data, _ := ioutil.ReadAll(tarReader)
+	if header.Name != "app.log" || string(data) != "hello" {
+		t.Errorf("got file %q with %q, expected app.log with %q", header.Name, data, "hello")
+	}
+}
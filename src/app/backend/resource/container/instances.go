@@ -0,0 +1,193 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/event"
+)
+
+// ContainerState is the state of a container instance.
+type ContainerState string
+
+const (
+	ContainerStateWaiting    ContainerState = "Waiting"
+	ContainerStateRunning    ContainerState = "Running"
+	ContainerStateTerminated ContainerState = "Terminated"
+	ContainerStateUnknown    ContainerState = "Unknown"
+)
+
+// ContainerInstance is a single run of a container. Besides the current instance, the kubelet keeps the
+// logs of the last terminated instance, which can be read with the 'previous' log option.
+type ContainerInstance struct {
+	ContainerName string `json:"containerName"`
+	InitContainer bool   `json:"initContainer"`
+
+	// Previous is true for the last terminated instance of a restarted container. Its logs are
+	// available with the 'previous=true' query parameter of the log endpoints.
+	Previous bool `json:"previous"`
+
+	// RestartCount is the number of restarts of the container before the instance was started, i.e. the
+	// restart count of the container for the current instance and one less for the previous one.
+	RestartCount int32          `json:"restartCount"`
+	State        ContainerState `json:"state"`
+
+	// Details of the state. Exit code and finish time are only set for terminated instances.
+	Reason     string       `json:"reason,omitempty"`
+	Message    string       `json:"message,omitempty"`
+	ExitCode   *int32       `json:"exitCode,omitempty"`
+	Signal     int32        `json:"signal,omitempty"`
+	StartedAt  *metaV1.Time `json:"startedAt,omitempty"`
+	FinishedAt *metaV1.Time `json:"finishedAt,omitempty"`
+}
+
+// ContainerInstanceList lists all container instances of a pod together with the pod events, so that
+// crashes can be correlated with e.g. failing probes or OOM kills.
+type ContainerInstanceList struct {
+	PodName   string              `json:"podName"`
+	Namespace string              `json:"namespace"`
+	Instances []ContainerInstance `json:"instances"`
+	Events    []common.Event      `json:"events"`
+}
+
+// GetContainerInstances returns all instances of init and regular containers of a pod.
+func GetContainerInstances(client kubernetes.Interface, namespace, podID string) (*ContainerInstanceList, error) {
+	return GetContainerInstancesWithMultiTenancy(client, "", namespace, podID)
+}
+
+// GetContainerInstancesWithMultiTenancy returns all instances of init and regular containers of a pod.
+func GetContainerInstancesWithMultiTenancy(client kubernetes.Interface, tenant, namespace, podID string) (
+	*ContainerInstanceList, error) {
+	pod, err := client.CoreV1().PodsWithMultiTenancy(namespace, tenant).Get(podID, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := event.GetPodsEventsWithMultiTenancy(client, tenant, namespace, []v1.Pod{*pod})
+	if err != nil {
+		return nil, err
+	}
+
+	return toContainerInstanceList(pod, event.FillEventsType(events)), nil
+}
+
+func toContainerInstanceList(pod *v1.Pod, events []v1.Event) *ContainerInstanceList {
+	result := &ContainerInstanceList{
+		PodName:   pod.Name,
+		Namespace: pod.Namespace,
+		Instances: make([]ContainerInstance, 0),
+		Events:    make([]common.Event, 0),
+	}
+
+	result.Instances = appendContainerInstances(result.Instances, pod.Spec.InitContainers,
+		pod.Status.InitContainerStatuses, true)
+	result.Instances = appendContainerInstances(result.Instances, pod.Spec.Containers,
+		pod.Status.ContainerStatuses, false)
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.Before(&events[j].LastTimestamp)
+	})
+	for _, e := range events {
+		result.Events = append(result.Events, event.ToEvent(e))
+	}
+
+	return result
+}
+
+// appendContainerInstances adds the current and, if the container was restarted, the previous
+// instance of every container. Containers without status are reported in unknown state.
+func appendContainerInstances(instances []ContainerInstance, containers []v1.Container,
+	statuses []v1.ContainerStatus, init bool) []ContainerInstance {
+	statusByName := make(map[string]v1.ContainerStatus)
+	for _, status := range statuses {
+		statusByName[status.Name] = status
+	}
+
+	for _, container := range containers {
+		status, ok := statusByName[container.Name]
+		if !ok {
+			instances = append(instances, ContainerInstance{
+				ContainerName: container.Name,
+				InitContainer: init,
+				State:         ContainerStateUnknown,
+			})
+			continue
+		}
+
+		current := toContainerInstance(container.Name, init, status.RestartCount, status.State)
+		instances = append(instances, current)
+
+		if status.LastTerminationState.Terminated != nil {
+			previous := toContainerInstance(container.Name, init, previousRestartCount(status.RestartCount),
+				status.LastTerminationState)
+			previous.Previous = true
+			instances = append(instances, previous)
+		}
+	}
+
+	return instances
+}
+
+// previousRestartCount returns the restart count of the instance before the current one. It is never
+// negative, as the kubelet may report a last termination state without counting the restart.
+func previousRestartCount(restartCount int32) int32 {
+	if restartCount > 0 {
+		return restartCount - 1
+	}
+	return 0
+}
+
+func toContainerInstance(name string, init bool, restartCount int32, state v1.ContainerState) ContainerInstance {
+	instance := ContainerInstance{
+		ContainerName: name,
+		InitContainer: init,
+		RestartCount:  restartCount,
+		State:         ContainerStateUnknown,
+	}
+
+	switch {
+	case state.Running != nil:
+		instance.State = ContainerStateRunning
+		instance.StartedAt = timeOrNil(state.Running.StartedAt)
+	case state.Waiting != nil:
+		instance.State = ContainerStateWaiting
+		instance.Reason = state.Waiting.Reason
+		instance.Message = state.Waiting.Message
+	case state.Terminated != nil:
+		exitCode := state.Terminated.ExitCode
+		instance.State = ContainerStateTerminated
+		instance.Reason = state.Terminated.Reason
+		instance.Message = state.Terminated.Message
+		instance.ExitCode = &exitCode
+		instance.Signal = state.Terminated.Signal
+		instance.StartedAt = timeOrNil(state.Terminated.StartedAt)
+		instance.FinishedAt = timeOrNil(state.Terminated.FinishedAt)
+	}
+
+	return instance
+}
+
+func timeOrNil(t metaV1.Time) *metaV1.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
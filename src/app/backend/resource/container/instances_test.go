@@ -0,0 +1,117 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetContainerInstancesWithMultiTenancy(t *testing.T) {
+	started := metaV1.NewTime(time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC))
+	finished := metaV1.NewTime(time.Date(2020, 5, 1, 10, 5, 0, 0, time.UTC))
+	exitCode := int32(137)
+	initExitCode := int32(0)
+
+	pod := &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: "pod", Namespace: "ns", Tenant: "tenant", UID: "pod-uid"},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "setup"}},
+			Containers:     []v1.Container{{Name: "app"}, {Name: "worker"}, {Name: "sidecar"}},
+		},
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{{
+				Name: "setup",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: 0, Reason: "Completed", StartedAt: started, FinishedAt: started,
+				}},
+			}},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:         "app",
+				RestartCount: 3,
+				State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{
+					Reason: "CrashLoopBackOff", Message: "back-off restarting failed container",
+				}},
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: 137, Reason: "OOMKilled", StartedAt: started, FinishedAt: finished,
+				}},
+			}, {
+				Name:  "worker",
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: finished}},
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: 0, Reason: "Completed", StartedAt: started, FinishedAt: finished,
+				}},
+			}},
+		},
+	}
+	events := []v1.Event{
+		{
+			ObjectMeta:     metaV1.ObjectMeta{Name: "backoff", Namespace: "ns", Tenant: "tenant"},
+			InvolvedObject: v1.ObjectReference{UID: "pod-uid", FieldPath: "spec.containers{app}"},
+			Reason:         "BackOff",
+			Type:           v1.EventTypeWarning,
+			LastTimestamp:  finished,
+		},
+		{
+			ObjectMeta:     metaV1.ObjectMeta{Name: "pulled", Namespace: "ns", Tenant: "tenant"},
+			InvolvedObject: v1.ObjectReference{UID: "pod-uid", FieldPath: "spec.containers{app}"},
+			Reason:         "Pulled",
+			LastTimestamp:  started,
+		},
+		{
+			ObjectMeta:     metaV1.ObjectMeta{Name: "other", Namespace: "ns", Tenant: "tenant"},
+			InvolvedObject: v1.ObjectReference{UID: "other-uid"},
+			Reason:         "Pulled",
+		},
+	}
+
+	client := fake.NewSimpleClientset(pod, &events[0], &events[1], &events[2])
+	actual, err := GetContainerInstancesWithMultiTenancy(client, "tenant", "ns", "pod")
+	if err != nil {
+		t.Fatalf("GetContainerInstancesWithMultiTenancy() returned unexpected error: %v", err)
+	}
+
+	expected := []ContainerInstance{
+		{ContainerName: "setup", InitContainer: true, State: ContainerStateTerminated, Reason: "Completed",
+			ExitCode: &initExitCode, StartedAt: &started, FinishedAt: &started},
+		{ContainerName: "app", RestartCount: 3, State: ContainerStateWaiting, Reason: "CrashLoopBackOff",
+			Message: "back-off restarting failed container"},
+		{ContainerName: "app", Previous: true, RestartCount: 2, State: ContainerStateTerminated, Reason: "OOMKilled",
+			ExitCode: &exitCode, StartedAt: &started, FinishedAt: &finished},
+		{ContainerName: "worker", State: ContainerStateRunning, StartedAt: &finished},
+		{ContainerName: "worker", Previous: true, State: ContainerStateTerminated, Reason: "Completed",
+			ExitCode: &initExitCode, StartedAt: &started, FinishedAt: &finished},
+		{ContainerName: "sidecar", State: ContainerStateUnknown},
+	}
+	if !reflect.DeepEqual(actual.Instances, expected) {
+		t.Errorf("GetContainerInstancesWithMultiTenancy() instances ==\n%#v\nexpected\n%#v", actual.Instances, expected)
+	}
+
+	reasons := make([]string, 0)
+	for _, e := range actual.Events {
+		reasons = append(reasons, e.Reason)
+	}
+	if !reflect.DeepEqual(reasons, []string{"Pulled", "BackOff"}) {
+		t.Errorf("expected pod events ordered by time, got %v", reasons)
+	}
+	if actual.Events[0].Type != v1.EventTypeNormal {
+		t.Errorf("expected event type to be filled, got %q", actual.Events[0].Type)
+	}
+}
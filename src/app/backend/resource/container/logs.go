@@ -193,6 +193,21 @@ func GetLogFileWithMultiTenancy(client kubernetes.Interface, tenant, namespace,
 	return logStream, err
 }
 
+// GetLimitedLogFileWithMultiTenancy returns a stream to at most limitBytes of the log file, which is
+// read from the beginning of the log. Previous indicates to read archived logs created by log
+// rotation or container crash
+func GetLimitedLogFileWithMultiTenancy(client kubernetes.Interface, tenant, namespace, podID string, container string,
+	usePreviousLogs bool, limitBytes int64) (io.ReadCloser, error) {
+	logOptions := &v1.PodLogOptions{
+		Container:  container,
+		Follow:     false,
+		Previous:   usePreviousLogs,
+		Timestamps: false,
+		LimitBytes: &limitBytes,
+	}
+	return openStreamWithMultiTenancy(client, tenant, namespace, podID, logOptions)
+}
+
 func openStream(client kubernetes.Interface, namespace, podID string, logOptions *v1.PodLogOptions) (io.ReadCloser, error) {
 	return client.CoreV1().RESTClient().Get().
 		Tenant("").
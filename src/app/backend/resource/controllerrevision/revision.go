@@ -0,0 +1,187 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controllerrevision contains the revision history shared by stateful sets and daemon sets.
+// Both store every version of their pod template in a ControllerRevision, whose data is a strategic
+// merge patch that restores the template.
+package controllerrevision
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	client "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Revision is a single version of the pod template of a stateful set or daemon set.
+type Revision struct {
+	// Name of the controller revision.
+	Name string `json:"name"`
+
+	Revision int64 `json:"revision"`
+
+	CreationTimestamp metaV1.Time `json:"creationTimestamp"`
+
+	// Container images of the pod template.
+	Images []string `json:"images"`
+
+	// Whether this is the revision the controller currently rolls out.
+	Current bool `json:"current"`
+
+	// Unified diff of the pod template against the previous revision.
+	Diff string `json:"diff"`
+}
+
+// RevisionHistory contains all revisions of a stateful set or daemon set, the oldest first.
+type RevisionHistory struct {
+	ListMeta  api.ListMeta `json:"listMeta"`
+	Revisions []Revision   `json:"revisions"`
+}
+
+// RollbackSpec is a specification of the revision a stateful set or daemon set should be rolled back to.
+type RollbackSpec struct {
+	// Revision to roll back to. The previous revision is used if it is 0.
+	Revision int64 `json:"revision"`
+}
+
+// GetOwnedRevisions returns the controller revisions controlled by the owner, ordered by revision.
+func GetOwnedRevisions(client client.Interface, tenant string, owner metaV1.Object, selector *metaV1.LabelSelector) (
+	[]*apps.ControllerRevision, error) {
+	labelSelector, err := metaV1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.AppsV1().ControllerRevisionsWithMultiTenancy(owner.GetNamespace(), tenant).
+		List(metaV1.ListOptions{LabelSelector: labelSelector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*apps.ControllerRevision, 0)
+	for i := range list.Items {
+		if ref := metaV1.GetControllerOf(&list.Items[i]); ref != nil && ref.UID == owner.GetUID() {
+			revisions = append(revisions, &list.Items[i])
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+	return revisions, nil
+}
+
+// ToRevisionHistory returns the history of the revisions ordered by revision. The controllers reuse
+// and renumber a revision if its template is restored, so the latest revision is always the current one.
+func ToRevisionHistory(revisions []*apps.ControllerRevision) (*RevisionHistory, error) {
+	history := &RevisionHistory{Revisions: make([]Revision, 0, len(revisions))}
+	var previous []string
+	previousRevision := int64(0)
+	for i, revision := range revisions {
+		template, err := getTemplate(revision)
+		if err != nil {
+			return nil, err
+		}
+		lines, err := templateLines(template)
+		if err != nil {
+			return nil, err
+		}
+
+		history.Revisions = append(history.Revisions, Revision{
+			Name:              revision.Name,
+			Revision:          revision.Revision,
+			CreationTimestamp: revision.CreationTimestamp,
+			Images:            common.GetContainerImages(&template.Spec),
+			Current:           i == len(revisions)-1,
+			Diff: common.UnifiedDiff(fmt.Sprintf("revision %d", previousRevision),
+				fmt.Sprintf("revision %d", revision.Revision), previous, lines),
+		})
+		previous = lines
+		previousRevision = revision.Revision
+	}
+	history.ListMeta = api.ListMeta{TotalItems: len(history.Revisions)}
+	return history, nil
+}
+
+// FindRollbackTarget returns the revision to roll back to. If the revision is 0, the revision before the
+// current one is returned. Revisions have to be ordered by revision.
+func FindRollbackTarget(revisions []*apps.ControllerRevision, revision int64) (*apps.ControllerRevision, error) {
+	if revision < 0 {
+		return nil, errors.NewInvalid(fmt.Sprintf("Invalid revision %d", revision))
+	}
+
+	if revision == 0 {
+		if len(revisions) < 2 {
+			return nil, errors.NewNotFound("No previous revision found")
+		}
+		return revisions[len(revisions)-2], nil
+	}
+
+	for _, r := range revisions {
+		if r.Revision == revision {
+			return r, nil
+		}
+	}
+	return nil, errors.NewNotFound(fmt.Sprintf("Revision %d not found", revision))
+}
+
+// IsCurrent returns whether the revision is the current one of the ordered revisions.
+func IsCurrent(revisions []*apps.ControllerRevision, revision *apps.ControllerRevision) bool {
+	return len(revisions) > 0 && revisions[len(revisions)-1].UID == revision.UID
+}
+
+// getTemplate decodes the pod template stored in the patch of the revision.
+func getTemplate(revision *apps.ControllerRevision) (*v1.PodTemplateSpec, error) {
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(revision.Data.Raw, &patch); err != nil {
+		return nil, fmt.Errorf("could not decode revision %s: %s", revision.Name, err.Error())
+	}
+
+	object, _, err := unstructured.NestedMap(patch, "spec", "template")
+	if err != nil {
+		return nil, fmt.Errorf("could not decode template of revision %s: %s", revision.Name, err.Error())
+	}
+	delete(object, "$patch")
+
+	template := new(v1.PodTemplateSpec)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, template); err != nil {
+		return nil, fmt.Errorf("could not decode template of revision %s: %s", revision.Name, err.Error())
+	}
+	return template, nil
+}
+
+func templateLines(template *v1.PodTemplateSpec) ([]string, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(template)
+	if err != nil {
+		return nil, err
+	}
+	unstructured.RemoveNestedField(object, "metadata", "creationTimestamp")
+	if metadata, _, _ := unstructured.NestedMap(object, "metadata"); len(metadata) == 0 {
+		delete(object, "metadata")
+	}
+
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
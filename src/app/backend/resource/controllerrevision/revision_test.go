@@ -0,0 +1,110 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllerrevision
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	apps "k8s.io/api/apps/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newRevision(owner *apps.StatefulSet, revision int64, image string) *apps.ControllerRevision {
+	data := fmt.Sprintf(`{"spec":{"template":{"$patch":"replace","metadata":{"labels":{"app":"foo"}},`+
+		`"spec":{"containers":[{"name":"foo","image":%q}]}}}}`, image)
+	return &apps.ControllerRevision{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:            fmt.Sprintf("foo-%d", revision),
+			Namespace:       owner.Namespace,
+			UID:             types.UID(fmt.Sprintf("revision-%d", revision)),
+			Labels:          map[string]string{"app": "foo"},
+			OwnerReferences: []metaV1.OwnerReference{*metaV1.NewControllerRef(owner, apps.SchemeGroupVersion.WithKind("StatefulSet"))},
+		},
+		Data:     runtime.RawExtension{Raw: []byte(data)},
+		Revision: revision,
+	}
+}
+
+func TestGetOwnedRevisionsAndHistory(t *testing.T) {
+	owner := &apps.StatefulSet{ObjectMeta: metaV1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "owner"}}
+	other := &apps.StatefulSet{ObjectMeta: metaV1.ObjectMeta{Name: "other", Namespace: "bar", UID: "other"}}
+	client := fake.NewSimpleClientset(newRevision(owner, 3, "foo:3"), newRevision(owner, 1, "foo:1"),
+		newRevision(other, 2, "other:2"))
+
+	revisions, err := GetOwnedRevisions(client, "", owner, &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}})
+	if err != nil {
+		t.Fatalf("GetOwnedRevisions() returned unexpected error: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Revision != 1 || revisions[1].Revision != 3 {
+		t.Fatalf("Expected revisions 1 and 3 of the owner, got %v", revisions)
+	}
+
+	history, err := ToRevisionHistory(revisions)
+	if err != nil {
+		t.Fatalf("ToRevisionHistory() returned unexpected error: %v", err)
+	}
+	if history.ListMeta.TotalItems != 2 || history.Revisions[0].Current || !history.Revisions[1].Current {
+		t.Errorf("unexpected history %+v", history)
+	}
+	if !reflect.DeepEqual(history.Revisions[1].Images, []string{"foo:3"}) {
+		t.Errorf("Expected images [foo:3], got %v", history.Revisions[1].Images)
+	}
+	diff := history.Revisions[1].Diff
+	if !strings.Contains(diff, "--- revision 1\n+++ revision 3") || !strings.Contains(diff, "+  - image: foo:3") ||
+		strings.Contains(diff, "$patch") || strings.Contains(diff, "creationTimestamp") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+}
+
+func TestFindRollbackTarget(t *testing.T) {
+	owner := &apps.StatefulSet{ObjectMeta: metaV1.ObjectMeta{Name: "foo", UID: "owner"}}
+	revisions := []*apps.ControllerRevision{newRevision(owner, 1, "foo:1"), newRevision(owner, 2, "foo:2"),
+		newRevision(owner, 4, "foo:4")}
+
+	cases := []struct {
+		revision int64
+		expected int64
+	}{
+		{0, 2},
+		{1, 1},
+		{4, 4},
+	}
+	for _, c := range cases {
+		target, err := FindRollbackTarget(revisions, c.revision)
+		if err != nil {
+			t.Fatalf("FindRollbackTarget(%d) returned unexpected error: %v", c.revision, err)
+		}
+		if target.Revision != c.expected {
+			t.Errorf("FindRollbackTarget(%d) == %d, expected %d", c.revision, target.Revision, c.expected)
+		}
+	}
+
+	if _, err := FindRollbackTarget(revisions, 3); !errors.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown revision, got %v", err)
+	}
+	if _, err := FindRollbackTarget(revisions[:1], 0); !errors.IsNotFoundError(err) {
+		t.Errorf("Expected not found error without previous revision, got %v", err)
+	}
+	if !IsCurrent(revisions, revisions[2]) || IsCurrent(revisions, revisions[1]) {
+		t.Error("Expected only the latest revision to be current")
+	}
+}
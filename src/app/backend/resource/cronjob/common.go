@@ -42,6 +42,7 @@ func (self CronJobCell) GetProperty(name dataselect.PropertyName) dataselect.Com
 
 func (self CronJobCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindCronJob,
 		ResourceName: self.ObjectMeta.Name,
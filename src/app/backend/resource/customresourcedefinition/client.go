@@ -19,12 +19,18 @@ import (
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/rest"
 )
 
 func NewRESTClient(config *rest.Config, crd *apiextensions.CustomResourceDefinition) (*rest.RESTClient, error) {
-	groupVersion := getCustomResourceDefinitionGroupVersion(crd)
+	return NewRESTClientForGroupVersion(config, getCustomResourceDefinitionGroupVersion(crd))
+}
+
+// NewRESTClientForGroupVersion creates a JSON REST client for resources of an API group version that
+// is not known to the client-go scheme, e.g. the one of a custom resource.
+func NewRESTClientForGroupVersion(config *rest.Config, groupVersion schema.GroupVersion) (*rest.RESTClient, error) {
 	scheme := runtime.NewScheme()
 	schemeBuilder := runtime.NewSchemeBuilder(
 		func(scheme *runtime.Scheme) error {
@@ -39,10 +45,15 @@ func NewRESTClient(config *rest.Config, crd *apiextensions.CustomResourceDefinit
 		return nil, err
 	}
 
-	config.GetConfig().GroupVersion = &groupVersion
-	config.GetConfig().APIPath = "/apis"
-	config.GetConfig().ContentType = runtime.ContentTypeJSON
-	config.GetConfig().NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
+	// The config is shared by all clients of the request, so the group version is set on a copy.
+	kubeConfig := rest.CopyConfig(config.GetConfig())
+	kubeConfig.GroupVersion = &groupVersion
+	kubeConfig.APIPath = "/apis"
+	if len(groupVersion.Group) == 0 {
+		kubeConfig.APIPath = "/api"
+	}
+	kubeConfig.ContentType = runtime.ContentTypeJSON
+	kubeConfig.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
 
-	return rest.RESTClientFor(config.GetConfig())
+	return rest.RESTClientFor(kubeConfig)
 }
@@ -80,6 +80,7 @@ func (self DaemonSetCell) GetProperty(name dataselect.PropertyName) dataselect.C
 
 func (self DaemonSetCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindDaemonSet,
 		ResourceName: self.ObjectMeta.Name,
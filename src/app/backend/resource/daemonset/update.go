@@ -0,0 +1,126 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemonset
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/controllerrevision"
+	apps "k8s.io/api/apps/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sClient "k8s.io/client-go/kubernetes"
+)
+
+// UpdateStatus describes the rolling update of a daemon set.
+type UpdateStatus struct {
+	Strategy apps.DaemonSetUpdateStrategyType `json:"strategy"`
+
+	// Number of nodes that should run the daemon pod.
+	Desired int32 `json:"desired"`
+
+	// Number of nodes that run the latest revision of the daemon pod.
+	Updated int32 `json:"updated"`
+
+	// Number of nodes that run an available daemon pod.
+	Available int32 `json:"available"`
+}
+
+// RestartDaemonSet triggers a rolling restart of all pods of the daemon set.
+func RestartDaemonSet(client k8sClient.Interface, tenant, namespace, name string) (*UpdateStatus, error) {
+	log.Printf("Restarting %s daemonset in %s namespace for %s", name, namespace, tenant)
+
+	daemonSet, err := client.AppsV1().DaemonSetsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if daemonSet.Spec.UpdateStrategy.Type == apps.OnDeleteDaemonSetStrategyType {
+		return nil, errors.NewInvalid(fmt.Sprintf("Daemon set %s uses the OnDelete update strategy and "+
+			"cannot be restarted", name))
+	}
+
+	patch, err := common.RestartPatch()
+	if err != nil {
+		return nil, err
+	}
+	return patchDaemonSet(client, tenant, namespace, name, patch)
+}
+
+// RollbackDaemonSet restores the pod template of the given revision.
+func RollbackDaemonSet(client k8sClient.Interface, tenant, namespace, name string,
+	spec *controllerrevision.RollbackSpec) (*UpdateStatus, error) {
+	log.Printf("Rolling back %s daemonset in %s namespace for %s to revision %d", name, namespace, tenant,
+		spec.Revision)
+
+	daemonSet, revisions, err := getDaemonSetRevisions(client, tenant, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := controllerrevision.FindRollbackTarget(revisions, spec.Revision)
+	if err != nil {
+		return nil, err
+	}
+	if controllerrevision.IsCurrent(revisions, target) {
+		return toUpdateStatus(daemonSet), nil
+	}
+	return patchDaemonSet(client, tenant, namespace, name, target.Data.Raw)
+}
+
+// GetDaemonSetRevisionHistory returns all revisions of the daemon set.
+func GetDaemonSetRevisionHistory(client k8sClient.Interface, tenant, namespace, name string) (
+	*controllerrevision.RevisionHistory, error) {
+	_, revisions, err := getDaemonSetRevisions(client, tenant, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return controllerrevision.ToRevisionHistory(revisions)
+}
+
+func getDaemonSetRevisions(client k8sClient.Interface, tenant, namespace, name string) (
+	*apps.DaemonSet, []*apps.ControllerRevision, error) {
+	daemonSet, err := client.AppsV1().DaemonSetsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revisions, err := controllerrevision.GetOwnedRevisions(client, tenant, daemonSet, daemonSet.Spec.Selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	return daemonSet, revisions, nil
+}
+
+func patchDaemonSet(client k8sClient.Interface, tenant, namespace, name string, patch []byte) (
+	*UpdateStatus, error) {
+	daemonSet, err := client.AppsV1().DaemonSetsWithMultiTenancy(namespace, tenant).
+		Patch(name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return nil, err
+	}
+	return toUpdateStatus(daemonSet), nil
+}
+
+func toUpdateStatus(daemonSet *apps.DaemonSet) *UpdateStatus {
+	return &UpdateStatus{
+		Strategy:  daemonSet.Spec.UpdateStrategy.Type,
+		Desired:   daemonSet.Status.DesiredNumberScheduled,
+		Updated:   daemonSet.Status.UpdatedNumberScheduled,
+		Available: daemonSet.Status.NumberAvailable,
+	}
+}
@@ -17,6 +17,7 @@ package dataselect
 import (
 	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
 	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	metriccommon "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/common"
 	"log"
 	"sort"
 )
@@ -136,6 +137,7 @@ func (self *DataSelector) getMetrics(metricClient metricapi.MetricClient) (
 		return metricPromises, errors.NewInternal("No metrics specified. Skipping metrics.")
 	}
 
+	metricClient = metriccommon.WithWindow(metricClient, self.DataSelectQuery.MetricQuery.Window)
 	selectors := make([]metricapi.ResourceSelector, len(self.GenericDataList))
 	for i, dataCell := range self.GenericDataList {
 		// make sure data cells support metrics
@@ -15,6 +15,8 @@
 package dataselect
 
 import (
+	"time"
+
 	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
 )
 
@@ -35,6 +37,9 @@ var NoMetrics = NewMetricQuery(nil, nil)
 var StandardMetrics = NewMetricQuery([]string{metricapi.CpuUsage, metricapi.MemoryUsage},
 	metricapi.OnlySumAggregation)
 
+// MaxMetricWindow is the longest time window of metrics that can be requested.
+const MaxMetricWindow = 7 * 24 * time.Hour
+
 // MetricQuery holds parameters for metric extraction process.
 // It accepts list of metrics to be downloaded and a list of aggregations that should be performed for each metric.
 // Query has this format  metrics=metric1,metric2,...&aggregations=aggregation1,aggregation2,...
@@ -45,6 +50,9 @@ type MetricQuery struct {
 	// Aggregations to be performed for each metric. Check available aggregations in aggregation.go.
 	// If empty, default aggregation will be used (sum).
 	Aggregations metricapi.AggregationModes
+	// Window is the time window of the downloaded metrics. If zero, the default window of the metric
+	// client will be used.
+	Window time.Duration
 }
 
 // NewMetricQuery returns a metric query from provided settings.
@@ -55,6 +63,14 @@ func NewMetricQuery(metricNames []string, aggregations metricapi.AggregationMode
 	}
 }
 
+// NewMetricQueryWithWindow returns a metric query of the given time window from provided settings.
+func NewMetricQueryWithWindow(metricNames []string, aggregations metricapi.AggregationModes,
+	window time.Duration) *MetricQuery {
+	query := NewMetricQuery(metricNames, aggregations)
+	query.Window = window
+	return query
+}
+
 // SortQuery holds options for sort functionality of data select.
 type SortQuery struct {
 	SortByList []SortBy
@@ -0,0 +1,415 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// DeployAction describes what happened to an object deployed from file.
+type DeployAction string
+
+const (
+	DeployActionCreated   DeployAction = "created"
+	DeployActionUpdated   DeployAction = "updated"
+	DeployActionUnchanged DeployAction = "unchanged"
+)
+
+// DocumentStatus is the state of a document of a file after the deployment.
+type DocumentStatus string
+
+const (
+	// All objects of the document have been deployed.
+	DocumentDeployed DocumentStatus = "Deployed"
+	// Deploying an object of the document failed. Objects deployed before have been rolled back.
+	DocumentFailed DocumentStatus = "Failed"
+	// The document has been deployed, but was rolled back because a later document failed.
+	DocumentRolledBack DocumentStatus = "RolledBack"
+	// The document has been deployed, but rolling back at least one of its objects failed.
+	DocumentRollbackFailed DocumentStatus = "RollbackFailed"
+	// The document has not been deployed because an earlier document failed.
+	DocumentSkipped DocumentStatus = "Skipped"
+	// The document has been rendered, but not deployed. Only set for previews.
+	DocumentRendered DocumentStatus = "Rendered"
+)
+
+// DeployedObject is a single object deployed from file.
+type DeployedObject struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Name       string       `json:"name"`
+	Namespace  string       `json:"namespace"`
+	Action     DeployAction `json:"action"`
+
+	// Unified diff between the live object and the result of the dry-run. Only set for dry-runs.
+	Diff string `json:"diff,omitempty"`
+
+	// Error that occurred while the object was rolled back. The object is left as deployed then.
+	RollbackError string `json:"rollbackError,omitempty"`
+}
+
+// DeployedDocument summarizes the objects defined in a single document of the file. A document holds
+// more than one object if it is a list.
+type DeployedDocument struct {
+	// Index of the document in the file, starting at 0.
+	Index int `json:"index"`
+
+	Status DocumentStatus `json:"status"`
+
+	// Error that made the document fail.
+	Error string `json:"error,omitempty"`
+
+	Objects []DeployedObject `json:"objects"`
+
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// fileDeployer creates or applies the objects decoded from a file.
+type fileDeployer struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	spec   *AppDeploymentFromFileSpec
+
+	// Steps that undo the changes made so far, in the order they were made.
+	undo []undoStep
+}
+
+// undoStep reverts the deployment of a single object.
+type undoStep struct {
+	document int
+	object   int
+	revert   func() error
+}
+
+// decodeDocuments decodes all yaml or json documents of the content. Lists are expanded to their items.
+func decodeDocuments(content string) ([][]unstructured.Unstructured, error) {
+	d := yaml.NewYAMLOrJSONDecoder(strings.NewReader(content), 4096)
+	documents := make([][]unstructured.Unstructured, 0)
+	for {
+		data := unstructured.Unstructured{}
+		if err := d.Decode(&data); err != nil {
+			if err == io.EOF {
+				return documents, nil
+			}
+			return nil, err
+		}
+		// Skip empty documents, e.g. a trailing '---'.
+		if len(data.Object) == 0 {
+			continue
+		}
+
+		if !data.IsList() {
+			documents = append(documents, []unstructured.Unstructured{data})
+			continue
+		}
+
+		list, err := data.ToList()
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, list.Items)
+	}
+}
+
+// validateDocuments checks that every object can be identified before anything is deployed, so that
+// a broken document at the end of a file does not leave a partial deployment behind.
+func validateDocuments(documents [][]unstructured.Unstructured) error {
+	for i, objects := range documents {
+		for _, object := range objects {
+			if len(object.GetAPIVersion()) == 0 || len(object.GetKind()) == 0 {
+				return errors.NewInvalid(fmt.Sprintf("document %d: apiVersion and kind have to be set", i))
+			}
+			if _, err := schema.ParseGroupVersion(object.GetAPIVersion()); err != nil {
+				return errors.NewInvalid(fmt.Sprintf("document %d: %s", i, err.Error()))
+			}
+			if len(object.GetName()) == 0 && len(object.GetGenerateName()) == 0 {
+				return errors.NewInvalid(fmt.Sprintf("document %d: %s has no name", i, object.GetKind()))
+			}
+		}
+	}
+	return nil
+}
+
+// preview summarizes the documents without sending anything to the API server.
+func (self *fileDeployer) preview(documents [][]unstructured.Unstructured) []DeployedDocument {
+	result := make([]DeployedDocument, len(documents))
+	for i, objects := range documents {
+		result[i] = DeployedDocument{Index: i, Status: DocumentRendered, Objects: make([]DeployedObject, 0)}
+		for _, object := range objects {
+			namespace := object.GetNamespace()
+			if len(namespace) == 0 && self.spec.Namespace != "_all" {
+				namespace = self.spec.Namespace
+			}
+			result[i].Objects = append(result[i].Objects, DeployedObject{
+				APIVersion: object.GetAPIVersion(),
+				Kind:       object.GetKind(),
+				Name:       object.GetName(),
+				Namespace:  namespace,
+			})
+		}
+	}
+	return result
+}
+
+// deploy deploys all documents in order. Deployments are atomic: if an object fails, all objects
+// deployed before are deleted or restored to their previous state and the error is returned together
+// with the status of every document.
+func (self *fileDeployer) deploy(documents [][]unstructured.Unstructured) ([]DeployedDocument, error) {
+	result := make([]DeployedDocument, len(documents))
+	for i := range documents {
+		result[i] = DeployedDocument{Index: i, Status: DocumentSkipped, Objects: make([]DeployedObject, 0)}
+	}
+
+	for i, objects := range documents {
+		document := &result[i]
+		for j := range objects {
+			deployed, err := self.deployObject(i, len(document.Objects), &objects[j])
+			if err != nil {
+				err = errors.LocalizeError(err)
+				document.Status = DocumentFailed
+				document.Error = err.Error()
+				self.rollback(result)
+				return result, err
+			}
+
+			document.Objects = append(document.Objects, *deployed)
+			switch deployed.Action {
+			case DeployActionCreated:
+				document.Created++
+			case DeployActionUpdated:
+				document.Updated++
+			case DeployActionUnchanged:
+				document.Unchanged++
+			}
+		}
+		document.Status = DocumentDeployed
+	}
+	return result, nil
+}
+
+// rollback reverts all changes in reverse order. Rollback errors are recorded on the objects, so
+// that the user knows what has to be cleaned up manually.
+func (self *fileDeployer) rollback(result []DeployedDocument) {
+	for i := len(self.undo) - 1; i >= 0; i-- {
+		step := self.undo[i]
+		document := &result[step.document]
+		if err := step.revert(); err != nil {
+			object := &document.Objects[step.object]
+			log.Printf("Could not roll back %s %s/%s: %v", object.Kind, object.Namespace, object.Name, err)
+			object.RollbackError = err.Error()
+			if document.Status != DocumentFailed {
+				document.Status = DocumentRollbackFailed
+			}
+		} else if document.Status == DocumentDeployed {
+			document.Status = DocumentRolledBack
+		}
+	}
+	self.undo = nil
+}
+
+// deployObject creates the object. In apply mode existing objects are patched instead. Unless this is a
+// dry-run, a step that reverts the change is recorded with the given document and object index.
+func (self *fileDeployer) deployObject(documentIndex, objectIndex int, object *unstructured.Unstructured) (
+	*DeployedObject, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := self.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := ""
+	var client dynamic.ResourceInterface
+	switch mapping.Scope.Name() {
+	case meta.RESTScopeNameNamespace:
+		namespace = self.spec.Namespace
+		if strings.Compare(namespace, "_all") == 0 {
+			namespace = object.GetNamespace()
+		}
+		client = self.client.Resource(mapping.Resource).NamespaceWithMultiTenancy(namespace, self.spec.Tenant)
+	case meta.RESTScopeNameTenant:
+		client = self.client.Resource(mapping.Resource).NamespaceWithMultiTenancy("", self.spec.Tenant)
+	default:
+		client = self.client.Resource(mapping.Resource)
+	}
+
+	// In apply mode the configuration is stored on the object, so that later applies can compute a
+	// three-way merge. Like 'kubectl create', plain create mode leaves the object untouched.
+	if self.spec.Apply {
+		if err := setLastAppliedConfiguration(object); err != nil {
+			return nil, err
+		}
+	}
+
+	var dryRun []string
+	if self.spec.DryRun {
+		dryRun = []string{metaV1.DryRunAll}
+	}
+
+	result := &DeployedObject{
+		APIVersion: object.GetAPIVersion(),
+		Kind:       object.GetKind(),
+		Name:       object.GetName(),
+		Namespace:  namespace,
+	}
+
+	var live *unstructured.Unstructured
+	if self.spec.Apply && len(object.GetName()) > 0 {
+		live, err = client.Get(object.GetName(), metaV1.GetOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err != nil {
+			live = nil
+		}
+	}
+
+	if live == nil {
+		created, err := client.Create(object, metaV1.CreateOptions{DryRun: dryRun})
+		if err != nil {
+			return nil, err
+		}
+		result.Name = created.GetName()
+		result.Action = DeployActionCreated
+		if self.spec.DryRun {
+			result.Diff, err = diffObjects(nil, created)
+			return result, err
+		}
+
+		propagation := metaV1.DeletePropagationBackground
+		self.addUndoStep(documentIndex, objectIndex, func() error {
+			return client.Delete(created.GetName(), &metaV1.DeleteOptions{PropagationPolicy: &propagation})
+		})
+		return result, nil
+	}
+
+	patch, patchType, err := createApplyPatch(live, object)
+	if err != nil {
+		return nil, err
+	}
+	if string(patch) == "{}" {
+		result.Action = DeployActionUnchanged
+		return result, nil
+	}
+
+	log.Printf("Applying %s %s/%s with %s", object.GetKind(), namespace, object.GetName(), patchType)
+	patched, err := client.Patch(object.GetName(), patchType, patch, metaV1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		return nil, err
+	}
+	result.Action = DeployActionUpdated
+	if self.spec.DryRun {
+		result.Diff, err = diffObjects(live, patched)
+		return result, err
+	}
+
+	self.addUndoStep(documentIndex, objectIndex, func() error {
+		return restoreObject(client, live)
+	})
+	return result, nil
+}
+
+func (self *fileDeployer) addUndoStep(documentIndex, objectIndex int, revert func() error) {
+	self.undo = append(self.undo, undoStep{document: documentIndex, object: objectIndex, revert: revert})
+}
+
+// restoreObject replaces the current state of an object with a previous one.
+func restoreObject(client dynamic.ResourceInterface, previous *unstructured.Unstructured) error {
+	current, err := client.Get(previous.GetName(), metaV1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	restored := previous.DeepCopy()
+	restored.SetResourceVersion(current.GetResourceVersion())
+	_, err = client.Update(restored, metaV1.UpdateOptions{})
+	return err
+}
+
+// setLastAppliedConfiguration stores the configuration of the object in the annotation kubectl uses.
+func setLastAppliedConfiguration(object *unstructured.Unstructured) error {
+	config := object.DeepCopy()
+	annotations := config.GetAnnotations()
+	delete(annotations, api.LastAppliedConfigAnnotation)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(config.Object, "metadata", "annotations")
+	} else {
+		config.SetAnnotations(annotations)
+	}
+
+	data, err := config.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	annotations = object.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[api.LastAppliedConfigAnnotation] = strings.TrimSpace(string(data))
+	object.SetAnnotations(annotations)
+	return nil
+}
+
+// createApplyPatch computes a three-way merge patch between the last applied configuration, the new
+// configuration and the live object. Built-in kinds get a strategic merge patch, so that lists like
+// containers are merged by key. Other kinds, e.g. custom resources, get a JSON merge patch.
+func createApplyPatch(live, modified *unstructured.Unstructured) ([]byte, types.PatchType, error) {
+	original := []byte(live.GetAnnotations()[api.LastAppliedConfigAnnotation])
+	current, err := live.MarshalJSON()
+	if err != nil {
+		return nil, "", err
+	}
+	desired, err := modified.MarshalJSON()
+	if err != nil {
+		return nil, "", err
+	}
+
+	versioned, err := scheme.Scheme.New(modified.GroupVersionKind())
+	if runtime.IsNotRegisteredError(err) {
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, desired, current)
+		return patch, types.MergePatchType, err
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	patchMeta, err := strategicpatch.NewPatchMetaFromStruct(versioned)
+	if err != nil {
+		return nil, "", err
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, desired, current, patchMeta, true)
+	return patch, types.StrategicMergePatchType, err
+}
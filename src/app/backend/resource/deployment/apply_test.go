@@ -0,0 +1,317 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newFakeMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "example.com", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}, meta.RESTScopeRoot)
+	return mapper
+}
+
+const widgetsFile = `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: first
+spec:
+  size: 1
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: example.com/v1
+  kind: Widget
+  metadata:
+    name: second
+  spec:
+    size: 2
+- apiVersion: example.com/v1
+  kind: Widget
+  metadata:
+    name: third
+  spec:
+    size: 3
+---
+`
+
+func TestDecodeDocuments(t *testing.T) {
+	documents, err := decodeDocuments(widgetsFile)
+	if err != nil {
+		t.Fatalf("decodeDocuments() returned unexpected error: %v", err)
+	}
+
+	names := make([][]string, 0)
+	for _, objects := range documents {
+		document := make([]string, 0)
+		for _, object := range objects {
+			document = append(document, object.GetName())
+		}
+		names = append(names, document)
+	}
+
+	expected := [][]string{{"first"}, {"second", "third"}}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("decodeDocuments() == %v, expected %v", names, expected)
+	}
+}
+
+func TestValidateDocuments(t *testing.T) {
+	cases := []struct {
+		content string
+		valid   bool
+	}{
+		{widgetsFile, true},
+		{"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  generateName: config-\n", true},
+		{"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  labels:\n    app: test\n", false},
+		{"kind: ConfigMap\nmetadata:\n  name: config\n", false},
+	}
+	for _, c := range cases {
+		documents, err := decodeDocuments(c.content)
+		if err != nil {
+			t.Fatalf("decodeDocuments(%q) returned unexpected error: %v", c.content, err)
+		}
+		err = validateDocuments(documents)
+		if (err == nil) != c.valid {
+			t.Errorf("validateDocuments(%q) == %v, expected valid: %v", c.content, err, c.valid)
+		}
+	}
+}
+
+func newWidget(name string, size int64) *unstructured.Unstructured {
+	widget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"size": size},
+	}}
+	// Like objects deployed from a file, the applied configuration has no namespace.
+	if err := setLastAppliedConfiguration(widget); err != nil {
+		panic(err)
+	}
+	widget.SetNamespace("default")
+	widget.SetTenant("tenant")
+	return widget
+}
+
+func TestFileDeployerApply(t *testing.T) {
+	documents, err := decodeDocuments(widgetsFile)
+	if err != nil {
+		t.Fatalf("decodeDocuments() returned unexpected error: %v", err)
+	}
+
+	// 'first' is unchanged, 'second' has a different size and 'third' does not exist yet.
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), newWidget("first", 1), newWidget("second", 5))
+	deployer := &fileDeployer{
+		client: client,
+		mapper: newFakeMapper(),
+		spec:   &AppDeploymentFromFileSpec{Namespace: "default", Tenant: "tenant", Apply: true},
+	}
+
+	result, err := deployer.deploy(documents)
+	if err != nil {
+		t.Fatalf("deploy() returned unexpected error: %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(result))
+	}
+	if result[0].Unchanged != 1 || result[1].Updated != 1 || result[1].Created != 1 {
+		t.Errorf("unexpected summary: %+v", result)
+	}
+
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	second, err := client.Resource(gvr).NamespaceWithMultiTenancy("default", "tenant").Get("second", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get updated widget: %v", err)
+	}
+	if size, _, _ := unstructured.NestedInt64(second.Object, "spec", "size"); size != 2 {
+		t.Errorf("expected updated size 2, got %d", size)
+	}
+}
+
+func TestFileDeployerScope(t *testing.T) {
+	documents, err := decodeDocuments("apiVersion: example.com/v1\nkind: Gadget\nmetadata:\n  name: gadget\n")
+	if err != nil {
+		t.Fatalf("decodeDocuments() returned unexpected error: %v", err)
+	}
+
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+	deployer := &fileDeployer{
+		client: client,
+		mapper: newFakeMapper(),
+		spec:   &AppDeploymentFromFileSpec{Namespace: "default", Tenant: "tenant"},
+	}
+
+	result, err := deployer.deploy(documents)
+	if err != nil {
+		t.Fatalf("deploy() returned unexpected error: %v", err)
+	}
+	if result[0].Objects[0].Namespace != "" {
+		t.Errorf("expected cluster scoped object without namespace, got %+v", result[0].Objects[0])
+	}
+
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "gadgets"}
+	gadget, err := client.Resource(gvr).Get("gadget", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get cluster scoped gadget: %v", err)
+	}
+	if _, ok := gadget.GetAnnotations()[api.LastAppliedConfigAnnotation]; ok {
+		t.Errorf("expected no last applied configuration in create mode, got %v", gadget.GetAnnotations())
+	}
+
+	unknown, err := decodeDocuments("apiVersion: example.com/v1\nkind: Gizmo\nmetadata:\n  name: gizmo\n")
+	if err != nil {
+		t.Fatalf("decodeDocuments() returned unexpected error: %v", err)
+	}
+	if _, err := deployer.deploy(unknown); !meta.IsNoMatchError(err) {
+		t.Errorf("expected no match error for unknown kind, got %v", err)
+	}
+}
+
+func TestFileDeployerRollback(t *testing.T) {
+	documents, err := decodeDocuments(widgetsFile)
+	if err != nil {
+		t.Fatalf("decodeDocuments() returned unexpected error: %v", err)
+	}
+
+	// 'first' is created and 'second' is updated, then creating 'third' fails.
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), newWidget("second", 5))
+	client.PrependReactor("create", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		object := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if object.GetName() == "third" {
+			return true, nil, fmt.Errorf("quota exceeded")
+		}
+		return false, nil, nil
+	})
+	deployer := &fileDeployer{
+		client: client,
+		mapper: newFakeMapper(),
+		spec:   &AppDeploymentFromFileSpec{Namespace: "default", Tenant: "tenant", Apply: true},
+	}
+
+	result, err := deployer.deploy(documents)
+	if err == nil {
+		t.Fatalf("expected deploy() to fail")
+	}
+
+	statuses := []DocumentStatus{result[0].Status, result[1].Status}
+	expected := []DocumentStatus{DocumentRolledBack, DocumentFailed}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected document statuses %v, got %v", expected, statuses)
+	}
+	if result[1].Error != "quota exceeded" || result[1].Updated != 1 {
+		t.Errorf("unexpected failed document: %+v", result[1])
+	}
+
+	widgets := client.Resource(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}).
+		NamespaceWithMultiTenancy("default", "tenant")
+	if _, err := widgets.Get("first", metaV1.GetOptions{}); !k8serrors.IsNotFound(err) {
+		t.Errorf("expected created widget to be deleted, got %v", err)
+	}
+	second, err := widgets.Get("second", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get restored widget: %v", err)
+	}
+	if size, _, _ := unstructured.NestedInt64(second.Object, "spec", "size"); size != 5 {
+		t.Errorf("expected widget to be restored to size 5, got %d", size)
+	}
+}
+
+func TestFileDeployerRollbackError(t *testing.T) {
+	documents, err := decodeDocuments(widgetsFile)
+	if err != nil {
+		t.Fatalf("decodeDocuments() returned unexpected error: %v", err)
+	}
+
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), newWidget("second", 2))
+	client.PrependReactor("delete", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("forbidden")
+	})
+	deployer := &fileDeployer{
+		client: client,
+		mapper: newFakeMapper(),
+		spec:   &AppDeploymentFromFileSpec{Namespace: "default", Tenant: "tenant"},
+	}
+
+	// Creating 'second' fails, because it already exists.
+	result, err := deployer.deploy(documents)
+	if err == nil {
+		t.Fatalf("expected deploy() to fail")
+	}
+	if result[0].Status != DocumentRollbackFailed || result[0].Objects[0].RollbackError != "forbidden" {
+		t.Errorf("expected failed rollback to be reported, got %+v", result[0])
+	}
+}
+
+func TestCreateApplyPatch(t *testing.T) {
+	newConfigMap := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "config"},
+			"data":       data,
+		}}
+	}
+
+	live := newConfigMap(map[string]interface{}{"a": "1", "b": "2"})
+	if err := setLastAppliedConfiguration(live); err != nil {
+		t.Fatal(err)
+	}
+	// Added by another client, so it has to survive the apply.
+	unstructured.SetNestedField(live.Object, "external", "data", "c")
+
+	modified := newConfigMap(map[string]interface{}{"a": "1", "b": "3"})
+	if err := setLastAppliedConfiguration(modified); err != nil {
+		t.Fatal(err)
+	}
+
+	patch, patchType, err := createApplyPatch(live, modified)
+	if err != nil {
+		t.Fatalf("createApplyPatch() returned unexpected error: %v", err)
+	}
+	if patchType != types.StrategicMergePatchType {
+		t.Errorf("expected strategic merge patch for built-in kind, got %s", patchType)
+	}
+	if !strings.Contains(string(patch), `"b":"3"`) || strings.Contains(string(patch), `"c"`) {
+		t.Errorf("unexpected patch %s", patch)
+	}
+
+	unchanged, _, err := createApplyPatch(live, live)
+	if err != nil {
+		t.Fatalf("createApplyPatch() returned unexpected error: %v", err)
+	}
+	if string(unchanged) != "{}" {
+		t.Errorf("expected empty patch for unchanged object, got %s", unchanged)
+	}
+}
@@ -44,6 +44,7 @@ func (self DeploymentCell) GetProperty(name dataselect.PropertyName) dataselect.
 
 func (self DeploymentCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindDeployment,
 		ResourceName: self.ObjectMeta.Name,
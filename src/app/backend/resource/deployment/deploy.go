@@ -16,21 +16,16 @@ package deployment
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"strings"
 
-	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
 	apps "k8s.io/api/apps/v1"
 	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/rand"
-	"k8s.io/apimachinery/pkg/util/yaml"
-	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -91,6 +86,24 @@ type AppDeploymentSpec struct {
 	RunAsPrivileged bool `json:"runAsPrivileged"`
 
 	Tenant string `json:"tenant"`
+
+	// Optional check whether the container is alive. The container is restarted if it fails.
+	LivenessProbe *ProbeSpec `json:"livenessProbe"`
+
+	// Optional check whether the container is ready to serve traffic.
+	ReadinessProbe *ProbeSpec `json:"readinessProbe"`
+
+	// Volumes mounted into the container.
+	Volumes []VolumeSpec `json:"volumes"`
+
+	// Names of secrets whose keys are exposed as environment variables of the container.
+	EnvFromSecrets []string `json:"envFromSecrets"`
+
+	// Labels that nodes have to match for the pods to be scheduled on them.
+	NodeSelector []Label `json:"nodeSelector"`
+
+	// Tolerations of the pods for node taints.
+	Tolerations []api.Toleration `json:"tolerations"`
 }
 
 // AppDeploymentFromFileSpec is a specification for deployment from file
@@ -109,6 +122,26 @@ type AppDeploymentFromFileSpec struct {
 
 	// Whether validate content before creation or not
 	Validate bool `json:"validate"`
+
+	// Whether existing objects should be updated with a three-way merge, the same way 'kubectl apply'
+	// does. Objects are only created otherwise.
+	Apply bool `json:"apply"`
+
+	// Whether the content should only be sent to the API server as a dry-run. Nothing is persisted and
+	// the response contains a diff against the live state of every object.
+	DryRun bool `json:"dryRun"`
+
+	// Base64 encoded tar, gzipped tar or zip archive of a kustomization. If set, the kustomization is
+	// rendered and the result replaces the content.
+	Kustomization []byte `json:"kustomization,omitempty"`
+
+	// Directory of the kustomization to render within the archive, e.g. an overlay. Defaults to the
+	// root of the archive.
+	KustomizationPath string `json:"kustomizationPath,omitempty"`
+
+	// Whether the content should only be rendered. Nothing is sent to the API server and the response
+	// contains the rendered manifests.
+	Preview bool `json:"preview"`
 }
 
 // AppDeploymentFromFileResponse is a specification for deployment from file
@@ -121,6 +154,15 @@ type AppDeploymentFromFileResponse struct {
 
 	// Error after create resource
 	Error string `json:"error"`
+
+	// Whether the content was only sent as a dry-run.
+	DryRun bool `json:"dryRun"`
+
+	// Whether the content was only rendered. Content holds the rendered manifests then.
+	Preview bool `json:"preview"`
+
+	// Objects that have been deployed, grouped by the document of the file they were defined in.
+	Documents []DeployedDocument `json:"documents"`
 }
 
 // PortMapping is a specification of port mapping for an application deployment.
@@ -201,37 +243,39 @@ func DeployApp(spec *AppDeploymentSpec, client client.Interface) error {
 	if spec.MemoryRequirement != nil {
 		containerSpec.Resources.Requests[api.ResourceMemory] = *spec.MemoryRequirement
 	}
+
+	var err error
+	if containerSpec.LivenessProbe, err = convertProbeSpec(spec.LivenessProbe); err != nil {
+		return err
+	}
+	if containerSpec.ReadinessProbe, err = convertProbeSpec(spec.ReadinessProbe); err != nil {
+		return err
+	}
+	volumes, volumeMounts, err := convertVolumeSpecs(spec.Volumes)
+	if err != nil {
+		return err
+	}
+	containerSpec.VolumeMounts = volumeMounts
+	containerSpec.EnvFrom = convertEnvFromSecrets(spec.EnvFromSecrets)
+
 	podSpec := api.PodSpec{
-		Containers: []api.Container{containerSpec},
+		Containers:  []api.Container{containerSpec},
+		Volumes:     volumes,
+		Tolerations: spec.Tolerations,
+	}
+	if len(spec.NodeSelector) > 0 {
+		podSpec.NodeSelector = getLabelsMap(spec.NodeSelector)
 	}
 	if spec.ImagePullSecret != nil {
 		podSpec.ImagePullSecrets = []api.LocalObjectReference{{Name: *spec.ImagePullSecret}}
 	}
 
-	podTemplate := api.PodTemplateSpec{
-		ObjectMeta: objectMeta,
-		Spec:       podSpec,
-	}
-
-	deployment := &apps.Deployment{
-		ObjectMeta: objectMeta,
-		Spec: apps.DeploymentSpec{
-			Replicas: &spec.Replicas,
-			Template: podTemplate,
-			Selector: &metaV1.LabelSelector{
-				// Quoting from https://kubernetes.io/docs/concepts/workloads/controllers/deployment/#selector:
-				// In API version apps/v1beta2, .spec.selector and .metadata.labels no longer default to
-				// .spec.template.metadata.labels if not set. So they must be set explicitly.
-				// Also note that .spec.selector is immutable after creation of the Deployment in apps/v1beta2.
-				MatchLabels: labels,
-			},
-		},
-	}
+	deployment := newDeployment(objectMeta, spec.Replicas, podSpec)
 	if spec.Tenant == "" {
 		spec.Tenant = "system"
 	}
 	log.Printf("Tenant : %s", spec.Tenant)
-	_, err := client.AppsV1().DeploymentsWithMultiTenancy(spec.Namespace, spec.Tenant).Create(deployment)
+	_, err = client.AppsV1().DeploymentsWithMultiTenancy(spec.Namespace, spec.Tenant).Create(deployment)
 
 	if err != nil {
 		return err
@@ -272,6 +316,28 @@ func DeployApp(spec *AppDeploymentSpec, client client.Interface) error {
 	return nil
 }
 
+// newDeployment returns a deployment that maintains the given number of pods with the pod spec. The
+// pods are selected by the labels of the object meta.
+func newDeployment(objectMeta metaV1.ObjectMeta, replicas int32, podSpec api.PodSpec) *apps.Deployment {
+	return &apps.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: apps.DeploymentSpec{
+			Replicas: &replicas,
+			Template: api.PodTemplateSpec{
+				ObjectMeta: objectMeta,
+				Spec:       podSpec,
+			},
+			Selector: &metaV1.LabelSelector{
+				// Quoting from https://kubernetes.io/docs/concepts/workloads/controllers/deployment/#selector:
+				// In API version apps/v1beta2, .spec.selector and .metadata.labels no longer default to
+				// .spec.template.metadata.labels if not set. So they must be set explicitly.
+				// Also note that .spec.selector is immutable after creation of the Deployment in apps/v1beta2.
+				MatchLabels: objectMeta.Labels,
+			},
+		},
+	}
+}
+
 // GetAvailableProtocols returns list of available protocols. Currently it is TCP and UDP.
 func GetAvailableProtocols() *Protocols {
 	return &Protocols{Protocols: []api.Protocol{api.ProtocolTCP, api.ProtocolUDP}}
@@ -311,68 +377,50 @@ func getLabelsMap(labels []Label) map[string]string {
 	return result
 }
 
-// DeployAppFromFile deploys an app based on the given yaml or json file.
-func DeployAppFromFile(cfg *rest.Config, spec *AppDeploymentFromFileSpec) (bool, error) {
-	reader := strings.NewReader(spec.Content)
-	log.Printf("Namespace for deploy from file: %s\n", spec.Namespace)
-	d := yaml.NewYAMLOrJSONDecoder(reader, 4096)
-	for {
-		data := unstructured.Unstructured{}
-		if err := d.Decode(&data); err != nil {
-			if err == io.EOF {
-				return true, nil
-			}
-			return false, err
-		}
-
-		version := data.GetAPIVersion()
-		kind := data.GetKind()
-		gv, err := schema.ParseGroupVersion(version)
-		if err != nil {
-			gv = schema.GroupVersion{Version: version}
-		}
-
-		discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
-		if err != nil {
-			return false, err
-		}
+// DeployAppFromFile deploys an app based on the given yaml or json file. All documents are decoded
+// before the first object is sent to the API server. The deployment is atomic: if an object fails,
+// the changes made so far are rolled back and the status of every document is returned with the error.
+// Kinds are mapped to resources with the given mapper.
+func DeployAppFromFile(cfg *rest.Config, mapper meta.RESTMapper, spec *AppDeploymentFromFileSpec) (
+	[]DeployedDocument, error) {
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return DeployAppFromFileWithClient(dynamicClient, mapper, spec)
+}
 
-		apiResourceList, err := discoveryClient.ServerResourcesForGroupVersion(version)
-		if err != nil {
-			return false, err
-		}
-		apiResources := apiResourceList.APIResources
-		var resource *metaV1.APIResource
-		for _, apiResource := range apiResources {
-			if apiResource.Kind == kind && !strings.Contains(apiResource.Name, "/") {
-				resource = &apiResource
-				break
-			}
-		}
-		// TODO currently api-resources gives empty list so concatenating kind with s
-		resourceName := strings.ToLower(kind) + "s"
-
-		//if resource == nil {
-		//	return false, fmt.Errorf("unknown resource kind: %s", kind)
-		//}
-		if resource != nil {
-			resourceName = resource.Name
-		}
-		dynamicClient, err := dynamic.NewForConfig(cfg)
+// DeployAppFromFileWithClient deploys an app based on the given yaml or json file with the given
+// dynamic client. See DeployAppFromFile.
+func DeployAppFromFileWithClient(client dynamic.Interface, mapper meta.RESTMapper, spec *AppDeploymentFromFileSpec) (
+	[]DeployedDocument, error) {
+	log.Printf("Namespace for deploy from file: %s\n", spec.Namespace)
+	if len(spec.Kustomization) > 0 {
+		content, err := renderKustomization(spec.Kustomization, spec.KustomizationPath)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
+		spec.Content = content
+	}
 
-		groupVersionResource := schema.GroupVersionResource{Group: gv.Group, Version: gv.Version, Resource: resourceName}
+	documents, err := decodeDocuments(spec.Content)
+	if err != nil {
+		return nil, err
+	}
 
-		if strings.Compare(spec.Namespace, "_all") == 0 {
-			_, err = dynamicClient.Resource(groupVersionResource).NamespaceWithMultiTenancy(data.GetNamespace(), spec.Tenant).Create(&data, metaV1.CreateOptions{})
-		} else {
-			_, err = dynamicClient.Resource(groupVersionResource).NamespaceWithMultiTenancy(spec.Namespace, spec.Tenant).Create(&data, metaV1.CreateOptions{})
+	if spec.Validate {
+		if err := validateDocuments(documents); err != nil {
+			return nil, err
 		}
+	}
 
-		if err != nil {
-			return false, errors.LocalizeError(err)
-		}
+	deployer := &fileDeployer{
+		client: client,
+		mapper: mapper,
+		spec:   spec,
+	}
+	if spec.Preview {
+		return deployer.preview(documents), nil
 	}
+	return deployer.deploy(documents)
 }
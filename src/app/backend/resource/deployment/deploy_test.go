@@ -186,3 +186,118 @@ func TestGetAvailableProtocols(t *testing.T) {
 			expected, actual)
 	}
 }
+
+func TestDeployAppPodSpec(t *testing.T) {
+	spec := &AppDeploymentSpec{
+		Namespace:      "foo-namespace",
+		Name:           "foo-name",
+		LivenessProbe:  &ProbeSpec{Type: ProbeHTTP, Port: 8080, PeriodSeconds: 5},
+		ReadinessProbe: &ProbeSpec{Type: ProbeExec, Command: []string{"cat", "/ready"}},
+		Volumes: []VolumeSpec{
+			{Type: VolumePersistentVolumeClaim, Source: "data", MountPath: "/data"},
+			{Name: "config", Type: VolumeConfigMap, Source: "foo-config", MountPath: "/etc/foo", ReadOnly: true},
+			{Name: "config", Type: VolumeConfigMap, Source: "foo-config", MountPath: "/etc/bar", SubPath: "bar"},
+		},
+		EnvFromSecrets: []string{"foo-secret"},
+		NodeSelector:   []Label{{Key: "disk", Value: "ssd"}},
+		Tolerations:    []api.Toleration{{Key: "dedicated", Operator: api.TolerationOpExists}},
+	}
+	testClient := fake.NewSimpleClientset()
+
+	if err := DeployApp(spec, testClient); err != nil {
+		t.Fatalf("DeployApp() returned unexpected error: %v", err)
+	}
+
+	podSpec := testClient.Actions()[0].(core.CreateActionImpl).GetObject().(*apps.Deployment).Spec.Template.Spec
+	container := podSpec.Containers[0]
+	if container.LivenessProbe.HTTPGet == nil || container.LivenessProbe.HTTPGet.Path != "/" ||
+		container.LivenessProbe.HTTPGet.Port.IntVal != 8080 || container.LivenessProbe.PeriodSeconds != 5 {
+		t.Errorf("unexpected liveness probe %#v", container.LivenessProbe)
+	}
+	if container.ReadinessProbe.Exec == nil || !reflect.DeepEqual(container.ReadinessProbe.Exec.Command, []string{"cat", "/ready"}) {
+		t.Errorf("unexpected readiness probe %#v", container.ReadinessProbe)
+	}
+
+	if len(podSpec.Volumes) != 2 || podSpec.Volumes[0].PersistentVolumeClaim.ClaimName != "data" ||
+		podSpec.Volumes[1].ConfigMap.Name != "foo-config" {
+		t.Errorf("unexpected volumes %#v", podSpec.Volumes)
+	}
+	expectedMounts := []api.VolumeMount{
+		{Name: "data", MountPath: "/data"},
+		{Name: "config", MountPath: "/etc/foo", ReadOnly: true},
+		{Name: "config", MountPath: "/etc/bar", SubPath: "bar"},
+	}
+	if !reflect.DeepEqual(container.VolumeMounts, expectedMounts) {
+		t.Errorf("Expected volume mounts %#v but got %#v", expectedMounts, container.VolumeMounts)
+	}
+
+	if len(container.EnvFrom) != 1 || container.EnvFrom[0].SecretRef.Name != "foo-secret" {
+		t.Errorf("unexpected env from %#v", container.EnvFrom)
+	}
+	if !reflect.DeepEqual(podSpec.NodeSelector, map[string]string{"disk": "ssd"}) {
+		t.Errorf("unexpected node selector %#v", podSpec.NodeSelector)
+	}
+	if !reflect.DeepEqual(podSpec.Tolerations, spec.Tolerations) {
+		t.Errorf("unexpected tolerations %#v", podSpec.Tolerations)
+	}
+}
+
+func TestDeployAppInvalidPodSpec(t *testing.T) {
+	cases := []*AppDeploymentSpec{
+		{Name: "foo-name", LivenessProbe: &ProbeSpec{Type: ProbeTCP}},
+		{Name: "foo-name", ReadinessProbe: &ProbeSpec{Type: "Unknown"}},
+		{Name: "foo-name", Volumes: []VolumeSpec{{Type: VolumeSecret, MountPath: "/secret"}}},
+		{Name: "foo-name", Volumes: []VolumeSpec{{Type: "HostPath", Source: "/", MountPath: "/host"}}},
+	}
+	for _, spec := range cases {
+		testClient := fake.NewSimpleClientset()
+		if err := DeployApp(spec, testClient); err == nil {
+			t.Errorf("Expected error for spec %#v", spec)
+		}
+		if len(testClient.Actions()) != 0 {
+			t.Errorf("Expected no actions for invalid spec but got %#v", testClient.Actions())
+		}
+	}
+}
+
+func TestDeployVirtualMachine(t *testing.T) {
+	memory := resource.MustParse("1Gi")
+	spec := &VirtualMachineDeploymentSpec{
+		Namespace:         "foo-namespace",
+		Name:              "foo-vm",
+		Image:             "download.cirros-cloud.net/0.3.5/cirros-0.3.5-x86_64-disk.img",
+		Replicas:          2,
+		KeyPairName:       "foo-key",
+		MemoryRequirement: &memory,
+		Nics:              []api.Nic{{SubnetName: "foo-subnet"}},
+	}
+	testClient := fake.NewSimpleClientset()
+
+	if err := DeployVirtualMachine(spec, testClient); err != nil {
+		t.Fatalf("DeployVirtualMachine() returned unexpected error: %v", err)
+	}
+
+	deployment := testClient.Actions()[0].(core.CreateActionImpl).GetObject().(*apps.Deployment)
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("Expected 2 replicas but got %d", *deployment.Spec.Replicas)
+	}
+	podSpec := deployment.Spec.Template.Spec
+	vm := podSpec.VirtualMachine
+	if vm == nil || len(podSpec.Containers) != 0 {
+		t.Fatalf("Expected a virtual machine workload but got %#v", podSpec)
+	}
+	if vm.Name != "foo-vm" || vm.Image != spec.Image || vm.KeyPairName != "foo-key" ||
+		vm.PowerSpec != api.VmPowerSpecRunning {
+		t.Errorf("unexpected virtual machine %#v", vm)
+	}
+	if limit := vm.Resources.Limits[api.ResourceMemory]; limit.Cmp(memory) != 0 {
+		t.Errorf("Expected memory limit %v but got %v", memory, limit)
+	}
+	if !reflect.DeepEqual(podSpec.Nics, spec.Nics) {
+		t.Errorf("unexpected nics %#v", podSpec.Nics)
+	}
+
+	if err := DeployVirtualMachine(&VirtualMachineDeploymentSpec{Name: "foo-vm", Image: "foo"}, testClient); err == nil {
+		t.Error("Expected error for virtual machine without key")
+	}
+}
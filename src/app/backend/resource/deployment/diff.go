@@ -0,0 +1,76 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// Fields that are maintained by the API server and would only add noise to a diff.
+var ignoredDiffFields = [][]string{
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "managedFields"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "uid"},
+	{"metadata", "hashKey"},
+	{"status"},
+}
+
+// diffObjects returns a unified diff of the yaml representation of both objects. A nil live object
+// means that the object is going to be created.
+func diffObjects(live, result *unstructured.Unstructured) (string, error) {
+	before, err := toDiffYAML(live)
+	if err != nil {
+		return "", err
+	}
+	after, err := toDiffYAML(result)
+	if err != nil {
+		return "", err
+	}
+	return common.UnifiedDiff("live", "dry-run", before, after), nil
+}
+
+func toDiffYAML(object *unstructured.Unstructured) ([]string, error) {
+	if object == nil {
+		return []string{}, nil
+	}
+
+	object = object.DeepCopy()
+	for _, field := range ignoredDiffFields {
+		unstructured.RemoveNestedField(object.Object, field...)
+	}
+	annotations := object.GetAnnotations()
+	if _, ok := annotations[api.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, api.LastAppliedConfigAnnotation)
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(object.Object, "metadata", "annotations")
+		} else {
+			object.SetAnnotations(annotations)
+		}
+	}
+
+	data, err := yaml.Marshal(object.Object)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/yaml"
+)
+
+// MaxKustomizationSize is the maximum size of the uncompressed files of a kustomization archive.
+const MaxKustomizationSize = 20 * 1024 * 1024
+
+// Directory of the in-memory file system the kustomization archive is extracted to.
+const kustomizationRoot = "/kustomization"
+
+// Names of the files that define a kustomization.
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// renderKustomization extracts the archive into memory and renders the kustomization in the given
+// directory of the archive, e.g. an overlay. The rendered objects are returned as YAML documents.
+func renderKustomization(archive []byte, dir string) (string, error) {
+	fSys := filesys.MakeFsInMemory()
+	if err := extractArchive(fSys, archive); err != nil {
+		return "", err
+	}
+	if err := checkKustomizationResources(fSys); err != nil {
+		return "", err
+	}
+
+	resources, err := krusty.MakeKustomizer(fSys, krusty.MakeDefaultOptions()).
+		Run(path.Join(kustomizationRoot, path.Clean("/"+dir)))
+	if err != nil {
+		return "", errors.NewInvalid(fmt.Sprintf("Could not render kustomization: %s", err.Error()))
+	}
+
+	data, err := resources.AsYaml()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// extractArchive writes the files of a zip, tar or gzipped tar archive to the file system.
+func extractArchive(fSys filesys.FileSystem, archive []byte) error {
+	files := make(map[string][]byte)
+	var err error
+	switch {
+	case bytes.HasPrefix(archive, []byte("PK\x03\x04")):
+		err = readZipArchive(archive, files)
+	case bytes.HasPrefix(archive, []byte{0x1f, 0x8b}):
+		var reader *gzip.Reader
+		if reader, err = gzip.NewReader(bytes.NewReader(archive)); err == nil {
+			err = readTarArchive(reader, files)
+		}
+	default:
+		err = readTarArchive(bytes.NewReader(archive), files)
+	}
+	if err != nil {
+		return errors.NewInvalid(fmt.Sprintf("Could not read kustomization archive: %s", err.Error()))
+	}
+
+	for name, data := range files {
+		if err := fSys.WriteFile(path.Join(kustomizationRoot, name), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTarArchive(archive io.Reader, files map[string][]byte) error {
+	reader := tar.NewReader(archive)
+	size := int64(0)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if size += header.Size; size > MaxKustomizationSize {
+			return fmt.Errorf("archive is larger than %d bytes", MaxKustomizationSize)
+		}
+		if err := addArchiveFile(files, header.Name, reader); err != nil {
+			return err
+		}
+	}
+}
+
+func readZipArchive(archive []byte, files map[string][]byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+
+	size := uint64(0)
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		if size += file.UncompressedSize64; size > MaxKustomizationSize {
+			return fmt.Errorf("archive is larger than %d bytes", MaxKustomizationSize)
+		}
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = addArchiveFile(files, file.Name, io.LimitReader(content, int64(file.UncompressedSize64)))
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addArchiveFile(files map[string][]byte, name string, content io.Reader) error {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	if name == "/" {
+		return nil
+	}
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	files[name] = data
+	return nil
+}
+
+// kustomizationReferences are the fields of a kustomization that reference other kustomizations.
+type kustomizationReferences struct {
+	Resources  []string `json:"resources"`
+	Bases      []string `json:"bases"`
+	Components []string `json:"components"`
+}
+
+// checkKustomizationResources makes sure that all kustomizations only reference files of the archive.
+// Kustomize would clone remote bases with git, which the dashboard must not do on behalf of users.
+func checkKustomizationResources(fSys filesys.FileSystem) error {
+	return fSys.Walk(kustomizationRoot, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !isKustomizationFile(name) {
+			return err
+		}
+
+		data, err := fSys.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var references kustomizationReferences
+		if err := yaml.Unmarshal(data, &references); err != nil {
+			return errors.NewInvalid(fmt.Sprintf("Could not parse %s: %s",
+				strings.TrimPrefix(name, kustomizationRoot+"/"), err.Error()))
+		}
+
+		dir := path.Dir(name)
+		all := append(append(references.Resources, references.Bases...), references.Components...)
+		for _, reference := range all {
+			if isRemoteReference(reference) || !fSys.Exists(path.Join(dir, reference)) {
+				return errors.NewInvalid(fmt.Sprintf("Resource %q of %s is not part of the archive", reference,
+					strings.TrimPrefix(name, kustomizationRoot+"/")))
+			}
+		}
+		return nil
+	})
+}
+
+func isKustomizationFile(name string) bool {
+	for _, fileName := range kustomizationFileNames {
+		if path.Base(name) == fileName {
+			return true
+		}
+	}
+	return false
+}
+
+func isRemoteReference(reference string) bool {
+	for _, prefix := range []string{"git@", "git::", "gh:", "github.com"} {
+		if strings.HasPrefix(reference, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(reference, "://") || strings.Contains(reference, "_git/")
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var widgetsKustomization = map[string]string{
+	"base/kustomization.yaml": "resources:\n- widget.yaml\n",
+	"base/widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  size: 1
+`,
+	"overlays/prod/kustomization.yaml": `resources:
+- ../../base
+namePrefix: prod-
+commonLabels:
+  env: prod
+`,
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newTarArchive(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range sortedNames(files) {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func newZipArchive(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buffer)
+	for _, name := range sortedNames(files) {
+		writer, err := zipWriter.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestRenderKustomization(t *testing.T) {
+	cases := []struct {
+		archive   []byte
+		dir       string
+		contained []string
+	}{
+		{newTarArchive(t, widgetsKustomization), "overlays/prod", []string{"name: prod-widget", "env: prod"}},
+		{newZipArchive(t, widgetsKustomization), "overlays/prod/", []string{"name: prod-widget", "env: prod"}},
+		{newZipArchive(t, widgetsKustomization), "base", []string{"name: widget", "size: 1"}},
+	}
+	for _, c := range cases {
+		rendered, err := renderKustomization(c.archive, c.dir)
+		if err != nil {
+			t.Fatalf("renderKustomization(%q) returned unexpected error: %v", c.dir, err)
+		}
+		for _, text := range c.contained {
+			if !strings.Contains(rendered, text) {
+				t.Errorf("renderKustomization(%q): expected %q in:\n%s", c.dir, text, rendered)
+			}
+		}
+	}
+}
+
+func TestRenderKustomizationErrors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"remote base": {
+			"kustomization.yaml": "resources:\n- github.com/example/widgets//base?ref=v1\n",
+		},
+		"missing resource": {
+			"kustomization.yaml": "resources:\n- ../outside.yaml\n",
+		},
+		"no kustomization": {
+			"widget.yaml": "kind: Widget\n",
+		},
+	}
+	for name, files := range cases {
+		if _, err := renderKustomization(newTarArchive(t, files), ""); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := renderKustomization([]byte("not an archive"), ""); err == nil {
+		t.Error("expected error for invalid archive")
+	}
+}
+
+func TestDeployKustomizationPreview(t *testing.T) {
+	spec := &AppDeploymentFromFileSpec{
+		Namespace:         "default",
+		Kustomization:     newTarArchive(t, widgetsKustomization),
+		KustomizationPath: "overlays/prod",
+		Validate:          true,
+		Preview:           true,
+	}
+
+	// Previews never reach the API server, so no client is needed.
+	documents, err := DeployAppFromFileWithClient(nil, nil, spec)
+	if err != nil {
+		t.Fatalf("DeployAppFromFileWithClient() returned unexpected error: %v", err)
+	}
+	if !strings.Contains(spec.Content, "name: prod-widget") {
+		t.Errorf("expected rendered content, got:\n%s", spec.Content)
+	}
+	if len(documents) != 1 || documents[0].Status != DocumentRendered {
+		t.Fatalf("expected one rendered document, got %+v", documents)
+	}
+	object := documents[0].Objects[0]
+	if object.Kind != "Widget" || object.Name != "prod-widget" || object.Namespace != "default" {
+		t.Errorf("unexpected object %+v", object)
+	}
+}
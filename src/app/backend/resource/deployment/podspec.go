@@ -0,0 +1,185 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"fmt"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ProbeType is the kind of check a probe performs.
+type ProbeType string
+
+const (
+	// ProbeHTTP performs a GET request against the path and port of the container.
+	ProbeHTTP ProbeType = "HTTP"
+	// ProbeTCP opens a TCP connection to the port of the container.
+	ProbeTCP ProbeType = "TCP"
+	// ProbeExec executes the command in the container.
+	ProbeExec ProbeType = "Exec"
+)
+
+// ProbeSpec is a specification of a health check of a container.
+type ProbeSpec struct {
+	Type ProbeType `json:"type"`
+
+	// Path of the HTTP request. Only used for HTTP probes.
+	Path string `json:"path"`
+
+	// Port of the container to check. Used for HTTP and TCP probes.
+	Port int32 `json:"port"`
+
+	// Command to execute. Only used for Exec probes.
+	Command []string `json:"command"`
+
+	// Optional timing of the probe. Kubernetes defaults are used if not set.
+	InitialDelaySeconds int32 `json:"initialDelaySeconds"`
+	PeriodSeconds       int32 `json:"periodSeconds"`
+	TimeoutSeconds      int32 `json:"timeoutSeconds"`
+	FailureThreshold    int32 `json:"failureThreshold"`
+}
+
+// VolumeType is the kind of source a volume is backed by.
+type VolumeType string
+
+const (
+	VolumePersistentVolumeClaim VolumeType = "PersistentVolumeClaim"
+	VolumeConfigMap             VolumeType = "ConfigMap"
+	VolumeSecret                VolumeType = "Secret"
+)
+
+// VolumeSpec is a specification of a volume mounted into a container.
+type VolumeSpec struct {
+	// Name of the volume. Defaults to the name of the source.
+	Name string `json:"name"`
+
+	Type VolumeType `json:"type"`
+
+	// Name of the persistent volume claim, config map or secret.
+	Source string `json:"source"`
+
+	// Path the volume is mounted at.
+	MountPath string `json:"mountPath"`
+
+	// Optional path within the volume to mount instead of its root.
+	SubPath string `json:"subPath"`
+
+	ReadOnly bool `json:"readOnly"`
+}
+
+// convertProbeSpec returns the probe for the spec, or nil if no spec is given.
+func convertProbeSpec(spec *ProbeSpec) (*api.Probe, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	probe := &api.Probe{
+		InitialDelaySeconds: spec.InitialDelaySeconds,
+		PeriodSeconds:       spec.PeriodSeconds,
+		TimeoutSeconds:      spec.TimeoutSeconds,
+		FailureThreshold:    spec.FailureThreshold,
+	}
+	switch spec.Type {
+	case ProbeHTTP:
+		if spec.Port <= 0 {
+			return nil, errors.NewInvalid("HTTP probe requires a port")
+		}
+		path := spec.Path
+		if len(path) == 0 {
+			path = "/"
+		}
+		probe.HTTPGet = &api.HTTPGetAction{Path: path, Port: intstr.FromInt(int(spec.Port))}
+	case ProbeTCP:
+		if spec.Port <= 0 {
+			return nil, errors.NewInvalid("TCP probe requires a port")
+		}
+		probe.TCPSocket = &api.TCPSocketAction{Port: intstr.FromInt(int(spec.Port))}
+	case ProbeExec:
+		if len(spec.Command) == 0 {
+			return nil, errors.NewInvalid("Exec probe requires a command")
+		}
+		probe.Exec = &api.ExecAction{Command: spec.Command}
+	default:
+		return nil, errors.NewInvalid(fmt.Sprintf("Unknown probe type %q", spec.Type))
+	}
+	return probe, nil
+}
+
+// convertVolumeSpecs returns the pod volumes and the matching mounts of the container.
+func convertVolumeSpecs(specs []VolumeSpec) ([]api.Volume, []api.VolumeMount, error) {
+	var volumes []api.Volume
+	var mounts []api.VolumeMount
+	names := make(map[string]bool)
+	for _, spec := range specs {
+		if len(spec.Source) == 0 || len(spec.MountPath) == 0 {
+			return nil, nil, errors.NewInvalid("Volumes require a source and a mount path")
+		}
+
+		name := spec.Name
+		if len(name) == 0 {
+			name = spec.Source
+		}
+		// The same volume may be mounted at several paths, e.g. with different sub paths.
+		if !names[name] {
+			volume, err := convertVolumeSource(name, spec)
+			if err != nil {
+				return nil, nil, err
+			}
+			volumes = append(volumes, volume)
+			names[name] = true
+		}
+
+		mounts = append(mounts, api.VolumeMount{
+			Name:      name,
+			MountPath: spec.MountPath,
+			SubPath:   spec.SubPath,
+			ReadOnly:  spec.ReadOnly,
+		})
+	}
+	return volumes, mounts, nil
+}
+
+func convertVolumeSource(name string, spec VolumeSpec) (api.Volume, error) {
+	volume := api.Volume{Name: name}
+	switch spec.Type {
+	case VolumePersistentVolumeClaim:
+		volume.PersistentVolumeClaim = &api.PersistentVolumeClaimVolumeSource{
+			ClaimName: spec.Source,
+			ReadOnly:  spec.ReadOnly,
+		}
+	case VolumeConfigMap:
+		volume.ConfigMap = &api.ConfigMapVolumeSource{
+			LocalObjectReference: api.LocalObjectReference{Name: spec.Source},
+		}
+	case VolumeSecret:
+		volume.Secret = &api.SecretVolumeSource{SecretName: spec.Source}
+	default:
+		return volume, errors.NewInvalid(fmt.Sprintf("Unknown volume type %q", spec.Type))
+	}
+	return volume, nil
+}
+
+func convertEnvFromSecrets(secrets []string) []api.EnvFromSource {
+	var result []api.EnvFromSource
+	for _, secret := range secrets {
+		result = append(result, api.EnvFromSource{
+			SecretRef: &api.SecretEnvSource{LocalObjectReference: api.LocalObjectReference{Name: secret}},
+		})
+	}
+	return result
+}
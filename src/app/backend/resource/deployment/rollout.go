@@ -0,0 +1,369 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	client "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RevisionAnnotation is the annotation of a deployment and its replica sets that holds the revision.
+	RevisionAnnotation = "deployment.kubernetes.io/revision"
+	// ChangeCauseAnnotation describes the change that created a revision.
+	ChangeCauseAnnotation = "kubernetes.io/change-cause"
+
+	// Reason of the progressing condition once the progress deadline is exceeded.
+	progressDeadlineExceededReason = "ProgressDeadlineExceeded"
+)
+
+// RolloutState is the overall state of a deployment rollout.
+type RolloutState string
+
+const (
+	RolloutProgressing RolloutState = "Progressing"
+	RolloutComplete    RolloutState = "Complete"
+	RolloutFailed      RolloutState = "Failed"
+	RolloutPaused      RolloutState = "Paused"
+)
+
+// RolloutStatus describes the progress of the latest rollout of a deployment.
+type RolloutStatus struct {
+	// Revision of the deployment.
+	Revision int64 `json:"revision"`
+
+	State RolloutState `json:"state"`
+
+	// Human readable description of the state, e.g. how many replicas are still being updated.
+	Message string `json:"message"`
+
+	Paused bool `json:"paused"`
+
+	StatusInfo StatusInfo `json:"statusInfo"`
+
+	// The progressing and available conditions of the deployment, if reported yet.
+	Progressing *common.Condition `json:"progressing,omitempty"`
+	Available   *common.Condition `json:"available,omitempty"`
+}
+
+// RolloutRevision is a single revision of a deployment, backed by one of its replica sets.
+type RolloutRevision struct {
+	Revision int64 `json:"revision"`
+
+	// Name of the replica set of the revision.
+	ReplicaSet string `json:"replicaSet"`
+
+	CreationTimestamp metaV1.Time `json:"creationTimestamp"`
+
+	// Value of the change-cause annotation of the revision.
+	ChangeCause string `json:"changeCause"`
+
+	// Container images of the pod template.
+	Images []string `json:"images"`
+
+	// Whether this revision is the one the deployment currently rolls out.
+	Current bool `json:"current"`
+
+	// Unified diff of the pod template against the previous revision.
+	Diff string `json:"diff"`
+}
+
+// RolloutHistory contains all revisions of a deployment, the oldest first.
+type RolloutHistory struct {
+	ListMeta  api.ListMeta      `json:"listMeta"`
+	Revisions []RolloutRevision `json:"revisions"`
+}
+
+// RollbackSpec is a specification of the revision a deployment should be rolled back to.
+type RollbackSpec struct {
+	// Revision to roll back to. The previous revision is used if it is 0.
+	Revision int64 `json:"revision"`
+}
+
+// GetDeploymentRolloutStatus returns the rollout status of the deployment.
+func GetDeploymentRolloutStatus(client client.Interface, tenant, namespace, name string) (*RolloutStatus, error) {
+	deployment, err := client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return toRolloutStatus(deployment), nil
+}
+
+// PauseDeployment pauses the rollout of the deployment. Changes of the pod template are not rolled
+// out until the deployment is resumed.
+func PauseDeployment(client client.Interface, tenant, namespace, name string) (*RolloutStatus, error) {
+	return patchDeployment(client, tenant, namespace, name, map[string]interface{}{
+		"spec": map[string]interface{}{"paused": true},
+	})
+}
+
+// ResumeDeployment resumes the paused rollout of the deployment.
+func ResumeDeployment(client client.Interface, tenant, namespace, name string) (*RolloutStatus, error) {
+	return patchDeployment(client, tenant, namespace, name, map[string]interface{}{
+		"spec": map[string]interface{}{"paused": false},
+	})
+}
+
+// RestartDeployment triggers a rolling restart of all pods of the deployment by setting the restart
+// annotation of the pod template.
+func RestartDeployment(client client.Interface, tenant, namespace, name string) (*RolloutStatus, error) {
+	deployment, err := client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if deployment.Spec.Paused {
+		return nil, errors.NewInvalid(fmt.Sprintf("Deployment %s is paused. Resume it before restarting.", name))
+	}
+
+	patch, err := common.RestartPatch()
+	if err != nil {
+		return nil, err
+	}
+	deployment, err = client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).
+		Patch(name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return nil, err
+	}
+	return toRolloutStatus(deployment), nil
+}
+
+// RollbackDeployment rolls the deployment back to the pod template of the given revision. Rolling
+// back creates a new revision with the old template, the same way 'kubectl rollout undo' does.
+func RollbackDeployment(client client.Interface, tenant, namespace, name string, spec *RollbackSpec) (
+	*RolloutStatus, error) {
+	deployment, err := client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if deployment.Spec.Paused {
+		return nil, errors.NewInvalid(fmt.Sprintf("Deployment %s is paused. Resume it before rolling back.", name))
+	}
+
+	replicaSets, err := getDeploymentReplicaSets(client, tenant, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := findRollbackTarget(deployment, replicaSets, spec.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, apps.DefaultDeploymentUniqueLabelKey)
+	if common.EqualIgnoreHash(*template, deployment.Spec.Template) {
+		return toRolloutStatus(deployment), nil
+	}
+
+	deployment.Spec.Template = *template
+	updated, err := client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).Update(deployment)
+	if err != nil {
+		return nil, err
+	}
+	return toRolloutStatus(updated), nil
+}
+
+// GetDeploymentRolloutHistory returns all revisions of the deployment with the changes of their pod
+// templates.
+func GetDeploymentRolloutHistory(client client.Interface, tenant, namespace, name string) (*RolloutHistory, error) {
+	deployment, err := client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	replicaSets, err := getDeploymentReplicaSets(client, tenant, deployment)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(replicaSets, func(i, j int) bool { return getRevision(replicaSets[i]) < getRevision(replicaSets[j]) })
+
+	current := FindNewReplicaSet(deployment, replicaSets)
+	history := &RolloutHistory{Revisions: make([]RolloutRevision, 0, len(replicaSets))}
+	previous := []string{}
+	for _, replicaSet := range replicaSets {
+		lines, err := templateLines(replicaSet.Spec.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		revision := getRevision(replicaSet)
+		history.Revisions = append(history.Revisions, RolloutRevision{
+			Revision:          revision,
+			ReplicaSet:        replicaSet.Name,
+			CreationTimestamp: replicaSet.CreationTimestamp,
+			ChangeCause:       replicaSet.Annotations[ChangeCauseAnnotation],
+			Images:            common.GetContainerImages(&replicaSet.Spec.Template.Spec),
+			Current:           current != nil && current.UID == replicaSet.UID,
+			Diff: common.UnifiedDiff(fmt.Sprintf("revision %d", revision-1), fmt.Sprintf("revision %d", revision),
+				previous, lines),
+		})
+		previous = lines
+	}
+	history.ListMeta = api.ListMeta{TotalItems: len(history.Revisions)}
+	return history, nil
+}
+
+func patchDeployment(client client.Interface, tenant, namespace, name string, patch map[string]interface{}) (
+	*RolloutStatus, error) {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := client.AppsV1().DeploymentsWithMultiTenancy(namespace, tenant).
+		Patch(name, types.StrategicMergePatchType, data)
+	if err != nil {
+		return nil, err
+	}
+	return toRolloutStatus(deployment), nil
+}
+
+// getDeploymentReplicaSets returns the replica sets controlled by the deployment.
+func getDeploymentReplicaSets(client client.Interface, tenant string, deployment *apps.Deployment) (
+	[]*apps.ReplicaSet, error) {
+	selector, err := metaV1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.AppsV1().ReplicaSetsWithMultiTenancy(deployment.Namespace, tenant).
+		List(metaV1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	replicaSets := make([]*apps.ReplicaSet, 0)
+	for i := range list.Items {
+		if owner := metaV1.GetControllerOf(&list.Items[i]); owner != nil && owner.UID == deployment.UID {
+			replicaSets = append(replicaSets, &list.Items[i])
+		}
+	}
+	return replicaSets, nil
+}
+
+// findRollbackTarget returns the replica set of the revision. If the revision is 0, the replica set of
+// the revision before the current one is returned.
+func findRollbackTarget(deployment *apps.Deployment, replicaSets []*apps.ReplicaSet, revision int64) (
+	*apps.ReplicaSet, error) {
+	if revision < 0 {
+		return nil, errors.NewInvalid(fmt.Sprintf("Invalid revision %d", revision))
+	}
+
+	if revision > 0 {
+		for _, replicaSet := range replicaSets {
+			if getRevision(replicaSet) == revision {
+				return replicaSet, nil
+			}
+		}
+		return nil, errors.NewNotFound(fmt.Sprintf("Revision %d of deployment %s not found", revision,
+			deployment.Name))
+	}
+
+	current := getRevision(deployment)
+	var previous *apps.ReplicaSet
+	for _, replicaSet := range replicaSets {
+		if r := getRevision(replicaSet); r < current && (previous == nil || r > getRevision(previous)) {
+			previous = replicaSet
+		}
+	}
+	if previous == nil {
+		return nil, errors.NewNotFound(fmt.Sprintf("Deployment %s has no previous revision", deployment.Name))
+	}
+	return previous, nil
+}
+
+// getRevision returns the revision of a deployment or replica set, or 0 if it has none.
+func getRevision(object metaV1.Object) int64 {
+	revision, err := strconv.ParseInt(object.GetAnnotations()[RevisionAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// templateLines returns the yaml lines of the pod template without the hash label added by the
+// deployment controller, so that revisions only differ by actual changes.
+func templateLines(template v1.PodTemplateSpec) ([]string, error) {
+	template = *template.DeepCopy()
+	delete(template.Labels, apps.DefaultDeploymentUniqueLabelKey)
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&template)
+	if err != nil {
+		return nil, err
+	}
+	return toDiffYAML(&unstructured.Unstructured{Object: object})
+}
+
+// toRolloutStatus describes the rollout the same way 'kubectl rollout status' does.
+func toRolloutStatus(deployment *apps.Deployment) *RolloutStatus {
+	status := &RolloutStatus{
+		Revision:   getRevision(deployment),
+		Paused:     deployment.Spec.Paused,
+		StatusInfo: GetStatusInfo(&deployment.Status),
+	}
+	for _, condition := range getConditions(deployment.Status.Conditions) {
+		condition := condition
+		switch apps.DeploymentConditionType(condition.Type) {
+		case apps.DeploymentProgressing:
+			status.Progressing = &condition
+		case apps.DeploymentAvailable:
+			status.Available = &condition
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	deploymentStatus := deployment.Status
+	status.State = RolloutProgressing
+	switch {
+	case deployment.Generation > deploymentStatus.ObservedGeneration:
+		status.Message = "Waiting for the deployment spec update to be observed"
+	case status.Progressing != nil && status.Progressing.Reason == progressDeadlineExceededReason:
+		status.State = RolloutFailed
+		status.Message = fmt.Sprintf("Deployment %s exceeded its progress deadline", deployment.Name)
+	case deploymentStatus.UpdatedReplicas < replicas:
+		status.Message = fmt.Sprintf("%d out of %d new replicas have been updated",
+			deploymentStatus.UpdatedReplicas, replicas)
+	case deploymentStatus.Replicas > deploymentStatus.UpdatedReplicas:
+		status.Message = fmt.Sprintf("%d old replicas are pending termination",
+			deploymentStatus.Replicas-deploymentStatus.UpdatedReplicas)
+	case deploymentStatus.AvailableReplicas < deploymentStatus.UpdatedReplicas:
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available",
+			deploymentStatus.AvailableReplicas, deploymentStatus.UpdatedReplicas)
+	default:
+		status.State = RolloutComplete
+		status.Message = fmt.Sprintf("Deployment %s successfully rolled out", deployment.Name)
+	}
+
+	if status.Paused && status.State == RolloutProgressing {
+		status.State = RolloutPaused
+	}
+	return status
+}
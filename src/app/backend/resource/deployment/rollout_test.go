@@ -0,0 +1,207 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newRolloutTemplate(image string) v1.PodTemplateSpec {
+	return v1.PodTemplateSpec{
+		ObjectMeta: metaV1.ObjectMeta{Labels: map[string]string{"app": "foo"}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "foo", Image: image}}},
+	}
+}
+
+func newRolloutReplicaSet(revision, image string, deployment *apps.Deployment) *apps.ReplicaSet {
+	template := newRolloutTemplate(image)
+	template.Labels[apps.DefaultDeploymentUniqueLabelKey] = "hash-" + revision
+	replicas := int32(0)
+	return &apps.ReplicaSet{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:            "foo-" + revision,
+			Namespace:       deployment.Namespace,
+			UID:             types.UID("rs-" + revision),
+			Labels:          template.Labels,
+			Annotations:     map[string]string{RevisionAnnotation: revision},
+			OwnerReferences: []metaV1.OwnerReference{*metaV1.NewControllerRef(deployment, apps.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: apps.ReplicaSetSpec{Replicas: &replicas, Template: template},
+	}
+}
+
+func newRolloutClient() *fake.Clientset {
+	replicas := int32(2)
+	deployment := &apps.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   "bar",
+			UID:         "deployment",
+			Annotations: map[string]string{RevisionAnnotation: "3"},
+		},
+		Spec: apps.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			Template: newRolloutTemplate("foo:3"),
+		},
+	}
+	return fake.NewSimpleClientset(deployment,
+		newRolloutReplicaSet("1", "foo:1", deployment),
+		newRolloutReplicaSet("2", "foo:2", deployment),
+		newRolloutReplicaSet("3", "foo:3", deployment))
+}
+
+func getRolloutDeployment(t *testing.T, client *fake.Clientset) *apps.Deployment {
+	deployment, err := client.AppsV1().DeploymentsWithMultiTenancy("bar", "").Get("foo", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get deployment: %v", err)
+	}
+	return deployment
+}
+
+func TestPauseResumeRestartDeployment(t *testing.T) {
+	client := newRolloutClient()
+
+	status, err := PauseDeployment(client, "", "bar", "foo")
+	if err != nil {
+		t.Fatalf("PauseDeployment() returned unexpected error: %v", err)
+	}
+	if !status.Paused || !getRolloutDeployment(t, client).Spec.Paused {
+		t.Errorf("Expected deployment to be paused, got %+v", status)
+	}
+
+	if _, err := RestartDeployment(client, "", "bar", "foo"); err == nil {
+		t.Error("Expected restarting a paused deployment to fail")
+	}
+	if _, err := RollbackDeployment(client, "", "bar", "foo", &RollbackSpec{}); err == nil {
+		t.Error("Expected rolling back a paused deployment to fail")
+	}
+
+	if _, err := ResumeDeployment(client, "", "bar", "foo"); err != nil {
+		t.Fatalf("ResumeDeployment() returned unexpected error: %v", err)
+	}
+	if _, err := RestartDeployment(client, "", "bar", "foo"); err != nil {
+		t.Fatalf("RestartDeployment() returned unexpected error: %v", err)
+	}
+	deployment := getRolloutDeployment(t, client)
+	if deployment.Spec.Paused || len(deployment.Spec.Template.Annotations[common.RestartedAtAnnotation]) == 0 {
+		t.Errorf("Expected resumed and restarted deployment, got %+v", deployment.Spec)
+	}
+}
+
+func TestRollbackDeployment(t *testing.T) {
+	cases := []struct {
+		revision int64
+		image    string
+	}{
+		{0, "foo:2"},
+		{1, "foo:1"},
+	}
+	for _, c := range cases {
+		client := newRolloutClient()
+		if _, err := RollbackDeployment(client, "", "bar", "foo", &RollbackSpec{Revision: c.revision}); err != nil {
+			t.Fatalf("RollbackDeployment(%d) returned unexpected error: %v", c.revision, err)
+		}
+
+		template := getRolloutDeployment(t, client).Spec.Template
+		if template.Spec.Containers[0].Image != c.image {
+			t.Errorf("RollbackDeployment(%d): expected image %s, got %s", c.revision, c.image,
+				template.Spec.Containers[0].Image)
+		}
+		if _, ok := template.Labels[apps.DefaultDeploymentUniqueLabelKey]; ok {
+			t.Errorf("RollbackDeployment(%d): expected hash label to be removed", c.revision)
+		}
+	}
+
+	_, err := RollbackDeployment(newRolloutClient(), "", "bar", "foo", &RollbackSpec{Revision: 7})
+	if !errors.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown revision, got %v", err)
+	}
+}
+
+func TestGetDeploymentRolloutHistory(t *testing.T) {
+	history, err := GetDeploymentRolloutHistory(newRolloutClient(), "", "bar", "foo")
+	if err != nil {
+		t.Fatalf("GetDeploymentRolloutHistory() returned unexpected error: %v", err)
+	}
+	if history.ListMeta.TotalItems != 3 {
+		t.Fatalf("Expected 3 revisions, got %+v", history)
+	}
+
+	revisions := make([]int64, 0)
+	for _, revision := range history.Revisions {
+		revisions = append(revisions, revision.Revision)
+	}
+	if !reflect.DeepEqual(revisions, []int64{1, 2, 3}) {
+		t.Errorf("Expected revisions [1 2 3], got %v", revisions)
+	}
+
+	latest := history.Revisions[2]
+	if !latest.Current || history.Revisions[1].Current || !reflect.DeepEqual(latest.Images, []string{"foo:3"}) {
+		t.Errorf("unexpected latest revision %+v", latest)
+	}
+	if !strings.Contains(latest.Diff, "-  - image: foo:2") || !strings.Contains(latest.Diff, "+  - image: foo:3") ||
+		strings.Contains(latest.Diff, apps.DefaultDeploymentUniqueLabelKey) {
+		t.Errorf("unexpected diff:\n%s", latest.Diff)
+	}
+}
+
+func TestToRolloutStatus(t *testing.T) {
+	replicas := int32(3)
+	cases := []struct {
+		generation int64
+		paused     bool
+		status     apps.DeploymentStatus
+		expected   RolloutState
+		message    string
+	}{
+		{2, false, apps.DeploymentStatus{ObservedGeneration: 1}, RolloutProgressing, "spec update"},
+		{1, false, apps.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 1, Replicas: 3},
+			RolloutProgressing, "1 out of 3 new replicas"},
+		{1, true, apps.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 1, Replicas: 3},
+			RolloutPaused, "1 out of 3 new replicas"},
+		{1, false, apps.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 3, Replicas: 4},
+			RolloutProgressing, "1 old replicas"},
+		{1, false, apps.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 3, Replicas: 3, AvailableReplicas: 2},
+			RolloutProgressing, "2 of 3 updated replicas"},
+		{1, false, apps.DeploymentStatus{ObservedGeneration: 1, UpdatedReplicas: 3, Replicas: 3, AvailableReplicas: 3},
+			RolloutComplete, "successfully rolled out"},
+		{1, false, apps.DeploymentStatus{ObservedGeneration: 1, Conditions: []apps.DeploymentCondition{
+			{Type: apps.DeploymentProgressing, Status: v1.ConditionFalse, Reason: progressDeadlineExceededReason},
+		}}, RolloutFailed, "progress deadline"},
+	}
+	for _, c := range cases {
+		deployment := &apps.Deployment{
+			ObjectMeta: metaV1.ObjectMeta{Name: "foo", Generation: c.generation},
+			Spec:       apps.DeploymentSpec{Replicas: &replicas, Paused: c.paused},
+			Status:     c.status,
+		}
+		status := toRolloutStatus(deployment)
+		if status.State != c.expected || !strings.Contains(status.Message, c.message) {
+			t.Errorf("Expected state %s with message %q, got %s with %q", c.expected, c.message,
+				status.State, status.Message)
+		}
+	}
+}
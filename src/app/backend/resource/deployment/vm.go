@@ -0,0 +1,148 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deployment
+
+import (
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client "k8s.io/client-go/kubernetes"
+)
+
+// VirtualMachineDeploymentSpec is a specification for a deployment of Arktos virtual machines.
+type VirtualMachineDeploymentSpec struct {
+	// Name of the deployment and its virtual machines.
+	Name string `json:"name"`
+
+	// Target namespace of the virtual machines.
+	Namespace string `json:"namespace"`
+
+	Tenant string `json:"tenant"`
+
+	// Image the virtual machines are booted from.
+	Image string `json:"image"`
+
+	// Number of virtual machines to maintain.
+	Replicas int32 `json:"replicas"`
+
+	// Name of the key pair or the public key used to log on to the virtual machines. One of them has
+	// to be set.
+	KeyPairName string `json:"keyPairName"`
+	PublicKey   string `json:"publicKey"`
+
+	// Optional cloud-init user data script run when the virtual machine is launched.
+	CloudInitUserDataScript string `json:"cloudInitUserDataScript"`
+
+	// CPU and memory of the virtual machines. Virtual machines are allocated a fixed size, so the
+	// requirements are used as limits as well.
+	MemoryRequirement *resource.Quantity `json:"memoryRequirement"`
+	CpuRequirement    *resource.Quantity `json:"cpuRequirement"`
+
+	// Desired power state of the virtual machines. Defaults to running.
+	PowerSpec api.VmPowerSpec `json:"powerSpec"`
+
+	// Name of the volume the virtual machines boot from. Defaults to the first volume.
+	BootVolume string `json:"bootVolume"`
+
+	// Volumes attached to the virtual machines.
+	Volumes []VolumeSpec `json:"volumes"`
+
+	// Network interfaces of the virtual machines.
+	Nics []api.Nic `json:"nics"`
+
+	// Description of the deployment.
+	Description *string `json:"description"`
+
+	// Labels that will be defined on the deployment and the virtual machines.
+	Labels []Label `json:"labels"`
+
+	// Labels that nodes have to match for the virtual machines to be scheduled on them.
+	NodeSelector []Label `json:"nodeSelector"`
+
+	// Tolerations of the virtual machines for node taints.
+	Tolerations []api.Toleration `json:"tolerations"`
+}
+
+// DeployVirtualMachine deploys virtual machines based on the given configuration. The virtual
+// machines are pods with an Arktos VirtualMachine workload, maintained by a deployment.
+func DeployVirtualMachine(spec *VirtualMachineDeploymentSpec, client client.Interface) error {
+	log.Printf("Deploying %s virtual machine into %s namespace", spec.Name, spec.Namespace)
+
+	if len(spec.Image) == 0 {
+		return errors.NewInvalid("Virtual machines require an image")
+	}
+	if len(spec.KeyPairName) == 0 && len(spec.PublicKey) == 0 {
+		return errors.NewInvalid("Virtual machines require a key pair name or a public key")
+	}
+
+	annotations := map[string]string{}
+	if spec.Description != nil {
+		annotations[DescriptionAnnotationKey] = *spec.Description
+	}
+	objectMeta := metaV1.ObjectMeta{
+		Annotations: annotations,
+		Name:        spec.Name,
+		Labels:      getLabelsMap(spec.Labels),
+	}
+
+	resources := make(api.ResourceList)
+	if spec.CpuRequirement != nil {
+		resources[api.ResourceCPU] = *spec.CpuRequirement
+	}
+	if spec.MemoryRequirement != nil {
+		resources[api.ResourceMemory] = *spec.MemoryRequirement
+	}
+
+	volumes, volumeMounts, err := convertVolumeSpecs(spec.Volumes)
+	if err != nil {
+		return err
+	}
+
+	powerSpec := spec.PowerSpec
+	if len(powerSpec) == 0 {
+		powerSpec = api.VmPowerSpecRunning
+	}
+
+	podSpec := api.PodSpec{
+		VirtualMachine: &api.VirtualMachine{
+			Name:                    spec.Name,
+			Image:                   spec.Image,
+			ImagePullPolicy:         api.PullIfNotPresent,
+			KeyPairName:             spec.KeyPairName,
+			PublicKey:               spec.PublicKey,
+			CloudInitUserDataScript: spec.CloudInitUserDataScript,
+			Resources:               api.ResourceRequirements{Requests: resources, Limits: resources},
+			VolumeMounts:            volumeMounts,
+			BootVolume:              spec.BootVolume,
+			PowerSpec:               powerSpec,
+		},
+		Nics:        spec.Nics,
+		Volumes:     volumes,
+		Tolerations: spec.Tolerations,
+	}
+	if len(spec.NodeSelector) > 0 {
+		podSpec.NodeSelector = getLabelsMap(spec.NodeSelector)
+	}
+
+	if spec.Tenant == "" {
+		spec.Tenant = "system"
+	}
+	_, err = client.AppsV1().DeploymentsWithMultiTenancy(spec.Namespace, spec.Tenant).
+		Create(newDeployment(objectMeta, spec.Replicas, podSpec))
+	return err
+}
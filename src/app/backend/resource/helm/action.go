@@ -0,0 +1,622 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/deployment"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// Release names are used in the names of the objects of charts, so Helm limits their length.
+const maxReleaseNameLength = 53
+
+// ChartSource is the chart a release is installed or upgraded from. Either an uploaded archive or the
+// name of a chart of the configured repository has to be given.
+type ChartSource struct {
+	// Chart archive created by 'helm package', base64 encoded in JSON.
+	Archive []byte `json:"archive,omitempty"`
+
+	// Name of a chart in the configured repository.
+	Chart string `json:"chart,omitempty"`
+
+	// Semantic version constraint of the repository chart. The latest stable version is used if empty.
+	Version string `json:"version,omitempty"`
+}
+
+// InstallSpec is a specification of a release installation.
+type InstallSpec struct {
+	ChartSource
+
+	// Name of the release.
+	Name string `json:"name"`
+
+	// Values in YAML or JSON that are merged over the default values of the chart.
+	Values string `json:"values"`
+
+	// Whether the chart should only be rendered. Nothing is deployed or stored.
+	DryRun bool `json:"dryRun"`
+}
+
+// UpgradeSpec is a specification of a release upgrade.
+type UpgradeSpec struct {
+	ChartSource
+
+	// Values in YAML or JSON that are merged over the default values of the chart.
+	Values string `json:"values"`
+
+	// Whether the values of the current revision should be reused. The given values are merged over them.
+	ReuseValues bool `json:"reuseValues"`
+
+	// Whether the chart should only be rendered. Nothing is deployed or stored.
+	DryRun bool `json:"dryRun"`
+}
+
+// RollbackSpec is a specification of a release rollback.
+type RollbackSpec struct {
+	// Revision to roll back to. The previous revision is used if it is 0.
+	Revision int `json:"revision"`
+}
+
+// ReleaseClient manages the releases of a namespace of a tenant. Objects of releases are deployed with
+// the tenant aware clients of deploy from file, and revisions are stored in Helm's secret storage.
+type ReleaseClient struct {
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+	capabilities  *Capabilities
+	namespace     string
+	tenant        string
+	storage       *storage
+}
+
+// NewReleaseClient creates a client for the releases of the given namespace of the given tenant.
+// Capabilities are read from the cluster if they are nil.
+func NewReleaseClient(client kubernetes.Interface, dynamicClient dynamic.Interface, mapper meta.RESTMapper,
+	capabilities *Capabilities, namespace, tenant string) *ReleaseClient {
+	return &ReleaseClient{
+		client:        client,
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+		capabilities:  capabilities,
+		namespace:     namespace,
+		tenant:        tenant,
+		storage:       newStorage(client, namespace, tenant),
+	}
+}
+
+// List returns the latest revision of every release.
+func (self *ReleaseClient) List() (*ReleaseList, error) {
+	releases, err := self.storage.list()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReleaseList{
+		ListMeta: api.ListMeta{TotalItems: len(releases)},
+		Releases: make([]ReleaseSummary, 0, len(releases)),
+	}
+	for _, release := range releases {
+		result.Releases = append(result.Releases, toReleaseSummary(release))
+	}
+	return result, nil
+}
+
+// Get returns the latest revision of the release.
+func (self *ReleaseClient) Get(name string) (*ReleaseDetail, error) {
+	release, err := self.storage.last(name)
+	if err != nil {
+		return nil, err
+	}
+	return toReleaseDetail(release), nil
+}
+
+// History returns all stored revisions of the release.
+func (self *ReleaseClient) History(name string) (*ReleaseHistory, error) {
+	releases, err := self.storage.history(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReleaseHistory{Name: name, Revisions: make([]ReleaseSummary, 0, len(releases))}
+	for _, release := range releases {
+		result.Revisions = append(result.Revisions, toReleaseSummary(release))
+	}
+	return result, nil
+}
+
+// Install installs a new release. If the deployment fails, the objects created so far are deleted and
+// the revision is stored as failed.
+func (self *ReleaseClient) Install(repositoryURL string, spec *InstallSpec) (*ReleaseDetail, error) {
+	if err := validateReleaseName(spec.Name); err != nil {
+		return nil, err
+	}
+	if _, err := self.storage.history(spec.Name); err == nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("Release %s already exists", spec.Name))
+	} else if !errors.IsNotFoundError(err) {
+		return nil, err
+	}
+
+	chart, err := spec.load(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseValues(spec.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := now()
+	release := &Release{
+		Name:      spec.Name,
+		Namespace: self.namespace,
+		Chart:     chart,
+		Config:    values,
+		Version:   1,
+		Info: &Info{
+			FirstDeployed: timestamp,
+			LastDeployed:  timestamp,
+			Status:        StatusPendingInstall,
+			Description:   "Initial install underway",
+		},
+	}
+	// Like in Helm, CRDs are installed before the templates are rendered, so that templates can rely on
+	// them, and are never upgraded or deleted afterwards.
+	if crds := chart.crdObjects(coalesceValues(chart, values)); len(crds) > 0 && !spec.DryRun {
+		if err := self.installCRDs(crds); err != nil {
+			return nil, err
+		}
+	}
+	info := releaseInfo{IsInstall: true}
+	if err := self.render(release, info); err != nil {
+		return nil, err
+	}
+	if spec.DryRun {
+		release.Info.Description = "Dry run complete"
+		return toReleaseDetail(release), nil
+	}
+
+	if err := self.storage.create(release); err != nil {
+		return nil, err
+	}
+	if err := self.runHooks(release, hookPreInstall); err != nil {
+		return nil, self.fail(release, err)
+	}
+	if err := self.deploy(release, false); err != nil {
+		return nil, self.fail(release, err)
+	}
+	if err := self.runHooks(release, hookPostInstall); err != nil {
+		return nil, self.fail(release, err)
+	}
+	release.Info.Status = StatusDeployed
+	release.Info.Description = "Install complete"
+	return toReleaseDetail(release), self.storage.update(release)
+}
+
+// Upgrade upgrades the release to a new revision. Objects that are not part of the new revision are
+// deleted. If the deployment fails, the changes are rolled back and the revision is stored as failed.
+func (self *ReleaseClient) Upgrade(repositoryURL, name string, spec *UpgradeSpec) (*ReleaseDetail, error) {
+	current, err := self.storage.last(name)
+	if err != nil {
+		return nil, err
+	}
+	if current.Info != nil && current.Info.Status == StatusUninstalled {
+		return nil, errors.NewInvalid(fmt.Sprintf("Release %s has been uninstalled", name))
+	}
+
+	// Subcharts are not stored with releases, so the chart cannot be reused from the current revision.
+	chart, err := spec.load(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseValues(spec.Values)
+	if err != nil {
+		return nil, err
+	}
+	if spec.ReuseValues {
+		values = mergeValues(copyValues(current.Config), values)
+	}
+
+	release := &Release{
+		Name:      name,
+		Namespace: self.namespace,
+		Chart:     chart,
+		Config:    values,
+		Version:   current.Version + 1,
+		Info: &Info{
+			FirstDeployed: current.Info.FirstDeployed,
+			LastDeployed:  now(),
+			Status:        StatusPendingUpgrade,
+			Description:   "Preparing upgrade",
+		},
+	}
+	if err := self.render(release, releaseInfo{IsUpgrade: true}); err != nil {
+		return nil, err
+	}
+	if spec.DryRun {
+		release.Info.Description = "Dry run complete"
+		return toReleaseDetail(release), nil
+	}
+
+	if err := self.replace(current, release, hookPreUpgrade, hookPostUpgrade); err != nil {
+		return nil, err
+	}
+	release.Info.Description = "Upgrade complete"
+	return toReleaseDetail(release), self.storage.update(release)
+}
+
+// Rollback deploys the manifest of an earlier revision as a new revision.
+func (self *ReleaseClient) Rollback(name string, spec *RollbackSpec) (*ReleaseDetail, error) {
+	current, err := self.storage.last(name)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := spec.Revision
+	if revision == 0 {
+		revision = current.Version - 1
+	}
+	if revision <= 0 || revision >= current.Version {
+		return nil, errors.NewInvalid(fmt.Sprintf("Release %s has no revision %d to roll back to", name, revision))
+	}
+	target, err := self.storage.get(name, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{
+		Name:      name,
+		Namespace: self.namespace,
+		Chart:     target.Chart,
+		Config:    target.Config,
+		Manifest:  target.Manifest,
+		Hooks:     target.Hooks,
+		Version:   current.Version + 1,
+		Info: &Info{
+			FirstDeployed: current.Info.FirstDeployed,
+			LastDeployed:  now(),
+			Status:        StatusPendingRollback,
+			Description:   fmt.Sprintf("Rollback to %d", revision),
+			Notes:         target.Info.Notes,
+		},
+	}
+	if err := self.replace(current, release, hookPreRollback, hookPostRollback); err != nil {
+		return nil, err
+	}
+	return toReleaseDetail(release), self.storage.update(release)
+}
+
+// Uninstall deletes the objects of the release. The revisions are kept with the uninstalled status if
+// keepHistory is set, and deleted otherwise.
+func (self *ReleaseClient) Uninstall(name string, keepHistory bool) (*ReleaseDetail, error) {
+	releases, err := self.storage.history(name)
+	if err != nil {
+		return nil, err
+	}
+	current := releases[len(releases)-1]
+	if current.Info != nil && current.Info.Status == StatusUninstalled {
+		return nil, errors.NewNotFound(fmt.Sprintf("Release %s has already been uninstalled", name))
+	}
+
+	manifests, err := parseManifest(current.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := self.runHooks(current, hookPreDelete); err != nil {
+		return nil, err
+	}
+	if err := self.deleteObjects(manifests); err != nil {
+		return nil, err
+	}
+	if err := self.runHooks(current, hookPostDelete); err != nil {
+		return nil, err
+	}
+
+	current.Info.Status = StatusUninstalled
+	current.Info.Deleted = now()
+	current.Info.Description = "Uninstallation complete"
+	if keepHistory {
+		return toReleaseDetail(current), self.storage.update(current)
+	}
+
+	for _, release := range releases {
+		if err := self.storage.delete(release.Name, release.Version); err != nil && !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return toReleaseDetail(current), nil
+}
+
+// replace deploys the new revision over the current one and deletes the objects that are not part of it
+// anymore. The hooks of the pre and post events are run before and after. The new revision is stored as
+// deployed and the current one as superseded, unless the deployment fails.
+func (self *ReleaseClient) replace(current, release *Release, preEvent, postEvent string) error {
+	if err := self.storage.create(release); err != nil {
+		return err
+	}
+	if err := self.runHooks(release, preEvent); err != nil {
+		return self.fail(release, err)
+	}
+	if err := self.deploy(release, true); err != nil {
+		return self.fail(release, err)
+	}
+
+	currentManifests, err := parseManifest(current.Manifest)
+	if err != nil {
+		return err
+	}
+	manifests, err := parseManifest(release.Manifest)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, manifest := range manifests {
+		keep[manifest.key()] = true
+	}
+	removed := make([]manifest, 0)
+	for _, manifest := range currentManifests {
+		if !keep[manifest.key()] {
+			removed = append(removed, manifest)
+		}
+	}
+	if err := self.deleteObjects(removed); err != nil {
+		return self.fail(release, err)
+	}
+	if err := self.runHooks(release, postEvent); err != nil {
+		return self.fail(release, err)
+	}
+
+	if current.Info != nil && current.Info.Status == StatusDeployed {
+		current.Info.Status = StatusSuperseded
+		if err := self.storage.update(current); err != nil {
+			return err
+		}
+	}
+	release.Info.Status = StatusDeployed
+	return nil
+}
+
+// render renders the chart of the release with its values and stores the manifest, hooks and notes.
+func (self *ReleaseClient) render(release *Release, info releaseInfo) error {
+	capabilities := self.capabilities
+	if capabilities == nil {
+		var err error
+		if capabilities, err = GetCapabilities(self.client.Discovery()); err != nil {
+			return err
+		}
+	}
+
+	info.Name = release.Name
+	info.Namespace = release.Namespace
+	info.Revision = release.Version
+	info.Service = "Helm"
+	values := coalesceValues(release.Chart, release.Config)
+	rendered, err := render(release.Chart, values, info, capabilities, self.lookup)
+	if err != nil {
+		return err
+	}
+
+	manifests, hooks, notes, err := splitManifests(release.Chart.Metadata.Name, rendered)
+	if err != nil {
+		return err
+	}
+	release.Manifest = joinManifests(manifests)
+	release.Hooks = hooks
+	release.Info.Notes = notes
+	return nil
+}
+
+// deploy deploys the manifest of the release with deploy from file. On install, objects are only created,
+// so that existing objects that do not belong to the release are not taken over.
+func (self *ReleaseClient) deploy(release *Release, apply bool) error {
+	manifests, err := parseManifest(release.Manifest)
+	if err != nil {
+		return err
+	}
+	content, err := withOwnership(manifests, release.Name, release.Namespace)
+	if err != nil {
+		return err
+	}
+
+	_, err = deployment.DeployAppFromFileWithClient(self.dynamicClient, self.mapper,
+		&deployment.AppDeploymentFromFileSpec{
+			Name:      release.Name,
+			Namespace: self.namespace,
+			Tenant:    self.tenant,
+			Content:   content,
+			Validate:  true,
+			Apply:     apply,
+		})
+	return err
+}
+
+// fail stores the revision as failed and returns the error that caused the failure.
+func (self *ReleaseClient) fail(release *Release, cause error) error {
+	release.Info.Status = StatusFailed
+	release.Info.Description = fmt.Sprintf("Release %q failed: %s", release.Name, cause.Error())
+	if err := self.storage.update(release); err != nil {
+		log.Printf("Could not store failed release %s: %v", release.Name, err)
+	}
+	return cause
+}
+
+// deleteObjects deletes the objects in reverse install order. Objects that do not exist anymore are
+// ignored.
+func (self *ReleaseClient) deleteObjects(manifests []manifest) error {
+	failed := make([]string, 0)
+	propagation := metaV1.DeletePropagationBackground
+	for i := len(manifests) - 1; i >= 0; i-- {
+		head := manifests[i].head
+		client, err := self.resourceClient(schema.FromAPIVersionAndKind(head.APIVersion, head.Kind), self.namespace)
+		if err != nil {
+			return err
+		}
+
+		err = client.Delete(head.Metadata.Name, &metaV1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Printf("Could not delete %s %s: %v", head.Kind, head.Metadata.Name, err)
+			failed = append(failed, fmt.Sprintf("%s %s: %s", head.Kind, head.Metadata.Name, err.Error()))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not delete objects: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// installCRDs creates the CRDs of the chart and waits until they are established. CRDs that already exist
+// are left unchanged, like in Helm.
+func (self *ReleaseClient) installCRDs(files []*File) error {
+	created := make([]*unstructured.Unstructured, 0)
+	for _, file := range files {
+		for _, content := range documentSeparator.Split(string(file.Data), -1) {
+			if len(strings.TrimSpace(content)) == 0 {
+				continue
+			}
+			object := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal([]byte(content), &object.Object); err != nil {
+				return errors.NewInvalid(fmt.Sprintf("%s: %s", file.Name, err.Error()))
+			}
+			if len(object.Object) == 0 {
+				continue
+			}
+
+			client, err := self.resourceClient(object.GroupVersionKind(), "")
+			if err != nil {
+				return err
+			}
+			if _, err := client.Create(object, metaV1.CreateOptions{}); k8serrors.IsAlreadyExists(err) {
+				log.Printf("CRD %s already exists, skipping it", object.GetName())
+				continue
+			} else if err != nil {
+				return err
+			}
+			created = append(created, object)
+		}
+	}
+
+	for _, crd := range created {
+		client, err := self.resourceClient(crd.GroupVersionKind(), "")
+		if err != nil {
+			return err
+		}
+		err = wait.PollImmediate(pollInterval, readyTimeout, func() (bool, error) {
+			object, err := client.Get(crd.GetName(), metaV1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+			for _, condition := range conditions {
+				if condition, ok := condition.(map[string]interface{}); ok &&
+					condition["type"] == "Established" && condition["status"] == "True" {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("CRD %s did not become established: %v", crd.GetName(), err)
+		}
+	}
+
+	// The kinds of the new CRDs are only known to caching mappers after they have been reset.
+	if mapper, ok := self.mapper.(interface{ Reset() }); ok && len(created) > 0 {
+		mapper.Reset()
+	}
+	return nil
+}
+
+// lookup implements the 'lookup' template function of Helm. It returns the object, or the list of objects
+// if the name is empty, from the tenant of the release. Objects that do not exist are returned as empty
+// maps, so that templates can check for them.
+func (self *ReleaseClient) lookup(apiVersion, kind, namespace, name string) (map[string]interface{}, error) {
+	client, err := self.resourceClient(schema.FromAPIVersionAndKind(apiVersion, kind), namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(name) == 0 {
+		list, err := client.List(metaV1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return list.UnstructuredContent(), nil
+	}
+	object, err := client.Get(name, metaV1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return map[string]interface{}{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return object.UnstructuredContent(), nil
+}
+
+// resourceClient returns a dynamic client for the kind in the tenant of the release. The namespace is
+// only used for namespaced kinds.
+func (self *ReleaseClient) resourceClient(gvk schema.GroupVersionKind, namespace string) (
+	dynamic.ResourceInterface, error) {
+	mapping, err := self.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mapping.Scope.Name() {
+	case meta.RESTScopeNameNamespace:
+		return self.dynamicClient.Resource(mapping.Resource).NamespaceWithMultiTenancy(namespace, self.tenant), nil
+	case meta.RESTScopeNameTenant:
+		return self.dynamicClient.Resource(mapping.Resource).NamespaceWithMultiTenancy("", self.tenant), nil
+	default:
+		return self.dynamicClient.Resource(mapping.Resource), nil
+	}
+}
+
+// load loads the uploaded chart or downloads it from the repository.
+func (self *ChartSource) load(repositoryURL string) (*Chart, error) {
+	switch {
+	case len(self.Archive) > 0:
+		return LoadArchive(self.Archive)
+	case len(self.Chart) > 0:
+		return fetchChart(repositoryURL, self.Chart, self.Version)
+	default:
+		return nil, errors.NewInvalid("Either a chart archive or the name of a repository chart is required")
+	}
+}
+
+func validateReleaseName(name string) error {
+	if len(name) == 0 || len(name) > maxReleaseNameLength {
+		return errors.NewInvalid(fmt.Sprintf("Release name has to be between 1 and %d characters long",
+			maxReleaseNameLength))
+	}
+	if messages := validation.IsDNS1123Label(name); len(messages) > 0 {
+		return errors.NewInvalid(fmt.Sprintf("Invalid release name %q: %s", name, strings.Join(messages, ", ")))
+	}
+	return nil
+}
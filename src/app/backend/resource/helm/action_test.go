@@ -0,0 +1,419 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	widgetsResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	gadgetsResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "gadgets"}
+	jobsResource    = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+	crdsResource    = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1beta1",
+		Resource: "customresourcedefinitions"}
+)
+
+// setStatusCondition makes the fake client store created objects of the resource with the condition.
+func setStatusCondition(client *fakedynamic.FakeDynamicClient, resource, condition string) {
+	client.PrependReactor("create", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		object := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		unstructured.SetNestedSlice(object.Object, []interface{}{
+			map[string]interface{}{"type": condition, "status": "True", "message": "test"},
+		}, "status", "conditions")
+		return false, nil, nil
+	})
+}
+
+func newFakeReleaseClient() (*ReleaseClient, *fakedynamic.FakeDynamicClient) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "example.com", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1",
+		Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+
+	dynamicClient := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+	client := NewReleaseClient(fake.NewSimpleClientset(), dynamicClient, mapper, &Capabilities{}, "default", "tenant")
+	return client, dynamicClient
+}
+
+func getWidget(t *testing.T, client *fakedynamic.FakeDynamicClient, name string) *unstructured.Unstructured {
+	widget, err := client.Resource(widgetsResource).NamespaceWithMultiTenancy("default", "tenant").
+		Get(name, metaV1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("could not get widget %s: %v", name, err)
+	}
+	return widget
+}
+
+func getGadget(t *testing.T, client *fakedynamic.FakeDynamicClient, name string) *unstructured.Unstructured {
+	gadget, err := client.Resource(gadgetsResource).Get(name, metaV1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("could not get gadget %s: %v", name, err)
+	}
+	return gadget
+}
+
+func createdNames(client *fakedynamic.FakeDynamicClient) []string {
+	names := make([]string, 0)
+	for _, action := range client.Actions() {
+		if create, ok := action.(k8stesting.CreateAction); ok {
+			names = append(names, create.GetObject().(*unstructured.Unstructured).GetName())
+		}
+	}
+	return names
+}
+
+func revisionStatuses(t *testing.T, client *ReleaseClient, name string) []Status {
+	history, err := client.History(name)
+	if err != nil {
+		t.Fatalf("History() returned unexpected error: %v", err)
+	}
+	statuses := make([]Status, 0)
+	for _, revision := range history.Revisions {
+		statuses = append(statuses, revision.Status)
+	}
+	return statuses
+}
+
+func TestReleaseLifecycle(t *testing.T) {
+	client, dynamicClient := newFakeReleaseClient()
+	archive := newArchive(t, widgetsChart)
+
+	installed, err := client.Install("", &InstallSpec{
+		ChartSource: ChartSource{Archive: archive},
+		Name:        "test",
+		Values:      "size: 3",
+	})
+	if err != nil {
+		t.Fatalf("Install() returned unexpected error: %v", err)
+	}
+	if installed.Status != StatusDeployed || installed.Revision != 1 || installed.Notes != "Installed test revision 1" {
+		t.Errorf("unexpected installed release %+v", installed.ReleaseSummary)
+	}
+	if size, _, _ := unstructured.NestedInt64(getWidget(t, dynamicClient, "test-widgets").Object, "spec", "size"); size != 3 {
+		t.Errorf("expected widget of size 3, got %d", size)
+	}
+	if getGadget(t, dynamicClient, "test-gadget") == nil {
+		t.Error("expected gadget of subchart to be created")
+	}
+	widget := getWidget(t, dynamicClient, "test-widgets")
+	if widget.GetLabels()[managedByLabel] != managedByHelm || widget.GetAnnotations()[releaseNameAnnotation] != "test" ||
+		widget.GetAnnotations()[releaseNamespaceAnnotation] != "default" {
+		t.Errorf("expected widget to carry the ownership metadata of the release, got %v and %v",
+			widget.GetLabels(), widget.GetAnnotations())
+	}
+	if created := createdNames(dynamicClient); created[0] != "test-hook" {
+		t.Errorf("expected pre-install hook to be created before the release objects, got %v", created)
+	}
+	if getWidget(t, dynamicClient, "test-hook") != nil {
+		t.Error("expected succeeded hook to be deleted")
+	}
+
+	if _, err := client.Install("", &InstallSpec{ChartSource: ChartSource{Archive: archive}, Name: "test"}); err == nil {
+		t.Error("expected installing an existing release to fail")
+	}
+
+	// The new revision reuses the size, adds the extra widget and removes the gadget.
+	upgraded, err := client.Upgrade("", "test", &UpgradeSpec{
+		ChartSource: ChartSource{Archive: archive},
+		Values:      "extra: true\ngadget:\n  enabled: false\n",
+		ReuseValues: true,
+	})
+	if err != nil {
+		t.Fatalf("Upgrade() returned unexpected error: %v", err)
+	}
+	if upgraded.Revision != 2 || upgraded.Values["size"] != float64(3) {
+		t.Errorf("unexpected upgraded release %+v with values %v", upgraded.ReleaseSummary, upgraded.Values)
+	}
+	if getWidget(t, dynamicClient, "test-widgets-extra") == nil {
+		t.Error("expected extra widget to be created")
+	}
+	if getGadget(t, dynamicClient, "test-gadget") != nil {
+		t.Error("expected gadget to be deleted")
+	}
+
+	rolledBack, err := client.Rollback("test", &RollbackSpec{Revision: 1})
+	if err != nil {
+		t.Fatalf("Rollback() returned unexpected error: %v", err)
+	}
+	if rolledBack.Revision != 3 || rolledBack.Description != "Rollback to 1" {
+		t.Errorf("unexpected rolled back release %+v", rolledBack.ReleaseSummary)
+	}
+	if getWidget(t, dynamicClient, "test-widgets-extra") != nil || getGadget(t, dynamicClient, "test-gadget") == nil {
+		t.Error("expected objects of revision 1 after rollback")
+	}
+
+	expected := []Status{StatusSuperseded, StatusSuperseded, StatusDeployed}
+	if statuses := revisionStatuses(t, client, "test"); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected revision statuses %v, got %v", expected, statuses)
+	}
+
+	list, err := client.List()
+	if err != nil {
+		t.Fatalf("List() returned unexpected error: %v", err)
+	}
+	if list.ListMeta.TotalItems != 1 || list.Releases[0].Revision != 3 || list.Releases[0].ChartVersion != "0.1.0" {
+		t.Errorf("unexpected release list %+v", list)
+	}
+
+	if _, err := client.Uninstall("test", false); err != nil {
+		t.Fatalf("Uninstall() returned unexpected error: %v", err)
+	}
+	if getWidget(t, dynamicClient, "test-widgets") != nil || getGadget(t, dynamicClient, "test-gadget") != nil {
+		t.Error("expected objects to be deleted")
+	}
+	if _, err := client.Get("test"); !errors.IsNotFoundError(err) {
+		t.Errorf("expected release to be deleted, got %v", err)
+	}
+}
+
+func TestReleaseInstallFailure(t *testing.T) {
+	client, dynamicClient := newFakeReleaseClient()
+	dynamicClient.PrependReactor("create", "widgets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("quota exceeded")
+	})
+
+	_, err := client.Install("", &InstallSpec{ChartSource: ChartSource{Archive: newArchive(t, widgetsChart)}, Name: "test"})
+	if err == nil {
+		t.Fatal("expected Install() to fail")
+	}
+	if getGadget(t, dynamicClient, "test-gadget") != nil {
+		t.Error("expected created gadget to be rolled back")
+	}
+
+	release, err := client.Get("test")
+	if err != nil {
+		t.Fatalf("Get() returned unexpected error: %v", err)
+	}
+	if release.Status != StatusFailed {
+		t.Errorf("expected failed release, got %+v", release.ReleaseSummary)
+	}
+
+	// Failed releases are kept until they are uninstalled.
+	if _, err := client.Uninstall("test", true); err != nil {
+		t.Fatalf("Uninstall() returned unexpected error: %v", err)
+	}
+	expected := []Status{StatusUninstalled}
+	if statuses := revisionStatuses(t, client, "test"); !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected revision statuses %v, got %v", expected, statuses)
+	}
+}
+
+func TestReleaseStorageEncoding(t *testing.T) {
+	release := &Release{
+		Name:      "test",
+		Namespace: "default",
+		Version:   2,
+		Manifest:  "---\n# Source: test/templates/test.yaml\nkind: Widget\n",
+		Config:    map[string]interface{}{"size": float64(3)},
+		Info:      &Info{Status: StatusDeployed, LastDeployed: now()},
+	}
+
+	secret, err := encodeRelease(release)
+	if err != nil {
+		t.Fatalf("encodeRelease() returned unexpected error: %v", err)
+	}
+	if secret.Name != "sh.helm.release.v1.test.v2" || secret.Labels["status"] != "deployed" ||
+		secret.Labels["owner"] != "helm" || secret.Type != releaseSecretType {
+		t.Errorf("unexpected secret %+v", secret.ObjectMeta)
+	}
+
+	decoded, err := decodeRelease(secret)
+	if err != nil {
+		t.Fatalf("decodeRelease() returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Config, release.Config) || decoded.Manifest != release.Manifest ||
+		!decoded.Info.LastDeployed.Equal(release.Info.LastDeployed.Time) || !decoded.Info.Deleted.IsZero() {
+		t.Errorf("decoded release %+v differs from %+v", decoded, release)
+	}
+}
+
+var jobHookChart = map[string]string{
+	"jobs/Chart.yaml": `apiVersion: v2
+name: jobs
+version: 0.1.0
+`,
+	"jobs/templates/widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ .Release.Name }}-widget
+`,
+	"jobs/templates/migrate.yaml": `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-migrate
+  annotations:
+    helm.sh/hook: post-install
+    helm.sh/hook-delete-policy: hook-failed
+`,
+}
+
+func TestReleaseHooks(t *testing.T) {
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = 2 * time.Second }()
+
+	cases := []struct {
+		name      string
+		condition string
+		status    Status
+		job       bool
+	}{
+		{"succeeded job is kept", "Complete", StatusDeployed, true},
+		{"failed job is deleted and fails the release", "Failed", StatusFailed, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client, dynamicClient := newFakeReleaseClient()
+			setStatusCondition(dynamicClient, "jobs", c.condition)
+
+			_, err := client.Install("", &InstallSpec{ChartSource: ChartSource{Archive: newArchive(t, jobHookChart)},
+				Name: "test"})
+			if (err != nil) != (c.status == StatusFailed) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			release, err := client.Get("test")
+			if err != nil {
+				t.Fatalf("Get() returned unexpected error: %v", err)
+			}
+			if release.Status != c.status {
+				t.Errorf("expected release status %s, got %s", c.status, release.Status)
+			}
+
+			_, err = dynamicClient.Resource(jobsResource).NamespaceWithMultiTenancy("default", "tenant").
+				Get("test-migrate", metaV1.GetOptions{})
+			if c.job != (err == nil) {
+				t.Errorf("expected job to exist: %t, got %v", c.job, err)
+			}
+		})
+	}
+}
+
+var crdChart = map[string]string{
+	"crds/Chart.yaml": `apiVersion: v2
+name: crds
+version: 0.1.0
+`,
+	"crds/crds/widgets.yaml": `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  version: v1
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+`,
+	"crds/templates/widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ .Release.Name }}-widget
+`,
+}
+
+func TestReleaseInstallCRDs(t *testing.T) {
+	pollInterval = time.Millisecond
+	defer func() { pollInterval = 2 * time.Second }()
+
+	client, dynamicClient := newFakeReleaseClient()
+	setStatusCondition(dynamicClient, "customresourcedefinitions", "Established")
+	archive := newArchive(t, crdChart)
+
+	if _, err := client.Install("", &InstallSpec{ChartSource: ChartSource{Archive: archive}, Name: "first"}); err != nil {
+		t.Fatalf("Install() returned unexpected error: %v", err)
+	}
+	if _, err := dynamicClient.Resource(crdsResource).Get("widgets.example.com", metaV1.GetOptions{}); err != nil {
+		t.Errorf("expected CRD to be created, got %v", err)
+	}
+	if created := createdNames(dynamicClient); created[0] != "widgets.example.com" {
+		t.Errorf("expected CRD to be created first, got %v", created)
+	}
+
+	// Existing CRDs are skipped and are not part of the manifest, so they are never deleted.
+	second, err := client.Install("", &InstallSpec{ChartSource: ChartSource{Archive: archive}, Name: "second"})
+	if err != nil {
+		t.Fatalf("Install() returned unexpected error for existing CRD: %v", err)
+	}
+	if strings.Contains(second.Manifest, "CustomResourceDefinition") {
+		t.Errorf("expected CRDs not to be part of the manifest, got %s", second.Manifest)
+	}
+}
+
+var lookupChart = map[string]string{
+	"lookup/Chart.yaml": `apiVersion: v2
+name: lookup
+version: 0.1.0
+`,
+	"lookup/templates/widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ .Release.Name }}-widget
+spec:
+  size: {{ (lookup "example.com/v1" "Widget" .Release.Namespace "existing").spec.size | default 1 }}
+  missing: {{ empty (lookup "example.com/v1" "Widget" .Release.Namespace "missing") }}
+  count: {{ len (lookup "example.com/v1" "Widget" .Release.Namespace "").items }}
+`,
+}
+
+func TestReleaseLookup(t *testing.T) {
+	client, dynamicClient := newFakeReleaseClient()
+	existing := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "existing"},
+		"spec":       map[string]interface{}{"size": int64(7)},
+	}}
+	_, err := dynamicClient.Resource(widgetsResource).NamespaceWithMultiTenancy("default", "tenant").
+		Create(existing, metaV1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("could not create widget: %v", err)
+	}
+
+	release, err := client.Install("", &InstallSpec{ChartSource: ChartSource{Archive: newArchive(t, lookupChart)},
+		Name: "test", DryRun: true})
+	if err != nil {
+		t.Fatalf("Install() returned unexpected error: %v", err)
+	}
+	for _, expected := range []string{"size: 7", "missing: true", "count: 1"} {
+		if !strings.Contains(release.Manifest, expected) {
+			t.Errorf("expected manifest to contain %q, got %s", expected, release.Manifest)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// MaxChartSize is the maximum size of an uncompressed chart archive.
+const MaxChartSize = 20 * 1024 * 1024
+
+// Metadata describes a chart. It is read from the Chart.yaml file of the chart.
+type Metadata struct {
+	Name         string            `json:"name,omitempty"`
+	Home         string            `json:"home,omitempty"`
+	Sources      []string          `json:"sources,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Keywords     []string          `json:"keywords,omitempty"`
+	Icon         string            `json:"icon,omitempty"`
+	APIVersion   string            `json:"apiVersion,omitempty"`
+	AppVersion   string            `json:"appVersion,omitempty"`
+	Deprecated   bool              `json:"deprecated,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	KubeVersion  string            `json:"kubeVersion,omitempty"`
+	Dependencies []*Dependency     `json:"dependencies,omitempty"`
+	Type         string            `json:"type,omitempty"`
+}
+
+// Dependency is a subchart listed in the Chart.yaml file of its parent.
+type Dependency struct {
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	Repository string `json:"repository"`
+	// Path of a boolean value in the parent values that enables the subchart.
+	Condition string `json:"condition,omitempty"`
+	Alias     string `json:"alias,omitempty"`
+}
+
+// File is a file of a chart.
+type File struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// Chart is a Helm chart. Its JSON representation is the one Helm uses to store charts with releases.
+type Chart struct {
+	Metadata  *Metadata              `json:"metadata"`
+	Templates []*File                `json:"templates"`
+	Values    map[string]interface{} `json:"values"`
+	Files     []*File                `json:"files"`
+
+	// Charts of the charts directory. Like in Helm, they are not stored with releases.
+	dependencies []*Chart
+}
+
+// LoadArchive loads a chart from a gzipped tar archive, the format of 'helm package'.
+func LoadArchive(data []byte) (*Chart, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("Chart is not a gzipped archive: %s", err.Error()))
+	}
+	defer gzipReader.Close()
+
+	files := make(map[string][]byte)
+	size := int64(0)
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.NewInvalid(fmt.Sprintf("Could not read chart archive: %s", err.Error()))
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		size += header.Size
+		if size > MaxChartSize {
+			return nil, errors.NewInvalid(fmt.Sprintf("Chart is larger than %d bytes", MaxChartSize))
+		}
+
+		// All files are in a directory named after the chart.
+		name := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) != 2 || strings.HasPrefix(parts[1], "..") {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		files[parts[1]] = content
+	}
+
+	return loadFiles(files)
+}
+
+// loadFiles loads a chart from its files, which are indexed by their path relative to the chart directory.
+func loadFiles(files map[string][]byte) (*Chart, error) {
+	data, ok := files["Chart.yaml"]
+	if !ok {
+		return nil, errors.NewInvalid("Chart.yaml file is missing")
+	}
+
+	chart := &Chart{
+		Metadata:  new(Metadata),
+		Templates: make([]*File, 0),
+		Values:    make(map[string]interface{}),
+		Files:     make([]*File, 0),
+	}
+	if err := yaml.Unmarshal(data, chart.Metadata); err != nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("Could not parse Chart.yaml: %s", err.Error()))
+	}
+	if len(chart.Metadata.Name) == 0 || len(chart.Metadata.Version) == 0 {
+		return nil, errors.NewInvalid("Chart.yaml has to contain the name and version of the chart")
+	}
+
+	subcharts := make(map[string]map[string][]byte)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content := files[name]
+		switch {
+		case name == "Chart.yaml":
+		case name == "values.yaml":
+			if err := yaml.Unmarshal(content, &chart.Values); err != nil {
+				return nil, errors.NewInvalid(fmt.Sprintf("Could not parse values.yaml: %s", err.Error()))
+			}
+			if chart.Values == nil {
+				chart.Values = make(map[string]interface{})
+			}
+		case strings.HasPrefix(name, "templates/"):
+			chart.Templates = append(chart.Templates, &File{Name: name, Data: content})
+		case strings.HasPrefix(name, "charts/") && strings.HasSuffix(name, ".tgz") && strings.Count(name, "/") == 1:
+			subchart, err := LoadArchive(content)
+			if err != nil {
+				return nil, err
+			}
+			chart.dependencies = append(chart.dependencies, subchart)
+		case strings.HasPrefix(name, "charts/") && strings.Count(name, "/") > 1:
+			parts := strings.SplitN(strings.TrimPrefix(name, "charts/"), "/", 2)
+			if _, ok := subcharts[parts[0]]; !ok {
+				subcharts[parts[0]] = make(map[string][]byte)
+			}
+			subcharts[parts[0]][parts[1]] = content
+		default:
+			chart.Files = append(chart.Files, &File{Name: name, Data: content})
+		}
+	}
+
+	for _, files := range subcharts {
+		subchart, err := loadFiles(files)
+		if err != nil {
+			return nil, err
+		}
+		chart.dependencies = append(chart.dependencies, subchart)
+	}
+	sort.Slice(chart.dependencies, func(i, j int) bool {
+		return chart.dependencies[i].Metadata.Name < chart.dependencies[j].Metadata.Name
+	})
+
+	return chart, nil
+}
+
+// dependency returns the Chart.yaml entry of the given subchart, if there is one.
+func (self *Chart) dependency(subchart *Chart) *Dependency {
+	for _, dependency := range self.Metadata.Dependencies {
+		if dependency.Name == subchart.Metadata.Name {
+			return dependency
+		}
+	}
+	return nil
+}
+
+// crdObjects returns the files of the crds directories of the chart and of its enabled subcharts. The
+// values have to be coalesced.
+func (self *Chart) crdObjects(values map[string]interface{}) []*File {
+	files := make([]*File, 0)
+	for _, file := range self.Files {
+		if strings.HasPrefix(file.Name, "crds/") {
+			files = append(files, file)
+		}
+	}
+
+	for _, subchart := range self.dependencies {
+		if !subchartEnabled(self, subchart, values) {
+			continue
+		}
+		subvalues, ok := values[subchartKey(self, subchart)].(map[string]interface{})
+		if !ok {
+			subvalues = make(map[string]interface{})
+		}
+		files = append(files, subchart.crdObjects(subvalues)...)
+	}
+	return files
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var widgetsChart = map[string]string{
+	"widgets/Chart.yaml": `apiVersion: v2
+name: widgets
+version: 0.1.0
+appVersion: "1.0"
+dependencies:
+- name: gadget
+  version: 0.2.0
+  repository: file://charts/gadget
+  condition: gadget.enabled
+`,
+	"widgets/values.yaml": `size: 1
+extra: false
+global:
+  owner: team
+`,
+	"widgets/templates/_helpers.tpl": `{{- define "widgets.fullname" -}}
+{{ .Release.Name }}-{{ .Chart.Name }}
+{{- end -}}
+`,
+	"widgets/templates/widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ include "widgets.fullname" . }}
+  labels:
+{{ toYaml (dict "owner" .Values.global.owner) | indent 4 }}
+spec:
+  size: {{ .Values.size }}
+{{- if .Values.extra }}
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ include "widgets.fullname" . }}-extra
+spec:
+  size: 0
+{{- end }}
+`,
+	"widgets/templates/hook.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: {{ .Release.Name }}-hook
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "5"
+    helm.sh/hook-delete-policy: hook-succeeded
+`,
+	"widgets/templates/NOTES.txt": `Installed {{ .Release.Name }} revision {{ .Release.Revision }}`,
+	"widgets/charts/gadget/Chart.yaml": `apiVersion: v2
+name: gadget
+version: 0.2.0
+`,
+	"widgets/charts/gadget/values.yaml": `color: red
+`,
+	"widgets/charts/gadget/templates/gadget.yaml": `apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: {{ .Release.Name }}-gadget
+spec:
+  color: {{ .Values.color | quote }}
+  owner: {{ .Values.global.owner }}
+`,
+	"widgets/charts/gadget/templates/NOTES.txt": `Not shown`,
+}
+
+func newArchive(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestLoadArchive(t *testing.T) {
+	chart, err := LoadArchive(newArchive(t, widgetsChart))
+	if err != nil {
+		t.Fatalf("LoadArchive() returned unexpected error: %v", err)
+	}
+
+	if chart.Metadata.Name != "widgets" || chart.Metadata.AppVersion != "1.0" {
+		t.Errorf("unexpected metadata %+v", chart.Metadata)
+	}
+	if len(chart.Templates) != 4 {
+		t.Errorf("expected 4 templates, got %d", len(chart.Templates))
+	}
+	if len(chart.dependencies) != 1 || chart.dependencies[0].Metadata.Name != "gadget" {
+		t.Errorf("expected gadget subchart, got %v", chart.dependencies)
+	}
+
+	if _, err := LoadArchive([]byte("not an archive")); err == nil {
+		t.Error("expected error for invalid archive")
+	}
+	if _, err := LoadArchive(newArchive(t, map[string]string{"widgets/values.yaml": "size: 1"})); err == nil {
+		t.Error("expected error for archive without Chart.yaml")
+	}
+}
+
+func TestRender(t *testing.T) {
+	chart, err := LoadArchive(newArchive(t, widgetsChart))
+	if err != nil {
+		t.Fatalf("LoadArchive() returned unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		values    string
+		sources   []string
+		contained []string
+	}{
+		{
+			"size: 3\ngadget:\n  color: blue\n",
+			[]string{"widgets/charts/gadget/templates/gadget.yaml", "widgets/templates/widget.yaml"},
+			[]string{"size: 3", `color: "blue"`, "owner: team", "name: test-widgets"},
+		},
+		{
+			"extra: true\ngadget:\n  enabled: false\n",
+			[]string{"widgets/templates/widget.yaml", "widgets/templates/widget.yaml"},
+			[]string{"size: 1", "name: test-widgets-extra"},
+		},
+	}
+	for _, c := range cases {
+		values, err := parseValues(c.values)
+		if err != nil {
+			t.Fatalf("parseValues(%q) returned unexpected error: %v", c.values, err)
+		}
+		rendered, err := render(chart, coalesceValues(chart, values),
+			releaseInfo{Name: "test", Revision: 2}, &Capabilities{}, nil)
+		if err != nil {
+			t.Fatalf("render() returned unexpected error: %v", err)
+		}
+
+		manifests, hooks, notes, err := splitManifests("widgets", rendered)
+		if err != nil {
+			t.Fatalf("splitManifests() returned unexpected error: %v", err)
+		}
+
+		sources := make([]string, 0)
+		for _, manifest := range manifests {
+			sources = append(sources, manifest.source)
+		}
+		if !reflect.DeepEqual(sources, c.sources) {
+			t.Errorf("values %q: expected manifests from %v, got %v", c.values, c.sources, sources)
+		}
+		joined := joinManifests(manifests)
+		for _, text := range c.contained {
+			if !strings.Contains(joined, text) {
+				t.Errorf("values %q: expected manifest to contain %q:\n%s", c.values, text, joined)
+			}
+		}
+		if len(hooks) != 1 || !reflect.DeepEqual(hooks[0].Events, []string{"pre-install", "pre-upgrade"}) ||
+			hooks[0].Weight != 5 || !reflect.DeepEqual(hooks[0].DeletePolicies, []string{"hook-succeeded"}) {
+			t.Errorf("unexpected hooks %+v", hooks)
+		}
+		if notes != "Installed test revision 2" {
+			t.Errorf("unexpected notes %q", notes)
+		}
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	cases := []string{
+		`{{ required "size is required" .Values.missing }}`,
+		`{{ include "missing" . }}`,
+		`{{ .Values.size`,
+		"kind: Widget\nmetadata:\n  name: test\n",
+	}
+	for _, template := range cases {
+		chart, err := LoadArchive(newArchive(t, map[string]string{
+			"test/Chart.yaml":          "name: test\nversion: 1.0.0\n",
+			"test/templates/test.yaml": template,
+		}))
+		if err != nil {
+			t.Fatalf("LoadArchive() returned unexpected error: %v", err)
+		}
+
+		rendered, err := render(chart, coalesceValues(chart, nil), releaseInfo{Name: "test"}, &Capabilities{}, nil)
+		if err == nil {
+			_, _, _, err = splitManifests("test", rendered)
+		}
+		if err == nil {
+			t.Errorf("expected error for template %q", template)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// Events of the release lifecycle at which hooks are run.
+const (
+	hookPreInstall   = "pre-install"
+	hookPostInstall  = "post-install"
+	hookPreUpgrade   = "pre-upgrade"
+	hookPostUpgrade  = "post-upgrade"
+	hookPreRollback  = "pre-rollback"
+	hookPostRollback = "post-rollback"
+	hookPreDelete    = "pre-delete"
+	hookPostDelete   = "post-delete"
+)
+
+// Policies that decide when hook objects are deleted.
+const (
+	hookBeforeHookCreation = "before-hook-creation"
+	hookSucceeded          = "hook-succeeded"
+	hookFailed             = "hook-failed"
+)
+
+var (
+	// Interval in which hook jobs and pods and installed CRDs are checked.
+	pollInterval = 2 * time.Second
+
+	// Time after which a hook or CRD that did not become ready fails, the default of Helm.
+	readyTimeout = 5 * time.Minute
+)
+
+// runHooks runs the hooks of the release for the event in the order of their weights and names, like
+// Helm does. Jobs and pods are waited for until they have completed, other objects are only created.
+// Objects of earlier runs are deleted before a hook is created, unless the hook sets other delete
+// policies.
+func (self *ReleaseClient) runHooks(release *Release, event string) error {
+	hooks := make([]*Hook, 0)
+	for _, hook := range release.Hooks {
+		for _, e := range hook.Events {
+			if e == event {
+				hooks = append(hooks, hook)
+				break
+			}
+		}
+	}
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if hooks[i].Weight != hooks[j].Weight {
+			return hooks[i].Weight < hooks[j].Weight
+		}
+		return hooks[i].Name < hooks[j].Name
+	})
+
+	clients := make(map[*Hook]dynamic.ResourceInterface)
+	for _, hook := range hooks {
+		object := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(hook.Manifest), &object.Object); err != nil {
+			return fmt.Errorf("could not parse %s hook %s: %v", event, hook.Name, err)
+		}
+		client, err := self.resourceClient(object.GroupVersionKind(), self.namespace)
+		if err != nil {
+			return err
+		}
+		clients[hook] = client
+
+		if len(hook.DeletePolicies) == 0 || hasDeletePolicy(hook, hookBeforeHookCreation) {
+			if err := deleteAndWait(client, hook.Name); err != nil {
+				return fmt.Errorf("could not delete %s hook %s of an earlier run: %v", event, hook.Name, err)
+			}
+		}
+
+		hook.LastRun = HookExecution{StartedAt: now(), Phase: HookPhaseRunning}
+		if _, err = client.Create(object, metaV1.CreateOptions{}); err == nil {
+			err = waitForHook(client, hook)
+		}
+		hook.LastRun.CompletedAt = now()
+		if err != nil {
+			hook.LastRun.Phase = HookPhaseFailed
+			if hasDeletePolicy(hook, hookFailed) {
+				deleteHook(client, hook)
+			}
+			return fmt.Errorf("%s hook %s failed: %v", event, hook.Name, err)
+		}
+		hook.LastRun.Phase = HookPhaseSucceeded
+	}
+
+	// Like in Helm, succeeded hooks are only deleted after all hooks of the event have run.
+	for _, hook := range hooks {
+		if hasDeletePolicy(hook, hookSucceeded) {
+			deleteHook(clients[hook], hook)
+		}
+	}
+	return nil
+}
+
+// waitForHook waits until the job or pod of the hook has completed. Other kinds are ready once created.
+func waitForHook(client dynamic.ResourceInterface, hook *Hook) error {
+	if hook.Kind != "Job" && hook.Kind != "Pod" {
+		return nil
+	}
+
+	return wait.PollImmediate(pollInterval, readyTimeout, func() (bool, error) {
+		object, err := client.Get(hook.Name, metaV1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		if hook.Kind == "Pod" {
+			phase, _, _ := unstructured.NestedString(object.Object, "status", "phase")
+			switch phase {
+			case "Succeeded":
+				return true, nil
+			case "Failed":
+				return false, fmt.Errorf("pod %s failed", hook.Name)
+			}
+			return false, nil
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+		for _, condition := range conditions {
+			condition, ok := condition.(map[string]interface{})
+			if !ok || condition["status"] != "True" {
+				continue
+			}
+			switch condition["type"] {
+			case "Complete":
+				return true, nil
+			case "Failed":
+				return false, fmt.Errorf("job %s failed: %v", hook.Name, condition["message"])
+			}
+		}
+		return false, nil
+	})
+}
+
+// deleteAndWait deletes the object and waits until it is gone, so that it can be created again.
+func deleteAndWait(client dynamic.ResourceInterface, name string) error {
+	propagation := metaV1.DeletePropagationBackground
+	err := client.Delete(name, &metaV1.DeleteOptions{PropagationPolicy: &propagation})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return wait.PollImmediate(pollInterval, readyTimeout, func() (bool, error) {
+		_, err := client.Get(name, metaV1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// deleteHook deletes the object of the hook after it has run. Errors are only recorded in the log, since
+// the hook itself has already run.
+func deleteHook(client dynamic.ResourceInterface, hook *Hook) {
+	propagation := metaV1.DeletePropagationBackground
+	err := client.Delete(hook.Name, &metaV1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		log.Printf("Could not delete hook %s: %v", hook.Name, err)
+	}
+}
+
+func hasDeletePolicy(hook *Hook, policy string) bool {
+	for _, p := range hook.DeletePolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
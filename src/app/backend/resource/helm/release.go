@@ -0,0 +1,386 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Status is the status of a release revision.
+type Status string
+
+// List of all release statuses, as defined by Helm.
+const (
+	StatusUnknown         Status = "unknown"
+	StatusDeployed        Status = "deployed"
+	StatusUninstalled     Status = "uninstalled"
+	StatusSuperseded      Status = "superseded"
+	StatusFailed          Status = "failed"
+	StatusUninstalling    Status = "uninstalling"
+	StatusPendingInstall  Status = "pending-install"
+	StatusPendingUpgrade  Status = "pending-upgrade"
+	StatusPendingRollback Status = "pending-rollback"
+)
+
+// Annotations with which templates are marked as hooks and the order and cleanup of hooks is configured.
+const (
+	hookAnnotation             = "helm.sh/hook"
+	hookWeightAnnotation       = "helm.sh/hook-weight"
+	hookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+)
+
+// Label and annotations with which Helm marks the objects of a release. The Helm CLI refuses to manage
+// objects of a release that do not carry them.
+const (
+	managedByLabel             = "app.kubernetes.io/managed-by"
+	releaseNameAnnotation      = "meta.helm.sh/release-name"
+	releaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	managedByHelm              = "Helm"
+)
+
+// Time is a timestamp of a release. Like in Helm, zero timestamps are stored as empty strings.
+type Time struct {
+	time.Time
+}
+
+// MarshalJSON implements json.Marshaler.
+func (self Time) MarshalJSON() ([]byte, error) {
+	if self.IsZero() {
+		return []byte(`""`), nil
+	}
+	return self.Time.MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (self *Time) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` || string(data) == "null" {
+		return nil
+	}
+	return self.Time.UnmarshalJSON(data)
+}
+
+func now() Time {
+	return Time{time.Now().UTC()}
+}
+
+// Info describes a release revision.
+type Info struct {
+	FirstDeployed Time   `json:"first_deployed,omitempty"`
+	LastDeployed  Time   `json:"last_deployed,omitempty"`
+	Deleted       Time   `json:"deleted"`
+	Description   string `json:"description,omitempty"`
+	Status        Status `json:"status,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+}
+
+// Hook is a template that is run at a certain point of the release lifecycle instead of being deployed
+// with the release.
+type Hook struct {
+	Name           string        `json:"name,omitempty"`
+	Kind           string        `json:"kind,omitempty"`
+	Path           string        `json:"path,omitempty"`
+	Manifest       string        `json:"manifest,omitempty"`
+	Events         []string      `json:"events,omitempty"`
+	LastRun        HookExecution `json:"last_run"`
+	Weight         int           `json:"weight,omitempty"`
+	DeletePolicies []string      `json:"delete_policies,omitempty"`
+}
+
+// HookExecution describes the last run of a hook.
+type HookExecution struct {
+	StartedAt   Time      `json:"started_at,omitempty"`
+	CompletedAt Time      `json:"completed_at,omitempty"`
+	Phase       HookPhase `json:"phase"`
+}
+
+// HookPhase is the state of the last run of a hook.
+type HookPhase string
+
+// List of all hook phases, as defined by Helm.
+const (
+	HookPhaseUnknown   HookPhase = "Unknown"
+	HookPhaseRunning   HookPhase = "Running"
+	HookPhaseSucceeded HookPhase = "Succeeded"
+	HookPhaseFailed    HookPhase = "Failed"
+)
+
+// Release is a revision of a release. Its JSON representation is the one of Helm 3, so that releases are
+// shared with the Helm CLI.
+type Release struct {
+	Name      string                 `json:"name,omitempty"`
+	Info      *Info                  `json:"info,omitempty"`
+	Chart     *Chart                 `json:"chart,omitempty"`
+	Config    map[string]interface{} `json:"config,omitempty"`
+	Manifest  string                 `json:"manifest,omitempty"`
+	Hooks     []*Hook                `json:"hooks,omitempty"`
+	Version   int                    `json:"version,omitempty"`
+	Namespace string                 `json:"namespace,omitempty"`
+}
+
+// ReleaseSummary is a release revision as shown in lists and the history of a release.
+type ReleaseSummary struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	Revision     int    `json:"revision"`
+	Updated      Time   `json:"updated"`
+	Status       Status `json:"status"`
+	Chart        string `json:"chart"`
+	ChartVersion string `json:"chartVersion"`
+	AppVersion   string `json:"appVersion"`
+	Description  string `json:"description"`
+}
+
+// ReleaseList contains the latest revision of every release of a namespace.
+type ReleaseList struct {
+	ListMeta api.ListMeta     `json:"listMeta"`
+	Releases []ReleaseSummary `json:"releases"`
+}
+
+// ReleaseHistory contains all stored revisions of a release, the oldest first.
+type ReleaseHistory struct {
+	Name      string           `json:"name"`
+	Revisions []ReleaseSummary `json:"revisions"`
+}
+
+// ReleaseDetail is a release revision with the values it was installed with and the rendered manifest.
+type ReleaseDetail struct {
+	ReleaseSummary `json:",inline"`
+	Values         map[string]interface{} `json:"values"`
+	Manifest       string                 `json:"manifest"`
+	Notes          string                 `json:"notes"`
+}
+
+func toReleaseSummary(release *Release) ReleaseSummary {
+	summary := ReleaseSummary{
+		Name:      release.Name,
+		Namespace: release.Namespace,
+		Revision:  release.Version,
+	}
+	if release.Info != nil {
+		summary.Updated = release.Info.LastDeployed
+		summary.Status = release.Info.Status
+		summary.Description = release.Info.Description
+	}
+	if release.Chart != nil && release.Chart.Metadata != nil {
+		summary.Chart = release.Chart.Metadata.Name
+		summary.ChartVersion = release.Chart.Metadata.Version
+		summary.AppVersion = release.Chart.Metadata.AppVersion
+	}
+	return summary
+}
+
+func toReleaseDetail(release *Release) *ReleaseDetail {
+	detail := &ReleaseDetail{
+		ReleaseSummary: toReleaseSummary(release),
+		Values:         release.Config,
+		Manifest:       release.Manifest,
+	}
+	if release.Info != nil {
+		detail.Notes = release.Info.Notes
+	}
+	return detail
+}
+
+// Order in which Helm installs resources. Unknown kinds are installed last.
+var installOrder = []string{
+	"Namespace", "NetworkPolicy", "ResourceQuota", "LimitRange", "PodSecurityPolicy", "PodDisruptionBudget",
+	"ServiceAccount", "Secret", "SecretList", "ConfigMap", "StorageClass", "PersistentVolume",
+	"PersistentVolumeClaim", "CustomResourceDefinition", "ClusterRole", "ClusterRoleList", "ClusterRoleBinding",
+	"ClusterRoleBindingList", "Role", "RoleList", "RoleBinding", "RoleBindingList", "Service", "DaemonSet", "Pod",
+	"ReplicationController", "ReplicaSet", "Deployment", "HorizontalPodAutoscaler", "StatefulSet", "Job",
+	"CronJob", "Ingress", "APIService",
+}
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// manifest is a single object rendered from a template.
+type manifest struct {
+	source  string
+	content string
+	head    manifestHead
+}
+
+// manifestHead contains the fields of an object that identify it.
+type manifestHead struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// key identifies the object of the manifest in a release. Like in Helm, the version is ignored, so that
+// objects whose API version changed between revisions are not deleted.
+func (self manifest) key() string {
+	group := ""
+	if gv, err := schema.ParseGroupVersion(self.head.APIVersion); err == nil {
+		group = gv.Group
+	}
+	return fmt.Sprintf("%s/%s/%s", group, self.head.Kind, self.head.Metadata.Name)
+}
+
+// splitManifests splits the rendered templates into objects sorted in install order. Hooks are returned
+// separately and the notes of the chart are returned as text.
+func splitManifests(chartName string, rendered map[string]string) ([]manifest, []*Hook, string, error) {
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifests := make([]manifest, 0)
+	hooks := make([]*Hook, 0)
+	notes := ""
+	for _, name := range names {
+		if path.Base(name) == "NOTES.txt" {
+			// Only the notes of the chart are shown, not the ones of its subcharts.
+			if name == path.Join(chartName, "templates", "NOTES.txt") {
+				notes = rendered[name]
+			}
+			continue
+		}
+
+		for _, content := range documentSeparator.Split(rendered[name], -1) {
+			content = strings.TrimSpace(content)
+			if len(content) == 0 {
+				continue
+			}
+
+			var head manifestHead
+			if err := yaml.Unmarshal([]byte(content), &head); err != nil {
+				return nil, nil, "", errors.NewInvalid(fmt.Sprintf("%s: %s", name, err.Error()))
+			}
+			// Documents that only contain comments are skipped.
+			if len(head.APIVersion) == 0 && len(head.Kind) == 0 && len(head.Metadata.Name) == 0 {
+				continue
+			}
+			if len(head.APIVersion) == 0 || len(head.Kind) == 0 {
+				return nil, nil, "", errors.NewInvalid(fmt.Sprintf("%s: apiVersion and kind have to be set", name))
+			}
+
+			if events, ok := head.Metadata.Annotations[hookAnnotation]; ok {
+				hook := &Hook{Name: head.Metadata.Name, Kind: head.Kind, Path: name, Manifest: content}
+				for _, event := range strings.Split(events, ",") {
+					hook.Events = append(hook.Events, strings.TrimSpace(event))
+				}
+				// Like in Helm, invalid weights are treated as 0.
+				hook.Weight, _ = strconv.Atoi(strings.TrimSpace(head.Metadata.Annotations[hookWeightAnnotation]))
+				if policies, ok := head.Metadata.Annotations[hookDeletePolicyAnnotation]; ok {
+					for _, policy := range strings.Split(policies, ",") {
+						hook.DeletePolicies = append(hook.DeletePolicies, strings.TrimSpace(policy))
+					}
+				}
+				hooks = append(hooks, hook)
+				continue
+			}
+			manifests = append(manifests, manifest{source: name, content: content, head: head})
+		}
+	}
+
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return installRank(manifests[i].head.Kind) < installRank(manifests[j].head.Kind)
+	})
+	return manifests, hooks, notes, nil
+}
+
+func installRank(kind string) int {
+	for i, k := range installOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(installOrder)
+}
+
+// joinManifests returns the manifest of a release in the format of Helm.
+func joinManifests(manifests []manifest) string {
+	builder := &strings.Builder{}
+	for _, manifest := range manifests {
+		fmt.Fprintf(builder, "---\n# Source: %s\n%s\n", manifest.source, manifest.content)
+	}
+	return builder.String()
+}
+
+// withOwnership returns the manifests with the label and annotations that mark their objects as part of
+// the release, in the format deploy from file expects. Like in Helm, the stored manifest of the release
+// does not contain them.
+func withOwnership(manifests []manifest, name, namespace string) (string, error) {
+	builder := &strings.Builder{}
+	for _, manifest := range manifests {
+		object := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(manifest.content), &object.Object); err != nil {
+			return "", errors.NewInvalid(fmt.Sprintf("%s: %s", manifest.source, err.Error()))
+		}
+
+		setOwnership := func(object runtime.Object) error {
+			accessor, err := meta.Accessor(object)
+			if err != nil {
+				return err
+			}
+			labels := accessor.GetLabels()
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[managedByLabel] = managedByHelm
+			accessor.SetLabels(labels)
+
+			annotations := accessor.GetAnnotations()
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[releaseNameAnnotation] = name
+			annotations[releaseNamespaceAnnotation] = namespace
+			accessor.SetAnnotations(annotations)
+			return nil
+		}
+		var err error
+		if object.IsList() {
+			err = object.EachListItem(setOwnership)
+		} else {
+			err = setOwnership(object)
+		}
+		if err != nil {
+			return "", errors.NewInvalid(fmt.Sprintf("%s: %s", manifest.source, err.Error()))
+		}
+
+		data, err := yaml.Marshal(object.Object)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(builder, "---\n%s", data)
+	}
+	return builder.String(), nil
+}
+
+// parseManifest parses the manifest of a stored release into its objects.
+func parseManifest(content string) ([]manifest, error) {
+	manifests, _, _, err := splitManifests("", map[string]string{"manifest": content})
+	return manifests, err
+}
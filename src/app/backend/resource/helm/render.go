@@ -0,0 +1,357 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/Masterminds/sprig/v3"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/yaml"
+)
+
+// Maximum depth of nested 'include' calls, to stop templates that include themselves.
+const maxIncludeDepth = 1000
+
+// releaseInfo is the information about the release that templates can access as .Release.
+type releaseInfo struct {
+	Name      string
+	Namespace string
+	Revision  int
+	IsInstall bool
+	IsUpgrade bool
+	Service   string
+}
+
+// Capabilities describe the cluster to templates, which can access them as .Capabilities.
+type Capabilities struct {
+	KubeVersion KubeVersion
+	APIVersions VersionSet
+}
+
+// KubeVersion is the version of the cluster.
+type KubeVersion struct {
+	Version    string
+	Major      string
+	Minor      string
+	GitVersion string
+}
+
+// String implements fmt.Stringer.
+func (self KubeVersion) String() string {
+	return self.Version
+}
+
+// VersionSet is the set of group versions served by the cluster.
+type VersionSet []string
+
+// Has returns true if the group version is served by the cluster.
+func (self VersionSet) Has(version string) bool {
+	for _, v := range self {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCapabilities reads the capabilities of the cluster with the discovery API.
+func GetCapabilities(client discovery.DiscoveryInterface) (*Capabilities, error) {
+	version, err := client.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := &Capabilities{
+		KubeVersion: KubeVersion{
+			Version:    version.GitVersion,
+			Major:      version.Major,
+			Minor:      version.Minor,
+			GitVersion: version.GitVersion,
+		},
+		APIVersions: make(VersionSet, 0),
+	}
+	for _, group := range groups.Groups {
+		for _, version := range group.Versions {
+			capabilities.APIVersions = append(capabilities.APIVersions, version.GroupVersion)
+		}
+	}
+	return capabilities, nil
+}
+
+// Files gives templates access to the files of a chart that are neither templates nor values. Templates
+// can access them as .Files.
+type Files map[string][]byte
+
+// Get returns the content of the file or an empty string if it does not exist.
+func (self Files) Get(name string) string {
+	return string(self[name])
+}
+
+// GetBytes returns the content of the file or nil if it does not exist.
+func (self Files) GetBytes(name string) []byte {
+	return self[name]
+}
+
+// Lines returns the lines of the file.
+func (self Files) Lines(name string) []string {
+	if len(self[name]) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(self[name]), "\n"), "\n")
+}
+
+// Glob returns the files whose names match the pattern.
+func (self Files) Glob(pattern string) Files {
+	result := make(Files)
+	for name, data := range self {
+		if matched, _ := path.Match(pattern, name); matched {
+			result[name] = data
+		}
+	}
+	return result
+}
+
+// AsConfig returns the files as the YAML data of a config map.
+func (self Files) AsConfig() string {
+	data := make(map[string]string)
+	for name, content := range self {
+		data[path.Base(name)] = string(content)
+	}
+	return toYAML(data)
+}
+
+// AsSecrets returns the files as the YAML data of a secret.
+func (self Files) AsSecrets() string {
+	data := make(map[string]string)
+	for name, content := range self {
+		data[path.Base(name)] = base64.StdEncoding.EncodeToString(content)
+	}
+	return toYAML(data)
+}
+
+// renderEntry is a template together with the data it is executed with.
+type renderEntry struct {
+	name    string
+	context map[string]interface{}
+}
+
+// lookupFunc returns an object of the cluster, or the list of objects of the kind if the name is empty.
+type lookupFunc func(apiVersion, kind, namespace, name string) (map[string]interface{}, error)
+
+// renderer renders the templates of a chart and its subcharts. All templates are parsed into the same
+// set, so that charts can include the named templates of their subcharts and vice versa.
+type renderer struct {
+	root    *template.Template
+	entries []renderEntry
+	depth   int
+	lookup  lookupFunc
+}
+
+// render executes all templates of the chart with the given values, which have to be coalesced. It
+// returns the output of every template indexed by its name, e.g. 'mychart/templates/service.yaml'.
+// Partial templates, whose names start with an underscore, are not returned. If lookup is nil, 'lookup'
+// returns empty results, the same as during 'helm template'.
+func render(chart *Chart, values map[string]interface{}, release releaseInfo, capabilities *Capabilities,
+	lookup lookupFunc) (map[string]string, error) {
+	if lookup == nil {
+		lookup = func(string, string, string, string) (map[string]interface{}, error) {
+			return map[string]interface{}{}, nil
+		}
+	}
+	self := &renderer{root: template.New("gotpl"), entries: make([]renderEntry, 0), lookup: lookup}
+	self.root.Option("missingkey=zero")
+	self.root.Funcs(self.funcMap())
+
+	if err := self.add(chart, chart.Metadata, chart.Metadata.Name, values, release, capabilities); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, entry := range self.entries {
+		if strings.HasPrefix(path.Base(entry.name), "_") {
+			continue
+		}
+		builder := &strings.Builder{}
+		if err := self.root.ExecuteTemplate(builder, entry.name, entry.context); err != nil {
+			return nil, errors.NewInvalid(fmt.Sprintf("Could not render template: %s", err.Error()))
+		}
+		// Missing values are rendered as empty strings, like Helm does.
+		result[entry.name] = strings.Replace(builder.String(), "<no value>", "", -1)
+	}
+	return result, nil
+}
+
+// add parses the templates of the chart and of all enabled subcharts.
+func (self *renderer) add(chart *Chart, metadata *Metadata, chartPath string, values map[string]interface{},
+	release releaseInfo, capabilities *Capabilities) error {
+	files := make(Files)
+	for _, file := range chart.Files {
+		files[file.Name] = file.Data
+	}
+
+	templates := make([]*File, len(chart.Templates))
+	copy(templates, chart.Templates)
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	for _, file := range templates {
+		name := path.Join(chartPath, file.Name)
+		if _, err := self.root.New(name).Parse(string(file.Data)); err != nil {
+			return errors.NewInvalid(fmt.Sprintf("Could not parse template: %s", err.Error()))
+		}
+		self.entries = append(self.entries, renderEntry{
+			name: name,
+			context: map[string]interface{}{
+				"Values":       values,
+				"Release":      release,
+				"Chart":        metadata,
+				"Capabilities": capabilities,
+				"Files":        files,
+				"Template": map[string]interface{}{
+					"Name":     name,
+					"BasePath": path.Join(chartPath, "templates"),
+				},
+			},
+		})
+	}
+
+	for _, subchart := range chart.dependencies {
+		if !subchartEnabled(chart, subchart, values) {
+			continue
+		}
+		key := subchartKey(chart, subchart)
+		subvalues, ok := values[key].(map[string]interface{})
+		if !ok {
+			subvalues = make(map[string]interface{})
+		}
+		// An aliased subchart is rendered under its alias.
+		submetadata := subchart.Metadata
+		if key != submetadata.Name {
+			copied := *submetadata
+			copied.Name = key
+			submetadata = &copied
+		}
+		err := self.add(subchart, submetadata, path.Join(chartPath, "charts", key), subvalues, release, capabilities)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// funcMap returns the functions available to templates. Like in Helm these are the Sprig functions
+// without access to the environment, plus a few Helm specific ones.
+func (self *renderer) funcMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+
+	funcs["toYaml"] = toYAML
+	funcs["fromYaml"] = fromYAML
+	funcs["toJson"] = toJSON
+	funcs["fromJson"] = fromJSON
+	funcs["include"] = self.include
+	funcs["tpl"] = self.tpl
+	funcs["required"] = required
+	funcs["lookup"] = self.lookup
+	return funcs
+}
+
+// include executes the named template and returns its output, so that it can be piped.
+func (self *renderer) include(name string, data interface{}) (string, error) {
+	if self.depth >= maxIncludeDepth {
+		return "", fmt.Errorf("rendering template has a nested reference name: %s", name)
+	}
+	self.depth++
+	defer func() { self.depth-- }()
+
+	builder := &strings.Builder{}
+	if err := self.root.ExecuteTemplate(builder, name, data); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// tpl executes the given text as a template, which has access to all named templates.
+func (self *renderer) tpl(text string, data interface{}) (string, error) {
+	root, err := self.root.Clone()
+	if err != nil {
+		return "", err
+	}
+	tpl, err := root.New("tpl").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	builder := &strings.Builder{}
+	if err := tpl.Execute(builder, data); err != nil {
+		return "", err
+	}
+	return strings.Replace(builder.String(), "<no value>", "", -1), nil
+}
+
+func required(message string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, fmt.Errorf("%s", message)
+	}
+	if text, ok := value.(string); ok && len(text) == 0 {
+		return nil, fmt.Errorf("%s", message)
+	}
+	return value, nil
+}
+
+// toYAML marshals the value to YAML. Errors are rendered as empty strings, like in Helm.
+func toYAML(value interface{}) string {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+// fromYAML unmarshals the YAML object. Errors are returned under the 'Error' key, like in Helm.
+func fromYAML(text string) map[string]interface{} {
+	result := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(text), &result); err != nil {
+		result["Error"] = err.Error()
+	}
+	return result
+}
+
+func toJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func fromJSON(text string) map[string]interface{} {
+	result := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		result["Error"] = err.Error()
+	}
+	return result
+}
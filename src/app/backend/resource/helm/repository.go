@@ -0,0 +1,226 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// Maximum size of the index file of a repository.
+const maxIndexSize = 50 * 1024 * 1024
+
+var repositoryClient = &http.Client{Timeout: 30 * time.Second}
+
+// ChartVersion is a version of a chart in the index of a repository.
+type ChartVersion struct {
+	Metadata `json:",inline"`
+	URLs     []string  `json:"urls"`
+	Created  time.Time `json:"created,omitempty"`
+	Digest   string    `json:"digest,omitempty"`
+}
+
+// indexFile is the index.yaml file of a chart repository.
+type indexFile struct {
+	APIVersion string                     `json:"apiVersion"`
+	Entries    map[string][]*ChartVersion `json:"entries"`
+}
+
+// RepositoryChart is a chart of the repository with all of its versions, the latest first.
+type RepositoryChart struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Icon        string   `json:"icon"`
+	Version     string   `json:"version"`
+	AppVersion  string   `json:"appVersion"`
+	Versions    []string `json:"versions"`
+}
+
+// RepositoryChartList contains the charts of the configured repository.
+type RepositoryChartList struct {
+	ListMeta api.ListMeta      `json:"listMeta"`
+	Charts   []RepositoryChart `json:"charts"`
+}
+
+// GetRepositoryChartList returns the charts of the repository with the given URL.
+func GetRepositoryChartList(repositoryURL string) (*RepositoryChartList, error) {
+	index, err := loadIndex(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepositoryChartList{Charts: make([]RepositoryChart, 0, len(index.Entries))}
+	for name, versions := range index.Entries {
+		sortVersions(versions)
+		if len(versions) == 0 {
+			continue
+		}
+		chart := RepositoryChart{
+			Name:        name,
+			Description: versions[0].Description,
+			Icon:        versions[0].Icon,
+			Version:     versions[0].Version,
+			AppVersion:  versions[0].AppVersion,
+			Versions:    make([]string, 0, len(versions)),
+		}
+		for _, version := range versions {
+			chart.Versions = append(chart.Versions, version.Version)
+		}
+		result.Charts = append(result.Charts, chart)
+	}
+	sort.Slice(result.Charts, func(i, j int) bool { return result.Charts[i].Name < result.Charts[j].Name })
+	result.ListMeta = api.ListMeta{TotalItems: len(result.Charts)}
+	return result, nil
+}
+
+// fetchChart downloads and loads a chart from the repository. The version is a semantic version
+// constraint. If it is empty, the latest stable version is used.
+func fetchChart(repositoryURL, name, version string) (*Chart, error) {
+	index, err := loadIndex(repositoryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	chartVersion, err := index.get(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(chartVersion.URLs) == 0 {
+		return nil, errors.NewInvalid(fmt.Sprintf("Chart %s %s has no download URL", name, chartVersion.Version))
+	}
+
+	chartURL, err := resolveURL(repositoryURL, chartVersion.URLs[0])
+	if err != nil {
+		return nil, err
+	}
+	data, err := download(chartURL, MaxChartSize)
+	if err != nil {
+		return nil, err
+	}
+	return LoadArchive(data)
+}
+
+func loadIndex(repositoryURL string) (*indexFile, error) {
+	if len(repositoryURL) == 0 {
+		return nil, errors.NewInvalid("No Helm chart repository is configured")
+	}
+
+	indexURL, err := resolveURL(repositoryURL, "index.yaml")
+	if err != nil {
+		return nil, err
+	}
+	data, err := download(indexURL, maxIndexSize)
+	if err != nil {
+		return nil, err
+	}
+
+	index := new(indexFile)
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("could not parse repository index: %s", err.Error())
+	}
+	return index, nil
+}
+
+// get returns the latest version of the chart that matches the constraint. Prereleases only match if the
+// constraint is an exact version or contains a prerelease.
+func (self *indexFile) get(name, constraint string) (*ChartVersion, error) {
+	versions, ok := self.Entries[name]
+	if !ok {
+		return nil, errors.NewNotFound(fmt.Sprintf("Chart %s not found in repository", name))
+	}
+	if len(constraint) == 0 {
+		constraint = "*"
+	}
+
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("Invalid chart version %q: %s", constraint, err.Error()))
+	}
+
+	sortVersions(versions)
+	for _, version := range versions {
+		if version.Version == constraint {
+			return version, nil
+		}
+		parsed, err := semver.NewVersion(version.Version)
+		if err != nil {
+			continue
+		}
+		if constraints.Check(parsed) {
+			return version, nil
+		}
+	}
+	return nil, errors.NewNotFound(fmt.Sprintf("Chart %s has no version matching %q", name, constraint))
+}
+
+// sortVersions sorts the versions of a chart, the latest first.
+func sortVersions(versions []*ChartVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		first, err := semver.NewVersion(versions[i].Version)
+		if err != nil {
+			return false
+		}
+		second, err := semver.NewVersion(versions[j].Version)
+		if err != nil {
+			return true
+		}
+		return first.GreaterThan(second)
+	})
+}
+
+// resolveURL resolves a URL of the index, which may be relative to the repository.
+func resolveURL(repositoryURL, reference string) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(repositoryURL, "/") + "/")
+	if err != nil {
+		return "", errors.NewInvalid(fmt.Sprintf("Invalid repository URL: %s", err.Error()))
+	}
+	resolved, err := base.Parse(reference)
+	if err != nil {
+		return "", errors.NewInvalid(fmt.Sprintf("Invalid chart URL: %s", err.Error()))
+	}
+	return resolved.String(), nil
+}
+
+func download(url string, limit int64) ([]byte, error) {
+	response, err := repositoryClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download %s: %s", url, response.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+	return data, nil
+}
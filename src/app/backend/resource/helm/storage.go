@@ -0,0 +1,225 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Release revisions are stored the same way Helm 3 stores them by default: every revision is a secret of
+// this type in the namespace of the release. The release is encoded as gzipped JSON in base64.
+const (
+	releaseSecretType   = "helm.sh/release.v1"
+	releaseSecretPrefix = "sh.helm.release.v1."
+	releaseSecretKey    = "release"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// storage stores the release revisions of a namespace of a tenant.
+type storage struct {
+	secrets corev1.SecretInterface
+}
+
+func newStorage(client kubernetes.Interface, namespace, tenant string) *storage {
+	return &storage{secrets: client.CoreV1().SecretsWithMultiTenancy(namespace, tenant)}
+}
+
+func secretName(name string, version int) string {
+	return fmt.Sprintf("%s%s.v%d", releaseSecretPrefix, name, version)
+}
+
+// create stores a new revision.
+func (self *storage) create(release *Release) error {
+	secret, err := encodeRelease(release)
+	if err != nil {
+		return err
+	}
+	_, err = self.secrets.Create(secret)
+	return err
+}
+
+// update updates a stored revision, e.g. after its status changed.
+func (self *storage) update(release *Release) error {
+	secret, err := encodeRelease(release)
+	if err != nil {
+		return err
+	}
+	_, err = self.secrets.Update(secret)
+	return err
+}
+
+// delete deletes a stored revision.
+func (self *storage) delete(name string, version int) error {
+	return self.secrets.Delete(secretName(name, version), &metaV1.DeleteOptions{})
+}
+
+// get returns the given revision of a release.
+func (self *storage) get(name string, version int) (*Release, error) {
+	secret, err := self.secrets.Get(secretName(name, version), metaV1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, errors.NewNotFound(fmt.Sprintf("Revision %d of release %s not found", version, name))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeRelease(secret)
+}
+
+// history returns all stored revisions of a release, the oldest first. It returns a not found error if
+// the release has no revisions.
+func (self *storage) history(name string) ([]*Release, error) {
+	releases, err := self.query(labels.Set{"owner": "helm", "name": name})
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, errors.NewNotFound(fmt.Sprintf("Release %s not found", name))
+	}
+	return releases, nil
+}
+
+// last returns the latest revision of a release.
+func (self *storage) last(name string) (*Release, error) {
+	releases, err := self.history(name)
+	if err != nil {
+		return nil, err
+	}
+	return releases[len(releases)-1], nil
+}
+
+// list returns the latest revision of every release, sorted by name.
+func (self *storage) list() ([]*Release, error) {
+	releases, err := self.query(labels.Set{"owner": "helm"})
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]*Release)
+	for _, release := range releases {
+		latest[release.Name] = release
+	}
+	result := make([]*Release, 0, len(latest))
+	for _, release := range latest {
+		result = append(result, release)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// query returns the revisions of the secrets with the given labels, sorted by name and version.
+func (self *storage) query(set labels.Set) ([]*Release, error) {
+	secrets, err := self.secrets.List(metaV1.ListOptions{LabelSelector: set.AsSelector().String()})
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*Release, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		if secrets.Items[i].Type != releaseSecretType {
+			continue
+		}
+		release, err := decodeRelease(&secrets.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Name != releases[j].Name {
+			return releases[i].Name < releases[j].Name
+		}
+		return releases[i].Version < releases[j].Version
+	})
+	return releases, nil
+}
+
+func encodeRelease(release *Release) (*v1.Secret, error) {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	status := StatusUnknown
+	if release.Info != nil {
+		status = release.Info.Status
+	}
+	return &v1.Secret{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: secretName(release.Name, release.Version),
+			Labels: map[string]string{
+				"name":    release.Name,
+				"owner":   "helm",
+				"status":  string(status),
+				"version": strconv.Itoa(release.Version),
+			},
+		},
+		Type: releaseSecretType,
+		Data: map[string][]byte{
+			releaseSecretKey: []byte(base64.StdEncoding.EncodeToString(buffer.Bytes())),
+		},
+	}, nil
+}
+
+func decodeRelease(secret *v1.Secret) (*Release, error) {
+	data, err := base64.StdEncoding.DecodeString(string(secret.Data[releaseSecretKey]))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		if data, err = ioutil.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	release := new(Release)
+	if err := json.Unmarshal(data, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Key of the values that are shared by a chart with all of its subcharts.
+const globalValuesKey = "global"
+
+// parseValues parses values given in YAML or JSON.
+func parseValues(data string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(data), &values); err != nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("Could not parse values: %s", err.Error()))
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	return values, nil
+}
+
+// coalesceValues merges the given values over the default values of the chart. Subcharts get the values
+// stored under their name or alias merged over their own defaults, together with the global values.
+func coalesceValues(chart *Chart, values map[string]interface{}) map[string]interface{} {
+	result := mergeValues(copyValues(chart.Values), values)
+
+	global, _ := result[globalValuesKey].(map[string]interface{})
+	for _, subchart := range chart.dependencies {
+		key := subchartKey(chart, subchart)
+		subvalues, ok := result[key].(map[string]interface{})
+		if !ok {
+			subvalues = make(map[string]interface{})
+		}
+		if global != nil {
+			subglobal, ok := subvalues[globalValuesKey].(map[string]interface{})
+			if !ok {
+				subglobal = make(map[string]interface{})
+			}
+			subvalues[globalValuesKey] = mergeValues(subglobal, global)
+		}
+		result[key] = coalesceValues(subchart, subvalues)
+	}
+	return result
+}
+
+// mergeValues merges src into dst. Values of src take precedence and null values remove keys from dst.
+func mergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[key] = mergeValues(dstMap, srcMap)
+		} else {
+			dst[key] = runtime.DeepCopyJSONValue(value)
+		}
+	}
+	return dst
+}
+
+// copyValues returns a deep copy of the values.
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return make(map[string]interface{})
+	}
+	return runtime.DeepCopyJSON(values)
+}
+
+// subchartKey returns the key of the values of the subchart in the values of its parent.
+func subchartKey(parent, subchart *Chart) string {
+	if dependency := parent.dependency(subchart); dependency != nil && len(dependency.Alias) > 0 {
+		return dependency.Alias
+	}
+	return subchart.Metadata.Name
+}
+
+// subchartEnabled evaluates the condition of the subchart. Conditions are comma separated value paths
+// and the first path that holds a boolean decides. Subcharts without condition are always enabled.
+func subchartEnabled(parent, subchart *Chart, values map[string]interface{}) bool {
+	dependency := parent.dependency(subchart)
+	if dependency == nil || len(dependency.Condition) == 0 {
+		return true
+	}
+
+	for _, condition := range strings.Split(dependency.Condition, ",") {
+		var value interface{} = values
+		for _, key := range strings.Split(strings.TrimSpace(condition), ".") {
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = values[key]
+		}
+		if enabled, ok := value.(bool); ok {
+			return enabled
+		}
+	}
+	return true
+}
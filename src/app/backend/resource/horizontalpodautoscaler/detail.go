@@ -17,7 +17,11 @@ package horizontalpodautoscaler
 import (
 	"log"
 
-	autoscaling "k8s.io/api/autoscaling/v1"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	autoscaling "k8s.io/api/autoscaling/v2beta2"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	client "k8s.io/client-go/kubernetes"
 )
@@ -30,13 +34,36 @@ type HorizontalPodAutoscalerDetail struct {
 	CurrentReplicas int32    `json:"currentReplicas"`
 	DesiredReplicas int32    `json:"desiredReplicas"`
 	LastScaleTime   *v1.Time `json:"lastScaleTime"`
+
+	// Metrics are the autoscaling/v2 metrics with their targets and current values.
+	Metrics    []MetricValue      `json:"metrics"`
+	Conditions []common.Condition `json:"conditions"`
+}
+
+// MetricValue is a metric the autoscaler scales on. Current is nil until the metric was read by
+// the autoscaler.
+type MetricValue struct {
+	MetricSpec `json:",inline"`
+	Current    *MetricTarget `json:"current"`
 }
 
 // GetHorizontalPodAutoscalerDetail returns detailed information about a horizontal pod autoscaler
 func GetHorizontalPodAutoscalerDetail(client client.Interface, namespace string, name string) (*HorizontalPodAutoscalerDetail, error) {
 	log.Printf("Getting details of %s horizontal pod autoscaler", name)
 
-	rawHorizontalPodAutoscaler, err := client.AutoscalingV1().HorizontalPodAutoscalers(namespace).Get(name, v1.GetOptions{})
+	rawHorizontalPodAutoscaler, err := client.AutoscalingV2beta2().HorizontalPodAutoscalers(namespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return getHorizontalPodAutoscalerDetail(rawHorizontalPodAutoscaler), nil
+}
+
+// GetHorizontalPodAutoscalerDetailWithMultiTenancy returns detailed information about a horizontal pod autoscaler of a tenant
+func GetHorizontalPodAutoscalerDetailWithMultiTenancy(client client.Interface, tenant, namespace, name string) (*HorizontalPodAutoscalerDetail, error) {
+	log.Printf("Getting details of %s horizontal pod autoscaler", name)
+
+	rawHorizontalPodAutoscaler, err := client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy(namespace, tenant).Get(name, v1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -45,10 +72,128 @@ func GetHorizontalPodAutoscalerDetail(client client.Interface, namespace string,
 }
 
 func getHorizontalPodAutoscalerDetail(hpa *autoscaling.HorizontalPodAutoscaler) *HorizontalPodAutoscalerDetail {
+	metrics := make([]MetricValue, 0, len(hpa.Spec.Metrics))
+	for _, metric := range hpa.Spec.Metrics {
+		metrics = append(metrics, toMetricValue(metric, hpa.Status.CurrentMetrics))
+	}
+
+	conditions := make([]common.Condition, 0, len(hpa.Status.Conditions))
+	for _, condition := range hpa.Status.Conditions {
+		conditions = append(conditions, common.Condition{
+			Type:               string(condition.Type),
+			Status:             core.ConditionStatus(condition.Status),
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+
 	return &HorizontalPodAutoscalerDetail{
-		HorizontalPodAutoscaler: toHorizontalPodAutoScaler(hpa),
+		HorizontalPodAutoscaler: toHorizontalPodAutoScalerV2(hpa),
 		CurrentReplicas:         hpa.Status.CurrentReplicas,
 		DesiredReplicas:         hpa.Status.DesiredReplicas,
 		LastScaleTime:           hpa.Status.LastScaleTime,
+		Metrics:                 metrics,
+		Conditions:              conditions,
+	}
+}
+
+// toHorizontalPodAutoScalerV2 converts an autoscaling/v2 autoscaler to a list item. The CPU
+// utilization is taken from the CPU resource metric, like it is done by the API server for
+// autoscaling/v1.
+func toHorizontalPodAutoScalerV2(hpa *autoscaling.HorizontalPodAutoscaler) HorizontalPodAutoscaler {
+	result := HorizontalPodAutoscaler{
+		ObjectMeta: api.NewObjectMeta(hpa.ObjectMeta),
+		TypeMeta:   api.NewTypeMeta(api.ResourceKindHorizontalPodAutoscaler),
+		ScaleTargetRef: ScaleTargetRef{
+			Kind: hpa.Spec.ScaleTargetRef.Kind,
+			Name: hpa.Spec.ScaleTargetRef.Name,
+		},
+		MinReplicas: hpa.Spec.MinReplicas,
+		MaxReplicas: hpa.Spec.MaxReplicas,
+	}
+	for _, metric := range hpa.Spec.Metrics {
+		if metric.Type == autoscaling.ResourceMetricSourceType && metric.Resource.Name == "cpu" {
+			result.TargetCPUUtilizationPercentage = metric.Resource.Target.AverageUtilization
+		}
+	}
+	for _, metric := range hpa.Status.CurrentMetrics {
+		if metric.Type == autoscaling.ResourceMetricSourceType && metric.Resource.Name == "cpu" {
+			result.CurrentCPUUtilizationPercentage = metric.Resource.Current.AverageUtilization
+		}
+	}
+	return result
+}
+
+// toMetricValue returns the metric with the current value of the matching status.
+func toMetricValue(metric autoscaling.MetricSpec, statuses []autoscaling.MetricStatus) MetricValue {
+	result := MetricValue{MetricSpec: MetricSpec{Type: string(metric.Type)}}
+	var identifier *autoscaling.MetricIdentifier
+	switch metric.Type {
+	case autoscaling.ResourceMetricSourceType:
+		result.Name = string(metric.Resource.Name)
+		result.Target = toMetricTarget(metric.Resource.Target)
+	case autoscaling.PodsMetricSourceType:
+		identifier = &metric.Pods.Metric
+		result.Target = toMetricTarget(metric.Pods.Target)
+	case autoscaling.ObjectMetricSourceType:
+		identifier = &metric.Object.Metric
+		result.Target = toMetricTarget(metric.Object.Target)
+	case autoscaling.ExternalMetricSourceType:
+		identifier = &metric.External.Metric
+		result.Target = toMetricTarget(metric.External.Target)
+	}
+	if identifier != nil {
+		result.Name = identifier.Name
+		if identifier.Selector != nil {
+			result.Selector = identifier.Selector.MatchLabels
+		}
+	}
+
+	for _, status := range statuses {
+		if status.Type != metric.Type {
+			continue
+		}
+		var name string
+		var current autoscaling.MetricValueStatus
+		switch status.Type {
+		case autoscaling.ResourceMetricSourceType:
+			name, current = string(status.Resource.Name), status.Resource.Current
+		case autoscaling.PodsMetricSourceType:
+			name, current = status.Pods.Metric.Name, status.Pods.Current
+		case autoscaling.ObjectMetricSourceType:
+			name, current = status.Object.Metric.Name, status.Object.Current
+		case autoscaling.ExternalMetricSourceType:
+			name, current = status.External.Metric.Name, status.External.Current
+		}
+		if name == result.Name {
+			result.Current = toMetricCurrent(current)
+			break
+		}
+	}
+	return result
+}
+
+func toMetricTarget(target autoscaling.MetricTarget) MetricTarget {
+	return MetricTarget{
+		Type:               string(target.Type),
+		AverageUtilization: target.AverageUtilization,
+		AverageValue:       formatQuantity(target.AverageValue),
+		Value:              formatQuantity(target.Value),
+	}
+}
+
+func toMetricCurrent(current autoscaling.MetricValueStatus) *MetricTarget {
+	return &MetricTarget{
+		AverageUtilization: current.AverageUtilization,
+		AverageValue:       formatQuantity(current.AverageValue),
+		Value:              formatQuantity(current.Value),
+	}
+}
+
+func formatQuantity(quantity *resource.Quantity) string {
+	if quantity == nil {
+		return ""
 	}
+	return quantity.String()
 }
@@ -19,7 +19,9 @@ import (
 	"testing"
 
 	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
-	autoscaling "k8s.io/api/autoscaling/v1"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	autoscaling "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -37,7 +39,7 @@ func TestGetHorizontalPodAutoscalerDetail(t *testing.T) {
 			"test-ns", "test-name",
 			[]string{"get"},
 			&autoscaling.HorizontalPodAutoscaler{
-				ObjectMeta: metaV1.ObjectMeta{Name: "test-name", Namespace: "test-ns"},
+				ObjectMeta: metaV1.ObjectMeta{Name: "test-name", Namespace: "test-ns", Tenant: "system"},
 				Spec: autoscaling.HorizontalPodAutoscalerSpec{
 					ScaleTargetRef: autoscaling.CrossVersionObjectReference{
 						Kind: "test-kind",
@@ -62,6 +64,81 @@ func TestGetHorizontalPodAutoscalerDetail(t *testing.T) {
 				},
 				CurrentReplicas: 1,
 				DesiredReplicas: 2,
+				Metrics:         []MetricValue{},
+				Conditions:      []common.Condition{},
+			},
+		},
+		{
+			"test-ns", "test-metrics",
+			[]string{"get"},
+			&autoscaling.HorizontalPodAutoscaler{
+				ObjectMeta: metaV1.ObjectMeta{Name: "test-metrics", Namespace: "test-ns", Tenant: "system"},
+				Spec: autoscaling.HorizontalPodAutoscalerSpec{
+					ScaleTargetRef: autoscaling.CrossVersionObjectReference{Kind: "Deployment", Name: "web"},
+					MaxReplicas:    5,
+					Metrics: []autoscaling.MetricSpec{
+						{
+							Type: autoscaling.ResourceMetricSourceType,
+							Resource: &autoscaling.ResourceMetricSource{
+								Name:   v1.ResourceCPU,
+								Target: autoscaling.MetricTarget{Type: autoscaling.UtilizationMetricType, AverageUtilization: int32Ptr(60)},
+							},
+						},
+						{
+							Type: autoscaling.ExternalMetricSourceType,
+							External: &autoscaling.ExternalMetricSource{
+								Metric: autoscaling.MetricIdentifier{
+									Name:     "queue_length",
+									Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"queue": "jobs"}},
+								},
+								Target: autoscaling.MetricTarget{Type: autoscaling.ValueMetricType, Value: quantityPtr("30")},
+							},
+						},
+					},
+				},
+				Status: autoscaling.HorizontalPodAutoscalerStatus{
+					CurrentReplicas: 2,
+					DesiredReplicas: 3,
+					CurrentMetrics: []autoscaling.MetricStatus{
+						{
+							Type: autoscaling.ResourceMetricSourceType,
+							Resource: &autoscaling.ResourceMetricStatus{
+								Name:    v1.ResourceCPU,
+								Current: autoscaling.MetricValueStatus{AverageUtilization: int32Ptr(75), AverageValue: quantityPtr("150m")},
+							},
+						},
+					},
+					Conditions: []autoscaling.HorizontalPodAutoscalerCondition{
+						{Type: autoscaling.ScalingActive, Status: v1.ConditionTrue, Reason: "ValidMetricFound"},
+					},
+				},
+			},
+			&HorizontalPodAutoscalerDetail{
+				HorizontalPodAutoscaler: HorizontalPodAutoscaler{
+					ObjectMeta:                      api.ObjectMeta{Name: "test-metrics", Namespace: "test-ns"},
+					TypeMeta:                        api.TypeMeta{Kind: api.ResourceKindHorizontalPodAutoscaler},
+					ScaleTargetRef:                  ScaleTargetRef{Kind: "Deployment", Name: "web"},
+					MaxReplicas:                     5,
+					CurrentCPUUtilizationPercentage: int32Ptr(75),
+					TargetCPUUtilizationPercentage:  int32Ptr(60),
+				},
+				CurrentReplicas: 2,
+				DesiredReplicas: 3,
+				Metrics: []MetricValue{
+					{
+						MetricSpec: MetricSpec{Type: MetricTypeResource, Name: "cpu",
+							Target: MetricTarget{Type: TargetTypeUtilization, AverageUtilization: int32Ptr(60)}},
+						Current: &MetricTarget{AverageUtilization: int32Ptr(75), AverageValue: "150m"},
+					},
+					{
+						MetricSpec: MetricSpec{Type: MetricTypeExternal, Name: "queue_length",
+							Selector: map[string]string{"queue": "jobs"},
+							Target:   MetricTarget{Type: TargetTypeValue, Value: "30"}},
+					},
+				},
+				Conditions: []common.Condition{
+					{Type: "ScalingActive", Status: v1.ConditionTrue, Reason: "ValidMetricFound"},
+				},
 			},
 		},
 	}
@@ -0,0 +1,29 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package horizontalpodautoscaler
+
+import (
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/dataselect"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/event"
+	client "k8s.io/client-go/kubernetes"
+)
+
+// GetEventsForHorizontalPodAutoscalerWithMultiTenancy gets the scaling events of a horizontal pod
+// autoscaler.
+func GetEventsForHorizontalPodAutoscalerWithMultiTenancy(client client.Interface, dsQuery *dataselect.DataSelectQuery,
+	tenant, namespace, name string) (*common.EventList, error) {
+	return event.GetResourceEventsWithMultiTenancy(client, dsQuery, tenant, namespace, name)
+}
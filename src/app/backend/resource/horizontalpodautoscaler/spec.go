@@ -0,0 +1,260 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package horizontalpodautoscaler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	autoscaling "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client "k8s.io/client-go/kubernetes"
+)
+
+// Metric source types of autoscaling/v2.
+const (
+	MetricTypeResource = "Resource"
+	MetricTypePods     = "Pods"
+	MetricTypeObject   = "Object"
+	MetricTypeExternal = "External"
+)
+
+// Metric target types of autoscaling/v2.
+const (
+	TargetTypeUtilization  = "Utilization"
+	TargetTypeAverageValue = "AverageValue"
+	TargetTypeValue        = "Value"
+)
+
+// scaleTargetAPIVersions are the API versions of the kinds that can be scaled by autoscalers
+// created by the dashboard.
+var scaleTargetAPIVersions = map[string]string{
+	"Deployment":  "apps/v1",
+	"StatefulSet": "apps/v1",
+	"ReplicaSet":  "apps/v1",
+}
+
+// HorizontalPodAutoscalerSpec contains the fields of a horizontal pod autoscaler that can be set on
+// creation and on update. The name is only used on creation.
+type HorizontalPodAutoscalerSpec struct {
+	Name           string            `json:"name"`
+	Labels         map[string]string `json:"labels"`
+	ScaleTargetRef ScaleTargetRef    `json:"scaleTargetRef"`
+	MinReplicas    *int32            `json:"minReplicas"`
+	MaxReplicas    int32             `json:"maxReplicas"`
+	// Metrics to scale on. The API server defaults to 80% average CPU utilization if there are none.
+	Metrics []MetricSpec `json:"metrics"`
+}
+
+// MetricSpec is a simplified autoscaling/v2 metric. The name is the resource name of resource
+// metrics and the metric name otherwise. The selector selects the series of external metrics.
+type MetricSpec struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name"`
+	Selector map[string]string `json:"selector,omitempty"`
+	Target   MetricTarget      `json:"target"`
+}
+
+// MetricTarget is the target or the current value of a metric. Quantities are formatted like in
+// Kubernetes manifests, e.g. 500m or 1Gi. Only the field of the target type is set.
+type MetricTarget struct {
+	Type               string `json:"type,omitempty"`
+	AverageUtilization *int32 `json:"averageUtilization,omitempty"`
+	AverageValue       string `json:"averageValue,omitempty"`
+	Value              string `json:"value,omitempty"`
+}
+
+// CreateHorizontalPodAutoscalerWithMultiTenancy creates a horizontal pod autoscaler from the spec.
+func CreateHorizontalPodAutoscalerWithMultiTenancy(client client.Interface, tenant, namespace string,
+	spec *HorizontalPodAutoscalerSpec) (*HorizontalPodAutoscalerDetail, error) {
+	log.Printf("Creating %s horizontal pod autoscaler in %s namespace", spec.Name, namespace)
+
+	if len(spec.Name) == 0 {
+		return nil, errors.NewInvalid("name is required")
+	}
+	hpa := &autoscaling.HorizontalPodAutoscaler{
+		ObjectMeta: metaV1.ObjectMeta{Name: spec.Name, Namespace: namespace, Tenant: tenant, Labels: spec.Labels},
+	}
+	if err := setSpec(hpa, spec); err != nil {
+		return nil, err
+	}
+
+	created, err := client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy(namespace, tenant).Create(hpa)
+	if err != nil {
+		return nil, err
+	}
+	return getHorizontalPodAutoscalerDetail(created), nil
+}
+
+// UpdateHorizontalPodAutoscalerWithMultiTenancy replaces the scale target, the replica bounds and
+// the metrics of a horizontal pod autoscaler. Labels are only replaced if they are set.
+func UpdateHorizontalPodAutoscalerWithMultiTenancy(client client.Interface, tenant, namespace, name string,
+	spec *HorizontalPodAutoscalerSpec) (*HorizontalPodAutoscalerDetail, error) {
+	log.Printf("Updating %s horizontal pod autoscaler in %s namespace", name, namespace)
+
+	hpas := client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy(namespace, tenant)
+	hpa, err := hpas.Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if spec.Labels != nil {
+		hpa.Labels = spec.Labels
+	}
+	if err := setSpec(hpa, spec); err != nil {
+		return nil, err
+	}
+
+	updated, err := hpas.Update(hpa)
+	if err != nil {
+		return nil, err
+	}
+	return getHorizontalPodAutoscalerDetail(updated), nil
+}
+
+// DeleteHorizontalPodAutoscalerWithMultiTenancy deletes a horizontal pod autoscaler. The scale
+// target keeps its current number of replicas.
+func DeleteHorizontalPodAutoscalerWithMultiTenancy(client client.Interface, tenant, namespace, name string) error {
+	log.Printf("Deleting %s horizontal pod autoscaler in %s namespace", name, namespace)
+	return client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy(namespace, tenant).
+		Delete(name, &metaV1.DeleteOptions{})
+}
+
+// setSpec validates the spec and sets it on the autoscaler.
+func setSpec(hpa *autoscaling.HorizontalPodAutoscaler, spec *HorizontalPodAutoscalerSpec) error {
+	apiVersion, supported := scaleTargetAPIVersions[spec.ScaleTargetRef.Kind]
+	if !supported {
+		return errors.NewInvalid("scale target must be a Deployment, StatefulSet or ReplicaSet")
+	}
+	if len(spec.ScaleTargetRef.Name) == 0 {
+		return errors.NewInvalid("scale target name is required")
+	}
+	if spec.MaxReplicas < 1 {
+		return errors.NewInvalid("maxReplicas must be at least 1")
+	}
+	if spec.MinReplicas != nil && (*spec.MinReplicas < 1 || *spec.MinReplicas > spec.MaxReplicas) {
+		return errors.NewInvalid("minReplicas must be between 1 and maxReplicas")
+	}
+
+	metrics := make([]autoscaling.MetricSpec, 0, len(spec.Metrics))
+	for _, metric := range spec.Metrics {
+		result, err := fromMetricSpec(metric)
+		if err != nil {
+			return err
+		}
+		metrics = append(metrics, result)
+	}
+
+	hpa.Spec = autoscaling.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscaling.CrossVersionObjectReference{
+			APIVersion: apiVersion,
+			Kind:       spec.ScaleTargetRef.Kind,
+			Name:       spec.ScaleTargetRef.Name,
+		},
+		MinReplicas: spec.MinReplicas,
+		MaxReplicas: spec.MaxReplicas,
+		Metrics:     metrics,
+	}
+	return nil
+}
+
+// fromMetricSpec converts a resource, pods or external metric to autoscaling/v2.
+func fromMetricSpec(metric MetricSpec) (autoscaling.MetricSpec, error) {
+	result := autoscaling.MetricSpec{Type: autoscaling.MetricSourceType(metric.Type)}
+	if len(metric.Name) == 0 {
+		return result, errors.NewInvalid(fmt.Sprintf("name of %s metric is required", metric.Type))
+	}
+
+	var allowed []string
+	switch metric.Type {
+	case MetricTypeResource:
+		allowed = []string{TargetTypeUtilization, TargetTypeAverageValue}
+	case MetricTypePods:
+		allowed = []string{TargetTypeAverageValue}
+	case MetricTypeExternal:
+		allowed = []string{TargetTypeValue, TargetTypeAverageValue}
+	default:
+		return result, errors.NewInvalid("metric type must be Resource, Pods or External")
+	}
+	target, err := fromMetricTarget(metric, allowed)
+	if err != nil {
+		return result, err
+	}
+
+	switch metric.Type {
+	case MetricTypeResource:
+		result.Resource = &autoscaling.ResourceMetricSource{Name: v1.ResourceName(metric.Name), Target: target}
+	case MetricTypePods:
+		result.Pods = &autoscaling.PodsMetricSource{
+			Metric: autoscaling.MetricIdentifier{Name: metric.Name},
+			Target: target,
+		}
+	case MetricTypeExternal:
+		identifier := autoscaling.MetricIdentifier{Name: metric.Name}
+		if len(metric.Selector) > 0 {
+			identifier.Selector = &metaV1.LabelSelector{MatchLabels: metric.Selector}
+		}
+		result.External = &autoscaling.ExternalMetricSource{Metric: identifier, Target: target}
+	}
+	return result, nil
+}
+
+// fromMetricTarget converts the target of a metric, which has to be of one of the allowed types.
+func fromMetricTarget(metric MetricSpec, allowed []string) (autoscaling.MetricTarget, error) {
+	target := metric.Target
+	result := autoscaling.MetricTarget{Type: autoscaling.MetricTargetType(target.Type)}
+
+	valid := false
+	for _, targetType := range allowed {
+		valid = valid || target.Type == targetType
+	}
+	if !valid {
+		return result, errors.NewInvalid(fmt.Sprintf("target of %s metric %s must be of type %v",
+			metric.Type, metric.Name, allowed))
+	}
+
+	switch target.Type {
+	case TargetTypeUtilization:
+		if target.AverageUtilization == nil || *target.AverageUtilization < 1 {
+			return result, errors.NewInvalid(fmt.Sprintf("averageUtilization of %s metric %s must be at least 1",
+				metric.Type, metric.Name))
+		}
+		result.AverageUtilization = target.AverageUtilization
+	case TargetTypeAverageValue:
+		quantity, err := parseQuantity(metric, "averageValue", target.AverageValue)
+		if err != nil {
+			return result, err
+		}
+		result.AverageValue = quantity
+	case TargetTypeValue:
+		quantity, err := parseQuantity(metric, "value", target.Value)
+		if err != nil {
+			return result, err
+		}
+		result.Value = quantity
+	}
+	return result, nil
+}
+
+func parseQuantity(metric MetricSpec, field, value string) (*resource.Quantity, error) {
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("%s of %s metric %s is invalid: %s", field, metric.Type,
+			metric.Name, err.Error()))
+	}
+	return &quantity, nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package horizontalpodautoscaler
+
+import (
+	"reflect"
+	"testing"
+
+	autoscaling "k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(value int32) *int32 {
+	return &value
+}
+
+func quantityPtr(value string) *resource.Quantity {
+	quantity := resource.MustParse(value)
+	return &quantity
+}
+
+func TestCreateHorizontalPodAutoscaler(t *testing.T) {
+	spec := &HorizontalPodAutoscalerSpec{
+		Name:           "web",
+		Labels:         map[string]string{"app": "web"},
+		ScaleTargetRef: ScaleTargetRef{Kind: "Deployment", Name: "web"},
+		MinReplicas:    int32Ptr(2),
+		MaxReplicas:    10,
+		Metrics: []MetricSpec{
+			{Type: MetricTypeResource, Name: "cpu",
+				Target: MetricTarget{Type: TargetTypeUtilization, AverageUtilization: int32Ptr(70)}},
+			{Type: MetricTypePods, Name: "requests_per_second",
+				Target: MetricTarget{Type: TargetTypeAverageValue, AverageValue: "100"}},
+			{Type: MetricTypeExternal, Name: "queue_length", Selector: map[string]string{"queue": "jobs"},
+				Target: MetricTarget{Type: TargetTypeValue, Value: "30"}},
+		},
+	}
+	expected := autoscaling.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+		MinReplicas:    int32Ptr(2),
+		MaxReplicas:    10,
+		Metrics: []autoscaling.MetricSpec{
+			{
+				Type: autoscaling.ResourceMetricSourceType,
+				Resource: &autoscaling.ResourceMetricSource{Name: "cpu",
+					Target: autoscaling.MetricTarget{Type: autoscaling.UtilizationMetricType, AverageUtilization: int32Ptr(70)}},
+			},
+			{
+				Type: autoscaling.PodsMetricSourceType,
+				Pods: &autoscaling.PodsMetricSource{Metric: autoscaling.MetricIdentifier{Name: "requests_per_second"},
+					Target: autoscaling.MetricTarget{Type: autoscaling.AverageValueMetricType, AverageValue: quantityPtr("100")}},
+			},
+			{
+				Type: autoscaling.ExternalMetricSourceType,
+				External: &autoscaling.ExternalMetricSource{
+					Metric: autoscaling.MetricIdentifier{Name: "queue_length",
+						Selector: &metaV1.LabelSelector{MatchLabels: map[string]string{"queue": "jobs"}}},
+					Target: autoscaling.MetricTarget{Type: autoscaling.ValueMetricType, Value: quantityPtr("30")}},
+			},
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	detail, err := CreateHorizontalPodAutoscalerWithMultiTenancy(client, "tenant", "default", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if detail.ObjectMeta.Name != "web" || len(detail.Metrics) != 3 {
+		t.Errorf("unexpected detail of created autoscaler: %#v", detail)
+	}
+
+	created, err := client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy("default", "tenant").
+		Get("web", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected autoscaler to be created: %s", err)
+	}
+	if !reflect.DeepEqual(created.Spec, expected) {
+		t.Errorf("unexpected spec:\ngot: %#v\nexpected: %#v", created.Spec, expected)
+	}
+	if !reflect.DeepEqual(created.Labels, spec.Labels) {
+		t.Errorf("expected labels %v, got %v", spec.Labels, created.Labels)
+	}
+}
+
+func TestCreateHorizontalPodAutoscalerInvalid(t *testing.T) {
+	valid := func() *HorizontalPodAutoscalerSpec {
+		return &HorizontalPodAutoscalerSpec{
+			Name:           "web",
+			ScaleTargetRef: ScaleTargetRef{Kind: "StatefulSet", Name: "web"},
+			MaxReplicas:    3,
+		}
+	}
+	cases := []struct {
+		info   string
+		modify func(*HorizontalPodAutoscalerSpec)
+	}{
+		{"missing name", func(s *HorizontalPodAutoscalerSpec) { s.Name = "" }},
+		{"unsupported kind", func(s *HorizontalPodAutoscalerSpec) { s.ScaleTargetRef.Kind = "DaemonSet" }},
+		{"missing target name", func(s *HorizontalPodAutoscalerSpec) { s.ScaleTargetRef.Name = "" }},
+		{"no replicas", func(s *HorizontalPodAutoscalerSpec) { s.MaxReplicas = 0 }},
+		{"minReplicas above maxReplicas", func(s *HorizontalPodAutoscalerSpec) { s.MinReplicas = int32Ptr(4) }},
+		{"unsupported metric type", func(s *HorizontalPodAutoscalerSpec) {
+			s.Metrics = []MetricSpec{{Type: MetricTypeObject, Name: "hits",
+				Target: MetricTarget{Type: TargetTypeValue, Value: "1"}}}
+		}},
+		{"missing metric name", func(s *HorizontalPodAutoscalerSpec) {
+			s.Metrics = []MetricSpec{{Type: MetricTypeResource,
+				Target: MetricTarget{Type: TargetTypeUtilization, AverageUtilization: int32Ptr(50)}}}
+		}},
+		{"utilization of pods metric", func(s *HorizontalPodAutoscalerSpec) {
+			s.Metrics = []MetricSpec{{Type: MetricTypePods, Name: "rps",
+				Target: MetricTarget{Type: TargetTypeUtilization, AverageUtilization: int32Ptr(50)}}}
+		}},
+		{"missing utilization", func(s *HorizontalPodAutoscalerSpec) {
+			s.Metrics = []MetricSpec{{Type: MetricTypeResource, Name: "cpu",
+				Target: MetricTarget{Type: TargetTypeUtilization}}}
+		}},
+		{"invalid quantity", func(s *HorizontalPodAutoscalerSpec) {
+			s.Metrics = []MetricSpec{{Type: MetricTypeExternal, Name: "queue",
+				Target: MetricTarget{Type: TargetTypeValue, Value: "many"}}}
+		}},
+	}
+
+	for _, c := range cases {
+		spec := valid()
+		c.modify(spec)
+		client := fake.NewSimpleClientset()
+		if _, err := CreateHorizontalPodAutoscalerWithMultiTenancy(client, "tenant", "default", spec); err == nil {
+			t.Errorf("%s: expected error", c.info)
+		}
+		if len(client.Actions()) != 0 {
+			t.Errorf("%s: expected no request, got %v", c.info, client.Actions())
+		}
+	}
+}
+
+func TestUpdateHorizontalPodAutoscaler(t *testing.T) {
+	hpa := &autoscaling.HorizontalPodAutoscaler{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Tenant: "tenant",
+			Labels: map[string]string{"app": "web"}, ResourceVersion: "7"},
+		Spec: autoscaling.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscaling.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MaxReplicas:    3,
+		},
+	}
+	client := fake.NewSimpleClientset(hpa)
+
+	spec := &HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: ScaleTargetRef{Kind: "ReplicaSet", Name: "web-1"},
+		MaxReplicas:    6,
+		Metrics: []MetricSpec{{Type: MetricTypeResource, Name: "memory",
+			Target: MetricTarget{Type: TargetTypeAverageValue, AverageValue: "512Mi"}}},
+	}
+	detail, err := UpdateHorizontalPodAutoscalerWithMultiTenancy(client, "tenant", "default", "web", spec)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if detail.MaxReplicas != 6 || detail.ScaleTargetRef.Kind != "ReplicaSet" || detail.Metrics[0].Target.AverageValue != "512Mi" {
+		t.Errorf("unexpected detail of updated autoscaler: %#v", detail)
+	}
+
+	actions := client.Actions()
+	if len(actions) != 2 || actions[0].GetVerb() != "get" || actions[1].GetVerb() != "update" {
+		t.Fatalf("expected get and update, got %v", actions)
+	}
+	updated, _ := client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy("default", "tenant").
+		Get("web", metaV1.GetOptions{})
+	if updated.Labels["app"] != "web" || updated.Spec.MinReplicas != nil || updated.Spec.ScaleTargetRef.Name != "web-1" {
+		t.Errorf("unexpected updated autoscaler: %#v", updated)
+	}
+
+	if _, err := UpdateHorizontalPodAutoscalerWithMultiTenancy(client, "tenant", "default", "missing", spec); err == nil {
+		t.Errorf("expected error for missing autoscaler")
+	}
+}
+
+func TestDeleteHorizontalPodAutoscaler(t *testing.T) {
+	client := fake.NewSimpleClientset(&autoscaling.HorizontalPodAutoscaler{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Namespace: "default", Tenant: "tenant"},
+	})
+
+	if err := DeleteHorizontalPodAutoscalerWithMultiTenancy(client, "tenant", "default", "web"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := client.AutoscalingV2beta2().HorizontalPodAutoscalersWithMultiTenancy("default", "tenant").
+		Get("web", metaV1.GetOptions{}); err == nil {
+		t.Errorf("expected autoscaler to be deleted")
+	}
+}
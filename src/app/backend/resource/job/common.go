@@ -43,6 +43,7 @@ func (self JobCell) GetProperty(name dataselect.PropertyName) dataselect.Compara
 
 func (self JobCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindJob,
 		ResourceName: self.ObjectMeta.Name,
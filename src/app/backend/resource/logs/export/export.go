@@ -0,0 +1,282 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/container"
+)
+
+// ManifestFileName is the name of the index file stored at the root of every archive.
+const ManifestFileName = "index.json"
+
+// ExportSpec describes which logs should be exported.
+type ExportSpec struct {
+	// Tenant the pods belong to.
+	Tenant string `json:"tenant"`
+
+	// Namespace of the pods. All namespaces of the tenant are exported when empty.
+	Namespace string `json:"namespace"`
+
+	// Optional label selector used to filter the pods.
+	LabelSelector string `json:"labelSelector"`
+
+	// Whether logs of previous container instances should be included. Defaults to true.
+	IncludePrevious bool `json:"includePrevious"`
+}
+
+// NewExportSpec returns a spec with the defaults applied. Requests are decoded into it, so fields
+// missing in the request keep their default.
+func NewExportSpec() *ExportSpec {
+	return &ExportSpec{IncludePrevious: true}
+}
+
+// ManifestEntry describes a single log file in the archive.
+type ManifestEntry struct {
+	Namespace     string `json:"namespace"`
+	PodName       string `json:"podName"`
+	ContainerName string `json:"containerName"`
+	InitContainer bool   `json:"initContainer"`
+	Previous      bool   `json:"previous"`
+	RestartCount  int32  `json:"restartCount"`
+
+	// Path of the log file relative to the archive root. Empty if the log could not be read.
+	File string `json:"file,omitempty"`
+
+	// Size of the log file in bytes.
+	Size int64 `json:"size"`
+
+	// Whether the log was cut off at the limit of bytes per log.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Error that occurred while reading the log.
+	Error string `json:"error,omitempty"`
+}
+
+// Manifest is the index of an archive.
+type Manifest struct {
+	Spec      ExportSpec      `json:"spec"`
+	CreatedAt metaV1.Time     `json:"createdAt"`
+	Entries   []ManifestEntry `json:"entries"`
+}
+
+// LogStreamer opens the log stream of a container, which returns at most limitBytes of the log.
+type LogStreamer func(namespace, podName, container string, previous bool, limitBytes int64) (io.ReadCloser, error)
+
+// NewLogStreamer returns a LogStreamer that reads container logs through the API server.
+func NewLogStreamer(client kubernetes.Interface, tenant string) LogStreamer {
+	return func(namespace, podName, containerName string, previous bool, limitBytes int64) (io.ReadCloser, error) {
+		return container.GetLimitedLogFileWithMultiTenancy(client, tenant, namespace, podName, containerName,
+			previous, limitBytes)
+	}
+}
+
+// logSource identifies a single log of a container instance.
+type logSource struct {
+	pod          v1.Pod
+	container    string
+	init         bool
+	previous     bool
+	restartCount int32
+}
+
+// listPods returns the pods selected by the spec.
+func listPods(client kubernetes.Interface, spec ExportSpec) ([]v1.Pod, error) {
+	namespace := spec.Namespace
+	if len(namespace) == 0 {
+		namespace = metaV1.NamespaceAll
+	}
+
+	list, err := client.CoreV1().PodsWithMultiTenancy(namespace, spec.Tenant).List(metaV1.ListOptions{
+		LabelSelector: spec.LabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// getLogSources returns the logs that should be collected for the given pods.
+func getLogSources(pods []v1.Pod, includePrevious bool) []logSource {
+	sources := make([]logSource, 0)
+	for _, pod := range pods {
+		restarts := restartCounts(pod)
+		for _, container := range pod.Spec.InitContainers {
+			sources = appendLogSources(sources, pod, container.Name, true, restarts[container.Name], includePrevious)
+		}
+		for _, container := range pod.Spec.Containers {
+			sources = appendLogSources(sources, pod, container.Name, false, restarts[container.Name], includePrevious)
+		}
+	}
+	return sources
+}
+
+func appendLogSources(sources []logSource, pod v1.Pod, container string, init bool, restartCount int32,
+	includePrevious bool) []logSource {
+	sources = append(sources, logSource{pod: pod, container: container, init: init, restartCount: restartCount})
+	if includePrevious && restartCount > 0 {
+		sources = append(sources, logSource{pod: pod, container: container, init: init, previous: true,
+			restartCount: restartCount})
+	}
+	return sources
+}
+
+func restartCounts(pod v1.Pod) map[string]int32 {
+	result := make(map[string]int32)
+	for _, status := range pod.Status.InitContainerStatuses {
+		result[status.Name] = status.RestartCount
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		result[status.Name] = status.RestartCount
+	}
+	return result
+}
+
+// fileName returns the path of the log file in the archive.
+func (self logSource) fileName() string {
+	name := self.container
+	if self.previous {
+		name += ".previous"
+	}
+	return path.Join(self.pod.Namespace, self.pod.Name, name+".log")
+}
+
+// writeArchive collects all logs into a gzipped tar archive. Every log is cut off after logLimitBytes.
+// Progress is reported after every log. Failures of single logs do not abort the export, they are
+// recorded in the manifest instead.
+func writeArchive(writer io.Writer, spec ExportSpec, sources []logSource, streamer LogStreamer,
+	logLimitBytes int64, progress func(processed int)) (*Manifest, error) {
+	gzipWriter := gzip.NewWriter(writer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	manifest := &Manifest{Spec: spec, CreatedAt: metaV1.Now(), Entries: make([]ManifestEntry, 0)}
+	for i, source := range sources {
+		entry := ManifestEntry{
+			Namespace:     source.pod.Namespace,
+			PodName:       source.pod.Name,
+			ContainerName: source.container,
+			InitContainer: source.init,
+			Previous:      source.previous,
+			RestartCount:  source.restartCount,
+		}
+
+		size, err := writeLog(tarWriter, source, streamer, logLimitBytes)
+		if err == errArchiveTooLarge {
+			return nil, err
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.File = source.fileName()
+			entry.Size = size
+			entry.Truncated = size >= logLimitBytes
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		progress(i + 1)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tarWriter, ManifestFileName, data); err != nil {
+		return nil, err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gzipWriter.Close()
+}
+
+// writeLog spools at most limitBytes of a single log to a temporary file, because tar headers need the
+// size up front.
+func writeLog(tarWriter *tar.Writer, source logSource, streamer LogStreamer, limitBytes int64) (int64, error) {
+	stream, err := streamer(source.pod.Namespace, source.pod.Name, source.container, source.previous, limitBytes)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	spool, err := ioutil.TempFile("", "dashboard-log-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, io.LimitReader(stream, limitBytes))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	header := &tar.Header{Name: source.fileName(), Mode: 0644, Size: size, ModTime: time.Now()}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return 0, err
+	}
+	if _, err := io.CopyN(tarWriter, spool, size); err != nil {
+		if err == errArchiveTooLarge {
+			return 0, err
+		}
+		return 0, fmt.Errorf("could not archive log of %s: %v", source.fileName(), err)
+	}
+	return size, nil
+}
+
+func writeFile(tarWriter *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tarWriter.Write(data)
+	return err
+}
+
+// limitedWriter fails with errArchiveTooLarge once more than limit bytes are written.
+type limitedWriter struct {
+	writer  io.Writer
+	limit   int64
+	written int64
+}
+
+func (self *limitedWriter) Write(data []byte) (int, error) {
+	if self.written+int64(len(data)) > self.limit {
+		return 0, errArchiveTooLarge
+	}
+	n, err := self.writer.Write(data)
+	self.written += int64(n)
+	return n, err
+}
+
+func logExportError(id string, err error) {
+	log.Printf("Log export %s failed: %v", id, err)
+}
@@ -0,0 +1,330 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPod(namespace, name string, labels map[string]string, restarts int32) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: namespace, Tenant: "tenant", Labels: labels},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init"}},
+			Containers:     []v1.Container{{Name: "app"}},
+		},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{Name: "app", RestartCount: restarts}},
+		},
+	}
+}
+
+func fakeStreamer(namespace, podName, container string, previous bool, limitBytes int64) (io.ReadCloser, error) {
+	if container == "init" {
+		return nil, errors.New("container init is not available")
+	}
+	content := namespace + "/" + podName + "/" + container
+	if previous {
+		content += " (previous)"
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func readArchive(t *testing.T, reader io.Reader) map[string]string {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		t.Fatalf("could not open gzip stream: %v", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	files := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not read tar header: %v", err)
+		}
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", header.Name, err)
+		}
+		files[header.Name] = string(data)
+	}
+	return files
+}
+
+func TestGetLogSources(t *testing.T) {
+	pods := []v1.Pod{*newPod("ns", "a", nil, 0), *newPod("ns", "b", nil, 2)}
+
+	cases := []struct {
+		includePrevious bool
+		expected        []string
+	}{
+		{false, []string{"ns/a/init.log", "ns/a/app.log", "ns/b/init.log", "ns/b/app.log"}},
+		{true, []string{"ns/a/init.log", "ns/a/app.log", "ns/b/init.log", "ns/b/app.log", "ns/b/app.previous.log"}},
+	}
+	for _, c := range cases {
+		actual := make([]string, 0)
+		for _, source := range getLogSources(pods, c.includePrevious) {
+			actual = append(actual, source.fileName())
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("getLogSources(includePrevious=%v) == %v, expected %v", c.includePrevious, actual, c.expected)
+		}
+	}
+}
+
+// waitForJob waits until the job of the owner is finished and returns it.
+func waitForJob(manager *Manager, owner string, job *Job) *Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Phase != JobCompleted && job.Phase != JobFailed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		job, _ = manager.Get("tenant", owner, job.ID)
+	}
+	return job
+}
+
+func TestManagerExport(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newPod("ns", "a", map[string]string{"app": "web"}, 1),
+		newPod("ns", "b", map[string]string{"app": "db"}, 0),
+	)
+	manager := NewManager()
+
+	job, err := manager.Start(client, ExportSpec{Tenant: "tenant", Namespace: "ns", LabelSelector: "app=web",
+		IncludePrevious: true}, "owner", fakeStreamer)
+	if err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	if job.Total != 3 {
+		t.Errorf("expected 3 logs to be collected, got %d", job.Total)
+	}
+
+	job = waitForJob(manager, "owner", job)
+	if job.Phase != JobCompleted {
+		t.Fatalf("expected job to complete, got phase %s: %s", job.Phase, job.Error)
+	}
+	if job.Processed != 3 || job.Failed != 1 {
+		t.Errorf("expected 3 processed and 1 failed log, got %d and %d", job.Processed, job.Failed)
+	}
+
+	if _, err := manager.Get("other", "owner", job.ID); err == nil {
+		t.Errorf("expected job to be hidden from other tenants")
+	}
+	if _, err := manager.Get("tenant", "other-user", job.ID); err == nil {
+		t.Errorf("expected job to be hidden from other users")
+	}
+	if _, err := manager.Archive("tenant", "other-user", job.ID); err == nil {
+		t.Errorf("expected archive to be hidden from other users")
+	}
+
+	archive, err := manager.Archive("tenant", "owner", job.ID)
+	if err != nil {
+		t.Fatalf("Archive() returned unexpected error: %v", err)
+	}
+	defer archive.Close()
+
+	files := readArchive(t, archive)
+	expected := map[string]string{
+		"ns/a/app.log":          "ns/a/app",
+		"ns/a/app.previous.log": "ns/a/app (previous)",
+	}
+	for name, content := range expected {
+		if files[name] != content {
+			t.Errorf("expected %s to contain %q, got %q", name, content, files[name])
+		}
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal([]byte(files[ManifestFileName]), manifest); err != nil {
+		t.Fatalf("could not read manifest: %v", err)
+	}
+	if len(manifest.Entries) != 3 {
+		t.Fatalf("expected 3 manifest entries, got %d", len(manifest.Entries))
+	}
+	if entry := manifest.Entries[0]; !entry.InitContainer || len(entry.Error) == 0 || len(entry.File) > 0 {
+		t.Errorf("expected failed init container log in manifest, got %+v", entry)
+	}
+}
+
+func TestManagerRunningJobLimit(t *testing.T) {
+	client := fake.NewSimpleClientset(newPod("ns", "a", nil, 0))
+	manager := newManager()
+	manager.maxRunningJobs = 1
+	release := make(chan struct{})
+	blockingStreamer := func(namespace, podName, container string, previous bool, limitBytes int64) (io.ReadCloser, error) {
+		<-release
+		return fakeStreamer(namespace, podName, container, previous, limitBytes)
+	}
+	spec := ExportSpec{Tenant: "tenant", Namespace: "ns"}
+
+	job, err := manager.Start(client, spec, "owner", blockingStreamer)
+	if err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	if _, err := manager.Start(client, spec, "owner", blockingStreamer); err == nil ||
+		!strings.Contains(err.Error(), "already running") {
+		t.Errorf("expected the second running job of the owner to be rejected, got %v", err)
+	}
+	if _, err := manager.Start(client, spec, "other-user", fakeStreamer); err != nil {
+		t.Errorf("expected jobs of other users to be started, got %v", err)
+	}
+
+	close(release)
+	if job = waitForJob(manager, "owner", job); job.Phase != JobCompleted {
+		t.Fatalf("expected job to complete, got phase %s: %s", job.Phase, job.Error)
+	}
+	if _, err := manager.Start(client, spec, "owner", fakeStreamer); err != nil {
+		t.Errorf("expected a new job to be started after the running one finished, got %v", err)
+	}
+}
+
+func TestManagerLogLimits(t *testing.T) {
+	client := fake.NewSimpleClientset(newPod("ns", "a", nil, 0))
+	var limits []int64
+	streamer := func(namespace, podName, container string, previous bool, limitBytes int64) (io.ReadCloser, error) {
+		limits = append(limits, limitBytes)
+		return fakeStreamer(namespace, podName, container, previous, limitBytes)
+	}
+	manager := newManager()
+	manager.logLimitBytes = 4
+
+	job, err := manager.Start(client, ExportSpec{Tenant: "tenant", Namespace: "ns"}, "owner", streamer)
+	if err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	if job = waitForJob(manager, "owner", job); job.Phase != JobCompleted {
+		t.Fatalf("expected job to complete, got phase %s: %s", job.Phase, job.Error)
+	}
+	if !reflect.DeepEqual(limits, []int64{4, 4}) {
+		t.Errorf("expected the limit to be passed to the streamer, got %v", limits)
+	}
+
+	archive, err := manager.Archive("tenant", "owner", job.ID)
+	if err != nil {
+		t.Fatalf("Archive() returned unexpected error: %v", err)
+	}
+	defer archive.Close()
+	files := readArchive(t, archive)
+	if files["ns/a/app.log"] != "ns/a" {
+		t.Errorf("expected the log to be cut off after 4 bytes, got %q", files["ns/a/app.log"])
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal([]byte(files[ManifestFileName]), manifest); err != nil {
+		t.Fatalf("could not read manifest: %v", err)
+	}
+	if entry := manifest.Entries[1]; !entry.Truncated || entry.Size != 4 {
+		t.Errorf("expected the log to be marked as truncated, got %+v", entry)
+	}
+}
+
+func TestManagerArchiveLimit(t *testing.T) {
+	client := fake.NewSimpleClientset(newPod("ns", "a", nil, 0))
+	random := make([]byte, 64*1024)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	streamer := func(namespace, podName, container string, previous bool, limitBytes int64) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(random)), nil
+	}
+	manager := newManager()
+	manager.maxArchiveBytes = 16 * 1024
+
+	job, err := manager.Start(client, ExportSpec{Tenant: "tenant", Namespace: "ns"}, "owner", streamer)
+	if err != nil {
+		t.Fatalf("Start() returned unexpected error: %v", err)
+	}
+	if job = waitForJob(manager, "owner", job); job.Phase != JobFailed ||
+		!strings.Contains(job.Error, "maximum size of 16384 bytes") {
+		t.Errorf("expected the job to fail with the archive limit, got phase %s: %s", job.Phase, job.Error)
+	}
+}
+
+func TestManagerExpire(t *testing.T) {
+	archive, err := ioutil.TempFile("", "dashboard-log-export-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+
+	manager := newManager()
+	expired := metaV1.NewTime(time.Now().Add(-2 * DefaultJobTTL))
+	recent := metaV1.Now()
+	manager.jobs["expired"] = &Job{ID: "expired", Phase: JobCompleted, FinishedAt: &expired, archivePath: archive.Name()}
+	manager.jobs["recent"] = &Job{ID: "recent", Phase: JobCompleted, FinishedAt: &recent}
+	manager.jobs["running"] = &Job{ID: "running", Phase: JobRunning}
+
+	manager.expire()
+	if _, ok := manager.jobs["expired"]; ok {
+		t.Errorf("expected the expired job to be removed")
+	}
+	if _, err := os.Stat(archive.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected the archive of the expired job to be removed, got %v", err)
+	}
+	if len(manager.jobs) != 2 {
+		t.Errorf("expected the recent and the running job to be kept, got %v", manager.jobs)
+	}
+}
+
+func TestArchiveNotReady(t *testing.T) {
+	manager := newManager()
+	manager.jobs["running"] = &Job{ID: "running", Spec: ExportSpec{Tenant: "tenant"}, Phase: JobRunning, owner: "owner"}
+
+	if _, err := manager.Archive("tenant", "owner", "running"); err == nil {
+		t.Errorf("expected error for job that is still running")
+	}
+	if _, err := manager.Archive("tenant", "owner", "missing"); err == nil {
+		t.Errorf("expected error for unknown job")
+	}
+}
+
+func TestNewExportSpec(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected bool
+	}{
+		{`{"namespace": "ns"}`, true},
+		{`{"namespace": "ns", "includePrevious": false}`, false},
+		{`{"namespace": "ns", "includePrevious": true}`, true},
+	}
+	for _, c := range cases {
+		spec := NewExportSpec()
+		if err := json.Unmarshal([]byte(c.body), spec); err != nil {
+			t.Fatalf("could not decode %s: %v", c.body, err)
+		}
+		if spec.IncludePrevious != c.expected {
+			t.Errorf("IncludePrevious of %s == %v, expected %v", c.body, spec.IncludePrevious, c.expected)
+		}
+	}
+}
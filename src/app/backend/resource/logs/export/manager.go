@@ -0,0 +1,278 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// JobPhase is the state of an export job.
+type JobPhase string
+
+const (
+	JobPending   JobPhase = "Pending"
+	JobRunning   JobPhase = "Running"
+	JobCompleted JobPhase = "Completed"
+	JobFailed    JobPhase = "Failed"
+)
+
+const (
+	// DefaultJobTTL defines how long finished jobs and their archives are kept.
+	DefaultJobTTL = time.Hour
+	// ExpireInterval defines how often expired jobs and their archives are removed.
+	ExpireInterval = time.Minute
+	// DefaultMaxRunningJobs is the number of jobs a user can run at the same time.
+	DefaultMaxRunningJobs = 2
+	// DefaultLogLimitBytes is the number of bytes of every log that are exported. Longer logs are cut off.
+	DefaultLogLimitBytes = 64 * 1024 * 1024
+	// DefaultMaxArchiveBytes is the size of the archive that fails the job when it is exceeded.
+	DefaultMaxArchiveBytes = 1024 * 1024 * 1024
+)
+
+// errArchiveTooLarge is returned by the writer of the archive once it reaches the maximum size.
+var errArchiveTooLarge = errors.NewInvalid("the archive exceeds the maximum size")
+
+// Job is the status of a log export.
+type Job struct {
+	ID   string     `json:"id"`
+	Spec ExportSpec `json:"spec"`
+
+	Phase JobPhase `json:"phase"`
+
+	// Number of logs that will be collected and that have been collected so far.
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+
+	// Number of logs that could not be read. Details are stored in the manifest.
+	Failed int `json:"failed"`
+
+	// Size of the finished archive in bytes.
+	ArchiveSize int64 `json:"archiveSize"`
+
+	Error string `json:"error,omitempty"`
+
+	CreatedAt  metaV1.Time  `json:"createdAt"`
+	FinishedAt *metaV1.Time `json:"finishedAt,omitempty"`
+
+	// Identity of the user that created the job. Only the owner can see the job and its archive.
+	owner       string
+	archivePath string
+}
+
+// Manager runs log export jobs in the background and keeps their archives on local disk until they
+// expire.
+type Manager struct {
+	jobs map[string]*Job
+	lock sync.RWMutex
+	ttl  time.Duration
+
+	maxRunningJobs  int
+	logLimitBytes   int64
+	maxArchiveBytes int64
+}
+
+// NewManager creates a log export manager and starts removing expired jobs in the background.
+func NewManager() *Manager {
+	manager := newManager()
+	go wait.Forever(manager.expire, ExpireInterval)
+	return manager
+}
+
+func newManager() *Manager {
+	return &Manager{jobs: make(map[string]*Job), ttl: DefaultJobTTL, maxRunningJobs: DefaultMaxRunningJobs,
+		logLimitBytes: DefaultLogLimitBytes, maxArchiveBytes: DefaultMaxArchiveBytes}
+}
+
+// Start creates a new job owned by the given user identity and starts collecting the logs selected
+// by the spec in the background. Pods are listed synchronously, so that invalid selectors and missing
+// permissions are reported right away. Users can only run a limited number of jobs at the same time.
+func (self *Manager) Start(client kubernetes.Interface, spec ExportSpec, owner string, streamer LogStreamer) (*Job, error) {
+	pods, err := listPods(client, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := generateJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	sources := getLogSources(pods, spec.IncludePrevious)
+	job := &Job{ID: id, Spec: spec, Phase: JobPending, Total: len(sources), CreatedAt: metaV1.Now(), owner: owner}
+
+	self.lock.Lock()
+	if running := self.runningJobs(owner); running >= self.maxRunningJobs {
+		self.lock.Unlock()
+		return nil, errors.NewTooManyRequests(fmt.Sprintf("%d log export jobs are already running, wait for "+
+			"them to finish before starting another one", running))
+	}
+	self.jobs[id] = job
+	self.lock.Unlock()
+
+	go self.run(job.ID, sources, streamer)
+	return self.Get(spec.Tenant, owner, id)
+}
+
+// runningJobs returns the number of pending and running jobs of the owner. The lock must be held.
+func (self *Manager) runningJobs(owner string) int {
+	result := 0
+	for _, job := range self.jobs {
+		if job.owner == owner && (job.Phase == JobPending || job.Phase == JobRunning) {
+			result++
+		}
+	}
+	return result
+}
+
+// Get returns a copy of the job status. Jobs are only visible to their owner within the tenant that
+// they were created in. Jobs of other users are reported as not found.
+func (self *Manager) Get(tenant, owner, id string) (*Job, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+
+	job, ok := self.jobs[id]
+	if !ok || job.Spec.Tenant != tenant || job.owner != owner {
+		return nil, errors.NewNotFound("log export job " + id + " not found")
+	}
+
+	result := *job
+	return &result, nil
+}
+
+// Archive opens the archive of a completed job of the owner.
+func (self *Manager) Archive(tenant, owner, id string) (io.ReadCloser, error) {
+	job, err := self.Get(tenant, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Phase != JobCompleted {
+		return nil, errors.NewInvalid("log export job " + id + " is " + string(job.Phase))
+	}
+	return os.Open(job.archivePath)
+}
+
+func (self *Manager) run(id string, sources []logSource, streamer LogStreamer) {
+	self.update(id, func(job *Job) { job.Phase = JobRunning })
+
+	file, err := ioutil.TempFile("", "dashboard-log-export-")
+	if err != nil {
+		self.fail(id, err)
+		return
+	}
+	defer file.Close()
+
+	job, _ := self.getByID(id)
+	archive := &limitedWriter{writer: file, limit: self.maxArchiveBytes}
+	manifest, err := writeArchive(archive, job.Spec, sources, streamer, self.logLimitBytes, func(processed int) {
+		self.update(id, func(job *Job) { job.Processed = processed })
+	})
+	if err == errArchiveTooLarge {
+		err = errors.NewInvalid(fmt.Sprintf("the archive exceeds the maximum size of %d bytes, export fewer "+
+			"pods by selecting a namespace or labels", self.maxArchiveBytes))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		self.fail(id, err)
+		return
+	}
+
+	failed := 0
+	for _, entry := range manifest.Entries {
+		if len(entry.Error) > 0 {
+			failed++
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		os.Remove(file.Name())
+		self.fail(id, err)
+		return
+	}
+
+	log.Printf("Log export %s finished: %d logs, %d failed, %d bytes", id, len(manifest.Entries), failed, info.Size())
+	self.update(id, func(job *Job) {
+		now := metaV1.Now()
+		job.Phase = JobCompleted
+		job.Failed = failed
+		job.ArchiveSize = info.Size()
+		job.FinishedAt = &now
+		job.archivePath = file.Name()
+	})
+}
+
+func (self *Manager) fail(id string, err error) {
+	logExportError(id, err)
+	self.update(id, func(job *Job) {
+		now := metaV1.Now()
+		job.Phase = JobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &now
+	})
+}
+
+func (self *Manager) update(id string, update func(job *Job)) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if job, ok := self.jobs[id]; ok {
+		update(job)
+	}
+}
+
+func (self *Manager) getByID(id string) (Job, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	job, ok := self.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// expire removes finished jobs older than the TTL together with their archives.
+func (self *Manager) expire() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for id, job := range self.jobs {
+		if job.FinishedAt != nil && time.Since(job.FinishedAt.Time) > self.ttl {
+			if len(job.archivePath) > 0 {
+				os.Remove(job.archivePath)
+			}
+			delete(self.jobs, id)
+		}
+	}
+}
+
+func generateJobID() (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
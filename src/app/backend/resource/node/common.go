@@ -53,6 +53,7 @@ func (self NodeCell) GetProperty(name dataselect.PropertyName) dataselect.Compar
 
 func (self NodeCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindNode,
 		ResourceName: self.ObjectMeta.Name,
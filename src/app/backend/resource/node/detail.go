@@ -124,6 +124,11 @@ type NodeDetail struct {
 	ClusterName string `json:"clusterName"`
 }
 
+// NodeMetrics are the metrics shown on the node detail page. Metrics that are not supported by the
+// configured metric provider are skipped.
+var NodeMetrics = []string{metricapi.CpuUsage, metricapi.MemoryUsage, metricapi.FilesystemUsage,
+	metricapi.NetworkRxRate, metricapi.NetworkTxRate}
+
 // GetNodeDetail gets node details.
 func GetNodeDetail(client k8sClient.Interface, metricClient metricapi.MetricClient, name string,
 	dsQuery *dataselect.DataSelectQuery, clusterName string) (*NodeDetail, error) {
@@ -146,7 +151,11 @@ func GetNodeDetail(client k8sClient.Interface, metricClient metricapi.MetricClie
 		return nil, criticalError
 	}
 
-	podList, err := GetNodePods(client, metricClient, dsQuery, name)
+	// Pods only provide standard metrics, so that unsupported node metrics do not hide them.
+	podQuery := dataselect.NewDataSelectQuery(dsQuery.PaginationQuery, dsQuery.SortQuery, dsQuery.FilterQuery,
+		dataselect.NewMetricQueryWithWindow(dataselect.StandardMetrics.MetricNames,
+			dataselect.StandardMetrics.Aggregations, dsQuery.MetricQuery.Window))
+	podList, err := GetNodePods(client, metricClient, podQuery, name)
 	nonCriticalErrors, criticalError = errors.AppendError(err, nonCriticalErrors)
 	if criticalError != nil {
 		return nil, criticalError
@@ -164,11 +173,29 @@ func GetNodeDetail(client k8sClient.Interface, metricClient metricapi.MetricClie
 		return nil, criticalError
 	}
 
-	metrics, _ := metricPromises.GetMetrics()
+	metrics := getAvailableMetrics(metricPromises)
 	nodeDetails := toNodeDetail(*node, podList, eventList, allocatedResources, metrics, nonCriticalErrors, clusterName)
 	return &nodeDetails, nil
 }
 
+// getAvailableMetrics returns all metrics that were downloaded successfully and skips the others.
+func getAvailableMetrics(metricPromises metricapi.MetricPromises) []metricapi.Metric {
+	metrics := make([]metricapi.Metric, 0)
+	for _, metricPromise := range metricPromises {
+		metric, err := metricPromise.GetMetric()
+		if err != nil {
+			log.Printf("Skipping node metric because of error: %s", err.Error())
+			continue
+		}
+
+		if metric != nil {
+			metrics = append(metrics, *metric)
+		}
+	}
+
+	return metrics
+}
+
 func GetNodeAllocatedResources(node v1.Node, podList *v1.PodList) (NodeAllocatedResources, error) {
 	reqs, limits := map[v1.ResourceName]resource.Quantity{}, map[v1.ResourceName]resource.Quantity{}
 
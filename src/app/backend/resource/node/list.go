@@ -90,6 +90,20 @@ func toNodeList(client client.Interface, nodes []v1.Node, nonCriticalErrors []er
 	return nodeList
 }
 
+// GetCumulativeMetrics returns the metrics of the given nodes aggregated as instructed by the metric
+// query, e.g. the usage of all nodes of a partition.
+func GetCumulativeMetrics(nodes []v1.Node, dsQuery *dataselect.DataSelectQuery,
+	metricClient metricapi.MetricClient) []metricapi.Metric {
+	_, metricPromises := dataselect.GenericDataSelectWithMetrics(toCells(nodes), dsQuery,
+		metricapi.NoResourceCache, metricClient)
+	cumulativeMetrics, err := metricPromises.GetMetrics()
+	if err != nil {
+		log.Printf("Skipping cumulative node metrics because of error: %s", err.Error())
+		return make([]metricapi.Metric, 0)
+	}
+	return cumulativeMetrics
+}
+
 func toNode(node v1.Node, pods *v1.PodList) Node {
 	allocatedResources, err := GetNodeAllocatedResources(node, pods)
 	if err != nil {
@@ -0,0 +1,77 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/dataselect"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/pod"
+	k8sClient "k8s.io/client-go/kubernetes"
+)
+
+// DefaultTopPodsLimit is the number of pods returned by GetNodeTopPods if no limit is given.
+const DefaultTopPodsLimit = 5
+
+// GetNodeTopPods returns the pods of the node with the highest current usage of the given metric,
+// ordered by usage. Only cpu and memory usage are supported.
+func GetNodeTopPods(client k8sClient.Interface, metricClient metricapi.MetricClient, name string,
+	metricName string, limit int, window time.Duration) (*pod.PodList, error) {
+	if metricName != metricapi.CpuUsage && metricName != metricapi.MemoryUsage {
+		return nil, errors.NewBadRequest(fmt.Sprintf("pods cannot be ranked by metric %s", metricName))
+	}
+	if limit <= 0 {
+		limit = DefaultTopPodsLimit
+	}
+
+	dsQuery := dataselect.NewDataSelectQuery(dataselect.NoPagination, dataselect.NoSort, dataselect.NoFilter,
+		dataselect.NewMetricQueryWithWindow(dataselect.StandardMetrics.MetricNames,
+			dataselect.StandardMetrics.Aggregations, window))
+	podList, err := GetNodePods(client, metricClient, dsQuery, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(podList.Pods, func(i, j int) bool {
+		return getPodUsage(podList.Pods[i], metricName) > getPodUsage(podList.Pods[j], metricName)
+	})
+	if len(podList.Pods) > limit {
+		podList.Pods = podList.Pods[:limit]
+	}
+	podList.ListMeta = api.ListMeta{TotalItems: len(podList.Pods)}
+	return podList, nil
+}
+
+// getPodUsage returns the latest value of the metric of the pod or -1 if there is none, so that pods
+// without metrics are listed last.
+func getPodUsage(p pod.Pod, metricName string) int64 {
+	if p.Metrics == nil {
+		return -1
+	}
+
+	usage := p.Metrics.CPUUsage
+	if metricName == metricapi.MemoryUsage {
+		usage = p.Metrics.MemoryUsage
+	}
+	if usage == nil {
+		return -1
+	}
+	return int64(*usage)
+}
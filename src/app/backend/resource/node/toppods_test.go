@@ -0,0 +1,129 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeMetricClient returns the usage of pods by UID and metric. Pods without usage get metrics without
+// points.
+type fakeMetricClient struct {
+	usage map[types.UID]map[string]uint64
+}
+
+func (self fakeMetricClient) DownloadMetric(selectors []metricapi.ResourceSelector, metricName string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, selector := range selectors {
+		metric := &metricapi.Metric{
+			MetricName: metricName,
+			Label:      metricapi.Label{selector.ResourceType: []types.UID{selector.UID}},
+		}
+		if value, exists := self.usage[selector.UID][metricName]; exists {
+			metric.MetricPoints = []metricapi.MetricPoint{{Timestamp: time.Now(), Value: value}}
+		}
+		promise := metricapi.NewMetricPromise()
+		promise.Metric <- metric
+		promise.Error <- nil
+		result = append(result, promise)
+	}
+	return result
+}
+
+func (self fakeMetricClient) DownloadMetrics(selectors []metricapi.ResourceSelector, metricNames []string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		result = append(result, self.DownloadMetric(selectors, metricName, cachedResources)...)
+	}
+	return result
+}
+
+func (self fakeMetricClient) AggregateMetrics(metrics metricapi.MetricPromises, metricName string,
+	aggregations metricapi.AggregationModes) metricapi.MetricPromises {
+	return metricapi.MetricPromises{}
+}
+
+func (self fakeMetricClient) HealthCheck() error {
+	return nil
+}
+
+func (self fakeMetricClient) ID() integrationapi.IntegrationID {
+	return "fake"
+}
+
+func newNodePod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default", Tenant: metaV1.TenantSystem,
+			UID: types.UID(name)},
+		Spec:   v1.PodSpec{NodeName: "test-node"},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+func TestGetNodeTopPods(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metaV1.ObjectMeta{Name: "test-node"}},
+		newNodePod("p1"), newNodePod("p2"), newNodePod("p3"), newNodePod("p4"))
+	metricClient := fakeMetricClient{usage: map[types.UID]map[string]uint64{
+		"p1": {metricapi.CpuUsage: 100, metricapi.MemoryUsage: 1024},
+		"p2": {metricapi.CpuUsage: 300, metricapi.MemoryUsage: 4096},
+		"p4": {metricapi.CpuUsage: 200},
+	}}
+
+	cases := []struct {
+		info       string
+		metricName string
+		limit      int
+		expected   []string
+	}{
+		{"pods are ordered by cpu usage", metricapi.CpuUsage, 0, []string{"p2", "p4", "p1", "p3"}},
+		{"pods without metrics are listed last", metricapi.MemoryUsage, 0, []string{"p2", "p1", "p3", "p4"}},
+		{"the number of pods is limited", metricapi.CpuUsage, 2, []string{"p2", "p4"}},
+	}
+
+	for _, c := range cases {
+		actual, err := GetNodeTopPods(client, metricClient, "test-node", c.metricName, c.limit, time.Hour)
+		if err != nil {
+			t.Fatalf("Test case: %s. Received unexpected error: %v", c.info, err)
+		}
+
+		names := []string{}
+		for _, p := range actual.Pods {
+			names = append(names, p.ObjectMeta.Name)
+		}
+		if !reflect.DeepEqual(names, c.expected) {
+			t.Errorf("Test case: %s. Expected pods %v, got %v", c.info, c.expected, names)
+		}
+		if actual.ListMeta.TotalItems != len(c.expected) {
+			t.Errorf("Test case: %s. Expected %d total items, got %d", c.info, len(c.expected),
+				actual.ListMeta.TotalItems)
+		}
+	}
+
+	if _, err := GetNodeTopPods(client, metricClient, "test-node", metricapi.NetworkRxRate, 0,
+		time.Hour); err == nil {
+		t.Error("Expected error for unsupported metric")
+	}
+}
@@ -3,6 +3,7 @@ package partition
 import (
 	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
 	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/dataselect"
 	resource "github.com/CentaurusInfra/dashboard/src/app/backend/resource/node"
 	v1 "k8s.io/api/core/v1"
 	client "k8s.io/client-go/kubernetes"
@@ -28,6 +29,9 @@ type TenantPartitionList struct {
 type ResourcePartitionDetail struct {
 	ObjectMeta ResourcePartition `json:"objectMeta"`
 	TypeMeta   api.TypeMeta      `json:"typeMeta"`
+
+	// Metrics of all nodes of the partition, aggregated as instructed by the metric query.
+	CumulativeMetrics []metricapi.Metric `json:"cumulativeMetrics"`
 }
 
 type TenantPartitionDetail struct {
@@ -101,7 +105,8 @@ type NodeAllocatedResources struct {
 	PodFraction float64 `json:"podFraction"`
 }
 
-func GetResourcePartitionDetail(client client.Interface, clusterName string) (*ResourcePartitionDetail, error) {
+func GetResourcePartitionDetail(client client.Interface, metricClient metricapi.MetricClient,
+	dsQuery *dataselect.DataSelectQuery, clusterName string) (*ResourcePartitionDetail, error) {
 	nodes, err := client.CoreV1().Nodes().List(api.ListEverything)
 	if err != nil {
 		return nil, err
@@ -138,6 +143,7 @@ func GetResourcePartitionDetail(client client.Interface, clusterName string) (*R
 	partitionDetail.ObjectMeta.HealthyNodeCount = healthyNodeCount
 	partitionDetail.ObjectMeta.Name = clusterName
 	partitionDetail.TypeMeta.Kind = "ResourcePartition"
+	partitionDetail.CumulativeMetrics = resource.GetCumulativeMetrics(nodes.Items, dsQuery, metricClient)
 	return partitionDetail, nil
 }
 
@@ -44,6 +44,7 @@ func (self ReplicaSetCell) GetProperty(name dataselect.PropertyName) dataselect.
 
 func (self ReplicaSetCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindReplicaSet,
 		ResourceName: self.ObjectMeta.Name,
@@ -104,6 +104,7 @@ func (self ReplicationControllerCell) GetProperty(name dataselect.PropertyName)
 }
 func (self ReplicationControllerCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindReplicationController,
 		ResourceName: self.ObjectMeta.Name,
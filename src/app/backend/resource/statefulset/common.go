@@ -44,6 +44,7 @@ func (self StatefulSetCell) GetProperty(name dataselect.PropertyName) dataselect
 
 func (self StatefulSetCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindStatefulSet,
 		ResourceName: self.ObjectMeta.Name,
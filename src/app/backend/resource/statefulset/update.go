@@ -0,0 +1,167 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulset
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/controllerrevision"
+	apps "k8s.io/api/apps/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// UpdateStatus describes the rolling update of a stateful set.
+type UpdateStatus struct {
+	Strategy apps.StatefulSetUpdateStrategyType `json:"strategy"`
+
+	// Pods with an ordinal lower than the partition keep their revision during rolling updates.
+	Partition *int32 `json:"partition"`
+
+	// Revisions of the pods below and at or above the partition.
+	CurrentRevision string `json:"currentRevision"`
+	UpdateRevision  string `json:"updateRevision"`
+
+	Replicas        int32 `json:"replicas"`
+	CurrentReplicas int32 `json:"currentReplicas"`
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+	ReadyReplicas   int32 `json:"readyReplicas"`
+}
+
+// PartitionSpec is a specification of the partition of a stateful set rolling update.
+type PartitionSpec struct {
+	Partition int32 `json:"partition"`
+}
+
+// RestartStatefulSet triggers a rolling restart of all pods of the stateful set.
+func RestartStatefulSet(client kubernetes.Interface, tenant, namespace, name string) (*UpdateStatus, error) {
+	log.Printf("Restarting %s statefulset in %s namespace for %s", name, namespace, tenant)
+
+	ss, err := client.AppsV1().StatefulSetsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if ss.Spec.UpdateStrategy.Type == apps.OnDeleteStatefulSetStrategyType {
+		return nil, errors.NewInvalid(fmt.Sprintf("Stateful set %s uses the OnDelete update strategy and "+
+			"cannot be restarted", name))
+	}
+
+	patch, err := common.RestartPatch()
+	if err != nil {
+		return nil, err
+	}
+	return patchStatefulSet(client, tenant, namespace, name, patch)
+}
+
+// UpdateStatefulSetPartition sets the partition of the rolling update. Only pods with an ordinal
+// greater than or equal to the partition are updated, which allows to roll out changes in stages.
+func UpdateStatefulSetPartition(client kubernetes.Interface, tenant, namespace, name string, spec *PartitionSpec) (
+	*UpdateStatus, error) {
+	log.Printf("Setting partition of %s statefulset in %s namespace for %s to %d", name, namespace, tenant,
+		spec.Partition)
+
+	if spec.Partition < 0 {
+		return nil, errors.NewInvalid(fmt.Sprintf("Invalid partition %d", spec.Partition))
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"updateStrategy": map[string]interface{}{
+				"type":          apps.RollingUpdateStatefulSetStrategyType,
+				"rollingUpdate": map[string]interface{}{"partition": spec.Partition},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return patchStatefulSet(client, tenant, namespace, name, patch)
+}
+
+// RollbackStatefulSet restores the pod template of the given revision.
+func RollbackStatefulSet(client kubernetes.Interface, tenant, namespace, name string,
+	spec *controllerrevision.RollbackSpec) (*UpdateStatus, error) {
+	log.Printf("Rolling back %s statefulset in %s namespace for %s to revision %d", name, namespace, tenant,
+		spec.Revision)
+
+	ss, revisions, err := getStatefulSetRevisions(client, tenant, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := controllerrevision.FindRollbackTarget(revisions, spec.Revision)
+	if err != nil {
+		return nil, err
+	}
+	if controllerrevision.IsCurrent(revisions, target) {
+		return toUpdateStatus(ss), nil
+	}
+	return patchStatefulSet(client, tenant, namespace, name, target.Data.Raw)
+}
+
+// GetStatefulSetRevisionHistory returns all revisions of the stateful set.
+func GetStatefulSetRevisionHistory(client kubernetes.Interface, tenant, namespace, name string) (
+	*controllerrevision.RevisionHistory, error) {
+	_, revisions, err := getStatefulSetRevisions(client, tenant, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return controllerrevision.ToRevisionHistory(revisions)
+}
+
+func getStatefulSetRevisions(client kubernetes.Interface, tenant, namespace, name string) (
+	*apps.StatefulSet, []*apps.ControllerRevision, error) {
+	ss, err := client.AppsV1().StatefulSetsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revisions, err := controllerrevision.GetOwnedRevisions(client, tenant, ss, ss.Spec.Selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ss, revisions, nil
+}
+
+func patchStatefulSet(client kubernetes.Interface, tenant, namespace, name string, patch []byte) (
+	*UpdateStatus, error) {
+	ss, err := client.AppsV1().StatefulSetsWithMultiTenancy(namespace, tenant).
+		Patch(name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return nil, err
+	}
+	return toUpdateStatus(ss), nil
+}
+
+func toUpdateStatus(ss *apps.StatefulSet) *UpdateStatus {
+	status := &UpdateStatus{
+		Strategy:        ss.Spec.UpdateStrategy.Type,
+		CurrentRevision: ss.Status.CurrentRevision,
+		UpdateRevision:  ss.Status.UpdateRevision,
+		Replicas:        ss.Status.Replicas,
+		CurrentReplicas: ss.Status.CurrentReplicas,
+		UpdatedReplicas: ss.Status.UpdatedReplicas,
+		ReadyReplicas:   ss.Status.ReadyReplicas,
+	}
+	if ss.Spec.UpdateStrategy.RollingUpdate != nil {
+		status.Partition = ss.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	return status
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statefulset
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/common"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/controllerrevision"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newUpdateTestClient(strategy apps.StatefulSetUpdateStrategyType) *fake.Clientset {
+	ss := &apps.StatefulSet{
+		ObjectMeta: metaV1.ObjectMeta{Name: "foo", Namespace: "bar", UID: "foo"},
+		Spec: apps.StatefulSetSpec{
+			Replicas:       getReplicasPointer(3),
+			Selector:       &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+			UpdateStrategy: apps.StatefulSetUpdateStrategy{Type: strategy},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metaV1.ObjectMeta{Labels: map[string]string{"app": "foo"}},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "foo", Image: "foo:2"}}},
+			},
+		},
+	}
+
+	objects := []runtime.Object{ss}
+	for revision := int64(1); revision <= 2; revision++ {
+		data := fmt.Sprintf(`{"spec":{"template":{"$patch":"replace","metadata":{"labels":{"app":"foo"}},`+
+			`"spec":{"containers":[{"name":"foo","image":"foo:%d"}]}}}}`, revision)
+		objects = append(objects, &apps.ControllerRevision{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:            fmt.Sprintf("foo-%d", revision),
+				Namespace:       "bar",
+				UID:             types.UID(fmt.Sprintf("foo-%d", revision)),
+				Labels:          map[string]string{"app": "foo"},
+				OwnerReferences: []metaV1.OwnerReference{*metaV1.NewControllerRef(ss, apps.SchemeGroupVersion.WithKind("StatefulSet"))},
+			},
+			Data:     runtime.RawExtension{Raw: []byte(data)},
+			Revision: revision,
+		})
+	}
+	return fake.NewSimpleClientset(objects...)
+}
+
+func getTestStatefulSet(t *testing.T, client *fake.Clientset) *apps.StatefulSet {
+	ss, err := client.AppsV1().StatefulSetsWithMultiTenancy("bar", "").Get("foo", metaV1.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get stateful set: %v", err)
+	}
+	return ss
+}
+
+func TestUpdateStatefulSetPartition(t *testing.T) {
+	client := newUpdateTestClient(apps.RollingUpdateStatefulSetStrategyType)
+
+	status, err := UpdateStatefulSetPartition(client, "", "bar", "foo", &PartitionSpec{Partition: 2})
+	if err != nil {
+		t.Fatalf("UpdateStatefulSetPartition() returned unexpected error: %v", err)
+	}
+	if status.Partition == nil || *status.Partition != 2 {
+		t.Errorf("Expected partition 2, got %+v", status)
+	}
+
+	if _, err := UpdateStatefulSetPartition(client, "", "bar", "foo", &PartitionSpec{Partition: -1}); err == nil {
+		t.Error("Expected error for negative partition")
+	}
+}
+
+func TestRestartStatefulSet(t *testing.T) {
+	client := newUpdateTestClient(apps.RollingUpdateStatefulSetStrategyType)
+	if _, err := RestartStatefulSet(client, "", "bar", "foo"); err != nil {
+		t.Fatalf("RestartStatefulSet() returned unexpected error: %v", err)
+	}
+	annotations := getTestStatefulSet(t, client).Spec.Template.Annotations
+	if len(annotations[common.RestartedAtAnnotation]) == 0 {
+		t.Errorf("Expected restart annotation, got %v", annotations)
+	}
+
+	if _, err := RestartStatefulSet(newUpdateTestClient(apps.OnDeleteStatefulSetStrategyType), "", "bar", "foo"); err == nil {
+		t.Error("Expected error for OnDelete update strategy")
+	}
+}
+
+func TestRollbackStatefulSet(t *testing.T) {
+	client := newUpdateTestClient(apps.RollingUpdateStatefulSetStrategyType)
+	if _, err := RollbackStatefulSet(client, "", "bar", "foo", &controllerrevision.RollbackSpec{}); err != nil {
+		t.Fatalf("RollbackStatefulSet() returned unexpected error: %v", err)
+	}
+	if image := getTestStatefulSet(t, client).Spec.Template.Spec.Containers[0].Image; image != "foo:1" {
+		t.Errorf("Expected image foo:1 after rollback, got %s", image)
+	}
+
+	history, err := GetStatefulSetRevisionHistory(client, "", "bar", "foo")
+	if err != nil {
+		t.Fatalf("GetStatefulSetRevisionHistory() returned unexpected error: %v", err)
+	}
+	if history.ListMeta.TotalItems != 2 {
+		t.Errorf("Expected 2 revisions, got %+v", history)
+	}
+}
@@ -105,6 +105,7 @@ func (self PodCell) GetProperty(name dataselect.PropertyName) dataselect.Compara
 
 func (self PodCell) GetResourceSelector() *metricapi.ResourceSelector {
 	return &metricapi.ResourceSelector{
+		Tenant:       self.ObjectMeta.Tenant,
 		Namespace:    self.ObjectMeta.Namespace,
 		ResourceType: api.ResourceKindPod,
 		ResourceName: self.ObjectMeta.Name,
@@ -0,0 +1,66 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// ConsoleTarget describes where the serial console and the VNC display of a virtual machine can be
+// reached. The serial console is attached through the pod attach subresource of the VM workload,
+// the VNC display through the port forward subresource. Arktos does not define on which port the VM
+// runtime serves the display, so the port is configured on the dashboard instead.
+type ConsoleTarget struct {
+	Tenant    string `json:"tenant"`
+	Namespace string `json:"namespace"`
+	PodName   string `json:"podName"`
+
+	// Name of the VM workload in the pod, used as container name for attach.
+	Workload string `json:"workload"`
+}
+
+// GetConsoleTargetWithMultiTenancy returns the console target of the named virtual machine. An
+// error is returned if the pod does not run a virtual machine.
+func GetConsoleTargetWithMultiTenancy(client kubernetes.Interface, tenant, namespace, name string) (*ConsoleTarget, error) {
+	pod, err := client.CoreV1().PodsWithMultiTenancy(namespace, tenant).Get(name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return toConsoleTarget(pod)
+}
+
+func toConsoleTarget(pod *v1.Pod) (*ConsoleTarget, error) {
+	if pod.Spec.VirtualMachine == nil {
+		return nil, errors.NewInvalid(fmt.Sprintf("pod %s is not a virtual machine", pod.Name))
+	}
+
+	if pod.Status.Phase != v1.PodRunning {
+		return nil, errors.NewInvalid(fmt.Sprintf("virtual machine %s is not running", pod.Name))
+	}
+
+	return &ConsoleTarget{
+		Tenant:    pod.Tenant,
+		Namespace: pod.Namespace,
+		PodName:   pod.Name,
+		Workload:  pod.Spec.VirtualMachine.Name,
+	}, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vm
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetConsoleTargetWithMultiTenancy(t *testing.T) {
+	cases := []struct {
+		pod         *v1.Pod
+		expected    *ConsoleTarget
+		expectedErr bool
+	}{
+		{
+			pod: &v1.Pod{
+				ObjectMeta: metaV1.ObjectMeta{Name: "vm-1", Namespace: "ns-1", Tenant: "tenant-1"},
+				Spec:       v1.PodSpec{VirtualMachine: &v1.VirtualMachine{Name: "guest"}},
+				Status:     v1.PodStatus{Phase: v1.PodRunning},
+			},
+			expected: &ConsoleTarget{Tenant: "tenant-1", Namespace: "ns-1", PodName: "vm-1", Workload: "guest"},
+		},
+		{
+			pod: &v1.Pod{
+				ObjectMeta: metaV1.ObjectMeta{Name: "vm-1", Namespace: "ns-1", Tenant: "tenant-1"},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "container"}}},
+				Status:     v1.PodStatus{Phase: v1.PodRunning},
+			},
+			expectedErr: true,
+		},
+		{
+			pod: &v1.Pod{
+				ObjectMeta: metaV1.ObjectMeta{Name: "vm-1", Namespace: "ns-1", Tenant: "tenant-1"},
+				Spec:       v1.PodSpec{VirtualMachine: &v1.VirtualMachine{Name: "guest"}},
+				Status:     v1.PodStatus{Phase: v1.PodPending},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		fakeClient := fake.NewSimpleClientset(c.pod)
+		actual, err := GetConsoleTargetWithMultiTenancy(fakeClient, "tenant-1", "ns-1", "vm-1")
+		if c.expectedErr {
+			if err == nil {
+				t.Errorf("GetConsoleTargetWithMultiTenancy(%#v) expected error, got %#v", c.pod, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("GetConsoleTargetWithMultiTenancy(%#v) returned unexpected error: %v", c.pod, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("GetConsoleTargetWithMultiTenancy(%#v) == %#v, expected %#v", c.pod, actual, c.expected)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rollup periodically collects samples of the partitions and adds them to hourly rollups, as
+// used by the usage reports and the capacity planning.
+package rollup
+
+import (
+	"log"
+	"time"
+
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultInterval is the interval in which samples are collected.
+const DefaultInterval = 5 * time.Minute
+
+// Partition is a partition whose samples are collected.
+type Partition struct {
+	Name   string
+	Client kubernetes.Interface
+	// MetricClient returns the active metric client of the partition, which can change over time or
+	// be nil.
+	MetricClient func() metricapi.MetricClient
+}
+
+// Metrics returns the active metric client of the partition or nil.
+func (self Partition) Metrics() metricapi.MetricClient {
+	if self.MetricClient == nil {
+		return nil
+	}
+	return self.MetricClient()
+}
+
+// CollectFunc collects a sample of the partition and adds it to the rollup of the hour.
+type CollectFunc func(partition Partition, hour time.Time) error
+
+// Rollup periodically collects a sample of every partition.
+type Rollup struct {
+	name       string
+	partitions []Partition
+	collect    CollectFunc
+	interval   time.Duration
+}
+
+// New creates a rollup that collects a sample of every partition every DefaultInterval. The name
+// tells what is collected in the logs, e.g. usage.
+func New(name string, partitions []Partition, collect CollectFunc) *Rollup {
+	return &Rollup{name: name, partitions: partitions, collect: collect, interval: DefaultInterval}
+}
+
+// Start starts collecting in the background.
+func (self *Rollup) Start() {
+	go wait.Forever(func() { self.Collect(time.Now()) }, self.interval)
+}
+
+// Collect collects a sample of every partition for the hour of now. Failures of a partition are
+// logged and do not affect the others.
+func (self *Rollup) Collect(now time.Time) {
+	hour := now.UTC().Truncate(time.Hour)
+	for _, partition := range self.partitions {
+		if err := self.collect(partition, hour); err != nil {
+			log.Printf("Could not collect %s of %s partition: %s", self.name, partition.Name, err.Error())
+		}
+	}
+}
+
+// Latest returns the value of the latest data point, or 0 if there is none.
+func Latest(dataPoints metricapi.DataPoints) int64 {
+	if len(dataPoints) == 0 {
+		return 0
+	}
+	return dataPoints[len(dataPoints)-1].Y
+}
+
+// Average returns the rounded average of n samples with the given sum.
+func Average(sum, n int64) int64 {
+	return (sum + n/2) / n
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollup
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+)
+
+func TestCollect(t *testing.T) {
+	collected := []string{}
+	rollup := New("test", []Partition{{Name: "p1"}, {Name: "p2"}, {Name: "p3"}},
+		func(partition Partition, hour time.Time) error {
+			collected = append(collected, partition.Name+" "+hour.Format(time.RFC3339))
+			if partition.Name == "p2" {
+				return errors.New("unavailable")
+			}
+			return nil
+		})
+
+	rollup.Collect(time.Date(2020, 6, 1, 12, 35, 0, 0, time.FixedZone("CEST", 2*60*60)))
+	expected := []string{"p1 2020-06-01T10:00:00Z", "p2 2020-06-01T10:00:00Z", "p3 2020-06-01T10:00:00Z"}
+	if !reflect.DeepEqual(collected, expected) {
+		t.Errorf("Expected samples %v to be collected after failures, got %v", expected, collected)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	if (Partition{}).Metrics() != nil {
+		t.Errorf("Expected no metric client without a getter")
+	}
+	if (Partition{MetricClient: func() metricapi.MetricClient { return nil }}).Metrics() != nil {
+		t.Errorf("Expected no metric client if the partition has none")
+	}
+}
+
+func TestLatestAndAverage(t *testing.T) {
+	if actual := Latest(metricapi.DataPoints{{X: 1, Y: 3}, {X: 2, Y: 5}}); actual != 5 {
+		t.Errorf("Expected the latest data point, got %d", actual)
+	}
+	if actual := Latest(nil); actual != 0 {
+		t.Errorf("Expected 0 without data points, got %d", actual)
+	}
+	if actual := Average(400, 3); actual != 133 {
+		t.Errorf("Expected rounded down average of 133, got %d", actual)
+	}
+	if actual := Average(5, 2); actual != 3 {
+		t.Errorf("Expected rounded up average of 3, got %d", actual)
+	}
+}
@@ -18,6 +18,7 @@ package scaling
 import (
 	"strconv"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
@@ -26,6 +27,7 @@ import (
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/scale"
 	"k8s.io/client-go/scale/scheme/appsv1beta2"
+	"k8s.io/client-go/util/retry"
 )
 
 // ReplicaCounts provide the desired and actual number of replicas.
@@ -134,6 +136,36 @@ func ScaleResourceWithMultiTenancy(cfg *rest.Config, tenant, kind, namespace, na
 	}, nil
 }
 
+// ScaleClient sets the desired number of replicas of resources through their scale subresource.
+type ScaleClient struct {
+	getter scale.ScalesGetter
+}
+
+// NewScaleClient creates a scale client for the cluster of the given config.
+func NewScaleClient(cfg *rest.Config) (*ScaleClient, error) {
+	getter, err := getScaleGetter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ScaleClient{getter: getter}, nil
+}
+
+// Scale sets the desired number of replicas of the resource. The kind is resolved in all API groups,
+// e.g. 'deployment' or 'statefulset'. The update is retried if the resource was scaled in the meantime.
+func (self *ScaleClient) Scale(tenant, kind, namespace, name string, replicas int32) error {
+	resource := schema.GroupResource{Resource: kind}
+	scales := self.getter.ScalesWithMultiTenancy(namespace, tenant)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		res, err := scales.Get(resource, name)
+		if err != nil {
+			return err
+		}
+		res.Spec.Replicas = replicas
+		_, err = scales.Update(resource, res)
+		return err
+	})
+}
+
 func getScaleGetter(cfg *rest.Config) (scale.ScalesGetter, error) {
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
 	if err != nil {
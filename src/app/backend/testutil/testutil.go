@@ -0,0 +1,91 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testutil contains fakes shared by the tests of the packages that read the resources of all
+// tenants of a partition.
+package testutil
+
+import (
+	integrationapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+// NewClientset returns a fake clientset that, like the API server, lists the objects of all tenants
+// for the explicit all tenant.
+func NewClientset(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("list", "*", func(action core.Action) (bool, runtime.Object, error) {
+		list := action.(core.ListActionImpl)
+		if list.GetTenant() != metaV1.TenantAllExplicit {
+			return false, nil, nil
+		}
+		obj, err := client.Tracker().ListWithMultiTenancy(list.GetResource(), list.GetKind(), list.GetNamespace(),
+			metaV1.TenantAll)
+		return true, obj, err
+	})
+	return client
+}
+
+// MetricClient is a fake metric client that returns the usage of resources by name and metric as
+// the latest data point.
+type MetricClient struct {
+	metricapi.MetricClient
+	Usage map[string]map[string]int64
+}
+
+// DownloadMetrics returns the metrics of all selectors of the first metric, then those of the
+// second one, like the metric clients do.
+func (self MetricClient) DownloadMetrics(selectors []metricapi.ResourceSelector, metricNames []string,
+	cachedResources *metricapi.CachedResources) metricapi.MetricPromises {
+	result := metricapi.MetricPromises{}
+	for _, metricName := range metricNames {
+		for _, selector := range selectors {
+			promise := metricapi.NewMetricPromise()
+			promise.Metric <- &metricapi.Metric{
+				MetricName: metricName,
+				DataPoints: metricapi.DataPoints{{X: 1, Y: 1}, {X: 2, Y: self.Usage[selector.ResourceName][metricName]}},
+			}
+			promise.Error <- nil
+			result = append(result, promise)
+		}
+	}
+	return result
+}
+
+// ID implements metricapi.MetricClient.
+func (self MetricClient) ID() integrationapi.IntegrationID {
+	return "fake"
+}
+
+// NewPod returns a pod with a single container that requests the cpu and memory and is limited to
+// the cpu.
+func NewPod(tenant, namespace, name string, phase v1.PodPhase, cpu, memory string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: namespace, Name: name},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name: "c",
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)},
+				Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)},
+			},
+		}}},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ClientFactory creates a kubernetes client whose calls to the apiserver are children of the span of
+// the context, see WrapTransport.
+type ClientFactory func(ctx context.Context) (kubernetes.Interface, error)
+
+// contextClient is a kubernetes client that carries the context of the request it was created for,
+// so that the spans of the resource channels using it are children of the span of the request.
+type contextClient struct {
+	kubernetes.Interface
+	ctx       context.Context
+	newClient ClientFactory
+}
+
+// Context returns the context of the request the client was created for.
+func (self *contextClient) Context() context.Context {
+	return self.ctx
+}
+
+// WithContext returns a client that carries the context. The client is returned as it is if the
+// context has no span, i.e. when tracing is disabled. The factory, if not nil, is used to create the
+// clients of resource channels, see StartResourceChannelSpan.
+func WithContext(client kubernetes.Interface, ctx context.Context, newClient ClientFactory) kubernetes.Interface {
+	if client == nil || !Enabled(ctx) {
+		return client
+	}
+	return &contextClient{Interface: client, ctx: ctx, newClient: newClient}
+}
+
+// Enabled returns true if the context has a span, i.e. tracing is enabled for the request.
+func Enabled(ctx context.Context) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
+
+// ContextOf returns the context carried by the client, see WithContext, or an empty context.
+func ContextOf(client interface{}) context.Context {
+	if c, ok := client.(interface{ Context() context.Context }); ok {
+		return c.Context()
+	}
+	return context.Background()
+}
+
+// WrapTransport returns a wrapper of the transport of a rest config, which creates a span for every
+// request sent to the apiserver as a child of the span of the context and propagates the trace to
+// the apiserver. Requests are not traced if the context has no span.
+func WrapTransport(ctx context.Context) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &tracingRoundTripper{ctx: ctx, delegate: rt}
+	}
+}
+
+type tracingRoundTripper struct {
+	ctx      context.Context
+	delegate http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (self *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(self.ctx).IsValid() {
+		return self.delegate.RoundTrip(req)
+	}
+
+	ctx, span := Tracer().Start(self.ctx, fmt.Sprintf("apiserver %s", req.Method),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPURLKey.String(req.URL.String()),
+		))
+	defer span.End()
+
+	// The request must not be modified, see http.RoundTripper.
+	req = req.Clone(req.Context())
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := self.delegate.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
@@ -0,0 +1,301 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// tracesPath is the default path of the OTLP/HTTP traces endpoint.
+	tracesPath = "/v1/traces"
+	// exportTimeout is the timeout of a single export request.
+	exportTimeout = 10 * time.Second
+)
+
+// Exporter exports spans to an OTLP/HTTP endpoint with the JSON encoding of OTLP.
+type Exporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewExporter returns an exporter to the endpoint, i.e. http://otel-collector:4318. The default
+// traces path is used if the endpoint has no path.
+func NewExporter(endpoint string) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: scheme must be http or https", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = tracesPath
+	}
+	return &Exporter{url: u.String(), client: &http.Client{Timeout: exportTimeout}}, nil
+}
+
+// ExportSpans implements sdktrace.SpanExporter.
+func (self *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(toExportRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, self.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to export spans to %s: %s: %s", self.url, resp.Status, msg)
+	}
+	return nil
+}
+
+// Shutdown implements sdktrace.SpanExporter.
+func (self *Exporter) Shutdown(ctx context.Context) error {
+	self.client.CloseIdleConnections()
+	return nil
+}
+
+// The types below are the JSON encoding of the OTLP ExportTraceServiceRequest, see
+// https://github.com/open-telemetry/opentelemetry-proto.
+
+type exportRequest struct {
+	ResourceSpans []*resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   otlpResource  `json:"resource"`
+	ScopeSpans []*scopeSpans `json:"scopeSpans"`
+	SchemaURL  string        `json:"schemaUrl,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope     scope  `json:"scope"`
+	Spans     []span `json:"spans"`
+	SchemaURL string `json:"schemaUrl,omitempty"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Events            []event    `json:"events,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type event struct {
+	Name         string     `json:"name"`
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Attributes   []keyValue `json:"attributes,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+// Status codes of OTLP, which differ from the ones of the API.
+const (
+	statusUnset = 0
+	statusOk    = 1
+	statusError = 2
+)
+
+// toExportRequest groups the spans by resource and instrumentation scope.
+func toExportRequest(spans []sdktrace.ReadOnlySpan) *exportRequest {
+	type scopeKey struct {
+		resource attribute.Distinct
+		scope    instrumentation.Library
+	}
+
+	result := &exportRequest{}
+	resources := make(map[attribute.Distinct]*resourceSpans)
+	scopes := make(map[scopeKey]*scopeSpans)
+	for _, s := range spans {
+		res := s.Resource()
+		if res == nil {
+			res = resource.Empty()
+		}
+
+		rs, ok := resources[res.Equivalent()]
+		if !ok {
+			rs = &resourceSpans{
+				Resource:  otlpResource{Attributes: toKeyValues(res.Attributes())},
+				SchemaURL: res.SchemaURL(),
+			}
+			resources[res.Equivalent()] = rs
+			result.ResourceSpans = append(result.ResourceSpans, rs)
+		}
+
+		key := scopeKey{resource: res.Equivalent(), scope: s.InstrumentationLibrary()}
+		ss, ok := scopes[key]
+		if !ok {
+			ss = &scopeSpans{
+				Scope:     scope{Name: key.scope.Name, Version: key.scope.Version},
+				SchemaURL: key.scope.SchemaURL,
+			}
+			scopes[key] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, toSpan(s))
+	}
+	return result
+}
+
+func toSpan(s sdktrace.ReadOnlySpan) span {
+	result := span{
+		TraceID:           s.SpanContext().TraceID().String(),
+		SpanID:            s.SpanContext().SpanID().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()),
+		StartTimeUnixNano: unixNano(s.StartTime()),
+		EndTimeUnixNano:   unixNano(s.EndTime()),
+		Attributes:        toKeyValues(s.Attributes()),
+		Status:            status{Code: statusUnset},
+	}
+	if s.Parent().HasSpanID() {
+		result.ParentSpanID = s.Parent().SpanID().String()
+	}
+
+	switch s.Status().Code {
+	case codes.Ok:
+		result.Status.Code = statusOk
+	case codes.Error:
+		result.Status = status{Code: statusError, Message: s.Status().Description}
+	}
+
+	for _, e := range s.Events() {
+		result.Events = append(result.Events, event{
+			Name:         e.Name,
+			TimeUnixNano: unixNano(e.Time),
+			Attributes:   toKeyValues(e.Attributes),
+		})
+	}
+	return result
+}
+
+func toKeyValues(attrs []attribute.KeyValue) []keyValue {
+	result := make([]keyValue, 0, len(attrs))
+	for _, a := range attrs {
+		result = append(result, keyValue{Key: string(a.Key), Value: toAnyValue(a.Value)})
+	}
+	return result
+}
+
+func toAnyValue(v attribute.Value) anyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return anyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return anyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return anyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		values := make([]anyValue, 0)
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, toAnyValue(attribute.BoolValue(b)))
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case attribute.INT64SLICE:
+		values := make([]anyValue, 0)
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, toAnyValue(attribute.Int64Value(i)))
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case attribute.FLOAT64SLICE:
+		values := make([]anyValue, 0)
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, toAnyValue(attribute.Float64Value(f)))
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	case attribute.STRINGSLICE:
+		values := make([]anyValue, 0)
+		for _, s := range v.AsStringSlice() {
+			values = append(values, toAnyValue(attribute.StringValue(s)))
+		}
+		return anyValue{ArrayValue: &arrayValue{Values: values}}
+	default:
+		s := v.Emit()
+		return anyValue{StringValue: &s}
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
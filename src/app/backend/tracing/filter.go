@@ -0,0 +1,62 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Filter is a web-service filter that creates the root span of every request. The context of the
+// span is set on the request, see RequestContext.
+func Filter(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
+	ctx := otel.GetTextMapPropagator().Extract(req.Request.Context(), propagation.HeaderCarrier(req.Request.Header))
+	route := req.SelectedRoutePath()
+	ctx, span := Tracer().Start(ctx, fmt.Sprintf("%s %s", req.Request.Method, route),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Request.Method),
+			semconv.HTTPRouteKey.String(route),
+			semconv.HTTPTargetKey.String(req.Request.URL.Path),
+			attribute.String("dashboard.partition", req.PathParameter("partition")),
+			attribute.String("dashboard.tenant", req.PathParameter("tenant")),
+		))
+	defer span.End()
+
+	req.Request = req.Request.WithContext(ctx)
+	chain.ProcessFilter(req, resp)
+
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode()))
+	if resp.StatusCode() >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode()))
+	}
+}
+
+// RequestContext returns the context of the request, which carries its span if tracing is enabled.
+func RequestContext(req *restful.Request) context.Context {
+	if req == nil || req.Request == nil {
+		return context.Background()
+	}
+	return req.Request.Context()
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing creates OpenTelemetry spans for the dashboard API requests and for the work done
+// to serve them, i.e. resource channels, client manager calls, apiserver calls and database queries.
+// Tracing is disabled unless an OTLP endpoint is configured, in which case the spans are exported
+// to it over OTLP/HTTP.
+package tracing
+
+import (
+	"context"
+	"log"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ServiceName is the name of the service the spans are reported for.
+	ServiceName = "dashboard"
+
+	instrumentationName = "github.com/CentaurusInfra/dashboard/src/app/backend/tracing"
+)
+
+// Init enables tracing if the endpoint is set, e.g. http://otel-collector:4318. It returns the
+// function that flushes the remaining spans and stops the export.
+func Init(endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		log.Print("Tracing is disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := NewExporter(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Exporting traces to %s", exporter.url)
+	provider := NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider of the dashboard service. Tests use it with an
+// in-memory exporter.
+func NewTracerProvider(opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
+
+// Tracer returns the tracer of the dashboard. It is a no-op tracer unless tracing is enabled.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// StartSpan starts a span that is a child of the span of the context, if any.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartResourceChannelSpan starts the span of a resource channel, e.g. PodList, which lists the
+// resources with the given client. It returns the client to list them with, whose calls to the
+// apiserver are children of the span if the client was created with a factory, see WithContext.
+func StartResourceChannelSpan(client kubernetes.Interface, channel string) (kubernetes.Interface, trace.Span) {
+	ctx, span := StartSpan(ContextOf(client), "ResourceChannel "+channel)
+	c, ok := client.(*contextClient)
+	if !ok || c.newClient == nil || !span.SpanContext().IsValid() {
+		return client, span
+	}
+
+	traced, err := c.newClient(ctx)
+	if err != nil {
+		log.Printf("Could not create traced client of %s resource channel: %s", channel, err.Error())
+		return client, span
+	}
+	return &contextClient{Interface: traced, ctx: ctx, newClient: c.newClient}, span
+}
+
+// StartDBSpan starts the span of a database query as a child of the span of the context, which is
+// the context of the request the query is made for or an empty one for background work.
+func StartDBSpan(ctx context.Context, operation string) trace.Span {
+	_, span := Tracer().Start(ctx, "db "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationKey.String(operation)))
+	return span
+}
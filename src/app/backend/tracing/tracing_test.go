@@ -0,0 +1,358 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// setupInMemoryTracing enables tracing with an in-memory exporter for the duration of the test.
+func setupInMemoryTracing(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	return exporter
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("span %q not found in %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, expected attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFilter(t *testing.T) {
+	exporter := setupInMemoryTracing(t)
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1").Filter(Filter)
+	ws.Route(ws.GET("/tenants/{tenant}/pod").To(func(req *restful.Request, resp *restful.Response) {
+		client := WithContext(fake.NewSimpleClientset(), RequestContext(req), nil)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, span := StartResourceChannelSpan(client, "PodList")
+			defer span.End()
+		}()
+		<-done
+		resp.WriteHeader(http.StatusInternalServerError)
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/tenants/t1/pod", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	root := findSpan(t, spans, "GET /api/v1/tenants/{tenant}/pod")
+	if root.Parent.IsValid() {
+		t.Errorf("expected the request span to be a root span")
+	}
+	if root.SpanKind != trace.SpanKindServer {
+		t.Errorf("expected a server span, got %v", root.SpanKind)
+	}
+	if !hasAttribute(root.Attributes, attribute.String("dashboard.tenant", "t1")) {
+		t.Errorf("expected the tenant attribute, got %v", root.Attributes)
+	}
+	if root.Status.Code != codes.Error {
+		t.Errorf("expected an error status for a server error, got %v", root.Status.Code)
+	}
+
+	channel := findSpan(t, spans, "ResourceChannel PodList")
+	if channel.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Errorf("expected the resource channel span to be a child of the request span")
+	}
+}
+
+func TestFilterPropagatesIncomingTrace(t *testing.T) {
+	exporter := setupInMemoryTracing(t)
+
+	ws := new(restful.WebService)
+	ws.Path("/api/v1").Filter(Filter)
+	ws.Route(ws.GET("/node").To(func(req *restful.Request, resp *restful.Response) {}))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/node", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	container.ServeHTTP(httptest.NewRecorder(), req)
+
+	root := findSpan(t, exporter.GetSpans(), "GET /api/v1/node")
+	if root.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the trace of the caller, got %s", root.SpanContext.TraceID())
+	}
+	if root.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the span of the caller as parent, got %s", root.Parent.SpanID())
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if WithContext(client, context.Background(), nil) != client {
+		t.Errorf("expected the client to be returned as it is without a span")
+	}
+	if ContextOf(client) != context.Background() {
+		t.Errorf("expected an empty context for a client without context")
+	}
+
+	setupInMemoryTracing(t)
+	ctx, span := StartSpan(context.Background(), "request")
+	defer span.End()
+	if ContextOf(WithContext(client, ctx, nil)) != ctx {
+		t.Errorf("expected the client to carry the context")
+	}
+}
+
+func TestStartResourceChannelSpan(t *testing.T) {
+	exporter := setupInMemoryTracing(t)
+
+	ctx, request := StartSpan(context.Background(), "request")
+	var created context.Context
+	client := WithContext(fake.NewSimpleClientset(), ctx, func(ctx context.Context) (kubernetes.Interface, error) {
+		created = ctx
+		return fake.NewSimpleClientset(), nil
+	})
+
+	channelClient, span := StartResourceChannelSpan(client, "PodList")
+	if channelClient == client {
+		t.Fatalf("expected a client for the resource channel")
+	}
+	if ContextOf(channelClient) != created || !trace.SpanContextFromContext(created).Equal(span.SpanContext()) {
+		t.Errorf("expected the client of the resource channel to be created for the context of its span")
+	}
+	span.End()
+	request.End()
+
+	channel := findSpan(t, exporter.GetSpans(), "ResourceChannel PodList")
+	if channel.Parent.SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("expected the resource channel span to be a child of the request span")
+	}
+
+	// Clients without a factory are used by the resource channels as they are.
+	client = WithContext(fake.NewSimpleClientset(), ctx, nil)
+	if channelClient, span := StartResourceChannelSpan(client, "PodList"); channelClient != client {
+		t.Errorf("expected the client to be returned as it is without a factory")
+	} else {
+		span.End()
+	}
+}
+
+func TestWrapTransport(t *testing.T) {
+	exporter := setupInMemoryTracing(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	// Requests without a span in the context are not traced.
+	client := &http.Client{Transport: WrapTransport(context.Background())(http.DefaultTransport)}
+	resp, err := client.Get(server.URL + "/api/v1/nodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if traceparent != "" || len(exporter.GetSpans()) != 0 {
+		t.Fatalf("expected no trace without a span in the context")
+	}
+
+	ctx, parent := StartSpan(context.Background(), "request")
+	client = &http.Client{Transport: WrapTransport(ctx)(http.DefaultTransport)}
+	resp, err = client.Get(server.URL + "/api/v1/tenants/t1/pods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	call := findSpan(t, exporter.GetSpans(), "apiserver GET")
+	if call.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected the apiserver span to be a child of the request span")
+	}
+	if call.SpanKind != trace.SpanKindClient {
+		t.Errorf("expected a client span, got %v", call.SpanKind)
+	}
+	if traceparent == "" {
+		t.Errorf("expected the trace to be propagated to the apiserver")
+	}
+}
+
+func TestExporter(t *testing.T) {
+	var received []span
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tracesPath {
+			t.Errorf("expected spans to be posted to %s, got %s", tracesPath, r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected JSON encoded spans, got %s", r.Header.Get("Content-Type"))
+		}
+		request := exportRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		for _, rs := range request.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				received = append(received, ss.Spans...)
+			}
+		}
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := NewTracerProvider(sdktrace.WithSyncer(exporter))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, child := provider.Tracer("test").Start(ctx, "child")
+	child.End()
+	parent.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 2 || received[0].Name != "child" || received[1].Name != "parent" {
+		t.Fatalf("expected the child and the parent spans to be exported, got %+v", received)
+	}
+	if received[0].ParentSpanID != received[1].SpanID {
+		t.Errorf("expected the parent span id %s, got %s", received[1].SpanID, received[0].ParentSpanID)
+	}
+}
+
+func TestExporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := tracetest.SpanStubs{{Name: "request"}}.Snapshots()
+	if err := exporter.ExportSpans(context.Background(), spans); err == nil {
+		t.Errorf("expected an error when the collector fails")
+	}
+}
+
+func TestExporterFormat(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	_, child := tracer.Start(ctx, "child", trace.WithAttributes(attribute.Int("count", 3)))
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+	parent.End()
+
+	request := toExportRequest(exporter.GetSpans().Snapshots())
+	if len(request.ResourceSpans) != 1 {
+		t.Fatalf("expected 1 resource, got %d", len(request.ResourceSpans))
+	}
+	if !hasKeyValue(request.ResourceSpans[0].Resource.Attributes, "service.name", ServiceName) {
+		t.Errorf("expected the service name resource attribute, got %+v", request.ResourceSpans[0].Resource.Attributes)
+	}
+	scopes := request.ResourceSpans[0].ScopeSpans
+	if len(scopes) != 1 || scopes[0].Scope.Name != "test" || len(scopes[0].Spans) != 2 {
+		t.Fatalf("expected 2 spans of the test scope, got %+v", scopes)
+	}
+
+	c, p := scopes[0].Spans[0], scopes[0].Spans[1]
+	if c.ParentSpanID != p.SpanID || c.TraceID != p.TraceID || p.ParentSpanID != "" {
+		t.Errorf("expected child %+v of parent %+v", c, p)
+	}
+	if len(c.TraceID) != 32 || len(c.SpanID) != 16 {
+		t.Errorf("expected hex encoded ids, got %s and %s", c.TraceID, c.SpanID)
+	}
+	if c.Status.Code != statusError || c.Status.Message != "failed" {
+		t.Errorf("expected an error status, got %+v", c.Status)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0].Value.IntValue == nil || *c.Attributes[0].Value.IntValue != "3" {
+		t.Errorf("expected an int attribute, got %+v", c.Attributes)
+	}
+}
+
+func hasKeyValue(attrs []keyValue, key, value string) bool {
+	for _, a := range attrs {
+		if a.Key == key && a.Value.StringValue != nil && *a.Value.StringValue == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNewExporter(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		expected string
+		err      bool
+	}{
+		{"http://collector:4318", "http://collector:4318/v1/traces", false},
+		{"https://collector:4318/", "https://collector:4318/v1/traces", false},
+		{"http://collector:4318/custom/traces", "http://collector:4318/custom/traces", false},
+		{"collector:4318", "", true},
+	}
+	for _, c := range cases {
+		exporter, err := NewExporter(c.endpoint)
+		if c.err {
+			if err == nil {
+				t.Errorf("NewExporter(%q) expected an error", c.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewExporter(%q) unexpected error: %v", c.endpoint, err)
+			continue
+		}
+		if exporter.url != c.expected {
+			t.Errorf("NewExporter(%q) url = %q, expected %q", c.endpoint, exporter.url, c.expected)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+)
+
+// NewAggregator creates a rollup that collects the usage of the tenant partitions every
+// rollup.DefaultInterval and stores it as hourly records.
+func NewAggregator(store Store, partitions []rollup.Partition) *rollup.Rollup {
+	return rollup.New("usage", partitions, collectInto(store))
+}
+
+// collectInto returns a function that collects the usage of a partition and adds it to the store.
+// Failed collections are not counted, so they do not lower the averages.
+func collectInto(store Store) rollup.CollectFunc {
+	return func(partition rollup.Partition, hour time.Time) error {
+		samples, err := Collect(partition.Client, partition.Metrics())
+		if err != nil {
+			return err
+		}
+		return store.Add(partition.Name, hour, samples)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/errors"
+)
+
+// MaxRange is the longest time range of a single report.
+const MaxRange = 366 * 24 * time.Hour
+
+// TenantUsage is the usage report of a tenant for a time range.
+type TenantUsage struct {
+	Tenant string    `json:"tenant"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+
+	// Records are the hourly rollups of the namespaces of the tenant.
+	Records []Record `json:"records"`
+
+	// Total is the sum of all records, i.e. millicore-hours of cpu, byte-hours of memory and storage,
+	// and pod-hours.
+	Total Usage `json:"total"`
+}
+
+// GetTenantUsage returns the usage report of the tenant for [from, to). The report can be limited to
+// a single namespace.
+func GetTenantUsage(store Store, tenant, namespace string, from, to time.Time) (*TenantUsage, error) {
+	if !from.Before(to) {
+		return nil, errors.NewInvalid("from has to be before to")
+	}
+	if to.Sub(from) > MaxRange {
+		return nil, errors.NewInvalid("time range must not be longer than " + MaxRange.String())
+	}
+
+	records, err := store.List(tenant, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TenantUsage{Tenant: tenant, From: from.UTC(), To: to.UTC(), Records: make([]Record, 0)}
+	for _, record := range records {
+		if len(namespace) > 0 && record.Namespace != namespace {
+			continue
+		}
+		result.Records = append(result.Records, record)
+		result.Total.add(record.Usage)
+	}
+	return result, nil
+}
+
+var csvHeader = []string{"tenant", "namespace", "hour", "samples", "cpu_requests_millicores",
+	"cpu_limits_millicores", "cpu_usage_millicores", "memory_requests_bytes", "memory_limits_bytes",
+	"memory_usage_bytes", "storage_requests_bytes", "pods"}
+
+// WriteCSV writes the records of the report as CSV with a header row.
+func WriteCSV(w io.Writer, report *TenantUsage) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, r := range report.Records {
+		row := []string{r.Tenant, r.Namespace, r.Hour.UTC().Format(time.RFC3339), strconv.FormatInt(r.Samples, 10)}
+		for _, value := range []int64{r.CPURequests, r.CPULimits, r.CPUUsage, r.MemoryRequests, r.MemoryLimits,
+			r.MemoryUsage, r.StorageRequests, r.Pods} {
+			row = append(row, strconv.FormatInt(value, 10))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
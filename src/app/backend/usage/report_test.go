@@ -0,0 +1,82 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestGetTenantUsage(t *testing.T) {
+	store := newMemoryStore()
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	store.Add("tp", start, []Sample{
+		{Tenant: "t1", Namespace: "a", Usage: Usage{CPURequests: 100, Pods: 1}},
+		{Tenant: "t1", Namespace: "b", Usage: Usage{CPURequests: 300, MemoryUsage: 1024, Pods: 3}},
+		{Tenant: "t2", Namespace: "a", Usage: Usage{CPURequests: 1000}},
+	})
+	store.Add("tp", start.Add(time.Hour), []Sample{{Tenant: "t1", Namespace: "a", Usage: Usage{CPURequests: 200, Pods: 2}}})
+	store.Add("tp", start.Add(2*time.Hour), []Sample{{Tenant: "t1", Namespace: "a", Usage: Usage{CPURequests: 400}}})
+
+	cases := []struct {
+		info      string
+		namespace string
+		records   int
+		total     Usage
+	}{
+		{"all namespaces", "", 3, Usage{CPURequests: 600, MemoryUsage: 1024, Pods: 6}},
+		{"single namespace", "a", 2, Usage{CPURequests: 300, Pods: 3}},
+	}
+
+	for _, c := range cases {
+		actual, err := GetTenantUsage(store, "t1", c.namespace, start, start.Add(2*time.Hour))
+		if err != nil {
+			t.Fatalf("Test case: %s. Received unexpected error: %v", c.info, err)
+		}
+		if len(actual.Records) != c.records || actual.Total != c.total {
+			t.Errorf("Test case: %s. Expected %d records with total %+v, got %d records with total %+v", c.info,
+				c.records, c.total, len(actual.Records), actual.Total)
+		}
+	}
+
+	if _, err := GetTenantUsage(store, "t1", "", start, start); err == nil {
+		t.Error("Expected error for empty time range")
+	}
+	if _, err := GetTenantUsage(store, "t1", "", start, start.Add(MaxRange+time.Hour)); err == nil {
+		t.Error("Expected error for too long time range")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	report := &TenantUsage{Records: []Record{{
+		Tenant: "t1", Namespace: "a", Hour: time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC), Samples: 12,
+		Usage: Usage{CPURequests: 100, CPULimits: 200, CPUUsage: 50, MemoryRequests: 1024, MemoryLimits: 2048,
+			MemoryUsage: 512, StorageRequests: 4096, Pods: 2},
+	}}}
+
+	buffer := new(bytes.Buffer)
+	if err := WriteCSV(buffer, report); err != nil {
+		t.Fatalf("WriteCSV() returned unexpected error: %v", err)
+	}
+
+	expected := "tenant,namespace,hour,samples,cpu_requests_millicores,cpu_limits_millicores," +
+		"cpu_usage_millicores,memory_requests_bytes,memory_limits_bytes,memory_usage_bytes," +
+		"storage_requests_bytes,pods\n" +
+		"t1,a,2020-06-01T10:00:00Z,12,100,200,50,1024,2048,512,4096,2\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expected, buffer.String())
+	}
+}
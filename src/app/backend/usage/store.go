@@ -0,0 +1,178 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/instrumentation"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+)
+
+// Record is the hourly rollup of the usage of a namespace. The usage is the average over all
+// collections of its partition within the hour, so collections in which the namespace had no pods or
+// claims count as zero usage.
+type Record struct {
+	Tenant    string    `json:"tenant"`
+	Namespace string    `json:"namespace"`
+	Hour      time.Time `json:"hour"`
+	Samples   int64     `json:"samples"`
+	Usage
+}
+
+// Store persists hourly usage rollups.
+type Store interface {
+	// Add records a collection of the partition and merges its samples into the records of the given
+	// hour.
+	Add(partition string, hour time.Time, samples []Sample) error
+	// List returns the records of the tenant within [from, to), ordered by hour and namespace.
+	List(tenant string, from, to time.Time) ([]Record, error)
+}
+
+// The tables keep the sums of the samples of every hour and the number of collections of every
+// partition in that hour, so that samples of several collectors and restarts are merged exactly.
+// Averages are computed when records are read.
+const createTablesStatement = `CREATE TABLE IF NOT EXISTS tenantusage (
+	partition TEXT NOT NULL,
+	tenant TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	hour TIMESTAMP NOT NULL,
+	cpurequests BIGINT NOT NULL,
+	cpulimits BIGINT NOT NULL,
+	cpuusage BIGINT NOT NULL,
+	memoryrequests BIGINT NOT NULL,
+	memorylimits BIGINT NOT NULL,
+	memoryusage BIGINT NOT NULL,
+	storagerequests BIGINT NOT NULL,
+	pods BIGINT NOT NULL,
+	PRIMARY KEY (partition, tenant, namespace, hour));
+	CREATE TABLE IF NOT EXISTS usagecollections (
+	partition TEXT NOT NULL,
+	hour TIMESTAMP NOT NULL,
+	collections BIGINT NOT NULL,
+	PRIMARY KEY (partition, hour));`
+
+const upsertCollectionStatement = `INSERT INTO usagecollections (partition, hour, collections)
+	VALUES ($1, $2, 1)
+	ON CONFLICT (partition, hour) DO UPDATE SET
+	collections = usagecollections.collections + 1;`
+
+const upsertStatement = `INSERT INTO tenantusage (partition, tenant, namespace, hour, cpurequests, cpulimits,
+	cpuusage, memoryrequests, memorylimits, memoryusage, storagerequests, pods)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	ON CONFLICT (partition, tenant, namespace, hour) DO UPDATE SET
+	cpurequests = tenantusage.cpurequests + EXCLUDED.cpurequests,
+	cpulimits = tenantusage.cpulimits + EXCLUDED.cpulimits,
+	cpuusage = tenantusage.cpuusage + EXCLUDED.cpuusage,
+	memoryrequests = tenantusage.memoryrequests + EXCLUDED.memoryrequests,
+	memorylimits = tenantusage.memorylimits + EXCLUDED.memorylimits,
+	memoryusage = tenantusage.memoryusage + EXCLUDED.memoryusage,
+	storagerequests = tenantusage.storagerequests + EXCLUDED.storagerequests,
+	pods = tenantusage.pods + EXCLUDED.pods;`
+
+const selectStatement = `SELECT u.tenant, u.namespace, u.hour, c.collections, u.cpurequests, u.cpulimits,
+	u.cpuusage, u.memoryrequests, u.memorylimits, u.memoryusage, u.storagerequests, u.pods
+	FROM tenantusage u JOIN usagecollections c ON c.partition = u.partition AND c.hour = u.hour
+	WHERE u.tenant = $1 AND u.hour >= $2 AND u.hour < $3 ORDER BY u.hour, u.namespace;`
+
+// Implements Store interface backed by postgres.
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates the usage tables if they do not exist yet and returns a store backed by
+// them.
+func NewPostgresStore(db *sql.DB) (Store, error) {
+	if _, err := db.Exec(createTablesStatement); err != nil {
+		return nil, err
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// Add implements Store interface. See Store for more information.
+func (self *postgresStore) Add(partition string, hour time.Time, samples []Sample) error {
+	defer instrumentation.ObserveDBQuery("add_usage", time.Now())
+	tx, err := self.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(upsertCollectionStatement, partition, hour.UTC()); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	statement, err := tx.Prepare(upsertStatement)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer statement.Close()
+
+	for _, s := range samples {
+		_, err := statement.Exec(partition, s.Tenant, s.Namespace, hour.UTC(), s.CPURequests, s.CPULimits,
+			s.CPUUsage, s.MemoryRequests, s.MemoryLimits, s.MemoryUsage, s.StorageRequests, s.Pods)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// List implements Store interface. See Store for more information.
+func (self *postgresStore) List(tenant string, from, to time.Time) ([]Record, error) {
+	defer instrumentation.ObserveDBQuery("list_usage", time.Now())
+	rows, err := self.db.Query(selectStatement, tenant, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Record, 0)
+	for rows.Next() {
+		var r Record
+		err := rows.Scan(&r.Tenant, &r.Namespace, &r.Hour, &r.Samples, &r.CPURequests, &r.CPULimits,
+			&r.CPUUsage, &r.MemoryRequests, &r.MemoryLimits, &r.MemoryUsage, &r.StorageRequests, &r.Pods)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, toAverage(r))
+	}
+	return result, rows.Err()
+}
+
+// toAverage turns a record with the sums of its samples into a record with their rounded averages over
+// the collections of the hour.
+func toAverage(record Record) Record {
+	if record.Samples <= 0 {
+		return record
+	}
+
+	n := record.Samples
+	record.Usage = Usage{
+		CPURequests:     rollup.Average(record.CPURequests, n),
+		CPULimits:       rollup.Average(record.CPULimits, n),
+		CPUUsage:        rollup.Average(record.CPUUsage, n),
+		MemoryRequests:  rollup.Average(record.MemoryRequests, n),
+		MemoryLimits:    rollup.Average(record.MemoryLimits, n),
+		MemoryUsage:     rollup.Average(record.MemoryUsage, n),
+		StorageRequests: rollup.Average(record.StorageRequests, n),
+		Pods:            rollup.Average(record.Pods, n),
+	}
+	record.Hour = record.Hour.UTC()
+	return record
+}
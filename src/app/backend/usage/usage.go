@@ -0,0 +1,165 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"log"
+
+	"github.com/CentaurusInfra/dashboard/src/app/backend/api"
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/resource/node"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+	v1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Usage is the resource consumption of the pods and persistent volume claims of a namespace. CPU is
+// in millicores, memory and storage in bytes.
+type Usage struct {
+	CPURequests     int64 `json:"cpuRequests"`
+	CPULimits       int64 `json:"cpuLimits"`
+	CPUUsage        int64 `json:"cpuUsage"`
+	MemoryRequests  int64 `json:"memoryRequests"`
+	MemoryLimits    int64 `json:"memoryLimits"`
+	MemoryUsage     int64 `json:"memoryUsage"`
+	StorageRequests int64 `json:"storageRequests"`
+	Pods            int64 `json:"pods"`
+}
+
+// add adds the other usage to this one.
+func (self *Usage) add(other Usage) {
+	self.CPURequests += other.CPURequests
+	self.CPULimits += other.CPULimits
+	self.CPUUsage += other.CPUUsage
+	self.MemoryRequests += other.MemoryRequests
+	self.MemoryLimits += other.MemoryLimits
+	self.MemoryUsage += other.MemoryUsage
+	self.StorageRequests += other.StorageRequests
+	self.Pods += other.Pods
+}
+
+// Sample is the usage of a namespace at a single point in time.
+type Sample struct {
+	Tenant    string
+	Namespace string
+	Usage
+}
+
+// Collect returns the current usage of all namespaces of all tenants of a partition. Requests and
+// limits are summed over pods that are not finished yet. Actual usage is read from the metric
+// client and left empty if it is nil or fails.
+func Collect(client kubernetes.Interface, metricClient metricapi.MetricClient) ([]Sample, error) {
+	pods, err := client.CoreV1().PodsWithMultiTenancy(metaV1.NamespaceAll, metaV1.TenantAllExplicit).
+		List(api.ListEverything)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := client.CoreV1().PersistentVolumeClaimsWithMultiTenancy(metaV1.NamespaceAll,
+		metaV1.TenantAllExplicit).List(api.ListEverything)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make(map[string]*Sample)
+	getSample := func(meta metaV1.ObjectMeta) *Sample {
+		key := meta.Tenant + "/" + meta.Namespace
+		sample, exists := samples[key]
+		if !exists {
+			sample = &Sample{Tenant: meta.Tenant, Namespace: meta.Namespace}
+			samples[key] = sample
+		}
+		return sample
+	}
+
+	running := make([]v1.Pod, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		running = append(running, pod)
+
+		reqs, limits, err := node.PodRequestsAndLimits(&pod)
+		if err != nil {
+			return nil, err
+		}
+		cpuRequests, cpuLimits := reqs[v1.ResourceCPU], limits[v1.ResourceCPU]
+		memoryRequests, memoryLimits := reqs[v1.ResourceMemory], limits[v1.ResourceMemory]
+		getSample(pod.ObjectMeta).add(Usage{
+			CPURequests:    cpuRequests.MilliValue(),
+			CPULimits:      cpuLimits.MilliValue(),
+			MemoryRequests: memoryRequests.Value(),
+			MemoryLimits:   memoryLimits.Value(),
+			Pods:           1,
+		})
+	}
+
+	for _, claim := range claims.Items {
+		// Bound claims may get more storage than requested.
+		storage, exists := claim.Status.Capacity[v1.ResourceStorage]
+		if !exists {
+			storage = claim.Spec.Resources.Requests[v1.ResourceStorage]
+		}
+		getSample(claim.ObjectMeta).StorageRequests += storage.Value()
+	}
+
+	for i, usage := range getPodUsage(metricClient, running) {
+		getSample(running[i].ObjectMeta).add(usage)
+	}
+
+	result := make([]Sample, 0, len(samples))
+	for _, sample := range samples {
+		result = append(result, *sample)
+	}
+	return result, nil
+}
+
+// getPodUsage returns the latest cpu and memory usage of the pods in the same order.
+func getPodUsage(metricClient metricapi.MetricClient, pods []v1.Pod) []Usage {
+	if metricClient == nil || len(pods) == 0 {
+		return nil
+	}
+
+	selectors := make([]metricapi.ResourceSelector, len(pods))
+	for i, pod := range pods {
+		selectors[i] = metricapi.ResourceSelector{
+			Tenant:       pod.Tenant,
+			Namespace:    pod.Namespace,
+			ResourceType: api.ResourceKindPod,
+			ResourceName: pod.Name,
+			UID:          pod.UID,
+		}
+	}
+
+	// Metrics are returned for all selectors of the first metric, then for those of the second one.
+	metrics, err := metricClient.DownloadMetrics(selectors, []string{metricapi.CpuUsage, metricapi.MemoryUsage},
+		metricapi.NoResourceCache).GetMetrics()
+	if err != nil {
+		log.Printf("Could not download usage metrics: %s", err.Error())
+		return nil
+	}
+	if len(metrics) != 2*len(pods) {
+		log.Printf("Expected %d usage metrics, got %d", 2*len(pods), len(metrics))
+		return nil
+	}
+
+	result := make([]Usage, len(pods))
+	for i := range pods {
+		result[i].CPUUsage = rollup.Latest(metrics[i].DataPoints)
+		result[i].MemoryUsage = rollup.Latest(metrics[len(pods)+i].DataPoints)
+	}
+	return result
+}
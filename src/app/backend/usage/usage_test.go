@@ -0,0 +1,184 @@
+// Copyright 2020 Authors of Arktos.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	metricapi "github.com/CentaurusInfra/dashboard/src/app/backend/integration/metric/api"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/rollup"
+	"github.com/CentaurusInfra/dashboard/src/app/backend/testutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newClaim(tenant, namespace, name, requested, capacity string) *v1.PersistentVolumeClaim {
+	claim := &v1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{Tenant: tenant, Namespace: namespace, Name: name},
+		Spec: v1.PersistentVolumeClaimSpec{Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(requested)},
+		}},
+	}
+	if len(capacity) > 0 {
+		claim.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse(capacity)}
+	}
+	return claim
+}
+
+func sortSamples(samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Tenant+"/"+samples[i].Namespace < samples[j].Tenant+"/"+samples[j].Namespace
+	})
+}
+
+func TestCollect(t *testing.T) {
+	client := testutil.NewClientset(
+		testutil.NewPod("t1", "a", "p1", v1.PodRunning, "100m", "1Ki"),
+		testutil.NewPod("t1", "a", "p2", v1.PodPending, "250m", "2Ki"),
+		testutil.NewPod("t1", "a", "p3", v1.PodSucceeded, "1", "1Gi"),
+		testutil.NewPod("t2", "a", "p1", v1.PodRunning, "2", "1Mi"),
+		newClaim("t1", "a", "c1", "1Gi", "2Gi"),
+		newClaim("t1", "b", "c1", "1Mi", ""),
+	)
+	metricClient := testutil.MetricClient{Usage: map[string]map[string]int64{
+		"p1": {metricapi.CpuUsage: 10, metricapi.MemoryUsage: 512},
+		"p2": {metricapi.CpuUsage: 20, metricapi.MemoryUsage: 1024},
+	}}
+
+	cases := []struct {
+		info         string
+		metricClient metricapi.MetricClient
+		expected     []Sample
+	}{
+		{
+			"requests, limits and usage are summed per tenant and namespace",
+			metricClient,
+			[]Sample{
+				{Tenant: "t1", Namespace: "a", Usage: Usage{CPURequests: 350, CPULimits: 350, CPUUsage: 30,
+					MemoryRequests: 3072, MemoryUsage: 1536, StorageRequests: 2 * 1024 * 1024 * 1024, Pods: 2}},
+				{Tenant: "t1", Namespace: "b", Usage: Usage{StorageRequests: 1024 * 1024}},
+				{Tenant: "t2", Namespace: "a", Usage: Usage{CPURequests: 2000, CPULimits: 2000, CPUUsage: 10,
+					MemoryRequests: 1024 * 1024, MemoryUsage: 512, Pods: 1}},
+			},
+		},
+		{
+			"usage is empty without metric client",
+			nil,
+			[]Sample{
+				{Tenant: "t1", Namespace: "a", Usage: Usage{CPURequests: 350, CPULimits: 350,
+					MemoryRequests: 3072, StorageRequests: 2 * 1024 * 1024 * 1024, Pods: 2}},
+				{Tenant: "t1", Namespace: "b", Usage: Usage{StorageRequests: 1024 * 1024}},
+				{Tenant: "t2", Namespace: "a", Usage: Usage{CPURequests: 2000, CPULimits: 2000,
+					MemoryRequests: 1024 * 1024, Pods: 1}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := Collect(client, c.metricClient)
+		if err != nil {
+			t.Fatalf("Test case: %s. Received unexpected error: %v", c.info, err)
+		}
+		sortSamples(actual)
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Test case: %s. Expected %+v, got %+v", c.info, c.expected, actual)
+		}
+	}
+}
+
+// memoryStore keeps the sums of the samples and the collections of every hour like the postgres store.
+type memoryStore struct {
+	records     map[string]*Record
+	partitions  map[string]string
+	collections map[string]int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[string]*Record), partitions: make(map[string]string),
+		collections: make(map[string]int64)}
+}
+
+func (self *memoryStore) Add(partition string, hour time.Time, samples []Sample) error {
+	self.collections[partition+"/"+hour.String()]++
+	for _, sample := range samples {
+		key := partition + "/" + sample.Tenant + "/" + sample.Namespace + "/" + hour.String()
+		record, exists := self.records[key]
+		if !exists {
+			record = &Record{Tenant: sample.Tenant, Namespace: sample.Namespace, Hour: hour}
+			self.records[key] = record
+			self.partitions[key] = partition
+		}
+		record.add(sample.Usage)
+	}
+	return nil
+}
+
+func (self *memoryStore) List(tenant string, from, to time.Time) ([]Record, error) {
+	result := []Record{}
+	for key, record := range self.records {
+		if record.Tenant == tenant && !record.Hour.Before(from) && record.Hour.Before(to) {
+			r := *record
+			r.Samples = self.collections[self.partitions[key]+"/"+record.Hour.String()]
+			result = append(result, toAverage(r))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Hour.Equal(result[j].Hour) {
+			return result[i].Hour.Before(result[j].Hour)
+		}
+		return result[i].Namespace < result[j].Namespace
+	})
+	return result, nil
+}
+
+func TestAggregatorCollect(t *testing.T) {
+	store := newMemoryStore()
+	// The rollup shares the partitions, so their clients can be replaced between collections.
+	partitions := []rollup.Partition{
+		{Name: "tp1", Client: testutil.NewClientset(testutil.NewPod("t1", "a", "p1", v1.PodRunning, "100m", "1Ki"))},
+		{Name: "tp2", Client: testutil.NewClientset(testutil.NewPod("t2", "a", "p1", v1.PodRunning, "1", "1Ki")),
+			MetricClient: func() metricapi.MetricClient { return nil }},
+	}
+	aggregator := NewAggregator(store, partitions)
+
+	start := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	for i, minute := range []int{5, 35, 55, 65} {
+		aggregator.Collect(start.Add(time.Duration(minute) * time.Minute))
+		switch i {
+		case 0:
+			// The namespace has no pods during the second collection, which counts as zero usage.
+			partitions[0].Client = testutil.NewClientset()
+		case 1:
+			partitions[0].Client = testutil.NewClientset(
+				testutil.NewPod("t1", "a", "p1", v1.PodRunning, "100m", "1Ki"),
+				testutil.NewPod("t1", "a", "p2", v1.PodRunning, "100m", "1Ki"))
+		}
+	}
+
+	records, _ := store.List("t1", start, start.Add(2*time.Hour))
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 hourly records, got %+v", records)
+	}
+	if first := records[0]; first.Samples != 3 || first.CPURequests != 100 || first.Pods != 1 || !first.Hour.Equal(start) {
+		t.Errorf("Expected averaged record of first hour, got %+v", first)
+	}
+	if second := records[1]; second.Samples != 1 || second.CPURequests != 200 {
+		t.Errorf("Expected record of second hour, got %+v", second)
+	}
+}
@@ -98,7 +98,9 @@ export class CreateService {
     this.isDeployInProgress_ = false;
 
     if (error) {
-      this.reportError(i18n.MSG_DEPLOY_DIALOG_ERROR, error.error);
+      // Failed deployments from file are rolled back and report the error with the document statuses.
+      const message = error.error && error.error.error ? error.error.error : error.error;
+      this.reportError(i18n.MSG_DEPLOY_DIALOG_ERROR, message);
       throw error;
     } else {
       this.router_.navigate(['overview']);
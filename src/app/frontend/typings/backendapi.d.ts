@@ -359,6 +359,7 @@ export interface ResourcePartition extends Resource {
   cpuLimit: number
   memoryLimit: number
   healthyNodeCount: number
+  cumulativeMetrics: Metric[] | null;
 }
 
 export interface TenantPartition extends Resource {
@@ -672,6 +673,7 @@ export interface NodeDetail extends ResourceDetail {
   conditions: Condition[];
   podList: PodList;
   eventList: EventList;
+  metrics: Metric[] | null;
 }
 
 export interface PartitionDetail extends ResourceDetail {
@@ -700,6 +702,35 @@ export interface HorizontalPodAutoscalerDetail extends ResourceDetail {
   currentReplicas: number;
   desiredReplicas: number;
   lastScaleTime: string;
+  metrics: HorizontalPodAutoscalerMetricValue[];
+  conditions: Condition[];
+}
+
+export interface HorizontalPodAutoscalerMetricTarget {
+  type?: string;
+  averageUtilization?: number;
+  averageValue?: string;
+  value?: string;
+}
+
+export interface HorizontalPodAutoscalerMetric {
+  type: string;
+  name: string;
+  selector?: StringMap;
+  target: HorizontalPodAutoscalerMetricTarget;
+}
+
+export interface HorizontalPodAutoscalerMetricValue extends HorizontalPodAutoscalerMetric {
+  current: HorizontalPodAutoscalerMetricTarget;
+}
+
+export interface HorizontalPodAutoscalerSpec {
+  name?: string;
+  labels?: StringMap;
+  scaleTargetRef: ScaleTargetRef;
+  minReplicas?: number;
+  maxReplicas: number;
+  metrics: HorizontalPodAutoscalerMetric[];
 }
 
 // Validation types
@@ -761,12 +792,104 @@ export interface AppDeploymentContentSpec {
   tenant: string;
   content: string;
   validate: boolean;
+  apply?: boolean;
+  dryRun?: boolean;
+  kustomization?: string;
+  kustomizationPath?: string;
+  preview?: boolean;
 }
 
 export interface AppDeploymentContentResponse {
   error: string;
   contet: string;
   name: string;
+  dryRun: boolean;
+  preview: boolean;
+  documents: DeployedDocument[];
+}
+
+export interface DeployedDocument {
+  index: number;
+  status: string;
+  error?: string;
+  objects: DeployedObject[];
+  created: number;
+  updated: number;
+  unchanged: number;
+}
+
+export interface DeployedObject {
+  apiVersion: string;
+  kind: string;
+  name: string;
+  namespace: string;
+  action: string;
+  diff?: string;
+  rollbackError?: string;
+}
+
+export interface HelmChartSource {
+  archive?: string;
+  chart?: string;
+  version?: string;
+}
+
+export interface HelmInstallSpec extends HelmChartSource {
+  name: string;
+  values: string;
+  dryRun?: boolean;
+}
+
+export interface HelmUpgradeSpec extends HelmChartSource {
+  values: string;
+  reuseValues: boolean;
+  dryRun?: boolean;
+}
+
+export interface HelmRollbackSpec {
+  revision: number;
+}
+
+export interface HelmReleaseSummary {
+  name: string;
+  namespace: string;
+  revision: number;
+  updated: string;
+  status: string;
+  chart: string;
+  chartVersion: string;
+  appVersion: string;
+  description: string;
+}
+
+export interface HelmReleaseList {
+  listMeta: ListMeta;
+  releases: HelmReleaseSummary[];
+}
+
+export interface HelmReleaseHistory {
+  name: string;
+  revisions: HelmReleaseSummary[];
+}
+
+export interface HelmReleaseDetail extends HelmReleaseSummary {
+  values: {[key: string]: {}};
+  manifest: string;
+  notes: string;
+}
+
+export interface HelmRepositoryChart {
+  name: string;
+  description: string;
+  icon: string;
+  version: string;
+  appVersion: string;
+  versions: string[];
+}
+
+export interface HelmRepositoryChartList {
+  listMeta: ListMeta;
+  charts: HelmRepositoryChart[];
 }
 
 export interface AppDeploymentSpec {
@@ -786,6 +909,70 @@ export interface AppDeploymentSpec {
   runAsPrivileged: boolean;
   imagePullSecret: string;
   variables: EnvironmentVariable[];
+  livenessProbe?: ProbeSpec;
+  readinessProbe?: ProbeSpec;
+  volumes?: VolumeSpec[];
+  envFromSecrets?: string[];
+  nodeSelector?: Label[];
+  tolerations?: Toleration[];
+}
+
+export interface ProbeSpec {
+  type: string;
+  path?: string;
+  port?: number;
+  command?: string[];
+  initialDelaySeconds?: number;
+  periodSeconds?: number;
+  timeoutSeconds?: number;
+  failureThreshold?: number;
+}
+
+export interface VolumeSpec {
+  name?: string;
+  type: string;
+  source: string;
+  mountPath: string;
+  subPath?: string;
+  readOnly?: boolean;
+}
+
+export interface Toleration {
+  key?: string;
+  operator?: string;
+  value?: string;
+  effect?: string;
+  tolerationSeconds?: number;
+}
+
+export interface VirtualMachineNic {
+  name?: string;
+  subnetName?: string;
+  portId?: string;
+  ipAddress?: string;
+  tag?: string;
+  ipv6Enabled?: boolean;
+}
+
+export interface VirtualMachineDeploymentSpec {
+  name: string;
+  namespace: string;
+  tenant: string;
+  image: string;
+  replicas: number;
+  keyPairName?: string;
+  publicKey?: string;
+  cloudInitUserDataScript?: string;
+  memoryRequirement?: string;
+  cpuRequirement?: string;
+  powerSpec?: string;
+  bootVolume?: string;
+  volumes?: VolumeSpec[];
+  nics?: VirtualMachineNic[];
+  description?: string;
+  labels?: Label[];
+  nodeSelector?: Label[];
+  tolerations?: Toleration[];
 }
 
 export interface CsrfToken {
@@ -1155,6 +1342,75 @@ export interface DeploymentInfo {
   unavailable: number;
 }
 
+export interface DeploymentRolloutStatus {
+  revision: number;
+  state: string;
+  message: string;
+  paused: boolean;
+  statusInfo: DeploymentInfo;
+  progressing?: Condition;
+  available?: Condition;
+}
+
+export interface DeploymentRolloutRevision {
+  revision: number;
+  replicaSet: string;
+  creationTimestamp: string;
+  changeCause: string;
+  images: string[];
+  current: boolean;
+  diff: string;
+}
+
+export interface DeploymentRolloutHistory {
+  listMeta: ListMeta;
+  revisions: DeploymentRolloutRevision[];
+}
+
+export interface DeploymentRollbackSpec {
+  revision: number;
+}
+
+export interface StatefulSetUpdateStatus {
+  strategy: string;
+  partition?: number;
+  currentRevision: string;
+  updateRevision: string;
+  replicas: number;
+  currentReplicas: number;
+  updatedReplicas: number;
+  readyReplicas: number;
+}
+
+export interface StatefulSetPartitionSpec {
+  partition: number;
+}
+
+export interface DaemonSetUpdateStatus {
+  strategy: string;
+  desired: number;
+  updated: number;
+  available: number;
+}
+
+export interface ControllerRevision {
+  name: string;
+  revision: number;
+  creationTimestamp: string;
+  images: string[];
+  current: boolean;
+  diff: string;
+}
+
+export interface ControllerRevisionHistory {
+  listMeta: ListMeta;
+  revisions: ControllerRevision[];
+}
+
+export interface ControllerRevisionRollbackSpec {
+  revision: number;
+}
+
 export interface ReplicationControllerSpec {
   replicas: number;
 }
@@ -1164,6 +1420,175 @@ export interface ReplicaCounts {
   actualReplicas: number;
 }
 
+export type BulkAction = 'delete' | 'scale' | 'label' | 'annotate' | 'restart';
+
+export interface BulkResourceReference {
+  kind: string;
+  namespace: string;
+  name: string;
+}
+
+export interface BulkResourceSelector {
+  kind: string;
+  namespace: string;
+  labelSelector: string;
+}
+
+export interface BulkActionSpec {
+  action: BulkAction;
+  resources?: BulkResourceReference[];
+  selector?: BulkResourceSelector;
+  replicas?: number;
+  set?: {[name: string]: string};
+  remove?: string[];
+}
+
+export interface BulkItemResult extends BulkResourceReference {
+  status: string;
+  error?: string;
+}
+
+export interface BulkActionResult {
+  action: BulkAction;
+  items: BulkItemResult[];
+  succeeded: number;
+  failed: number;
+}
+
+export type MetadataField = 'labels' | 'annotations';
+
+export type MetadataOperation = 'add' | 'remove' | 'replace';
+
+export interface MetadataSpec {
+  resourceVersion?: string;
+  values?: {[name: string]: string};
+  keys?: string[];
+}
+
+export interface Metadata {
+  resourceVersion: string;
+  values: {[name: string]: string};
+}
+
+export interface Usage {
+  cpuRequests: number;
+  cpuLimits: number;
+  cpuUsage: number;
+  memoryRequests: number;
+  memoryLimits: number;
+  memoryUsage: number;
+  storageRequests: number;
+  pods: number;
+}
+
+export interface UsageRecord extends Usage {
+  tenant: string;
+  namespace: string;
+  hour: string;
+  samples: number;
+}
+
+export interface TenantUsage {
+  tenant: string;
+  from: string;
+  to: string;
+  records: UsageRecord[];
+  total: Usage;
+}
+
+export interface PartitionCapacity {
+  nodes: number;
+  cpuCapacity: number;
+  cpuRequests: number;
+  cpuUsage: number;
+  memoryCapacity: number;
+  memoryRequests: number;
+  memoryUsage: number;
+  podCapacity: number;
+  pods: number;
+}
+
+export interface ResourceForecast {
+  resource: string;
+  capacity: number;
+  demand: number;
+  headroom: number;
+  projected: number;
+  exhaustionTime: string;
+  additionalNodes: number;
+  removableNodes: number;
+}
+
+export interface CapacityPlan {
+  partition: string;
+  method: string;
+  horizonDays: number;
+  from: string;
+  to: string;
+  samples: number;
+  targetUtilization: number;
+  current: PartitionCapacity;
+  resources: ResourceForecast[];
+  additionalNodes: number;
+  removableNodes: number;
+  nodeCost: number;
+  currentCost: number;
+  costChange: number;
+}
+
+export interface CapacityPlanList {
+  plans: CapacityPlan[];
+}
+
+export interface AlertSink {
+  type: string;
+  url?: string;
+  to?: string[];
+}
+
+export interface AlertRule {
+  name: string;
+  tenant: string;
+  partition?: string;
+  condition: string;
+  threshold?: number;
+  sinks: AlertSink[];
+}
+
+export interface AlertSilence {
+  id: string;
+  tenant: string;
+  rule?: string;
+  resource?: string;
+  startsAt: string;
+  endsAt: string;
+  comment?: string;
+}
+
+export interface AlertResource {
+  kind: string;
+  tenant?: string;
+  namespace?: string;
+  name: string;
+}
+
+export interface Alert {
+  rule: string;
+  tenant: string;
+  partition: string;
+  condition: string;
+  resource: AlertResource;
+  message: string;
+  state: string;
+  startsAt: string;
+  endsAt?: string;
+  silenced: boolean;
+}
+
+export interface AlertList {
+  alerts: Alert[];
+}
+
 export interface DeleteReplicationControllerSpec {
   deleteServices: boolean;
 }